@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+)
+
+// limitListener wraps a net.Listener to cap the number of simultaneously open connections
+// Unlike netutil.LimitListener, which blocks in Accept until a slot frees up, connections
+// beyond the limit are accepted and closed immediately so a flood cannot queue behind them.
+type limitListener struct {
+	net.Listener
+	active atomic.Int64
+	max    int64
+}
+
+// newLimitListener returns a listener allowing at most maxConns open connections
+func newLimitListener(l net.Listener, maxConns int) net.Listener {
+	return &limitListener{Listener: l, max: int64(maxConns)}
+}
+
+// Accept waits for the next connection within the limit, closing any that exceed it
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if l.active.Add(1) > l.max {
+			l.active.Add(-1)
+			logger.Debug("Rejected connection from %s: MAX_HTTP_CONNECTIONS (%d) reached", conn.RemoteAddr(), l.max)
+			conn.Close()
+			continue
+		}
+
+		return &limitListenerConn{Conn: conn, release: func() { l.active.Add(-1) }}, nil
+	}
+}
+
+// limitListenerConn releases its slot in the limitListener when closed
+type limitListenerConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+// Close closes the connection and frees its slot; repeated calls release only once
+func (c *limitListenerConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
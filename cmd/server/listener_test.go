@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// acceptOne accepts a connection from l in the background
+func acceptOne(l net.Listener) <-chan net.Conn {
+	ch := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			close(ch)
+			return
+		}
+		ch <- conn
+	}()
+	return ch
+}
+
+// expectClosedByServer asserts the server side closed conn without sending anything
+func expectClosedByServer(t *testing.T, conn net.Conn) {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("Failed to set deadline: %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the excess connection to be closed, got: %v", err)
+	}
+}
+
+func TestLimitListener_RejectsExcessConnections(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	l := newLimitListener(inner, 1)
+	defer l.Close()
+
+	first, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer first.Close()
+	accepted := <-acceptOne(l)
+	if accepted == nil {
+		t.Fatal("Expected the first connection to be accepted")
+	}
+
+	// The second connection exceeds the limit and is closed while Accept keeps waiting
+	pending := acceptOne(l)
+	second, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer second.Close()
+	expectClosedByServer(t, second)
+
+	select {
+	case conn := <-pending:
+		t.Fatalf("Expected no connection to be returned while at the limit, got %v", conn)
+	default:
+	}
+
+	// Closing the first connection frees its slot, even if closed twice
+	accepted.Close()
+	accepted.Close()
+
+	third, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer third.Close()
+
+	select {
+	case conn := <-pending:
+		if conn == nil {
+			t.Fatal("Expected the third connection to be accepted")
+		}
+		conn.Close()
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the third connection to be accepted")
+	}
+
+	if active := l.(*limitListener).active.Load(); active != 0 {
+		t.Errorf("Expected no active connections after closing, got %d", active)
+	}
+}
@@ -3,17 +3,25 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 
+	"github.com/bancey/document-smbrelay-service/internal/config"
 	"github.com/bancey/document-smbrelay-service/internal/handlers"
 	"github.com/bancey/document-smbrelay-service/internal/logger"
+	"github.com/bancey/document-smbrelay-service/internal/middleware"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
 	"github.com/bancey/document-smbrelay-service/internal/telemetry"
 )
 
@@ -39,26 +47,87 @@ func main() {
 		}
 	}()
 
+	// Fail fast on an SMB configuration file that cannot be used
+	if err := config.CheckConfigFile(); err != nil {
+		logger.Error("Invalid SMB configuration file: %v", err)
+		os.Exit(1)
+	}
+
+	// Load HTTP service configuration
+	serverConfig := config.LoadServerConfig()
+
+	// Without trusted proxies any client could send X-Forwarded-Proto: https and bypass REQUIRE_HTTPS
+	if serverConfig.RequireHTTPS && len(serverConfig.TrustedProxies) == 0 {
+		logger.Error("REQUIRE_HTTPS is enabled without TRUSTED_PROXIES: set the proxies whose X-Forwarded-* headers to trust")
+		os.Exit(1)
+	}
+
+	// Surface a misconfiguration now rather than on the first request, before the port is bound
+	if serverConfig.ValidateOnStart != "" {
+		if err := validateStartup(serverConfig.ValidateOnStart); err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+	} else if _, err := smb.CheckSmbClientBinary(); err != nil {
+		// Every SMB operation runs smbclient, so say so now rather than on each failed request
+		logger.Warn("%v: SMB operations will fail until smbclient is installed", err)
+	}
+
+	// Remove staged upload files orphaned by crashes
+	janitorCtx, stopJanitor := context.WithCancel(ctx)
+	defer stopJanitor()
+	handlers.StartTempFileJanitor(janitorCtx, serverConfig.TempFileMaxAge)
+
 	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		AppName:               "Document SMB Relay Service",
-		DisableStartupMessage: false,
-		ReadBufferSize:        16 * 1024, // 16KB - increased from default 4KB to handle larger headers
-		// (e.g., OpenTelemetry trace context, large cookies, auth tokens)
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			logger.Error("Request error: %v", err)
-			return c.Status(code).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		},
-	})
+	app := fiber.New(fiberConfig(serverConfig))
 
 	// Middleware
-	app.Use(recover.New())
+	// Serve /list/ as /list before anything matches exact paths; share paths under WebDAV and
+	// /objects keep their trailing slash
+	app.Use(middleware.TrimTrailingSlash(handlers.WebDAVPrefix+"/", "/objects/"))
+
+	// Compress responses if enabled, leaving downloads of already compressed files as they are.
+	// It wraps everything below so error bodies are tagged with the request ID before compression.
+	if serverConfig.CompressionEnabled {
+		app.Use(middleware.Compress(handlers.DownloadContentType))
+		logger.Info("Response compression enabled")
+	}
+
+	// Tag every request with an ID next, so logs, recovered panics and error responses all carry it
+	app.Use(middleware.RequestID())
+
+	if serverConfig.DebugPanics {
+		logger.Warn("DEBUG_PANICS is enabled: stack traces of recovered panics will be logged")
+	}
+	app.Use(recover.New(recoverConfig(serverConfig.DebugPanics)))
+
+	// Turn new requests away with 503 once shutdown begins
+	gate := &middleware.ShutdownGate{}
+	app.Use(gate.Handler())
+
+	// Log each request through the project logger if enabled
+	if serverConfig.AccessLog {
+		app.Use(middleware.AccessLog(serverConfig.AccessLogExcludePaths...))
+		logger.Info("Access logging enabled")
+	}
+
+	// Enforce HTTPS behind a TLS-terminating proxy if enabled
+	if serverConfig.RequireHTTPS {
+		app.Use(middleware.RequireHTTPS(probePaths...))
+		logger.Info("HTTPS enforcement enabled")
+	}
+
+	// Limit requests per client IP so bursts don't spawn more smbclient processes than the host can run
+	if serverConfig.RateLimit > 0 {
+		app.Use(middleware.RateLimit(serverConfig.RateLimit, serverConfig.RateBurst, probePaths...))
+		logger.Info("Rate limiting enabled: %g requests per second per client", serverConfig.RateLimit)
+	}
+
+	// Require the service API key on everything but liveness probes and the API docs if configured
+	if serverConfig.ServiceAPIKey != "" {
+		app.Use(middleware.RequireAPIKey(serverConfig.ServiceAPIKey, apiKeyExemptPaths...))
+		logger.Info("API key authentication enabled")
+	}
 
 	// Add OpenTelemetry middleware if enabled
 	if telemetryConfig.Enabled {
@@ -66,24 +135,40 @@ func main() {
 		logger.Info("OpenTelemetry middleware enabled")
 	}
 
+	// Count requests for Prometheus and serve /metrics if enabled
+	if serverConfig.PrometheusEnabled {
+		app.Use(telemetry.PrometheusMiddleware())
+		app.Get("/metrics", telemetry.PrometheusHandler())
+		logger.Info("Prometheus metrics enabled at /metrics")
+	}
+
+	// Streamed bodies skip Fiber's BodyLimit, so apply it to every request that is not a streamed upload
+	if serverConfig.StreamUploads {
+		app.Use(middleware.LimitStreamedBody(app.Config().BodyLimit, handlers.StreamsRequestBody))
+	}
+
 	// Routes
-	app.Get("/health", handlers.HealthHandler)
-	app.Get("/list", handlers.ListHandler)
-	app.Post("/upload", handlers.UploadHandler)
-	app.Delete("/delete", handlers.DeleteHandler)
-	app.Get("/openapi.json", handlers.GetOpenAPISpec)
-	app.Get("/docs", handlers.ServeSwaggerUI)
+	app.Get("/", rootHandler(serverConfig.RootRedirect))
+	registerRoutes(app, serverConfig)
+
+	// Serve the share over WebDAV if enabled
+	if serverConfig.WebDAVEnabled {
+		for _, method := range handlers.WebDAVMethods {
+			app.Add(method, handlers.WebDAVPrefix, handlers.WebDAVHandler)
+			app.Add(method, handlers.WebDAVPrefix+"/*", handlers.WebDAVHandler)
+		}
+		logger.Info("WebDAV interface enabled at %s", handlers.WebDAVPrefix)
+	}
 
 	// Handle graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
+	shutdownDone := make(chan struct{})
 	go func() {
 		<-c
-		logger.Info("Shutting down server...")
-		if err := app.Shutdown(); err != nil {
-			logger.Error("Error during shutdown: %v", err)
-		}
+		shutdown(app, gate, serverConfig.ShutdownTimeout)
+		close(shutdownDone)
 	}()
 
 	// Start server
@@ -93,8 +178,201 @@ func main() {
 	}
 
 	logger.Info("Server starting on port %s", port)
-	if err := app.Listen(fmt.Sprintf("0.0.0.0:%s", port)); err != nil {
+	if err := listen(app, fmt.Sprintf("0.0.0.0:%s", port), serverConfig.MaxHTTPConnections); err != nil {
 		logger.Error("Server error: %v", err)
 		os.Exit(1)
 	}
+
+	// The listener closes as soon as shutdown begins; wait for in-flight work to drain before exiting
+	<-shutdownDone
+}
+
+// shutdown stops the server gracefully: new requests get 503 while in-flight requests and
+// SMB operations, including async uploads, get up to timeout to finish
+func shutdown(app *fiber.App, gate *middleware.ShutdownGate, timeout time.Duration) {
+	logger.Info("Shutting down server, waiting up to %s for %d in-flight SMB operations",
+		timeout, smb.ActiveOperations())
+	gate.Close()
+	smb.BeginDrain()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		logger.Error("Error during shutdown: %v", err)
+	}
+
+	drained, err := smb.WaitForOperations(ctx)
+	if err != nil {
+		logger.Warn("Shutdown timed out after draining %d SMB operations: %v", drained, err)
+		return
+	}
+	logger.Info("Drained %d SMB operations", drained)
+}
+
+// probePaths are the liveness and readiness endpoints, exempt from HTTPS enforcement and rate limiting
+var probePaths = []string{"/livez", "/health"}
+
+// apiKeyExemptPaths are reachable without SERVICE_API_KEY so probes and the Swagger UI keep working
+var apiKeyExemptPaths = append(
+	[]string{"/livez", "/health", "/docs", "/openapi.json"},
+	handlers.SwaggerUIAssetPaths()...,
+)
+
+// validateStartup checks the SMB configuration and the smbclient binary, logging every problem found
+// In fail mode any problem is returned as an error so the service exits instead of starting.
+func validateStartup(mode string) error {
+	problems := startupProblems()
+	if len(problems) == 0 {
+		logger.Info("Startup validation passed")
+		return nil
+	}
+
+	for _, problem := range problems {
+		logger.Error("Startup validation: %s", problem)
+	}
+	if mode == config.ValidateOnStartFail {
+		return fmt.Errorf("startup validation failed with %d problem(s)", len(problems))
+	}
+	logger.Warn("Starting despite %d startup validation problem(s)", len(problems))
+	return nil
+}
+
+// startupProblems lists what would make SMB requests fail: missing or invalid configuration
+// and an unusable smbclient binary
+func startupProblems() []string {
+	var problems []string
+	_, missing := config.LoadFromEnv()
+	for _, name := range missing {
+		problems = append(problems, "missing or invalid "+name)
+	}
+	if _, err := smb.CheckSmbClientBinary(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// listen serves the app on addr, capping simultaneous connections when maxConns is positive
+func listen(app *fiber.App, addr string, maxConns int) error {
+	if maxConns <= 0 {
+		return app.Listen(addr)
+	}
+
+	ln, err := net.Listen(app.Config().Network, addr)
+	if err != nil {
+		return err
+	}
+	logger.Info("Limiting HTTP connections to %d", maxConns)
+	return app.Listener(newLimitListener(ln, maxConns))
+}
+
+// fiberConfig builds the Fiber application configuration from the HTTP service configuration
+func fiberConfig(serverConfig *config.ServerConfig) fiber.Config {
+	return fiber.Config{
+		AppName:               serverConfig.AppName,
+		DisableStartupMessage: serverConfig.DisableStartupMessage,
+		ReadBufferSize:        16 * 1024, // 16KB - increased from default 4KB to handle larger headers
+		// (e.g., OpenTelemetry trace context, large cookies, auth tokens)
+		// Room for a file of SMB_MAX_UPLOAD_BYTES; unset keeps Fiber's default
+		BodyLimit:    serverConfig.BodyLimit(),
+		ErrorHandler: errorHandler,
+		// /list/ matches the /list route; TrimTrailingSlash makes middleware see the same path
+		StrictRouting: false,
+		// Only honor X-Forwarded-* headers from trusted proxies when a list is configured
+		EnableTrustedProxyCheck: len(serverConfig.TrustedProxies) > 0,
+		TrustedProxies:          serverConfig.TrustedProxies,
+		// Streamed uploads read the multipart body as it arrives instead of buffering or spooling it
+		StreamRequestBody:            serverConfig.StreamUploads,
+		DisablePreParseMultipartForm: serverConfig.StreamUploads,
+		RequestMethods:               requestMethods(serverConfig),
+	}
+}
+
+// requestMethods returns the HTTP methods the server accepts, adding the WebDAV methods when enabled
+// nil keeps Fiber's default methods.
+func requestMethods(serverConfig *config.ServerConfig) []string {
+	if !serverConfig.WebDAVEnabled {
+		return nil
+	}
+	return append(slices.Clone(fiber.DefaultMethods), handlers.WebDAVExtensionMethods...)
+}
+
+// incidentIDKey is the Fiber locals key holding the incident ID of a recovered panic
+const incidentIDKey = "incident_id"
+
+// registerRoutes adds the routes documented in the OpenAPI spec to app
+// Routes without a handler are registered by main when their feature is enabled.
+func registerRoutes(app *fiber.App, serverConfig *config.ServerConfig) {
+	for _, route := range handlers.Routes() {
+		if route.Handler == nil {
+			continue
+		}
+		chain := []fiber.Handler{route.Handler}
+		if route.Admin {
+			chain = append([]fiber.Handler{middleware.RequireAdminToken(serverConfig.AdminToken)}, chain...)
+		}
+		// Get also answers HEAD requests
+		if route.Method == fiber.MethodGet {
+			app.Get(route.Path, chain...)
+		} else {
+			app.Add(route.Method, route.Path, chain...)
+		}
+	}
+}
+
+// rootHandler returns the handler for GET /: a redirect to target if set, otherwise the endpoint index
+func rootHandler(target string) fiber.Handler {
+	if target == "" {
+		return handlers.IndexHandler
+	}
+	return func(c *fiber.Ctx) error {
+		return c.Redirect(target)
+	}
+}
+
+// errorHandler converts errors returned by handlers and middleware into JSON responses
+// Recovered panics carry an incident ID when DEBUG_PANICS is enabled so the response
+// can be correlated with the logged stack trace.
+func errorHandler(c *fiber.Ctx, err error) error {
+	code := fiber.StatusInternalServerError
+	if e, ok := err.(*fiber.Error); ok {
+		code = e.Code
+	}
+	logger.ErrorContext(c.UserContext(), "Request error: %v", err)
+
+	body := fiber.Map{
+		"error": err.Error(),
+	}
+	if incidentID, ok := c.Locals(incidentIDKey).(string); ok {
+		body["incident_id"] = incidentID
+	}
+	return c.Status(code).JSON(body)
+}
+
+// recoverConfig builds the recover middleware configuration
+// With debugPanics enabled the full stack trace is logged under a generated incident ID;
+// stack traces are never included in responses.
+func recoverConfig(debugPanics bool) recover.Config {
+	if !debugPanics {
+		return recover.ConfigDefault
+	}
+
+	return recover.Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c *fiber.Ctx, e interface{}) {
+			incidentID := newIncidentID()
+			c.Locals(incidentIDKey, incidentID)
+			logger.ErrorContext(c.UserContext(), "Recovered panic (incident %s) on %s %s: %v\n%s",
+				incidentID, c.Method(), c.Path(), e, debug.Stack())
+		},
+	}
+}
+
+// newIncidentID returns a short random identifier for correlating panics with logs
+func newIncidentID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
 }
@@ -2,19 +2,33 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
+	"net"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 
+	"github.com/bancey/document-smbrelay-service/internal/config"
 	"github.com/bancey/document-smbrelay-service/internal/handlers"
+	"github.com/bancey/document-smbrelay-service/internal/middleware"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
 )
 
 // setupTestApp creates a Fiber app configured for testing
@@ -22,24 +36,12 @@ func setupTestApp() *fiber.App {
 	app := fiber.New(fiber.Config{
 		AppName:               "Document SMB Relay Service",
 		DisableStartupMessage: true,
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		},
+		ErrorHandler:          errorHandler,
 	})
 
 	app.Use(recover.New())
-	app.Get("/health", handlers.HealthHandler)
-	app.Get("/list", handlers.ListHandler)
-	app.Post("/upload", handlers.UploadHandler)
-	app.Delete("/delete", handlers.DeleteHandler)
-	app.Get("/openapi.json", handlers.GetOpenAPISpec)
-	app.Get("/docs", handlers.ServeSwaggerUI)
+	app.Get("/", rootHandler(""))
+	registerRoutes(app, config.LoadServerConfig())
 
 	return app
 }
@@ -212,6 +214,79 @@ func TestIntegration_RecoverMiddleware(t *testing.T) {
 	}
 }
 
+// setupPanicApp creates an app with a route that panics, using the given recover mode
+func setupPanicApp(debugPanics bool) *fiber.App {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+		ErrorHandler:          errorHandler,
+	})
+	app.Use(recover.New(recoverConfig(debugPanics)))
+	app.Get("/panic", func(_ *fiber.Ctx) error {
+		panic("something went badly wrong")
+	})
+	return app
+}
+
+func TestIntegration_DebugPanicsLogsStackTrace(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	app := setupPanicApp(true)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/panic", nil))
+	if err != nil {
+		t.Fatalf("Failed to test panic route: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", fiber.StatusInternalServerError, resp.StatusCode)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	incidentID, _ := body["incident_id"].(string)
+	if incidentID == "" {
+		t.Fatalf("Expected incident_id in response, got: %v", body)
+	}
+
+	logged := buf.String()
+	if !strings.Contains(logged, "incident "+incidentID) {
+		t.Errorf("Expected log to reference incident %s, got: %s", incidentID, logged)
+	}
+	if !strings.Contains(logged, "goroutine") || !strings.Contains(logged, "runtime/debug.Stack") {
+		t.Errorf("Expected stack trace in log, got: %s", logged)
+	}
+	if strings.Contains(fmt.Sprint(body), "goroutine") {
+		t.Errorf("Stack trace must not be included in the response: %v", body)
+	}
+}
+
+func TestIntegration_PanicWithoutDebug(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	app := setupPanicApp(false)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/panic", nil))
+	if err != nil {
+		t.Fatalf("Failed to test panic route: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", fiber.StatusInternalServerError, resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if strings.Contains(string(body), "incident_id") {
+		t.Errorf("Expected no incident_id without DEBUG_PANICS, got: %s", string(body))
+	}
+	if strings.Contains(buf.String(), "goroutine") {
+		t.Errorf("Expected no stack trace in log without DEBUG_PANICS, got: %s", buf.String())
+	}
+}
+
 func TestIntegration_MultipleRequests(t *testing.T) {
 	// Set up environment
 	os.Clearenv()
@@ -477,10 +552,27 @@ func TestIntegration_OpenAPIIncludesAllEndpoints(t *testing.T) {
 
 	// Verify all endpoints are documented
 	requiredEndpoints := []string{
+		"/",
+		"/livez",
 		"/health",
 		"/list",
+		"/list/batch",
 		"/upload",
+		"/download",
+		"/download-zip",
+		"/diskusage",
 		"/delete",
+		"/mkdir",
+		"/move",
+		"/jobs/{id}",
+		"/uploads",
+		"/uploads/{id}",
+		"/objects/{key}",
+		"/batch",
+		"/stale",
+		"/diagnostics",
+		"/version",
+		"/metrics",
 	}
 
 	for _, endpoint := range requiredEndpoints {
@@ -489,3 +581,400 @@ func TestIntegration_OpenAPIIncludesAllEndpoints(t *testing.T) {
 		}
 	}
 }
+
+func TestOpenAPISpec_DocumentsEveryRoute(t *testing.T) {
+	app := setupTestApp()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/openapi.json", nil))
+	if err != nil {
+		t.Fatalf("Failed to test openapi endpoint: %v", err)
+	}
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Responses map[string]struct {
+				Content map[string]json.RawMessage `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatalf("Failed to decode spec: %v", err)
+	}
+
+	// Fiber's :name parameters are {name} in the spec, and a wildcard is a named path parameter
+	routeParam := regexp.MustCompile(`:(\w+)`)
+	for _, route := range app.GetRoutes(true) {
+		pattern := regexp.QuoteMeta(route.Path)
+		pattern = routeParam.ReplaceAllString(pattern, `\{$1\}`)
+		pattern = strings.ReplaceAll(pattern, `\*`, `\{\w+\}`)
+		matcher := regexp.MustCompile("^" + pattern + "$")
+
+		documented := false
+		for path, ops := range spec.Paths {
+			if !matcher.MatchString(path) {
+				continue
+			}
+			_, documented = ops[strings.ToLower(route.Method)]
+			// Fiber adds a HEAD route for every GET route
+			if _, hasGet := ops["get"]; route.Method == fiber.MethodHead && hasGet {
+				documented = true
+			}
+		}
+		if !documented {
+			t.Errorf("Expected %s %s to be documented in the OpenAPI spec", route.Method, route.Path)
+		}
+	}
+
+	// Error responses describe the JSON error body
+	list := spec.Paths["/list"]["get"]
+	for _, status := range []string{"400", "403", "404", "500", "504"} {
+		if _, ok := list.Responses[status].Content["application/json"]; !ok {
+			t.Errorf("Expected GET /list to document a JSON error body for %s", status)
+		}
+	}
+}
+
+func TestRootHandler(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", rootHandler(""))
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("Failed to test root endpoint: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(body), `"endpoints"`) {
+		t.Errorf("Expected an endpoint index, got %d: %s", resp.StatusCode, string(body))
+	}
+
+	app = fiber.New()
+	app.Get("/", rootHandler("/docs"))
+	resp, err = app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("Failed to test root endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusFound || resp.Header.Get("Location") != "/docs" {
+		t.Errorf("Expected a redirect to /docs, got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestFiberConfig_AppliesServerConfig(t *testing.T) {
+	os.Clearenv()
+	cfg := fiberConfig(config.LoadServerConfig())
+	if cfg.AppName != "Document SMB Relay Service" || cfg.DisableStartupMessage {
+		t.Errorf("Expected default app name with startup message, got %q, %v", cfg.AppName, cfg.DisableStartupMessage)
+	}
+
+	os.Setenv("APP_NAME", "Acme Document Relay")
+	os.Setenv("DISABLE_STARTUP_MESSAGE", "true")
+	defer os.Clearenv()
+
+	cfg = fiberConfig(config.LoadServerConfig())
+	if cfg.AppName != "Acme Document Relay" {
+		t.Errorf("Expected AppName %q, got %q", "Acme Document Relay", cfg.AppName)
+	}
+	if !cfg.DisableStartupMessage {
+		t.Error("Expected DisableStartupMessage to be true")
+	}
+	if fiber.New(cfg).Config().AppName != "Acme Document Relay" {
+		t.Error("Expected the app name to be applied to the Fiber app")
+	}
+	if cfg.StreamRequestBody {
+		t.Error("Expected request bodies to be buffered unless uploads are streamed")
+	}
+
+	os.Setenv("SMB_STREAM_UPLOADS", "true")
+	cfg = fiberConfig(config.LoadServerConfig())
+	if !cfg.StreamRequestBody || !cfg.DisablePreParseMultipartForm {
+		t.Error("Expected streamed uploads to enable request body streaming without multipart pre-parsing")
+	}
+	if cfg.BodyLimit != 0 {
+		t.Errorf("Expected Fiber's default body limit without SMB_MAX_UPLOAD_BYTES, got %d", cfg.BodyLimit)
+	}
+
+	os.Setenv("SMB_MAX_UPLOAD_BYTES", "1048576")
+	if cfg = fiberConfig(config.LoadServerConfig()); cfg.BodyLimit <= 1048576 {
+		t.Errorf("Expected the body limit to fit a file of SMB_MAX_UPLOAD_BYTES, got %d", cfg.BodyLimit)
+	}
+	if cfg.RequestMethods != nil {
+		t.Errorf("Expected Fiber's default methods without WEBDAV_ENABLED, got %v", cfg.RequestMethods)
+	}
+
+	os.Setenv("WEBDAV_ENABLED", "true")
+	cfg = fiberConfig(config.LoadServerConfig())
+	if !slices.Contains(cfg.RequestMethods, "PROPFIND") || !slices.Contains(cfg.RequestMethods, fiber.MethodGet) {
+		t.Errorf("Expected the WebDAV methods alongside the defaults, got %v", cfg.RequestMethods)
+	}
+}
+
+func TestIntegration_APIKeyExemptPaths(t *testing.T) {
+	os.Clearenv()
+
+	app := fiber.New()
+	app.Use(middleware.RequireAPIKey("k3y", apiKeyExemptPaths...))
+	app.Get("/livez", handlers.LivezHandler)
+	app.Get("/health", handlers.HealthHandler)
+	app.Get("/list", handlers.ListHandler)
+	app.Get("/openapi.json", handlers.GetOpenAPISpec)
+	app.Get("/docs", handlers.ServeSwaggerUI)
+	app.Get("/docs/swagger-ui-dist/:file", handlers.SwaggerUIAssetHandler)
+
+	for _, path := range []string{"/livez", "/health", "/docs", "/openapi.json", "/docs/swagger-ui-dist/swagger-ui.css"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil), 5000)
+		if err != nil {
+			t.Fatalf("Failed to test %s: %v", path, err)
+		}
+		if resp.StatusCode == fiber.StatusUnauthorized {
+			t.Errorf("Expected %s to be reachable without an API key", path)
+		}
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/list", nil))
+	if err != nil {
+		t.Fatalf("Failed to test /list: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("Expected /list to require the API key, got status %d", resp.StatusCode)
+	}
+}
+
+func TestIntegration_TrailingSlash(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SMB_SERVER_NAME", "testserver")
+	os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+	os.Setenv("SMB_SHARE_NAME", "testshare")
+	os.Setenv("SMB_USERNAME", "testuser")
+	os.Setenv("SMB_PASSWORD", "testpass")
+
+	origExec := smb.SetExecutor(smb.SetupSuccessfulMock())
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true, ErrorHandler: errorHandler})
+	app.Use(middleware.TrimTrailingSlash(handlers.WebDAVPrefix+"/", "/objects/"))
+	app.Use(middleware.RequireAPIKey("k3y", apiKeyExemptPaths...))
+	registerRoutes(app, config.LoadServerConfig())
+
+	for _, route := range []struct{ method, path, query string }{
+		{"GET", "/livez", ""},
+		{"GET", "/health", ""},
+		{"GET", "/list", ""},
+		{"GET", "/download", "?path=report.pdf"},
+		{"POST", "/upload", ""},
+		{"DELETE", "/delete", "?path=report.pdf"},
+		{"GET", "/openapi.json", ""},
+		{"GET", "/docs", ""},
+	} {
+		var statuses [2]int
+		for i, path := range []string{route.path, route.path + "/"} {
+			req := httptest.NewRequest(route.method, path+route.query, nil)
+			req.Header.Set("X-API-Key", "k3y")
+			resp, err := app.Test(req, 5000)
+			if err != nil {
+				t.Fatalf("Failed to test %s %s: %v", route.method, path, err)
+			}
+			// Fiber answers a path without a route with "Cannot <method> <path>"
+			if body, _ := io.ReadAll(resp.Body); strings.HasPrefix(string(body), "Cannot ") {
+				t.Errorf("Expected %s %s to be routed, got %s", route.method, path, string(body))
+			}
+			statuses[i] = resp.StatusCode
+		}
+		if statuses[0] != statuses[1] {
+			t.Errorf("Expected %s %s to answer the same with a trailing slash, got %d and %d",
+				route.method, route.path, statuses[0], statuses[1])
+		}
+	}
+
+	// Exemptions from the API key also apply with a trailing slash
+	resp, err := app.Test(httptest.NewRequest("GET", "/health/", nil), 5000)
+	if err != nil {
+		t.Fatalf("Failed to test /health/: %v", err)
+	}
+	if resp.StatusCode == fiber.StatusUnauthorized {
+		t.Error("Expected /health/ to be reachable without an API key")
+	}
+}
+
+func TestIntegration_CompressedListing(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SMB_SERVER_NAME", "testserver")
+	os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+	os.Setenv("SMB_SHARE_NAME", "testshare")
+	os.Setenv("SMB_USERNAME", "testuser")
+	os.Setenv("SMB_PASSWORD", "testpass")
+
+	var listing strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&listing, "  report-%03d.pdf                     A     1024  Mon Jan  1 12:00:00 2024\n", i)
+	}
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return listing.String(), nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true, ErrorHandler: errorHandler})
+	app.Use(middleware.Compress(handlers.DownloadContentType))
+	registerRoutes(app, config.LoadServerConfig())
+
+	req := httptest.NewRequest("GET", "/list", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("Failed to test /list: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzip-compressed listing, got status %d with Content-Encoding %q",
+			resp.StatusCode, resp.Header.Get("Content-Encoding"))
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip body: %v", err)
+	}
+	var body struct {
+		Files []smb.FileInfo `json:"files"`
+	}
+	if err := json.NewDecoder(zr).Decode(&body); err != nil || len(body.Files) != 200 {
+		t.Errorf("Expected the 200 files listed once decompressed, got %d: %v", len(body.Files), err)
+	}
+}
+
+func TestShutdown_DrainsInFlightOperations(t *testing.T) {
+	gate := &middleware.ShutdownGate{}
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(gate.Handler())
+	app.Get("/list", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	listening := make(chan struct{})
+	app.Hooks().OnListen(func(fiber.ListenData) error {
+		close(listening)
+		return nil
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- app.Listener(ln) }()
+	<-listening
+
+	// An SMB operation, such as an async upload, still running when the signal arrives
+	var completed atomic.Bool
+	endOperation := smb.BeginOperation()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		completed.Store(true)
+		endOperation()
+	}()
+
+	shutdown(app, gate, 5*time.Second)
+
+	if !completed.Load() {
+		t.Error("Expected the in-flight SMB operation to complete before shutdown returned")
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected the server to stop cleanly, got: %v", err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/list", nil))
+	if err != nil {
+		t.Fatalf("Failed to test /list: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("Expected new requests to get status %d after shutdown, got %d",
+			fiber.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
+
+func TestValidateStartup(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+	testBinary, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Cannot locate the test binary: %v", err)
+	}
+	os.Setenv("SMBCLIENT_PATH", testBinary)
+
+	problems := startupProblems()
+	for _, name := range []string{"SMB_SERVER_NAME", "SMB_SERVER_IP", "SMB_SHARE_NAME"} {
+		if !slices.Contains(problems, "missing or invalid "+name) {
+			t.Errorf("Expected %s to be reported, got %v", name, problems)
+		}
+	}
+	if err := validateStartup(config.ValidateOnStartFail); err == nil {
+		t.Error("Expected fail mode to return an error for missing configuration")
+	}
+	if err := validateStartup(config.ValidateOnStartWarn); err != nil {
+		t.Errorf("Expected warn mode to start despite missing configuration, got %v", err)
+	}
+
+	os.Setenv("SMB_SERVER_NAME", "testserver")
+	os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+	os.Setenv("SMB_SHARE_NAME", "testshare")
+	os.Setenv("SMB_USERNAME", "testuser")
+	os.Setenv("SMB_PASSWORD", "testpass")
+	if problems := startupProblems(); len(problems) != 0 {
+		t.Errorf("Expected no problems with complete configuration, got %v", problems)
+	}
+	if err := validateStartup(config.ValidateOnStartFail); err != nil {
+		t.Errorf("Expected complete configuration to pass, got %v", err)
+	}
+
+	os.Setenv("SMBCLIENT_PATH", t.TempDir())
+	if err := validateStartup(config.ValidateOnStartFail); err == nil {
+		t.Error("Expected an unusable SMBCLIENT_PATH to fail validation")
+	}
+}
+
+// TestMain_ValidateOnStartExits runs the service in a subprocess with no SMB configuration
+// and expects it to exit with status 1 instead of binding its port.
+func TestMain_ValidateOnStartExits(t *testing.T) {
+	if os.Getenv("SMBRELAY_TEST_RUN_MAIN") == "1" {
+		main()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestMain_ValidateOnStartExits$")
+	cmd.Env = []string{"SMBRELAY_TEST_RUN_MAIN=1", "CONFIG_VALIDATE_ON_START=true", "PORT=0"}
+	output, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("Expected exit status 1, got %v:\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "missing or invalid SMB_SERVER_NAME") {
+		t.Errorf("Expected the missing variables to be logged, got:\n%s", output)
+	}
+	if strings.Contains(string(output), "Server starting") {
+		t.Errorf("Expected the service to exit before starting the server, got:\n%s", output)
+	}
+}
+
+// TestMain_RequireHTTPSWithoutTrustedProxiesExits expects the service to refuse to start when
+// REQUIRE_HTTPS would trust X-Forwarded-Proto from any client.
+func TestMain_RequireHTTPSWithoutTrustedProxiesExits(t *testing.T) {
+	if os.Getenv("SMBRELAY_TEST_RUN_MAIN") == "1" {
+		main()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, os.Args[0], "-test.run=^TestMain_RequireHTTPSWithoutTrustedProxiesExits$")
+	cmd.Env = []string{"SMBRELAY_TEST_RUN_MAIN=1", "REQUIRE_HTTPS=true", "PORT=0"}
+	output, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("Expected exit status 1, got %v:\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "REQUIRE_HTTPS is enabled without TRUSTED_PROXIES") {
+		t.Errorf("Expected the missing TRUSTED_PROXIES to be logged, got:\n%s", output)
+	}
+	if strings.Contains(string(output), "Server starting") {
+		t.Errorf("Expected the service to exit before starting the server, got:\n%s", output)
+	}
+}
@@ -3,44 +3,120 @@ package config
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/bancey/document-smbrelay-service/internal/logger"
 )
 
 const (
 	defaultPortStr           = "445"
 	defaultPort              = 445
+	maxPort                  = 65535
 	defaultMaxRetries        = 3
 	defaultInitialRetryDelay = 1.0  // seconds
 	defaultMaxRetryDelay     = 30.0 // seconds
 	defaultRetryBackoff      = 2.0  // exponential backoff multiplier
+	defaultMaxPathDepth      = 64   // maximum number of segments in a request path
+	defaultMaxNameLength     = 255  // maximum length of a path segment (NTFS component limit)
+	defaultMaxListDepth      = 10   // maximum directory depth of a recursive listing
+	defaultCommandTimeout    = 30 * time.Second
+	defaultMaxConcurrent     = 10 // maximum number of smbclient processes running at once
+	defaultHealthWriteDir    = ".smbrelay-health"
+	defaultDedupDir          = ".smbrelay-dedup"
+	defaultHealthCacheTTL    = 10 * time.Second
+	defaultFreeSpaceCheck    = 100 * 1024 * 1024 // uploads of at least 100 MiB check the share's free space
 	trueValue                = "true"
 	oneValue                 = "1"
 	yesValue                 = "yes"
 	authProtocolNTLM         = "ntlm"
 	authProtocolNegotiate    = "negotiate"
 	authProtocolKerberos     = "kerberos"
+	authProtocolGuest        = "guest"
+)
+
+// Drive letter policies for request paths such as C:\folder\file.txt
+const (
+	// DriveLetterPolicyReject rejects request paths with a drive letter prefix
+	DriveLetterPolicyReject = "reject"
+	// DriveLetterPolicyStrip removes the drive letter prefix and converts backslashes
+	DriveLetterPolicyStrip = "strip"
+)
+
+// Filename policies for upload paths with characters Windows shares do not allow
+const (
+	// FilenamePolicyReplace replaces invalid characters and trims trailing spaces and dots
+	FilenamePolicyReplace = "replace"
+	// FilenamePolicyReject rejects upload paths with invalid characters or trailing spaces and dots
+	FilenamePolicyReject = "reject"
+)
+
+// SMB protocol dialects SMB_MIN_PROTOCOL and SMB_MAX_PROTOCOL accept, in ascending order
+const (
+	// ProtocolNT1 is the deprecated SMB1 dialect
+	ProtocolNT1 = "NT1"
+	// ProtocolSMB2 is the SMB2 family of dialects
+	ProtocolSMB2 = "SMB2"
+	// ProtocolSMB3 is the SMB3 family of dialects
+	ProtocolSMB3 = "SMB3"
 )
 
+// protocolOrder ranks the supported protocol dialects from oldest to newest
+var protocolOrder = map[string]int{ProtocolNT1: 1, ProtocolSMB2: 2, ProtocolSMB3: 3}
+
 // SMBConfig holds the SMB server configuration
 // Fields are ordered for optimal memory alignment
 type SMBConfig struct {
-	ServerName        string
-	ServerIP          string
-	ShareName         string
-	BasePath          string // Base path within the share (e.g., "apps/myapp")
-	Username          string
-	Password          string
-	Domain            string
-	AuthProtocol      string
-	Port              int
-	MaxRetries        int     // Maximum number of retry attempts for network errors (default: 3)
-	InitialRetryDelay float64 // Initial delay in seconds before first retry (default: 1.0)
-	MaxRetryDelay     float64 // Maximum delay in seconds between retries (default: 30.0)
-	RetryBackoff      float64 // Backoff multiplier for exponential backoff (default: 2.0)
-	UseNTLMv2         bool
-	LogSmbCommands    bool
+	TimestampLocation     *time.Location // Time zone listing timestamps are converted to (nil leaves them as parsed)
+	ListRetry             *RetryPolicy   // Retry settings for listings and stats (nil uses the global settings)
+	UploadRetry           *RetryPolicy   // Retry settings for uploads (nil uses the global settings)
+	ExtraArgs             []string       // Raw smbclient arguments appended before the command, unvalidated
+	AllowedPrefixes       []string       // Share paths operations are restricted to, nil for no restriction
+	CommandTimeout        time.Duration  // Maximum run time of one smbclient command, 0 for unlimited (default: 30s)
+	HealthCacheTTL        time.Duration  // How long a health check result is reused, 0 disables caching (default: 10s)
+	ServerName            string
+	ServerIP              string
+	ShareName             string
+	BasePath              string // Base path within the share (e.g., "apps/myapp")
+	HealthWriteDir        string // Directory (relative to BasePath) used for the health check write probe
+	DedupDir              string // Directory (relative to BasePath) of the deduplication index
+	DefaultUploadPath     string // Directory uploads without a remote_path go to, "" to require remote_path
+	Username              string
+	Password              string
+	Domain                string
+	AuthProtocol          string
+	KerberosKeytab        string // Keytab used to obtain a Kerberos ticket with kinit instead of an existing ticket cache
+	KerberosPrincipal     string // Principal the keytab ticket is requested for (default: Username)
+	DriveLetterPolicy     string // How request paths with a drive letter prefix are handled: reject or strip
+	FilenamePolicy        string // How upload paths with invalid characters are handled: replace, reject or "" to pass
+	MinProtocol           string // Oldest dialect smbclient may negotiate: NT1, SMB2 or SMB3 (default: unpinned)
+	MaxProtocol           string // Newest dialect smbclient may negotiate: NT1, SMB2 or SMB3 (default: unpinned)
+	Port                  int
+	MaxRetries            int     // Maximum number of retry attempts for network errors (default: 3)
+	MaxPathDepth          int     // Maximum number of segments in a request path, 0 for unlimited (default: 64)
+	MaxNameLength         int     // Maximum length of each path segment, 0 for unlimited (default: 255)
+	MaxListDepth          int     // Maximum subdirectory depth of a recursive listing (default: 10)
+	MaxConcurrent         int     // Maximum number of smbclient processes running at once, 0 for unlimited (default: 10)
+	FreeSpaceCheckBytes   int64   // Uploads of at least this size first check the share's free space, 0 disables it
+	InitialRetryDelay     float64 // Initial delay in seconds before first retry (default: 1.0)
+	MaxRetryDelay         float64 // Maximum delay in seconds between retries (default: 30.0)
+	RetryBackoff          float64 // Backoff multiplier for exponential backoff (default: 2.0)
+	UseNTLMv2             bool
+	PasswordIsNTHash      bool // Password holds an NT hash passed to smbclient with --pw-nt-hash
+	LogSmbCommands        bool
+	HealthWriteTest       bool // Upload and delete a probe file during health checks to verify the share is writable
+	HealthSingleFlight    bool // Concurrent identical health checks share one in-flight result
+	CleanupOnFailedUpload bool // Delete the partial remote file left by a failed upload of a new file
+	DisableAutoMkdir      bool // Do not create missing parent directories before uploading
+	CreateBasePath        bool // Create a missing BasePath instead of failing health checks and uploads
+	ReadOnly              bool // Refuse uploads, deletes and other writes without contacting the server
+	AllowSMB1             bool // Let smbclient negotiate the deprecated SMB1 (NT1) dialect for legacy servers
+	VerifyUpload          bool // Download each uploaded file back and compare its SHA-256 with the local copy
+	Dedup                 bool // Skip uploading a file whose content is already on the share
+	RequireSigning        bool // Refuse connections the server will not sign
+	RequireEncryption     bool // Refuse connections the server will not encrypt
+	AuthFromRequest       bool // Take the username and password from each request's Basic Authorization header
 }
 
 // parseBoolEnv parses a boolean environment variable
@@ -51,24 +127,34 @@ func parseBoolEnv(value string) bool {
 
 // getPortFromEnv gets the port from environment variable with fallback
 func getPortFromEnv() int {
-	portStr := os.Getenv("SMB_PORT")
+	portStr := getenv("SMB_PORT")
 	if portStr == "" {
 		portStr = defaultPortStr
 	}
 	port, err := strconv.Atoi(portStr)
 	if err != nil {
-		port = defaultPort
+		return defaultPort
+	}
+	if !portInRange(port) {
+		logger.Warn("Invalid SMB_PORT %d: must be between 1 and %d, using %d", port, maxPort, defaultPort)
+		return defaultPort
 	}
 	return port
 }
 
+// portInRange reports whether port is a usable TCP port number
+func portInRange(port int) bool {
+	return port >= 1 && port <= maxPort
+}
+
 // getAuthProtocol determines the authentication protocol
 func getAuthProtocol(useNTLMv2 bool) string {
-	authProtocol := strings.ToLower(os.Getenv("SMB_AUTH_PROTOCOL"))
+	authProtocol := strings.ToLower(getenv("SMB_AUTH_PROTOCOL"))
 	validProtocols := map[string]bool{
 		authProtocolNegotiate: true,
 		authProtocolNTLM:      true,
 		authProtocolKerberos:  true,
+		authProtocolGuest:     true,
 	}
 
 	if authProtocol == "" || !validProtocols[authProtocol] {
@@ -82,9 +168,20 @@ func getAuthProtocol(useNTLMv2 bool) string {
 	return authProtocol
 }
 
+// requiresCredentials reports whether an authentication protocol needs a username and password
+// Kerberos can use the system ticket cache, and guest access has no password.
+func requiresCredentials(authProtocol string) bool {
+	return authProtocol != authProtocolKerberos && authProtocol != authProtocolGuest
+}
+
+// takesPassword reports whether an authentication protocol authenticates with the password given
+func takesPassword(authProtocol string) bool {
+	return authProtocol == authProtocolNTLM || authProtocol == authProtocolNegotiate
+}
+
 // getIntEnv gets an integer from environment variable with a default value
 func getIntEnv(key string, defaultValue int) int {
-	valStr := os.Getenv(key)
+	valStr := getenv(key)
 	if valStr == "" {
 		return defaultValue
 	}
@@ -101,7 +198,7 @@ func getIntEnv(key string, defaultValue int) int {
 
 // getFloatEnv gets a float64 from environment variable with a default value
 func getFloatEnv(key string, defaultValue float64) float64 {
-	valStr := os.Getenv(key)
+	valStr := getenv(key)
 	if valStr == "" {
 		return defaultValue
 	}
@@ -116,57 +213,274 @@ func getFloatEnv(key string, defaultValue float64) float64 {
 	return val
 }
 
+// getLocationEnv loads an IANA time zone (e.g. "Europe/London") from an environment variable
+// Returns nil when the variable is unset or names an unknown zone
+func getLocationEnv(key string) *time.Location {
+	name := strings.TrimSpace(getenv(key))
+	if name == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logger.Warn("Ignoring invalid %s %q: %v", key, name, err)
+		return nil
+	}
+	return loc
+}
+
+// getProtocolEnv reads a protocol dialect from an environment variable
+// Values are case-insensitive. An unknown value is logged and reported as invalid.
+func getProtocolEnv(key string) (string, bool) {
+	raw := strings.TrimSpace(getenv(key))
+	protocol := strings.ToUpper(raw)
+	if protocol == "" {
+		return "", true
+	}
+	if _, ok := protocolOrder[protocol]; !ok {
+		logger.Error("Invalid %s %q: must be one of %s, %s, %s", key, raw, ProtocolNT1, ProtocolSMB2, ProtocolSMB3)
+		return "", false
+	}
+	return protocol, true
+}
+
+// loadProtocolRange reads SMB_MIN_PROTOCOL and SMB_MAX_PROTOCOL
+// Returns the variables that are invalid, including both when the minimum is newer than the maximum.
+func loadProtocolRange() (string, string, []string) {
+	var invalid []string
+	minProtocol, ok := getProtocolEnv("SMB_MIN_PROTOCOL")
+	if !ok {
+		invalid = append(invalid, "SMB_MIN_PROTOCOL")
+	}
+	maxProtocol, ok := getProtocolEnv("SMB_MAX_PROTOCOL")
+	if !ok {
+		invalid = append(invalid, "SMB_MAX_PROTOCOL")
+	}
+
+	if minProtocol != "" && maxProtocol != "" && protocolOrder[minProtocol] > protocolOrder[maxProtocol] {
+		logger.Error("Invalid SMB protocol range: SMB_MIN_PROTOCOL %s is newer than SMB_MAX_PROTOCOL %s",
+			minProtocol, maxProtocol)
+		invalid = append(invalid, "SMB_MIN_PROTOCOL", "SMB_MAX_PROTOCOL")
+	}
+	return minProtocol, maxProtocol, invalid
+}
+
 // LoadFromEnv loads SMB configuration from environment variables
-// Returns the config and a list of missing required variables
+// Variables that are unset are taken from the SMB_CONFIG_FILE settings, if a file is configured.
+// Returns the config and a list of missing required variables, which also names protocol pins
+// and SMB_EXTRA_ARGS with invalid values so SMB operations are refused rather than run wrongly.
 func LoadFromEnv() (*SMBConfig, []string) {
-	serverName := os.Getenv("SMB_SERVER_NAME")
-	serverIP := os.Getenv("SMB_SERVER_IP")
-	shareName := os.Getenv("SMB_SHARE_NAME")
-	basePath := os.Getenv("SMB_BASE_PATH")
-	username := os.Getenv("SMB_USERNAME")
-	password := os.Getenv("SMB_PASSWORD")
-	domain := os.Getenv("SMB_DOMAIN")
+	if err := CheckConfigFile(); err != nil {
+		logger.Error("Ignoring SMB_CONFIG_FILE: %v", err)
+	}
+
+	serverName := getenv("SMB_SERVER_NAME")
+	serverIP := getenv("SMB_SERVER_IP")
+	shareName := getenv("SMB_SHARE_NAME")
+	basePath := getenv("SMB_BASE_PATH")
+	username := getenv("SMB_USERNAME")
+	password := getenv("SMB_PASSWORD")
+	passwordIsNTHash := parseBoolEnv(getenv("SMB_PASSWORD_IS_NT_HASH"))
+
+	// Legacy servers that only speak SMB1 need the protocol floor lowered explicitly
+	allowSMB1 := parseBoolEnv(getenv("SMB_ALLOW_SMB1"))
+
+	// Compliance requirements: never fall back to unsigned or unencrypted connections
+	requireSigning := parseBoolEnv(getenv("SMB_REQUIRE_SIGNING"))
+	requireEncryption := parseBoolEnv(getenv("SMB_REQUIRE_ENCRYPTION"))
+
+	// Protocol dialect pins for servers or policies that require a particular SMB version
+	minProtocol, maxProtocol, invalidProtocols := loadProtocolRange()
+	domain := getenv("SMB_DOMAIN")
 
 	port := getPortFromEnv()
 
-	useNTLMv2Str := strings.ToLower(os.Getenv("SMB_USE_NTLM_V2"))
+	useNTLMv2Str := strings.ToLower(getenv("SMB_USE_NTLM_V2"))
 	if useNTLMv2Str == "" {
 		useNTLMv2Str = trueValue
 	}
 	useNTLMv2 := parseBoolEnv(useNTLMv2Str)
 
 	// Log SMB commands for debugging (support both env var names for user convenience)
-	logSmbCommandsStr := os.Getenv("LOG_SMB_COMMANDS")
+	logSmbCommandsStr := getenv("LOG_SMB_COMMANDS")
 	if logSmbCommandsStr == "" {
-		logSmbCommandsStr = os.Getenv("SMB_LOG_COMMANDS") // Alternative name
+		logSmbCommandsStr = getenv("SMB_LOG_COMMANDS") // Alternative name
 	}
 	logSmbCommands := parseBoolEnv(logSmbCommandsStr)
 
 	// Auth protocol: negotiate, ntlm, or kerberos
 	authProtocol := getAuthProtocol(useNTLMv2)
 
+	// Headless Kerberos: obtain tickets from a keytab rather than a ticket cache populated by hand
+	kerberosKeytab := getenv("SMB_KERBEROS_KEYTAB")
+	kerberosPrincipal := getenv("SMB_KERBEROS_PRINCIPAL")
+
 	// Retry configuration
 	maxRetries := getIntEnv("SMB_MAX_RETRIES", defaultMaxRetries)
 	initialRetryDelay := getFloatEnv("SMB_RETRY_INITIAL_DELAY", defaultInitialRetryDelay)
 	maxRetryDelay := getFloatEnv("SMB_RETRY_MAX_DELAY", defaultMaxRetryDelay)
 	retryBackoff := getFloatEnv("SMB_RETRY_BACKOFF", defaultRetryBackoff)
+	globalRetry := RetryPolicy{
+		MaxRetries:   maxRetries,
+		InitialDelay: initialRetryDelay,
+		MaxDelay:     maxRetryDelay,
+		Backoff:      retryBackoff,
+	}
+
+	// Kill smbclient commands that hang, e.g. on an unresponsive server
+	commandTimeout := getDurationEnv("SMB_COMMAND_TIMEOUT", defaultCommandTimeout)
+	maxConcurrent := getIntEnv("SMB_MAX_CONCURRENT", defaultMaxConcurrent)
+
+	// Check the share has room for large uploads before transferring them
+	freeSpaceCheckBytes := int64(getIntEnv("SMB_FREE_SPACE_CHECK_BYTES", defaultFreeSpaceCheck))
+
+	// Path limits
+	maxPathDepth := getIntEnv("SMB_MAX_PATH_DEPTH", defaultMaxPathDepth)
+	maxNameLength := getIntEnv("SMB_MAX_NAME_LENGTH", defaultMaxNameLength)
+	maxListDepth := getIntEnv("SMB_MAX_LIST_DEPTH", defaultMaxListDepth)
+
+	// Health check write probe (off by default as it creates and deletes a file); HEALTH_CHECK_WRITE
+	// is an alias
+	healthWriteTestStr := getenv("HEALTH_WRITE_TEST")
+	if healthWriteTestStr == "" {
+		healthWriteTestStr = getenv("HEALTH_CHECK_WRITE")
+	}
+	healthWriteTest := parseBoolEnv(healthWriteTestStr)
+	healthWriteDir := getenv("HEALTH_WRITE_TEST_DIR")
+	if healthWriteDir == "" {
+		healthWriteDir = defaultHealthWriteDir
+	}
+
+	// Request paths with a drive letter prefix are rejected unless stripping is configured
+	driveLetterPolicy := strings.ToLower(strings.TrimSpace(getenv("SMB_DRIVE_LETTER_POLICY")))
+	if driveLetterPolicy != DriveLetterPolicyStrip {
+		if driveLetterPolicy != "" && driveLetterPolicy != DriveLetterPolicyReject {
+			logger.Warn("Invalid SMB_DRIVE_LETTER_POLICY %q, using %q", driveLetterPolicy, DriveLetterPolicyReject)
+		}
+		driveLetterPolicy = DriveLetterPolicyReject
+	}
+
+	// Sanitize upload names Windows shares would refuse (off by default)
+	filenamePolicy := strings.ToLower(strings.TrimSpace(getenv("SMB_SANITIZE_FILENAMES")))
+	if filenamePolicy != "" && filenamePolicy != FilenamePolicyReplace && filenamePolicy != FilenamePolicyReject {
+		logger.Warn("Invalid SMB_SANITIZE_FILENAMES %q, filenames are not sanitized", filenamePolicy)
+		filenamePolicy = ""
+	}
+
+	// Create missing parent directories on upload (on by default); SMB_CREATE_DIRS is an alias
+	autoMkdirStr := getenv("SMB_AUTO_MKDIR")
+	if autoMkdirStr == "" {
+		autoMkdirStr = getenv("SMB_CREATE_DIRS")
+	}
+	disableAutoMkdir := autoMkdirStr != "" && !parseBoolEnv(autoMkdirStr)
+
+	// Create a missing base path, e.g. on a fresh share, rather than report it
+	createBasePath := parseBoolEnv(getenv("SMB_CREATE_BASE_PATH"))
+
+	// Only browse and download from the share
+	readOnly := parseBoolEnv(getenv("SMB_READ_ONLY"))
+
+	// Remove truncated files left by failed uploads
+	cleanupOnFailedUpload := parseBoolEnv(getenv("SMB_CLEANUP_ON_FAILED_UPLOAD"))
+
+	// Read uploads back to detect corruption in transit
+	verifyUpload := parseBoolEnv(getenv("SMB_VERIFY_UPLOAD"))
+
+	// Skip uploads whose content is already on the share, found by SHA-256 in the index directory
+	dedup := parseBoolEnv(getenv("SMB_DEDUP"))
+	dedupDir := getenv("SMB_DEDUP_DIR")
+	if dedupDir == "" {
+		dedupDir = defaultDedupDir
+	}
+
+	// Directory of uploads that name no remote_path, for single-purpose deployments
+	defaultUploadPath := getenv("SMB_DEFAULT_UPLOAD_PATH")
+
+	// Share one in-flight health check between concurrent callers (on by default)
+	healthSingleFlightStr := getenv("HEALTH_SINGLE_FLIGHT")
+	if healthSingleFlightStr == "" {
+		healthSingleFlightStr = trueValue
+	}
+	healthSingleFlight := parseBoolEnv(healthSingleFlightStr)
+
+	// Reuse recent health check results so frequent probes don't each connect to the server
+	healthCacheTTL := getDurationEnv("HEALTH_CACHE_TTL", defaultHealthCacheTTL)
+
+	// Per-request credentials replace the configured username and password. Only protocols that
+	// check the password may use them: Kerberos would kinit the caller's name from the keytab
+	authFromRequest := parseBoolEnv(getenv("SMB_AUTH_FROM_REQUEST"))
+	invalidAuthFromRequest := authFromRequest && !takesPassword(authProtocol)
+	if invalidAuthFromRequest {
+		logger.Error("SMB_AUTH_FROM_REQUEST requires SMB_AUTH_PROTOCOL ntlm or negotiate, not %s", authProtocol)
+		authFromRequest = false
+	}
+
+	// Raw smbclient options the service does not model; ones that would replace the command are refused
+	extraArgs, extraArgsErr := ParseExtraArgs(getenv("SMB_EXTRA_ARGS"))
+	if extraArgsErr != nil {
+		logger.Error("Invalid SMB_EXTRA_ARGS: %v", extraArgsErr)
+	}
+
+	// Confine requests to these share paths, e.g. one directory per team
+	var allowedPrefixes []string
+	for _, prefix := range strings.Split(getenv("SMB_ALLOWED_PREFIXES"), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			allowedPrefixes = append(allowedPrefixes, prefix)
+		}
+	}
+
+	// Time zone for listing timestamps
+	timestampLocation := getLocationEnv("TIMESTAMP_TIMEZONE")
 
 	config := &SMBConfig{
-		ServerName:        serverName,
-		ServerIP:          serverIP,
-		ShareName:         shareName,
-		BasePath:          basePath,
-		Username:          username,
-		Password:          password,
-		Domain:            domain,
-		Port:              port,
-		UseNTLMv2:         useNTLMv2,
-		AuthProtocol:      authProtocol,
-		LogSmbCommands:    logSmbCommands,
-		MaxRetries:        maxRetries,
-		InitialRetryDelay: initialRetryDelay,
-		MaxRetryDelay:     maxRetryDelay,
-		RetryBackoff:      retryBackoff,
+		ServerName:            serverName,
+		ServerIP:              serverIP,
+		ShareName:             shareName,
+		BasePath:              basePath,
+		Username:              username,
+		Password:              password,
+		PasswordIsNTHash:      passwordIsNTHash,
+		Domain:                domain,
+		Port:                  port,
+		UseNTLMv2:             useNTLMv2,
+		AuthProtocol:          authProtocol,
+		KerberosKeytab:        kerberosKeytab,
+		KerberosPrincipal:     kerberosPrincipal,
+		LogSmbCommands:        logSmbCommands,
+		MaxRetries:            maxRetries,
+		InitialRetryDelay:     initialRetryDelay,
+		MaxRetryDelay:         maxRetryDelay,
+		RetryBackoff:          retryBackoff,
+		ListRetry:             loadRetryOverride(RetryList, globalRetry),
+		UploadRetry:           loadRetryOverride(RetryUpload, globalRetry),
+		CommandTimeout:        commandTimeout,
+		MaxConcurrent:         maxConcurrent,
+		FreeSpaceCheckBytes:   freeSpaceCheckBytes,
+		MaxPathDepth:          maxPathDepth,
+		MaxListDepth:          maxListDepth,
+		MaxNameLength:         maxNameLength,
+		TimestampLocation:     timestampLocation,
+		HealthWriteTest:       healthWriteTest,
+		HealthWriteDir:        healthWriteDir,
+		HealthSingleFlight:    healthSingleFlight,
+		HealthCacheTTL:        healthCacheTTL,
+		CleanupOnFailedUpload: cleanupOnFailedUpload,
+		DriveLetterPolicy:     driveLetterPolicy,
+		FilenamePolicy:        filenamePolicy,
+		DisableAutoMkdir:      disableAutoMkdir,
+		CreateBasePath:        createBasePath,
+		ReadOnly:              readOnly,
+		AllowSMB1:             allowSMB1,
+		MinProtocol:           minProtocol,
+		MaxProtocol:           maxProtocol,
+		RequireSigning:        requireSigning,
+		RequireEncryption:     requireEncryption,
+		VerifyUpload:          verifyUpload,
+		Dedup:                 dedup,
+		DedupDir:              dedupDir,
+		DefaultUploadPath:     defaultUploadPath,
+		AuthFromRequest:       authFromRequest,
+		ExtraArgs:             extraArgs,
+		AllowedPrefixes:       allowedPrefixes,
 	}
 
 	// Check required fields
@@ -181,8 +495,9 @@ func LoadFromEnv() (*SMBConfig, []string) {
 		missing = append(missing, "SMB_SHARE_NAME")
 	}
 
-	// Username and password are only required for non-Kerberos authentication
-	if authProtocol != authProtocolKerberos {
+	// Username and password are not required for Kerberos or guest authentication, or when each
+	// request brings its own
+	if requiresCredentials(authProtocol) && !authFromRequest {
 		if username == "" {
 			missing = append(missing, "SMB_USERNAME")
 		}
@@ -190,6 +505,13 @@ func LoadFromEnv() (*SMBConfig, []string) {
 			missing = append(missing, "SMB_PASSWORD")
 		}
 	}
+	missing = append(missing, invalidProtocols...)
+	if extraArgsErr != nil {
+		missing = append(missing, "SMB_EXTRA_ARGS")
+	}
+	if invalidAuthFromRequest {
+		missing = append(missing, "SMB_AUTH_FROM_REQUEST")
+	}
 
 	return config, missing
 }
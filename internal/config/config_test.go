@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -427,20 +430,32 @@ func TestLoadFromEnv_EmptyPort(t *testing.T) {
 	}
 }
 
-// Test negative port number
-func TestLoadFromEnv_NegativePort(t *testing.T) {
-	os.Clearenv()
-	os.Setenv("SMB_SERVER_NAME", "test")
-	os.Setenv("SMB_SERVER_IP", "127.0.0.1")
-	os.Setenv("SMB_SHARE_NAME", "share")
-	os.Setenv("SMB_USERNAME", "user")
-	os.Setenv("SMB_PASSWORD", "pass")
-	os.Setenv("SMB_PORT", "-445")
+// Test port numbers outside 1-65535 default to 445
+func TestLoadFromEnv_PortOutOfRange(t *testing.T) {
+	for _, port := range []string{"-445", "0", "65536", "100000"} {
+		t.Run(port, func(t *testing.T) {
+			os.Clearenv()
+			os.Setenv("SMB_SERVER_NAME", "test")
+			os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+			os.Setenv("SMB_SHARE_NAME", "share")
+			os.Setenv("SMB_USERNAME", "user")
+			os.Setenv("SMB_PASSWORD", "pass")
+			os.Setenv("SMB_PORT", port)
 
-	cfg, _ := LoadFromEnv()
+			cfg, missing := LoadFromEnv()
+
+			if cfg.Port != 445 {
+				t.Errorf("Expected default port 445 for out-of-range port %s, got %d", port, cfg.Port)
+			}
+			if len(missing) != 0 {
+				t.Errorf("Expected no missing settings, got %v", missing)
+			}
+		})
+	}
 
-	if cfg.Port != -445 {
-		t.Errorf("Expected port -445, got %d", cfg.Port)
+	os.Setenv("SMB_PORT", "65535")
+	if cfg, _ := LoadFromEnv(); cfg.Port != 65535 {
+		t.Errorf("Expected the highest port 65535 to be accepted, got %d", cfg.Port)
 	}
 }
 
@@ -803,3 +818,563 @@ func TestLoadFromEnv_BasePath_Default(t *testing.T) {
 		t.Errorf("Expected empty BasePath by default, got '%s'", cfg.BasePath)
 	}
 }
+
+func TestLoadFromEnv_MaxPathDepth(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected int
+	}{
+		{"Default", "", defaultMaxPathDepth},
+		{"Custom", "10", 10},
+		{"Zero disables", "0", 0},
+		{"Invalid uses default", "deep", defaultMaxPathDepth},
+		{"Negative uses default", "-1", defaultMaxPathDepth},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Clearenv()
+			if tc.value != "" {
+				os.Setenv("SMB_MAX_PATH_DEPTH", tc.value)
+			}
+
+			cfg, _ := LoadFromEnv()
+			if cfg.MaxPathDepth != tc.expected {
+				t.Errorf("Expected MaxPathDepth %d, got %d", tc.expected, cfg.MaxPathDepth)
+			}
+		})
+	}
+}
+
+func TestLoadFromEnv_TimestampTimezone(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.TimestampLocation != nil {
+		t.Errorf("Expected no timestamp location by default, got %v", cfg.TimestampLocation)
+	}
+
+	os.Setenv("TIMESTAMP_TIMEZONE", "UTC")
+	cfg, _ = LoadFromEnv()
+	if cfg.TimestampLocation == nil || cfg.TimestampLocation.String() != "UTC" {
+		t.Errorf("Expected UTC timestamp location, got %v", cfg.TimestampLocation)
+	}
+
+	os.Setenv("TIMESTAMP_TIMEZONE", "Not/AZone")
+	cfg, _ = LoadFromEnv()
+	if cfg.TimestampLocation != nil {
+		t.Errorf("Expected invalid time zone to be ignored, got %v", cfg.TimestampLocation)
+	}
+}
+
+func TestLoadFromEnv_HealthWriteTest(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.HealthWriteTest {
+		t.Error("Expected HealthWriteTest to be false by default")
+	}
+	if cfg.HealthWriteDir != defaultHealthWriteDir {
+		t.Errorf("Expected HealthWriteDir %q, got %q", defaultHealthWriteDir, cfg.HealthWriteDir)
+	}
+
+	os.Setenv("HEALTH_WRITE_TEST", "true")
+	os.Setenv("HEALTH_WRITE_TEST_DIR", "tmp/health")
+	cfg, _ = LoadFromEnv()
+	if !cfg.HealthWriteTest {
+		t.Error("Expected HealthWriteTest to be true")
+	}
+	if cfg.HealthWriteDir != "tmp/health" {
+		t.Errorf("Expected HealthWriteDir 'tmp/health', got %q", cfg.HealthWriteDir)
+	}
+}
+
+func TestLoadFromEnv_HealthCheckWriteAlias(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("HEALTH_CHECK_WRITE", "true")
+	cfg, _ := LoadFromEnv()
+	if !cfg.HealthWriteTest {
+		t.Error("Expected HealthWriteTest to be true when HEALTH_CHECK_WRITE=true")
+	}
+
+	// HEALTH_WRITE_TEST takes precedence when both are set
+	os.Setenv("HEALTH_WRITE_TEST", "false")
+	cfg, _ = LoadFromEnv()
+	if cfg.HealthWriteTest {
+		t.Error("Expected HEALTH_WRITE_TEST=false to override HEALTH_CHECK_WRITE=true")
+	}
+}
+
+func TestLoadFromEnv_PasswordIsNTHash(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.PasswordIsNTHash {
+		t.Error("Expected PasswordIsNTHash to be false by default")
+	}
+
+	os.Setenv("SMB_PASSWORD_IS_NT_HASH", "true")
+	cfg, _ = LoadFromEnv()
+	if !cfg.PasswordIsNTHash {
+		t.Error("Expected PasswordIsNTHash to be true")
+	}
+}
+
+func TestLoadFromEnv_HealthSingleFlight(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if !cfg.HealthSingleFlight {
+		t.Error("Expected HealthSingleFlight to be true by default")
+	}
+
+	os.Setenv("HEALTH_SINGLE_FLIGHT", "false")
+	cfg, _ = LoadFromEnv()
+	if cfg.HealthSingleFlight {
+		t.Error("Expected HealthSingleFlight to be false")
+	}
+}
+
+func TestLoadFromEnv_MaxNameLength(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.MaxNameLength != defaultMaxNameLength {
+		t.Errorf("Expected MaxNameLength %d, got %d", defaultMaxNameLength, cfg.MaxNameLength)
+	}
+
+	os.Setenv("SMB_MAX_NAME_LENGTH", "100")
+	cfg, _ = LoadFromEnv()
+	if cfg.MaxNameLength != 100 {
+		t.Errorf("Expected MaxNameLength 100, got %d", cfg.MaxNameLength)
+	}
+}
+
+func TestLoadFromEnv_CleanupOnFailedUpload(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.CleanupOnFailedUpload {
+		t.Error("Expected CleanupOnFailedUpload to be false by default")
+	}
+
+	os.Setenv("SMB_CLEANUP_ON_FAILED_UPLOAD", "true")
+	cfg, _ = LoadFromEnv()
+	if !cfg.CleanupOnFailedUpload {
+		t.Error("Expected CleanupOnFailedUpload to be true")
+	}
+}
+
+func TestLoadFromEnv_VerifyUpload(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.VerifyUpload {
+		t.Error("Expected VerifyUpload to be false by default")
+	}
+
+	os.Setenv("SMB_VERIFY_UPLOAD", "true")
+	cfg, _ = LoadFromEnv()
+	if !cfg.VerifyUpload {
+		t.Error("Expected VerifyUpload to be true")
+	}
+}
+
+func TestLoadFromEnv_Dedup(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.Dedup || cfg.DedupDir != ".smbrelay-dedup" {
+		t.Errorf("Expected Dedup off with the default index directory, got %v and %q", cfg.Dedup, cfg.DedupDir)
+	}
+
+	os.Setenv("SMB_DEDUP", "true")
+	os.Setenv("SMB_DEDUP_DIR", "index/dedup")
+	cfg, _ = LoadFromEnv()
+	if !cfg.Dedup || cfg.DedupDir != "index/dedup" {
+		t.Errorf("Expected Dedup on with index directory index/dedup, got %v and %q", cfg.Dedup, cfg.DedupDir)
+	}
+}
+
+func TestLoadFromEnv_AutoMkdir(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.DisableAutoMkdir {
+		t.Error("Expected parent directories to be created by default")
+	}
+
+	os.Setenv("SMB_AUTO_MKDIR", "false")
+	cfg, _ = LoadFromEnv()
+	if !cfg.DisableAutoMkdir {
+		t.Error("Expected DisableAutoMkdir to be true when SMB_AUTO_MKDIR=false")
+	}
+
+	os.Setenv("SMB_AUTO_MKDIR", "true")
+	cfg, _ = LoadFromEnv()
+	if cfg.DisableAutoMkdir {
+		t.Error("Expected DisableAutoMkdir to be false when SMB_AUTO_MKDIR=true")
+	}
+}
+
+func TestLoadFromEnv_CreateBasePath(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.CreateBasePath {
+		t.Error("Expected CreateBasePath to be false by default")
+	}
+
+	os.Setenv("SMB_CREATE_BASE_PATH", "true")
+	cfg, _ = LoadFromEnv()
+	if !cfg.CreateBasePath {
+		t.Error("Expected CreateBasePath to be true when SMB_CREATE_BASE_PATH=true")
+	}
+}
+
+func TestLoadFromEnv_ReadOnly(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.ReadOnly {
+		t.Error("Expected ReadOnly to be false by default")
+	}
+
+	os.Setenv("SMB_READ_ONLY", "true")
+	cfg, _ = LoadFromEnv()
+	if !cfg.ReadOnly {
+		t.Error("Expected ReadOnly to be true when SMB_READ_ONLY=true")
+	}
+}
+
+func TestLoadFromEnv_AllowedPrefixes(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.AllowedPrefixes != nil {
+		t.Errorf("Expected no allowed prefixes by default, got %v", cfg.AllowedPrefixes)
+	}
+
+	os.Setenv("SMB_ALLOWED_PREFIXES", " teams/a, ,teams/b ")
+	cfg, _ = LoadFromEnv()
+	if want := []string{"teams/a", "teams/b"}; !slices.Equal(cfg.AllowedPrefixes, want) {
+		t.Errorf("Expected allowed prefixes %v, got %v", want, cfg.AllowedPrefixes)
+	}
+}
+
+func TestLoadFromEnv_CreateDirsAlias(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SMB_CREATE_DIRS", "false")
+	cfg, _ := LoadFromEnv()
+	if !cfg.DisableAutoMkdir {
+		t.Error("Expected DisableAutoMkdir to be true when SMB_CREATE_DIRS=false")
+	}
+
+	// SMB_AUTO_MKDIR takes precedence when both are set
+	os.Setenv("SMB_AUTO_MKDIR", "true")
+	cfg, _ = LoadFromEnv()
+	if cfg.DisableAutoMkdir {
+		t.Error("Expected SMB_AUTO_MKDIR=true to override SMB_CREATE_DIRS=false")
+	}
+}
+
+func TestLoadFromEnv_AllowSMB1(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.AllowSMB1 {
+		t.Error("Expected AllowSMB1 to be false by default")
+	}
+
+	os.Setenv("SMB_ALLOW_SMB1", "true")
+	cfg, _ = LoadFromEnv()
+	if !cfg.AllowSMB1 {
+		t.Error("Expected AllowSMB1 to be true")
+	}
+}
+
+func TestLoadFromEnv_SigningAndEncryption(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.RequireSigning || cfg.RequireEncryption {
+		t.Error("Expected signing and encryption not to be required by default")
+	}
+
+	os.Setenv("SMB_REQUIRE_SIGNING", "true")
+	os.Setenv("SMB_REQUIRE_ENCRYPTION", "1")
+	cfg, _ = LoadFromEnv()
+	if !cfg.RequireSigning || !cfg.RequireEncryption {
+		t.Errorf("Expected signing and encryption to be required, got signing=%v encryption=%v",
+			cfg.RequireSigning, cfg.RequireEncryption)
+	}
+}
+
+func TestLoadFromEnv_ProtocolRange(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name        string
+		minEnv      string
+		maxEnv      string
+		expectedMin string
+		expectedMax string
+		invalid     []string
+	}{
+		{"unset", "", "", "", "", nil},
+		{"minimum", "SMB3", "", "SMB3", "", nil},
+		{"maximum", "", "nt1", "", "NT1", nil},
+		{"range", " smb2 ", "Smb3", "SMB2", "SMB3", nil},
+		{"unknown minimum", "SMB4", "", "", "", []string{"SMB_MIN_PROTOCOL"}},
+		{"unknown maximum", "SMB2", "SMB2_10", "SMB2", "", []string{"SMB_MAX_PROTOCOL"}},
+		{"inverted range", "SMB3", "NT1", "SMB3", "NT1", []string{"SMB_MIN_PROTOCOL", "SMB_MAX_PROTOCOL"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			os.Setenv("SMB_SERVER_NAME", "testserver")
+			os.Setenv("SMB_SERVER_IP", "192.168.1.100")
+			os.Setenv("SMB_SHARE_NAME", "testshare")
+			os.Setenv("SMB_USERNAME", "testuser")
+			os.Setenv("SMB_PASSWORD", "testpass")
+			os.Setenv("SMB_MIN_PROTOCOL", tt.minEnv)
+			os.Setenv("SMB_MAX_PROTOCOL", tt.maxEnv)
+
+			cfg, missing := LoadFromEnv()
+			if cfg.MinProtocol != tt.expectedMin || cfg.MaxProtocol != tt.expectedMax {
+				t.Errorf("Expected protocols %q-%q, got %q-%q", tt.expectedMin, tt.expectedMax, cfg.MinProtocol, cfg.MaxProtocol)
+			}
+			if strings.Join(missing, ",") != strings.Join(tt.invalid, ",") {
+				t.Errorf("Expected invalid variables %v, got %v", tt.invalid, missing)
+			}
+		})
+	}
+}
+
+func TestLoadFromEnv_MaxListDepth(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.MaxListDepth != 10 {
+		t.Errorf("Expected default MaxListDepth 10, got %d", cfg.MaxListDepth)
+	}
+
+	os.Setenv("SMB_MAX_LIST_DEPTH", "3")
+	cfg, _ = LoadFromEnv()
+	if cfg.MaxListDepth != 3 {
+		t.Errorf("Expected MaxListDepth 3, got %d", cfg.MaxListDepth)
+	}
+
+	os.Setenv("SMB_MAX_LIST_DEPTH", "-1")
+	cfg, _ = LoadFromEnv()
+	if cfg.MaxListDepth != 10 {
+		t.Errorf("Expected default MaxListDepth for a negative value, got %d", cfg.MaxListDepth)
+	}
+}
+
+func TestLoadFromEnv_CommandTimeout(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.CommandTimeout != 30*time.Second {
+		t.Errorf("Expected default CommandTimeout 30s, got %v", cfg.CommandTimeout)
+	}
+
+	os.Setenv("SMB_COMMAND_TIMEOUT", "2m")
+	cfg, _ = LoadFromEnv()
+	if cfg.CommandTimeout != 2*time.Minute {
+		t.Errorf("Expected CommandTimeout 2m, got %v", cfg.CommandTimeout)
+	}
+
+	os.Setenv("SMB_COMMAND_TIMEOUT", "0")
+	cfg, _ = LoadFromEnv()
+	if cfg.CommandTimeout != 0 {
+		t.Errorf("Expected CommandTimeout 0 to disable the timeout, got %v", cfg.CommandTimeout)
+	}
+
+	os.Setenv("SMB_COMMAND_TIMEOUT", "soon")
+	cfg, _ = LoadFromEnv()
+	if cfg.CommandTimeout != 30*time.Second {
+		t.Errorf("Expected default CommandTimeout for an invalid value, got %v", cfg.CommandTimeout)
+	}
+}
+
+func TestLoadFromEnv_MaxConcurrent(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.MaxConcurrent != 10 {
+		t.Errorf("Expected default MaxConcurrent 10, got %d", cfg.MaxConcurrent)
+	}
+
+	os.Setenv("SMB_MAX_CONCURRENT", "4")
+	cfg, _ = LoadFromEnv()
+	if cfg.MaxConcurrent != 4 {
+		t.Errorf("Expected MaxConcurrent 4, got %d", cfg.MaxConcurrent)
+	}
+}
+
+func TestLoadFromEnv_KerberosKeytab(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SMB_SERVER_NAME", "testserver")
+	os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+	os.Setenv("SMB_SHARE_NAME", "testshare")
+	os.Setenv("SMB_AUTH_PROTOCOL", "kerberos")
+	os.Setenv("SMB_KERBEROS_KEYTAB", "/etc/smbrelay/relay.keytab")
+	os.Setenv("SMB_KERBEROS_PRINCIPAL", "relay@EXAMPLE.COM")
+
+	cfg, missing := LoadFromEnv()
+	if len(missing) != 0 {
+		t.Errorf("Expected no missing variables for keytab authentication, got: %v", missing)
+	}
+	if cfg.KerberosKeytab != "/etc/smbrelay/relay.keytab" {
+		t.Errorf("Expected KerberosKeytab to be set, got %q", cfg.KerberosKeytab)
+	}
+	if cfg.KerberosPrincipal != "relay@EXAMPLE.COM" {
+		t.Errorf("Expected KerberosPrincipal to be set, got %q", cfg.KerberosPrincipal)
+	}
+}
+
+func TestLoadFromEnv_GuestAuth(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SMB_SERVER_NAME", "publicnas")
+	os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+	os.Setenv("SMB_SHARE_NAME", "public")
+	os.Setenv("SMB_AUTH_PROTOCOL", "GUEST")
+
+	cfg, missing := LoadFromEnv()
+	if len(missing) != 0 {
+		t.Errorf("Expected no missing variables for guest authentication, got: %v", missing)
+	}
+	if cfg.AuthProtocol != "guest" {
+		t.Errorf("Expected AuthProtocol guest, got %q", cfg.AuthProtocol)
+	}
+}
+
+func TestLoadFromEnv_AuthFromRequest(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SMB_SERVER_NAME", "fileserver")
+	os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+	os.Setenv("SMB_SHARE_NAME", "tenants")
+	os.Setenv("SMB_AUTH_FROM_REQUEST", "true")
+
+	cfg, missing := LoadFromEnv()
+	if len(missing) != 0 {
+		t.Errorf("Expected no missing variables when credentials come from requests, got: %v", missing)
+	}
+	if !cfg.AuthFromRequest {
+		t.Error("Expected AuthFromRequest to be enabled")
+	}
+}
+
+func TestLoadFromEnv_AuthFromRequestNeedsPassword(t *testing.T) {
+	for _, protocol := range []string{"kerberos", "guest"} {
+		os.Clearenv()
+		os.Setenv("SMB_SERVER_NAME", "fileserver")
+		os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+		os.Setenv("SMB_SHARE_NAME", "tenants")
+		os.Setenv("SMB_AUTH_FROM_REQUEST", "true")
+		os.Setenv("SMB_AUTH_PROTOCOL", protocol)
+		os.Setenv("SMB_TARGET_HR_SHARE_NAME", "hr")
+
+		cfg, missing := LoadFromEnv()
+		if cfg.AuthFromRequest {
+			t.Errorf("%s: expected AuthFromRequest to be refused", protocol)
+		}
+		if len(missing) != 1 || missing[0] != "SMB_AUTH_FROM_REQUEST" {
+			t.Errorf("%s: expected SMB_AUTH_FROM_REQUEST to be reported, got: %v", protocol, missing)
+		}
+
+		cfg, missing, err := LoadTargetFromEnv("hr")
+		if err != nil || cfg.AuthFromRequest || len(missing) != 1 || missing[0] != "SMB_AUTH_FROM_REQUEST" {
+			t.Errorf("%s: expected the target to refuse it too, got %v, %v", protocol, missing, err)
+		}
+	}
+}
+
+func TestLoadFromEnv_DriveLetterPolicy(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "", expected: DriveLetterPolicyReject},
+		{value: "strip", expected: DriveLetterPolicyStrip},
+		{value: "STRIP", expected: DriveLetterPolicyStrip},
+		{value: "reject", expected: DriveLetterPolicyReject},
+		{value: "ignore", expected: DriveLetterPolicyReject},
+	}
+
+	for _, tt := range tests {
+		os.Clearenv()
+		if tt.value != "" {
+			os.Setenv("SMB_DRIVE_LETTER_POLICY", tt.value)
+		}
+		cfg, _ := LoadFromEnv()
+		if cfg.DriveLetterPolicy != tt.expected {
+			t.Errorf("SMB_DRIVE_LETTER_POLICY=%q: expected %q, got %q", tt.value, tt.expected, cfg.DriveLetterPolicy)
+		}
+	}
+}
+
+func TestLoadFromEnv_FilenamePolicy(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: "", expected: ""},
+		{value: "replace", expected: FilenamePolicyReplace},
+		{value: "REJECT", expected: FilenamePolicyReject},
+		{value: "strip", expected: ""},
+	}
+
+	for _, tt := range tests {
+		os.Clearenv()
+		if tt.value != "" {
+			os.Setenv("SMB_SANITIZE_FILENAMES", tt.value)
+		}
+		cfg, _ := LoadFromEnv()
+		if cfg.FilenamePolicy != tt.expected {
+			t.Errorf("SMB_SANITIZE_FILENAMES=%q: expected %q, got %q", tt.value, tt.expected, cfg.FilenamePolicy)
+		}
+	}
+}
+
+func TestLoadFromEnv_HealthCacheTTL(t *testing.T) {
+	os.Clearenv()
+	cfg, _ := LoadFromEnv()
+	if cfg.HealthCacheTTL != 10*time.Second {
+		t.Errorf("Expected default HealthCacheTTL 10s, got %v", cfg.HealthCacheTTL)
+	}
+
+	os.Setenv("HEALTH_CACHE_TTL", "30s")
+	cfg, _ = LoadFromEnv()
+	if cfg.HealthCacheTTL != 30*time.Second {
+		t.Errorf("Expected HealthCacheTTL 30s, got %v", cfg.HealthCacheTTL)
+	}
+
+	os.Setenv("HEALTH_CACHE_TTL", "0")
+	cfg, _ = LoadFromEnv()
+	if cfg.HealthCacheTTL != 0 {
+		t.Errorf("Expected HealthCacheTTL 0 to disable caching, got %v", cfg.HealthCacheTTL)
+	}
+}
+
+func TestLoadFromEnv_RetryOverrides(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+	os.Setenv("SMB_MAX_RETRIES", "2")
+	os.Setenv("SMB_RETRY_INITIAL_DELAY", "0.5")
+
+	cfg, _ := LoadFromEnv()
+	if cfg.ListRetry != nil || cfg.UploadRetry != nil {
+		t.Errorf("Expected no overrides by default, got list=%v upload=%v", cfg.ListRetry, cfg.UploadRetry)
+	}
+	global := RetryPolicy{MaxRetries: 2, InitialDelay: 0.5, MaxDelay: defaultMaxRetryDelay, Backoff: defaultRetryBackoff}
+	for _, kind := range []RetryKind{RetryDefault, RetryList, RetryUpload} {
+		if policy := cfg.RetryFor(kind); policy != global {
+			t.Errorf("RetryFor(%q) = %+v, want the global %+v", kind, policy, global)
+		}
+	}
+
+	os.Setenv("SMB_LIST_MAX_RETRIES", "0")
+	os.Setenv("SMB_UPLOAD_MAX_RETRIES", "6")
+	os.Setenv("SMB_UPLOAD_RETRY_MAX_DELAY", "120")
+	os.Setenv("SMB_UPLOAD_RETRY_BACKOFF", "invalid")
+	cfg, _ = LoadFromEnv()
+
+	list := RetryPolicy{MaxRetries: 0, InitialDelay: 0.5, MaxDelay: defaultMaxRetryDelay, Backoff: defaultRetryBackoff}
+	if policy := cfg.RetryFor(RetryList); policy != list {
+		t.Errorf("RetryFor(RetryList) = %+v, want %+v", policy, list)
+	}
+	upload := RetryPolicy{MaxRetries: 6, InitialDelay: 0.5, MaxDelay: 120, Backoff: defaultRetryBackoff}
+	if policy := cfg.RetryFor(RetryUpload); policy != upload {
+		t.Errorf("RetryFor(RetryUpload) = %+v, want %+v", policy, upload)
+	}
+	if policy := cfg.RetryFor(RetryDefault); policy != global {
+		t.Errorf("RetryFor(RetryDefault) = %+v, want the global %+v", policy, global)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCommandArg is returned for extra smbclient arguments that would set the command to run
+var ErrCommandArg = errors.New("extra smbclient arguments must not set the command (-c, --command)")
+
+// ParseExtraArgs splits SMB_EXTRA_ARGS into smbclient arguments
+// Arguments are separated by spaces or commas; single or double quotes keep separators within an
+// argument, as in --option="client min protocol=SMB2". Arguments that would replace the command
+// the service runs, -c or --command in any form, are rejected with ErrCommandArg.
+func ParseExtraArgs(value string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range value {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n' || r == ',':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	for _, arg := range args {
+		if IsCommandArg(arg) {
+			return nil, fmt.Errorf("%w: %s", ErrCommandArg, arg)
+		}
+	}
+	return args, nil
+}
+
+// IsCommandArg reports whether an smbclient argument sets the command to run
+// Short options may be bundled, as in -Nc, so a c among the leading letters of a short option
+// group counts too; a value attached to a short option should be passed as its own argument.
+func IsCommandArg(arg string) bool {
+	if strings.HasPrefix(arg, "--") {
+		name, _, _ := strings.Cut(arg[2:], "=")
+		return name == "command"
+	}
+	if !strings.HasPrefix(arg, "-") {
+		return false
+	}
+	letters := arg[1:]
+	if end := strings.IndexFunc(letters, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z')
+	}); end >= 0 {
+		letters = letters[:end]
+	}
+	return strings.ContainsRune(letters, 'c')
+}
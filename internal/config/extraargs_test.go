@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestParseExtraArgs(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name     string
+		value    string
+		expected []string
+		err      error
+	}{
+		{"empty", "", nil, nil},
+		{"spaces", "-e  -t 60", []string{"-e", "-t", "60"}, nil},
+		{"commas", "-e,-t,60", []string{"-e", "-t", "60"}, nil},
+		{"double quotes", `--option="client min protocol=SMB2" -e`, []string{"--option=client min protocol=SMB2", "-e"}, nil},
+		{"single quotes", `-O 'TCP_NODELAY,SO_KEEPALIVE'`, []string{"-O", "TCP_NODELAY,SO_KEEPALIVE"}, nil},
+		{"empty quoted argument", `-W ""`, []string{"-W", ""}, nil},
+		{"short option value", "-s /etc/samba/custom.conf", []string{"-s", "/etc/samba/custom.conf"}, nil},
+		{"command flag", "-e -c 'rm *'", nil, ErrCommandArg},
+		{"bundled command flag", "-Nc", nil, ErrCommandArg},
+		{"long command flag", "--command=deltree x", nil, ErrCommandArg},
+		{"long command flag with separate value", "--command ls", nil, ErrCommandArg},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := ParseExtraArgs(tt.value)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if !slices.Equal(args, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, args)
+			}
+		})
+	}
+
+	if _, err := ParseExtraArgs(`--option="client signing=required`); err == nil {
+		t.Error("Expected an unterminated quote to be rejected")
+	}
+}
+
+func TestLoadFromEnv_ExtraArgs(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SMB_EXTRA_ARGS", "-e, -t 60")
+	cfg, missing := LoadFromEnv()
+	if !slices.Equal(cfg.ExtraArgs, []string{"-e", "-t", "60"}) {
+		t.Errorf("Expected the extra args in order, got %q", cfg.ExtraArgs)
+	}
+	if slices.Contains(missing, "SMB_EXTRA_ARGS") {
+		t.Errorf("Expected valid extra args to be accepted, got missing %v", missing)
+	}
+
+	os.Setenv("SMB_EXTRA_ARGS", "-e -c ls")
+	cfg, missing = LoadFromEnv()
+	if len(cfg.ExtraArgs) != 0 || !slices.Contains(missing, "SMB_EXTRA_ARGS") {
+		t.Errorf("Expected an injected -c to be refused, got args %q and missing %v", cfg.ExtraArgs, missing)
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// configFileEnv names the environment variable pointing at a JSON file of SMB settings
+const configFileEnv = "SMB_CONFIG_FILE"
+
+// configFileVariables are the environment variables a configuration file can set
+// In the file each is keyed by its lowercase name without the SMB_ prefix, e.g. server_name for
+// SMB_SERVER_NAME and health_cache_ttl for HEALTH_CACHE_TTL.
+var configFileVariables = []string{
+	"SMB_SERVER_NAME",
+	"SMB_SERVER_IP",
+	"SMB_SHARE_NAME",
+	"SMB_BASE_PATH",
+	"SMB_DEFAULT_UPLOAD_PATH",
+	"SMB_USERNAME",
+	"SMB_PASSWORD",
+	"SMB_PASSWORD_IS_NT_HASH",
+	"SMB_DOMAIN",
+	"SMB_PORT",
+	"SMB_ALLOW_SMB1",
+	"SMB_MIN_PROTOCOL",
+	"SMB_MAX_PROTOCOL",
+	"SMB_REQUIRE_SIGNING",
+	"SMB_REQUIRE_ENCRYPTION",
+	"SMB_USE_NTLM_V2",
+	"SMB_AUTH_PROTOCOL",
+	"SMB_AUTH_FROM_REQUEST",
+	"SMB_KERBEROS_KEYTAB",
+	"SMB_KERBEROS_PRINCIPAL",
+	"LOG_SMB_COMMANDS",
+	"SMB_MAX_RETRIES",
+	"SMB_RETRY_INITIAL_DELAY",
+	"SMB_RETRY_MAX_DELAY",
+	"SMB_RETRY_BACKOFF",
+	"SMB_LIST_MAX_RETRIES",
+	"SMB_LIST_RETRY_INITIAL_DELAY",
+	"SMB_LIST_RETRY_MAX_DELAY",
+	"SMB_LIST_RETRY_BACKOFF",
+	"SMB_UPLOAD_MAX_RETRIES",
+	"SMB_UPLOAD_RETRY_INITIAL_DELAY",
+	"SMB_UPLOAD_RETRY_MAX_DELAY",
+	"SMB_UPLOAD_RETRY_BACKOFF",
+	"SMB_COMMAND_TIMEOUT",
+	"SMB_MAX_CONCURRENT",
+	"SMB_FREE_SPACE_CHECK_BYTES",
+	"SMB_MAX_PATH_DEPTH",
+	"SMB_MAX_NAME_LENGTH",
+	"SMB_MAX_LIST_DEPTH",
+	"HEALTH_WRITE_TEST",
+	"HEALTH_WRITE_TEST_DIR",
+	"HEALTH_CHECK_WRITE",
+	"SMB_DRIVE_LETTER_POLICY",
+	"SMB_SANITIZE_FILENAMES",
+	"SMB_AUTO_MKDIR",
+	"SMB_CREATE_DIRS",
+	"SMB_CREATE_BASE_PATH",
+	"SMB_READ_ONLY",
+	"SMB_CLEANUP_ON_FAILED_UPLOAD",
+	"SMB_VERIFY_UPLOAD",
+	"SMB_DEDUP",
+	"SMB_DEDUP_DIR",
+	"SMB_EXTRA_ARGS",
+	"SMB_ALLOWED_PREFIXES",
+	"HEALTH_SINGLE_FLIGHT",
+	"HEALTH_CACHE_TTL",
+	"TIMESTAMP_TIMEZONE",
+}
+
+// configFileCache holds the settings of the last configuration file read, keyed by its path
+type configFileCache struct {
+	err      error
+	settings map[string]string
+	path     string
+	mu       sync.Mutex
+}
+
+var configFile = &configFileCache{}
+
+// configFileKey returns the key a configuration file uses for an environment variable
+func configFileKey(variable string) string {
+	return strings.ToLower(strings.TrimPrefix(variable, "SMB_"))
+}
+
+// readConfigFile parses a JSON configuration file into settings keyed by environment variable
+// Values may be strings, numbers or booleans; null leaves a setting unset.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", configFileEnv, err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid %s %s: %w", configFileEnv, path, err)
+	}
+
+	variables := make(map[string]string, len(configFileVariables))
+	for _, variable := range configFileVariables {
+		variables[configFileKey(variable)] = variable
+	}
+
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		variable, ok := variables[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %s: unknown setting %q", configFileEnv, path, key)
+		}
+		value = bytes.TrimSpace(value)
+		switch {
+		case bytes.Equal(value, []byte("null")):
+			continue
+		case len(value) > 0 && value[0] == '"':
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, fmt.Errorf("invalid %s %s: setting %q: %w", configFileEnv, path, key, err)
+			}
+			settings[variable] = s
+		case len(value) > 0 && (value[0] == '{' || value[0] == '['):
+			return nil, fmt.Errorf("invalid %s %s: setting %q must be a string, number or boolean",
+				configFileEnv, path, key)
+		default:
+			settings[variable] = string(value)
+		}
+	}
+	return settings, nil
+}
+
+// configFileSettings returns the settings of the file named by SMB_CONFIG_FILE, or nil when unset
+// A file is read once per path; changes to it apply after a restart.
+func configFileSettings() (map[string]string, error) {
+	path := strings.TrimSpace(os.Getenv(configFileEnv))
+	if path == "" {
+		return nil, nil
+	}
+
+	configFile.mu.Lock()
+	defer configFile.mu.Unlock()
+	if configFile.path != path {
+		configFile.settings, configFile.err = readConfigFile(path)
+		configFile.path = path
+	}
+	return configFile.settings, configFile.err
+}
+
+// CheckConfigFile reports whether the file named by SMB_CONFIG_FILE, if any, can be read and parsed
+func CheckConfigFile() error {
+	_, err := configFileSettings()
+	return err
+}
+
+// getenv returns an environment variable, falling back to the SMB_CONFIG_FILE setting for it
+// A variable that is set, even to an empty value, overrides the file.
+func getenv(key string) string {
+	if val, ok := os.LookupEnv(key); ok {
+		return val
+	}
+	settings, _ := configFileSettings()
+	return settings[key]
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes content to a configuration file in a temp dir and points SMB_CONFIG_FILE at it
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "smb.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	os.Setenv("SMB_CONFIG_FILE", path)
+	return path
+}
+
+func TestLoadFromEnv_ConfigFile(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+	writeConfigFile(t, `{
+		"server_name": "fileserver",
+		"server_ip": "10.0.0.5",
+		"share_name": "Documents",
+		"base_path": "inbox",
+		"username": "relay",
+		"password": "secret",
+		"port": 1445,
+		"max_retries": 5,
+		"retry_backoff": 1.5,
+		"command_timeout": "45s",
+		"verify_upload": true,
+		"health_cache_ttl": "1m",
+		"domain": null
+	}`)
+
+	if err := CheckConfigFile(); err != nil {
+		t.Fatalf("Expected a valid config file, got %v", err)
+	}
+
+	cfg, missing := LoadFromEnv()
+	if len(missing) != 0 {
+		t.Errorf("Expected no missing settings, got %v", missing)
+	}
+	if cfg.ServerName != "fileserver" || cfg.ServerIP != "10.0.0.5" || cfg.ShareName != "Documents" ||
+		cfg.BasePath != "inbox" || cfg.Username != "relay" || cfg.Password != "secret" {
+		t.Errorf("Expected connection settings from the file, got %+v", cfg)
+	}
+	if cfg.Port != 1445 || cfg.MaxRetries != 5 || cfg.RetryBackoff != 1.5 {
+		t.Errorf("Expected numeric settings from the file, got port %d, retries %d, backoff %g",
+			cfg.Port, cfg.MaxRetries, cfg.RetryBackoff)
+	}
+	if cfg.CommandTimeout != 45*time.Second || cfg.HealthCacheTTL != time.Minute || !cfg.VerifyUpload {
+		t.Errorf("Expected durations and flags from the file, got %s, %s, %v",
+			cfg.CommandTimeout, cfg.HealthCacheTTL, cfg.VerifyUpload)
+	}
+	if cfg.Domain != "" || cfg.MaxConcurrent != defaultMaxConcurrent {
+		t.Errorf("Expected unset settings to keep their defaults, got domain %q, max concurrent %d",
+			cfg.Domain, cfg.MaxConcurrent)
+	}
+}
+
+func TestLoadFromEnv_ConfigFileWithEnvOverrides(t *testing.T) {
+	os.Clearenv()
+	defer os.Clearenv()
+	writeConfigFile(t, `{"server_name": "fileserver", "share_name": "Documents", "username": "relay", "port": 1445}`)
+	os.Setenv("SMB_PASSWORD", "from-env")
+	os.Setenv("SMB_PORT", "445")
+	os.Setenv("SMB_USERNAME", "")
+
+	cfg, missing := LoadFromEnv()
+	if cfg.Password != "from-env" || cfg.Port != 445 {
+		t.Errorf("Expected environment variables to override the file, got password %q, port %d",
+			cfg.Password, cfg.Port)
+	}
+	if cfg.ServerName != "fileserver" || cfg.ShareName != "Documents" {
+		t.Errorf("Expected file settings where no variable is set, got %q and %q", cfg.ServerName, cfg.ShareName)
+	}
+
+	expected := []string{"SMB_SERVER_IP", "SMB_USERNAME"}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("Expected missing %v, got %v", expected, missing)
+	}
+}
+
+func TestCheckConfigFile_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unparseable", `{"server_name": "fileserver",`},
+		{"unknown setting", `{"server_nmae": "fileserver"}`},
+		{"nested value", `{"server_name": {"host": "fileserver"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			defer os.Clearenv()
+			writeConfigFile(t, tt.content)
+			os.Setenv("SMB_SERVER_IP", "10.0.0.5")
+
+			if err := CheckConfigFile(); err == nil {
+				t.Error("Expected an error for an invalid config file")
+			}
+
+			// The loader falls back to the environment alone
+			cfg, missing := LoadFromEnv()
+			if cfg.ServerIP != "10.0.0.5" || cfg.ServerName != "" {
+				t.Errorf("Expected only environment settings, got server %q at %q", cfg.ServerName, cfg.ServerIP)
+			}
+			expected := []string{"SMB_SERVER_NAME", "SMB_SHARE_NAME", "SMB_USERNAME", "SMB_PASSWORD"}
+			if !reflect.DeepEqual(missing, expected) {
+				t.Errorf("Expected missing %v, got %v", expected, missing)
+			}
+		})
+	}
+
+	os.Clearenv()
+	defer os.Clearenv()
+	os.Setenv("SMB_CONFIG_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	if err := CheckConfigFile(); err == nil {
+		t.Error("Expected an error for a missing config file")
+	}
+}
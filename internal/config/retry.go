@@ -0,0 +1,62 @@
+package config
+
+import "fmt"
+
+// RetryKind selects which operations' retry settings apply to an smbclient command
+type RetryKind string
+
+const (
+	// RetryDefault uses the global SMB_MAX_RETRIES and SMB_RETRY_* settings
+	RetryDefault RetryKind = ""
+	// RetryList covers interactive reads: directory listings and stats
+	RetryList RetryKind = "LIST"
+	// RetryUpload covers the commands of an upload: the put itself and its checks and cleanup
+	RetryUpload RetryKind = "UPLOAD"
+)
+
+// RetryPolicy holds the retry settings for a kind of operation
+type RetryPolicy struct {
+	MaxRetries   int     // Maximum number of retry attempts for network errors
+	InitialDelay float64 // Initial delay in seconds before first retry
+	MaxDelay     float64 // Maximum delay in seconds between retries
+	Backoff      float64 // Backoff multiplier for exponential backoff
+}
+
+// RetryFor returns the retry settings for a kind of operation
+// The global settings apply unless overrides were configured for the kind.
+func (c *SMBConfig) RetryFor(kind RetryKind) RetryPolicy {
+	switch {
+	case kind == RetryList && c.ListRetry != nil:
+		return *c.ListRetry
+	case kind == RetryUpload && c.UploadRetry != nil:
+		return *c.UploadRetry
+	}
+	return RetryPolicy{
+		MaxRetries:   c.MaxRetries,
+		InitialDelay: c.InitialRetryDelay,
+		MaxDelay:     c.MaxRetryDelay,
+		Backoff:      c.RetryBackoff,
+	}
+}
+
+// retryOverrideKeys returns the SMB_<KIND>_* variables overriding the global retry settings for kind
+func retryOverrideKeys(kind RetryKind) (maxRetries, initialDelay, maxDelay, backoff string) {
+	return fmt.Sprintf("SMB_%s_MAX_RETRIES", kind), fmt.Sprintf("SMB_%s_RETRY_INITIAL_DELAY", kind),
+		fmt.Sprintf("SMB_%s_RETRY_MAX_DELAY", kind), fmt.Sprintf("SMB_%s_RETRY_BACKOFF", kind)
+}
+
+// loadRetryOverride reads the retry settings for kind, or nil when none of its variables is set
+// Each setting that is not overridden falls back to the global one.
+func loadRetryOverride(kind RetryKind, global RetryPolicy) *RetryPolicy {
+	maxRetriesKey, initialDelayKey, maxDelayKey, backoffKey := retryOverrideKeys(kind)
+	if getenv(maxRetriesKey) == "" && getenv(initialDelayKey) == "" &&
+		getenv(maxDelayKey) == "" && getenv(backoffKey) == "" {
+		return nil
+	}
+	return &RetryPolicy{
+		MaxRetries:   getIntEnv(maxRetriesKey, global.MaxRetries),
+		InitialDelay: getFloatEnv(initialDelayKey, global.InitialDelay),
+		MaxDelay:     getFloatEnv(maxDelayKey, global.MaxDelay),
+		Backoff:      getFloatEnv(backoffKey, global.Backoff),
+	}
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+)
+
+const (
+	defaultTempFileMaxAge  = time.Hour
+	defaultUploadJobTTL    = time.Hour
+	defaultSessionTTL      = time.Hour
+	defaultShutdownTimeout = 30 * time.Second
+	defaultAppName         = "Document SMB Relay Service"
+
+	// uploadBodyOverhead is the room left in the request body limit for multipart boundaries and form fields
+	uploadBodyOverhead = 1024 * 1024
+)
+
+const (
+	// ValidateOnStartWarn checks the configuration at startup and logs the problems found
+	ValidateOnStartWarn = "warn"
+	// ValidateOnStartFail checks the configuration at startup and exits if any problem is found
+	ValidateOnStartFail = "fail"
+)
+
+// ServerConfig holds process-level settings for the HTTP service
+// These are independent of the SMB target configuration
+type ServerConfig struct {
+	// TempFileMaxAge is the age after which staged upload files are removed by the janitor (0 disables it)
+	TempFileMaxAge time.Duration
+	// ShutdownTimeout is how long shutdown waits for in-flight requests and SMB operations to finish
+	ShutdownTimeout time.Duration
+	// UploadJobTTL is how long finished async upload jobs remain queryable via /jobs/{id}
+	UploadJobTTL time.Duration
+	// UploadSessionTTL is how long a resumable upload session is kept after its last chunk
+	UploadSessionTTL time.Duration
+	// DebugPanics logs full stack traces for recovered panics and adds an incident ID to the 500 response
+	DebugPanics bool
+	// RequireHTTPS rejects or redirects requests that did not arrive over HTTPS
+	RequireHTTPS bool
+	// TrustedProxies lists proxy IPs/CIDRs whose X-Forwarded-* headers are honored (empty trusts all)
+	TrustedProxies []string
+	// AppName is the application name reported by the HTTP server
+	AppName string
+	// DisableStartupMessage suppresses the Fiber startup banner
+	DisableStartupMessage bool
+	// AccessLog logs the method, path, status, latency and bytes sent of each request
+	AccessLog bool
+	// AccessLogExcludePaths lists request paths left out of the access log
+	AccessLogExcludePaths []string
+	// MaxHTTPConnections caps simultaneous HTTP connections; excess connections are closed (0 is unlimited)
+	MaxHTTPConnections int
+	// AdminToken is the bearer token required by admin endpoints such as /diagnostics (empty disables them)
+	AdminToken string
+	// ServiceAPIKey is the key required on every route except /health and the API docs (empty disables it)
+	ServiceAPIKey string
+	// RateLimit is the sustained requests per second allowed from each client IP (0 disables rate limiting)
+	RateLimit float64
+	// RateBurst is how many requests a client IP may make at once before RateLimit applies
+	RateBurst int
+	// CompressionEnabled compresses responses for clients that accept gzip, deflate or brotli
+	CompressionEnabled bool
+	// PrometheusEnabled exposes request and smbclient metrics for scraping at GET /metrics
+	PrometheusEnabled bool
+	// StreamUploads pipes uploaded files from the request body straight to smbclient instead of staging them
+	StreamUploads bool
+	// MaxUploadBytes is the largest file accepted by POST /upload, in bytes (0 is unlimited)
+	MaxUploadBytes int64
+	// WebDAVEnabled serves a WebDAV interface to the share at /dav
+	WebDAVEnabled bool
+	// AllowedMIMETypes lists the content types, sniffed from the file, accepted by POST /upload (empty allows all)
+	AllowedMIMETypes []string
+	// RootRedirect is where GET / redirects to, e.g. /docs (empty serves an index of the endpoints)
+	RootRedirect string
+	// ValidateOnStart checks the SMB configuration and smbclient binary before serving: warn, fail or empty (off)
+	ValidateOnStart string
+}
+
+// BodyLimit returns the request body size limit for the HTTP server in bytes
+// It leaves room for the multipart framing around a file of MaxUploadBytes, so an oversized file
+// is rejected by the upload handler with a clear message; 0 keeps the server default.
+func (c *ServerConfig) BodyLimit() int {
+	if c.MaxUploadBytes <= 0 {
+		return 0
+	}
+	return int(c.MaxUploadBytes) + uploadBodyOverhead
+}
+
+// getDurationEnv gets a time.Duration from environment variable with a default value
+// Values use Go duration syntax (e.g. "30s", "15m", "1h")
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	valStr := getenv(key)
+	if valStr == "" {
+		return defaultValue
+	}
+	val, err := time.ParseDuration(valStr)
+	if err != nil {
+		return defaultValue
+	}
+	// Ensure non-negative values
+	if val < 0 {
+		return defaultValue
+	}
+	return val
+}
+
+// LoadServerConfig loads the HTTP service configuration from environment variables
+func LoadServerConfig() *ServerConfig {
+	return &ServerConfig{
+		TempFileMaxAge:        getDurationEnv("TEMP_FILE_MAX_AGE", defaultTempFileMaxAge),
+		UploadJobTTL:          getDurationEnv("UPLOAD_JOB_TTL", defaultUploadJobTTL),
+		UploadSessionTTL:      getDurationEnv("UPLOAD_SESSION_TTL", defaultSessionTTL),
+		ShutdownTimeout:       getDurationEnv("SHUTDOWN_TIMEOUT", defaultShutdownTimeout),
+		DebugPanics:           parseBoolEnv(os.Getenv("DEBUG_PANICS")),
+		RequireHTTPS:          parseBoolEnv(os.Getenv("REQUIRE_HTTPS")),
+		TrustedProxies:        getListEnv("TRUSTED_PROXIES"),
+		AppName:               getStringEnv("APP_NAME", defaultAppName),
+		DisableStartupMessage: parseBoolEnv(os.Getenv("DISABLE_STARTUP_MESSAGE")),
+		AccessLog:             parseBoolEnv(getStringEnv("ACCESS_LOG", os.Getenv("ACCESS_LOG_ENABLED"))),
+		AccessLogExcludePaths: getListEnvDefault("ACCESS_LOG_EXCLUDE_PATHS", []string{"/health"}),
+		MaxHTTPConnections:    getIntEnv("MAX_HTTP_CONNECTIONS", 0),
+		AdminToken:            os.Getenv("ADMIN_TOKEN"),
+		ServiceAPIKey:         os.Getenv("SERVICE_API_KEY"),
+		RateLimit:             getFloatEnv("SERVICE_RATE_LIMIT", 0),
+		RateBurst:             getIntEnv("SERVICE_RATE_BURST", 0),
+		PrometheusEnabled:     parseBoolEnv(os.Getenv("PROMETHEUS_ENABLED")),
+		CompressionEnabled:    parseBoolEnv(os.Getenv("COMPRESSION_ENABLED")),
+		StreamUploads:         parseBoolEnv(os.Getenv("SMB_STREAM_UPLOADS")),
+		MaxUploadBytes:        int64(getIntEnv("SMB_MAX_UPLOAD_BYTES", 0)),
+		AllowedMIMETypes:      getListEnv("SMB_ALLOWED_MIME_TYPES"),
+		WebDAVEnabled:         parseBoolEnv(os.Getenv("WEBDAV_ENABLED")),
+		RootRedirect:          os.Getenv("ROOT_REDIRECT"),
+		ValidateOnStart:       getValidateOnStartEnv(),
+	}
+}
+
+// getValidateOnStartEnv reads CONFIG_VALIDATE_ON_START
+// A true value means fail, so enabling the check stops a misconfigured service from starting.
+func getValidateOnStartEnv() string {
+	val := strings.ToLower(strings.TrimSpace(os.Getenv("CONFIG_VALIDATE_ON_START")))
+	switch {
+	case val == ValidateOnStartWarn || val == ValidateOnStartFail:
+		return val
+	case parseBoolEnv(val):
+		return ValidateOnStartFail
+	case val != "" && val != "false" && val != "0" && val != "no":
+		logger.Warn("Invalid CONFIG_VALIDATE_ON_START %q, using %q", val, ValidateOnStartFail)
+		return ValidateOnStartFail
+	default:
+		return ""
+	}
+}
+
+// getListEnv gets a comma-separated list from an environment variable, ignoring empty entries
+func getListEnv(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getListEnvDefault gets a comma-separated list from an environment variable, using the default when unset
+// Setting the variable to an empty value yields an empty list.
+func getListEnvDefault(key string, defaultValue []string) []string {
+	if _, ok := os.LookupEnv(key); !ok {
+		return defaultValue
+	}
+	return getListEnv(key)
+}
+
+// getStringEnv gets a string from an environment variable, using the default when unset or blank
+func getStringEnv(key, defaultValue string) string {
+	if val := strings.TrimSpace(os.Getenv(key)); val != "" {
+		return val
+	}
+	return defaultValue
+}
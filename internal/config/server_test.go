@@ -0,0 +1,268 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoadServerConfig_TempFileMaxAge(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "default", value: "", expected: defaultTempFileMaxAge},
+		{name: "custom", value: "30m", expected: 30 * time.Minute},
+		{name: "disabled", value: "0", expected: 0},
+		{name: "invalid", value: "soon", expected: defaultTempFileMaxAge},
+		{name: "negative", value: "-5m", expected: defaultTempFileMaxAge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.value != "" {
+				os.Setenv("TEMP_FILE_MAX_AGE", tt.value)
+			}
+
+			cfg := LoadServerConfig()
+			if cfg.TempFileMaxAge != tt.expected {
+				t.Errorf("TempFileMaxAge = %v, want %v", cfg.TempFileMaxAge, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadServerConfig_UploadJobTTL(t *testing.T) {
+	os.Clearenv()
+	if cfg := LoadServerConfig(); cfg.UploadJobTTL != defaultUploadJobTTL {
+		t.Errorf("UploadJobTTL = %v, want %v", cfg.UploadJobTTL, defaultUploadJobTTL)
+	}
+
+	os.Setenv("UPLOAD_JOB_TTL", "10m")
+	if cfg := LoadServerConfig(); cfg.UploadJobTTL != 10*time.Minute {
+		t.Errorf("UploadJobTTL = %v, want %v", cfg.UploadJobTTL, 10*time.Minute)
+	}
+}
+
+func TestLoadServerConfig_DebugPanics(t *testing.T) {
+	os.Clearenv()
+	if LoadServerConfig().DebugPanics {
+		t.Error("Expected DebugPanics to default to false")
+	}
+
+	os.Setenv("DEBUG_PANICS", "true")
+	if !LoadServerConfig().DebugPanics {
+		t.Error("Expected DebugPanics to be true when DEBUG_PANICS=true")
+	}
+}
+
+func TestLoadServerConfig_RequireHTTPS(t *testing.T) {
+	os.Clearenv()
+	cfg := LoadServerConfig()
+	if cfg.RequireHTTPS || len(cfg.TrustedProxies) != 0 {
+		t.Errorf("Expected HTTPS enforcement off with no trusted proxies, got %v %v", cfg.RequireHTTPS, cfg.TrustedProxies)
+	}
+
+	os.Setenv("REQUIRE_HTTPS", "true")
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8, 192.168.1.5,,")
+	cfg = LoadServerConfig()
+	if !cfg.RequireHTTPS {
+		t.Error("Expected RequireHTTPS to be true")
+	}
+	if len(cfg.TrustedProxies) != 2 || cfg.TrustedProxies[0] != "10.0.0.0/8" || cfg.TrustedProxies[1] != "192.168.1.5" {
+		t.Errorf("Unexpected TrustedProxies: %v", cfg.TrustedProxies)
+	}
+}
+
+func TestLoadServerConfig_AccessLog(t *testing.T) {
+	os.Clearenv()
+	cfg := LoadServerConfig()
+	if cfg.AccessLog {
+		t.Error("Expected AccessLog to default to false")
+	}
+	if len(cfg.AccessLogExcludePaths) != 1 || cfg.AccessLogExcludePaths[0] != "/health" {
+		t.Errorf("Expected /health to be excluded by default, got %v", cfg.AccessLogExcludePaths)
+	}
+
+	os.Setenv("ACCESS_LOG", "true")
+	os.Setenv("ACCESS_LOG_EXCLUDE_PATHS", "")
+	cfg = LoadServerConfig()
+	if !cfg.AccessLog {
+		t.Error("Expected AccessLog to be true")
+	}
+	if len(cfg.AccessLogExcludePaths) != 0 {
+		t.Errorf("Expected no excluded paths when set empty, got %v", cfg.AccessLogExcludePaths)
+	}
+}
+
+func TestLoadServerConfig_AccessLogEnabledAlias(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ACCESS_LOG_ENABLED", "true")
+	if !LoadServerConfig().AccessLog {
+		t.Error("Expected ACCESS_LOG_ENABLED=true to enable the access log")
+	}
+
+	// ACCESS_LOG takes precedence when both are set
+	os.Setenv("ACCESS_LOG", "false")
+	if LoadServerConfig().AccessLog {
+		t.Error("Expected ACCESS_LOG=false to override ACCESS_LOG_ENABLED=true")
+	}
+}
+
+func TestLoadServerConfig_MaxHTTPConnections(t *testing.T) {
+	os.Clearenv()
+	if cfg := LoadServerConfig(); cfg.MaxHTTPConnections != 0 {
+		t.Errorf("MaxHTTPConnections = %d, want 0 (unlimited)", cfg.MaxHTTPConnections)
+	}
+
+	os.Setenv("MAX_HTTP_CONNECTIONS", "200")
+	if cfg := LoadServerConfig(); cfg.MaxHTTPConnections != 200 {
+		t.Errorf("MaxHTTPConnections = %d, want 200", cfg.MaxHTTPConnections)
+	}
+}
+
+func TestLoadServerConfig_AdminToken(t *testing.T) {
+	os.Clearenv()
+	if cfg := LoadServerConfig(); cfg.AdminToken != "" {
+		t.Errorf("AdminToken = %q, want empty", cfg.AdminToken)
+	}
+
+	os.Setenv("ADMIN_TOKEN", "s3cret")
+	if cfg := LoadServerConfig(); cfg.AdminToken != "s3cret" {
+		t.Errorf("AdminToken = %q, want %q", cfg.AdminToken, "s3cret")
+	}
+}
+
+func TestLoadServerConfig_ServiceAPIKey(t *testing.T) {
+	os.Clearenv()
+	if cfg := LoadServerConfig(); cfg.ServiceAPIKey != "" {
+		t.Errorf("ServiceAPIKey = %q, want empty", cfg.ServiceAPIKey)
+	}
+
+	os.Setenv("SERVICE_API_KEY", "k3y")
+	if cfg := LoadServerConfig(); cfg.ServiceAPIKey != "k3y" {
+		t.Errorf("ServiceAPIKey = %q, want %q", cfg.ServiceAPIKey, "k3y")
+	}
+}
+
+func TestLoadServerConfig_RateLimit(t *testing.T) {
+	os.Clearenv()
+	if cfg := LoadServerConfig(); cfg.RateLimit != 0 || cfg.RateBurst != 0 {
+		t.Errorf("RateLimit, RateBurst = %v, %d, want 0, 0 (disabled)", cfg.RateLimit, cfg.RateBurst)
+	}
+
+	os.Setenv("SERVICE_RATE_LIMIT", "2.5")
+	os.Setenv("SERVICE_RATE_BURST", "10")
+	if cfg := LoadServerConfig(); cfg.RateLimit != 2.5 || cfg.RateBurst != 10 {
+		t.Errorf("RateLimit, RateBurst = %v, %d, want 2.5, 10", cfg.RateLimit, cfg.RateBurst)
+	}
+}
+
+func TestLoadServerConfig_StreamUploads(t *testing.T) {
+	os.Clearenv()
+	if cfg := LoadServerConfig(); cfg.StreamUploads {
+		t.Error("StreamUploads = true, want false by default")
+	}
+
+	os.Setenv("SMB_STREAM_UPLOADS", "true")
+	if cfg := LoadServerConfig(); !cfg.StreamUploads {
+		t.Error("StreamUploads = false, want true")
+	}
+}
+
+func TestLoadServerConfig_PrometheusEnabled(t *testing.T) {
+	os.Clearenv()
+	if LoadServerConfig().PrometheusEnabled {
+		t.Error("Expected PrometheusEnabled to default to false")
+	}
+
+	os.Setenv("PROMETHEUS_ENABLED", "true")
+	if !LoadServerConfig().PrometheusEnabled {
+		t.Error("Expected PrometheusEnabled to be true when PROMETHEUS_ENABLED=true")
+	}
+}
+
+func TestLoadServerConfig_ShutdownTimeout(t *testing.T) {
+	os.Clearenv()
+	if cfg := LoadServerConfig(); cfg.ShutdownTimeout != defaultShutdownTimeout {
+		t.Errorf("ShutdownTimeout = %v, want %v", cfg.ShutdownTimeout, defaultShutdownTimeout)
+	}
+
+	os.Setenv("SHUTDOWN_TIMEOUT", "2m")
+	if cfg := LoadServerConfig(); cfg.ShutdownTimeout != 2*time.Minute {
+		t.Errorf("ShutdownTimeout = %v, want %v", cfg.ShutdownTimeout, 2*time.Minute)
+	}
+}
+
+func TestLoadServerConfig_MaxUploadBytes(t *testing.T) {
+	os.Clearenv()
+	cfg := LoadServerConfig()
+	if cfg.MaxUploadBytes != 0 || cfg.BodyLimit() != 0 {
+		t.Errorf("Expected no upload limit by default, got %d (body limit %d)", cfg.MaxUploadBytes, cfg.BodyLimit())
+	}
+
+	os.Setenv("SMB_MAX_UPLOAD_BYTES", "10485760")
+	defer os.Clearenv()
+	cfg = LoadServerConfig()
+	if cfg.MaxUploadBytes != 10485760 {
+		t.Errorf("Expected MaxUploadBytes 10485760, got %d", cfg.MaxUploadBytes)
+	}
+	if cfg.BodyLimit() != 10485760+uploadBodyOverhead {
+		t.Errorf("Expected body limit to leave room for multipart overhead, got %d", cfg.BodyLimit())
+	}
+}
+
+func TestLoadServerConfig_AllowedMIMETypes(t *testing.T) {
+	os.Clearenv()
+	if cfg := LoadServerConfig(); len(cfg.AllowedMIMETypes) != 0 {
+		t.Errorf("Expected no MIME type allowlist by default, got %v", cfg.AllowedMIMETypes)
+	}
+
+	os.Setenv("SMB_ALLOWED_MIME_TYPES", "application/pdf, image/png,")
+	defer os.Clearenv()
+	cfg := LoadServerConfig()
+	if len(cfg.AllowedMIMETypes) != 2 || cfg.AllowedMIMETypes[0] != "application/pdf" ||
+		cfg.AllowedMIMETypes[1] != "image/png" {
+		t.Errorf("Expected [application/pdf image/png], got %v", cfg.AllowedMIMETypes)
+	}
+}
+
+func TestLoadServerConfig_ValidateOnStart(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", ""},
+		{"false", ""},
+		{"true", ValidateOnStartFail},
+		{"1", ValidateOnStartFail},
+		{"fail", ValidateOnStartFail},
+		{"WARN", ValidateOnStartWarn},
+		{"sometimes", ValidateOnStartFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			os.Clearenv()
+			defer os.Clearenv()
+			os.Setenv("CONFIG_VALIDATE_ON_START", tt.value)
+			if cfg := LoadServerConfig(); cfg.ValidateOnStart != tt.expected {
+				t.Errorf("ValidateOnStart = %q, want %q", cfg.ValidateOnStart, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadServerConfig_CompressionEnabled(t *testing.T) {
+	os.Clearenv()
+	if LoadServerConfig().CompressionEnabled {
+		t.Error("Expected CompressionEnabled to default to false")
+	}
+
+	os.Setenv("COMPRESSION_ENABLED", "true")
+	if !LoadServerConfig().CompressionEnabled {
+		t.Error("Expected CompressionEnabled to be true when COMPRESSION_ENABLED=true")
+	}
+}
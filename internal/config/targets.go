@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+)
+
+// targetEnvPrefix starts the environment variables of a named SMB target, e.g. SMB_TARGET_HR_SHARE_NAME
+const targetEnvPrefix = "SMB_TARGET_"
+
+// targetSettings are the connection settings a named target can set, by variable suffix
+// Settings a target leaves unset, and everything else (retries, timeouts, limits), come from the
+// default SMB_* configuration.
+var targetSettings = []string{
+	"SERVER_NAME",
+	"SERVER_IP",
+	"SHARE_NAME",
+	"BASE_PATH",
+	"USERNAME",
+	"PASSWORD",
+	"DOMAIN",
+	"PORT",
+	"READ_ONLY",
+}
+
+// targetEnv returns the value of a named target's setting, and whether it is set
+func targetEnv(name, setting string) (string, bool) {
+	return os.LookupEnv(targetEnvPrefix + strings.ToUpper(name) + "_" + setting)
+}
+
+// targetNames returns the lowercase names of the targets defined in the environment
+func targetNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(key, targetEnvPrefix)
+		if !ok {
+			continue
+		}
+		for _, setting := range targetSettings {
+			name, ok := strings.CutSuffix(rest, "_"+setting)
+			if !ok || name == "" {
+				continue
+			}
+			if name = strings.ToLower(name); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			break
+		}
+	}
+	return names
+}
+
+// LoadTargetsFromEnv loads the named SMB targets defined by SMB_TARGET_<NAME>_<SETTING> variables
+// Targets are keyed by lowercase name and start from the default configuration; missing lists the
+// required variables each target lacks.
+func LoadTargetsFromEnv() (map[string]*SMBConfig, map[string][]string) {
+	targets := make(map[string]*SMBConfig)
+	missing := make(map[string][]string)
+	for _, name := range targetNames() {
+		cfg, targetMissing := loadTarget(name)
+		targets[name] = cfg
+		if len(targetMissing) > 0 {
+			missing[name] = targetMissing
+		}
+	}
+	return targets, missing
+}
+
+// LoadTargetFromEnv loads the named SMB target, or the default configuration when name is empty
+// Names are case-insensitive. An error is returned when no target of that name is defined.
+func LoadTargetFromEnv(name string) (*SMBConfig, []string, error) {
+	if name == "" {
+		cfg, missing := LoadFromEnv()
+		return cfg, missing, nil
+	}
+
+	name = strings.ToLower(name)
+	for _, defined := range targetNames() {
+		if defined == name {
+			cfg, missing := loadTarget(name)
+			return cfg, missing, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("unknown SMB target: %s", name)
+}
+
+// loadTarget applies a named target's settings on top of the default configuration
+func loadTarget(name string) (*SMBConfig, []string) {
+	cfg, defaultMissing := LoadFromEnv()
+
+	fields := map[string]*string{
+		"SERVER_NAME": &cfg.ServerName,
+		"SERVER_IP":   &cfg.ServerIP,
+		"SHARE_NAME":  &cfg.ShareName,
+		"BASE_PATH":   &cfg.BasePath,
+		"USERNAME":    &cfg.Username,
+		"PASSWORD":    &cfg.Password,
+		"DOMAIN":      &cfg.Domain,
+	}
+	for setting, field := range fields {
+		if val, ok := targetEnv(name, setting); ok {
+			*field = val
+		}
+	}
+	if val, ok := targetEnv(name, "PORT"); ok {
+		if port, err := strconv.Atoi(val); err == nil && portInRange(port) {
+			cfg.Port = port
+		} else if err == nil {
+			logger.Warn("Invalid %s%s_PORT %d: must be between 1 and %d, using %d",
+				targetEnvPrefix, strings.ToUpper(name), port, maxPort, cfg.Port)
+		}
+	}
+	if val, ok := targetEnv(name, "READ_ONLY"); ok {
+		cfg.ReadOnly = parseBoolEnv(val)
+	}
+
+	// Required settings are reported under the target's own variable names
+	type requiredSetting struct{ setting, value string }
+	required := []requiredSetting{
+		{"SERVER_NAME", cfg.ServerName},
+		{"SERVER_IP", cfg.ServerIP},
+		{"SHARE_NAME", cfg.ShareName},
+	}
+	if requiresCredentials(cfg.AuthProtocol) && !cfg.AuthFromRequest {
+		required = append(required, requiredSetting{"USERNAME", cfg.Username}, requiredSetting{"PASSWORD", cfg.Password})
+	}
+	var missing []string
+	// Per-request credentials refused for the default configuration are refused for every target
+	if slices.Contains(defaultMissing, "SMB_AUTH_FROM_REQUEST") {
+		missing = append(missing, "SMB_AUTH_FROM_REQUEST")
+	}
+	for _, r := range required {
+		if r.value == "" {
+			missing = append(missing, targetEnvPrefix+strings.ToUpper(name)+"_"+r.setting)
+		}
+	}
+	return cfg, missing
+}
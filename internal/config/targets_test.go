@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func setTargetTestEnv() {
+	os.Clearenv()
+	os.Setenv("SMB_SERVER_NAME", "fileserver")
+	os.Setenv("SMB_SERVER_IP", "10.0.0.5")
+	os.Setenv("SMB_SHARE_NAME", "Documents")
+	os.Setenv("SMB_USERNAME", "relay")
+	os.Setenv("SMB_PASSWORD", "secret")
+	os.Setenv("SMB_MAX_RETRIES", "2")
+	os.Setenv("SMB_TARGET_HR_SHARE_NAME", "HR")
+	os.Setenv("SMB_TARGET_HR_BASE_PATH", "records")
+	os.Setenv("SMB_TARGET_ARCHIVE_DOCS_SERVER_NAME", "archive")
+	os.Setenv("SMB_TARGET_ARCHIVE_DOCS_SERVER_IP", "10.0.0.9")
+	os.Setenv("SMB_TARGET_ARCHIVE_DOCS_PORT", "1445")
+	os.Setenv("SMB_TARGET_ARCHIVE_DOCS_SHARE_NAME", "Archive")
+	os.Setenv("SMB_TARGET_ARCHIVE_DOCS_PASSWORD", "")
+}
+
+func TestLoadTargetsFromEnv(t *testing.T) {
+	setTargetTestEnv()
+
+	targets, missing := LoadTargetsFromEnv()
+	if len(targets) != 2 {
+		t.Fatalf("Expected 2 targets, got %d: %v", len(targets), targets)
+	}
+
+	hr := targets["hr"]
+	if hr == nil {
+		t.Fatal("Expected target hr")
+	}
+	if hr.ShareName != "HR" || hr.BasePath != "records" {
+		t.Errorf("Expected hr to use share HR and base path records, got %q and %q", hr.ShareName, hr.BasePath)
+	}
+	if hr.ServerName != "fileserver" || hr.Username != "relay" || hr.MaxRetries != 2 {
+		t.Errorf("Expected hr to inherit unset settings from the default configuration, got %+v", hr)
+	}
+
+	archive := targets["archive_docs"]
+	if archive == nil {
+		t.Fatal("Expected target archive_docs")
+	}
+	if archive.GetServer() != "10.0.0.9:1445" || archive.ShareName != "Archive" {
+		t.Errorf("Expected archive_docs to connect to 10.0.0.9:1445/Archive, got %s/%s",
+			archive.GetServer(), archive.ShareName)
+	}
+
+	// A target can clear an inherited setting, which is then reported under its own name
+	if want := []string{"SMB_TARGET_ARCHIVE_DOCS_PASSWORD"}; !reflect.DeepEqual(missing["archive_docs"], want) {
+		t.Errorf("Expected missing %v for archive_docs, got %v", want, missing["archive_docs"])
+	}
+	if _, ok := missing["hr"]; ok {
+		t.Errorf("Expected hr to be complete, got missing %v", missing["hr"])
+	}
+}
+
+func TestLoadTargetFromEnv(t *testing.T) {
+	setTargetTestEnv()
+
+	cfg, missing, err := LoadTargetFromEnv("HR")
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("Expected target HR to load, got missing %v and error %v", missing, err)
+	}
+	if cfg.ShareName != "HR" {
+		t.Errorf("Expected share HR, got %q", cfg.ShareName)
+	}
+
+	cfg, _, err = LoadTargetFromEnv("")
+	if err != nil {
+		t.Fatalf("Expected the default configuration, got error %v", err)
+	}
+	if cfg.ShareName != "Documents" {
+		t.Errorf("Expected the default share Documents, got %q", cfg.ShareName)
+	}
+
+	if _, _, err := LoadTargetFromEnv("payroll"); err == nil || err.Error() != "unknown SMB target: payroll" {
+		t.Errorf("Expected an unknown target error, got %v", err)
+	}
+}
+
+func TestLoadTargetFromEnv_ReadOnly(t *testing.T) {
+	setTargetTestEnv()
+	os.Setenv("SMB_TARGET_HR_READ_ONLY", "true")
+
+	hr, _, err := LoadTargetFromEnv("hr")
+	if err != nil || !hr.ReadOnly {
+		t.Errorf("Expected target hr to be read-only, got %v, %v", hr, err)
+	}
+	def, _, _ := LoadTargetFromEnv("")
+	if def.ReadOnly {
+		t.Error("Expected the default configuration to stay writable")
+	}
+
+	// A target can make a read-only default writable
+	os.Setenv("SMB_READ_ONLY", "true")
+	os.Setenv("SMB_TARGET_HR_READ_ONLY", "false")
+	if hr, _, _ = LoadTargetFromEnv("hr"); hr.ReadOnly {
+		t.Error("Expected SMB_TARGET_HR_READ_ONLY=false to override SMB_READ_ONLY")
+	}
+}
+
+func TestLoadTargetFromEnv_PortOutOfRange(t *testing.T) {
+	setTargetTestEnv()
+	os.Setenv("SMB_PORT", "1446")
+	os.Setenv("SMB_TARGET_ARCHIVE_DOCS_PORT", "70000")
+
+	archive, _, err := LoadTargetFromEnv("archive_docs")
+	if err != nil || archive.Port != 1446 {
+		t.Errorf("Expected an out-of-range target port to keep the default 1446, got %v, %v", archive, err)
+	}
+}
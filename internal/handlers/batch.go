@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxBatchOperations caps the number of operations a single /batch request may run
+const maxBatchOperations = 100
+
+// batchFormField is the multipart form field holding the JSON batch description
+const batchFormField = "batch"
+
+// Outcomes reported for each batch step
+const (
+	batchStepOK      = "ok"
+	batchStepFailed  = "failed"
+	batchStepSkipped = "skipped"
+)
+
+// batchOperationRequest is one entry of the operations array accepted by POST /batch
+// Uploads name the multipart file part holding their content in File.
+type batchOperationRequest struct {
+	Op        string `json:"op"`
+	Path      string `json:"path"`
+	To        string `json:"to"`
+	File      string `json:"file"`
+	Overwrite bool   `json:"overwrite"`
+}
+
+// batchRejectedError is an operation refused by an upload policy, with the status /upload gives it
+type batchRejectedError struct {
+	detail string
+	status int
+}
+
+func (e *batchRejectedError) Error() string {
+	return e.detail
+}
+
+// batchPrepareStatus returns the status of a batch rejected because one of its operations is invalid
+func batchPrepareStatus(err error) int {
+	var rejected *batchRejectedError
+	if errors.As(err, &rejected) {
+		return rejected.status
+	}
+	return pathErrorStatus(err)
+}
+
+// batchRequest is the JSON batch description accepted by POST /batch
+type batchRequest struct {
+	StopOnError *bool                   `json:"stop_on_error"`
+	Operations  []batchOperationRequest `json:"operations"`
+}
+
+// BatchHandler handles POST /batch requests
+// Operations run in order against the share and each gets its own result. Invalid requests are
+// rejected before anything is sent to the server.
+func BatchHandler(c *fiber.Ctx) error {
+	// Load configuration
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		missingVars := strings.Join(missing, ", ")
+		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": errorMsg,
+		})
+	}
+	if cfg.ReadOnly {
+		return sendReadOnly(c)
+	}
+
+	req, form, err := parseBatchRequest(c)
+	if err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(req.Operations) == 0 {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "operations must contain at least one operation",
+		})
+	}
+	if len(req.Operations) > maxBatchOperations {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": fmt.Sprintf("too many operations: %d exceeds the maximum of %d",
+				len(req.Operations), maxBatchOperations),
+		})
+	}
+
+	// Stage every referenced file before touching the share; each part is staged once
+	staged := make(map[string]string)
+	defer func() {
+		for _, tmpPath := range staged {
+			removeStagedFile(tmpPath)
+		}
+	}()
+
+	ops := make([]smb.BatchOperation, 0, len(req.Operations))
+	for i, opReq := range req.Operations {
+		op, err := prepareBatchOperation(c, opReq, form, staged, cfg)
+		if err != nil {
+			return sendResponse(c, batchPrepareStatus(err), fiber.Map{
+				"detail": fmt.Sprintf("operations[%d]: %v", i, err),
+			})
+		}
+		ops = append(ops, op)
+	}
+
+	stopOnError := req.StopOnError == nil || *req.StopOnError
+	results := smb.RunBatchWithContext(c.UserContext(), ops, cfg, stopOnError)
+
+	entries := make([]fiber.Map, 0, len(results))
+	counts := map[string]int{batchStepOK: 0, batchStepFailed: 0, batchStepSkipped: 0}
+	for i, result := range results {
+		entry := fiber.Map{
+			"op":   result.Op,
+			"path": result.Path,
+		}
+		if ops[i].To != "" {
+			entry["to"] = ops[i].To
+		}
+		switch {
+		case result.Skipped:
+			entry["status"] = batchStepSkipped
+		case result.Err != nil:
+			entry["status"] = batchStepFailed
+			entry["detail"] = result.Err.Error()
+			entry["status_code"] = batchStepErrorStatus(result.Err)
+		default:
+			entry["status"] = batchStepOK
+		}
+		counts[entry["status"].(string)]++
+		entries = append(entries, entry)
+	}
+
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
+		"results":   entries,
+		"succeeded": counts[batchStepOK],
+		"failed":    counts[batchStepFailed],
+		"skipped":   counts[batchStepSkipped],
+	})
+}
+
+// parseBatchRequest reads the batch description from a JSON body, or from the batch field of a
+// multipart form whose file parts hold the content of upload operations
+func parseBatchRequest(c *fiber.Ctx) (batchRequest, *multipart.Form, error) {
+	var req batchRequest
+	var form *multipart.Form
+
+	raw := c.Body()
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		var err error
+		form, err = c.MultipartForm()
+		if err != nil {
+			return req, nil, fmt.Errorf("invalid multipart form: %v", err)
+		}
+		values := form.Value[batchFormField]
+		if len(values) == 0 {
+			return req, nil, fmt.Errorf("%s form field is required", batchFormField)
+		}
+		raw = []byte(values[0])
+	}
+
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return req, nil, fmt.Errorf("batch must be a JSON object with an operations array")
+	}
+	return req, form, nil
+}
+
+// prepareBatchOperation validates one requested operation and resolves its paths and file
+func prepareBatchOperation(
+	c *fiber.Ctx,
+	opReq batchOperationRequest,
+	form *multipart.Form,
+	staged map[string]string,
+	cfg *config.SMBConfig,
+) (smb.BatchOperation, error) {
+	op := smb.BatchOperation{
+		Op:        strings.ToLower(strings.TrimSpace(opReq.Op)),
+		Overwrite: opReq.Overwrite,
+	}
+	if opReq.Path == "" {
+		return op, fmt.Errorf("path is required")
+	}
+
+	// Uploads get the same filename policy as /upload
+	prepare := smb.PrepareRequestPath
+	if op.Op == smb.BatchOpUpload {
+		prepare = smb.PrepareUploadPath
+	}
+	var err error
+	op.Path, err = prepare(opReq.Path, cfg)
+	if err != nil {
+		return op, err
+	}
+
+	switch op.Op {
+	case smb.BatchOpDelete, smb.BatchOpMkdir:
+	case smb.BatchOpRename:
+		if opReq.To == "" {
+			return op, fmt.Errorf("to is required for rename")
+		}
+		op.To, err = smb.PrepareRequestPath(opReq.To, cfg)
+		if err != nil {
+			return op, err
+		}
+	case smb.BatchOpUpload:
+		if opReq.File == "" {
+			return op, fmt.Errorf("file is required for upload")
+		}
+		op.LocalPath, err = stageBatchFile(c, opReq.File, form, staged)
+		if err != nil {
+			return op, err
+		}
+	default:
+		return op, fmt.Errorf("unsupported operation: %q (valid operations: %s, %s, %s, %s)", opReq.Op,
+			smb.BatchOpUpload, smb.BatchOpDelete, smb.BatchOpRename, smb.BatchOpMkdir)
+	}
+
+	return op, nil
+}
+
+// stageBatchFile saves the multipart file part with the given name to a temp file
+// Parts referenced by several uploads are staged once, after the size and type checks /upload applies.
+func stageBatchFile(c *fiber.Ctx, name string, form *multipart.Form, staged map[string]string) (string, error) {
+	if tmpPath, ok := staged[name]; ok {
+		return tmpPath, nil
+	}
+
+	var files []*multipart.FileHeader
+	if form != nil {
+		files = form.File[name]
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("file part %q not found in the request", name)
+	}
+	if detail := uploadTooLargeDetail(files[0].Size); detail != "" {
+		return "", &batchRejectedError{detail: detail, status: fiber.StatusRequestEntityTooLarge}
+	}
+	typeDetail, err := checkUploadedFileType(files[0])
+	if err != nil {
+		return "", &batchRejectedError{
+			detail: fmt.Sprintf("failed to read file part %q: %v", name, err),
+			status: fiber.StatusInternalServerError,
+		}
+	}
+	if typeDetail != "" {
+		return "", &batchRejectedError{detail: typeDetail, status: fiber.StatusUnsupportedMediaType}
+	}
+
+	tmpPath, err := createStagingFile(files[0].Filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to stage file part %q: %v", name, err)
+	}
+	staged[name] = tmpPath
+
+	if err := c.SaveFile(files[0], tmpPath); err != nil {
+		return "", fmt.Errorf("failed to stage file part %q: %v", name, err)
+	}
+	return tmpPath, nil
+}
+
+// batchStepErrorStatus maps a failed batch step to the HTTP status the single-operation endpoint
+// would have returned
+func batchStepErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, smb.ErrFileExists):
+		return fiber.StatusConflict
+	case errors.Is(err, smb.ErrIsDirectory), errors.Is(err, smb.ErrInvalidPath):
+		return fiber.StatusBadRequest
+	case errors.Is(err, smb.ErrInsufficientStorage):
+		return fiber.StatusInsufficientStorage
+	case errors.Is(err, smb.ErrParentNotFound):
+		return fiber.StatusNotFound
+	default:
+		return listErrorStatus(err)
+	}
+}
+
+// batchRequestSchema describes the batch JSON accepted by POST /batch for the OpenAPI spec
+func batchRequestSchema() *schema {
+	return &schema{
+		Type:     "object",
+		Required: []string{"operations"},
+		Properties: map[string]*schema{
+			"stop_on_error": {Type: "boolean", Default: true},
+			"operations": {
+				Type:     "array",
+				MinItems: 1,
+				MaxItems: maxBatchOperations,
+				Items: &schema{
+					Type:     "object",
+					Required: []string{"op", "path"},
+					Properties: map[string]*schema{
+						"op": {
+							Type: "string",
+							Enum: []string{smb.BatchOpUpload, smb.BatchOpDelete, smb.BatchOpRename, smb.BatchOpMkdir},
+						},
+						"path":      {Type: "string"},
+						"to":        {Type: "string", Description: "Destination path for rename"},
+						"file":      {Type: "string", Description: "Name of the multipart file part to upload"},
+						"overwrite": {Type: "boolean", Default: false},
+					},
+				},
+			},
+		},
+	}
+}
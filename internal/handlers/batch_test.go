@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// newBatchRequest builds a multipart /batch request with the batch JSON and one file part per entry of files
+func newBatchRequest(t *testing.T, batch string, files map[string]string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	if err := writer.WriteField(batchFormField, batch); err != nil {
+		t.Fatalf("Failed to write batch field: %v", err)
+	}
+	for name, content := range files {
+		part, err := writer.CreateFormFile(name, name+".txt")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write([]byte(content)); err != nil {
+			t.Fatalf("Failed to write form file: %v", err)
+		}
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/batch", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// newBatchSessionMock simulates smbclient running a chain of commands, failing every command
+// that contains fail; all commands seen are appended to executed
+func newBatchSessionMock(fail string, executed *[]string) *smb.MockSmbClientExecutor {
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		var output strings.Builder
+		var err error
+		for _, command := range strings.Split(args[len(args)-1], "; ") {
+			*executed = append(*executed, command)
+			switch {
+			case command == "pwd":
+				output.WriteString("Current directory is \\\\testserver\\testshare\\\n")
+			case strings.Contains(command, fail):
+				output.WriteString("NT_STATUS_OBJECT_NAME_NOT_FOUND deleting remote file\n")
+				err = fmt.Errorf("smbclient command failed: exit status 1")
+			default:
+				err = nil
+			}
+		}
+		return output.String(), err
+	}
+	return mock
+}
+
+func TestBatchHandler_MixedSequence(t *testing.T) {
+	setupTestSMBEnv()
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name        string
+		stopOnError string
+		wantStatus  []string
+		wantMkdir   bool
+	}{
+		{name: "stop on error by default", stopOnError: "", wantStatus: []string{"ok", "failed", "skipped"}},
+		{name: "stop on error", stopOnError: `"stop_on_error": true,`, wantStatus: []string{"ok", "failed", "skipped"}},
+		{
+			name:        "continue on error",
+			stopOnError: `"stop_on_error": false,`,
+			wantStatus:  []string{"ok", "failed", "ok"},
+			wantMkdir:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var executed []string
+			origExec := smb.SetExecutor(newBatchSessionMock(`del "inbox/missing.txt"`, &executed))
+			defer smb.SetExecutor(origExec)
+
+			app := fiber.New()
+			app.Post("/batch", BatchHandler)
+
+			batch := `{` + tt.stopOnError + `"operations": [
+				{"op": "upload", "path": "inbox/report.txt", "file": "doc", "overwrite": true},
+				{"op": "delete", "path": "inbox/missing.txt"},
+				{"op": "mkdir", "path": "archive"}
+			]}`
+			resp, err := app.Test(newBatchRequest(t, batch, map[string]string{"doc": "report body"}), -1)
+			if err != nil {
+				t.Fatalf("Failed to test batch endpoint: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+			}
+
+			var result struct {
+				Results []struct {
+					Op         string `json:"op"`
+					Status     string `json:"status"`
+					Detail     string `json:"detail"`
+					StatusCode int    `json:"status_code"`
+				} `json:"results"`
+				Failed int `json:"failed"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(result.Results) != len(tt.wantStatus) {
+				t.Fatalf("Expected %d results, got %d", len(tt.wantStatus), len(result.Results))
+			}
+			for i, want := range tt.wantStatus {
+				if result.Results[i].Status != want {
+					t.Errorf("Step %d (%s): expected %s, got %s", i, result.Results[i].Op, want, result.Results[i].Status)
+				}
+			}
+			if result.Results[1].StatusCode != fiber.StatusNotFound ||
+				result.Results[1].Detail != "file not found: inbox/missing.txt" {
+				t.Errorf("Expected delete to fail with 404, got: %+v", result.Results[1])
+			}
+			if result.Failed != 1 {
+				t.Errorf("Expected 1 failed step, got %d", result.Failed)
+			}
+
+			mkdirSent := strings.Contains(strings.Join(executed, "\n"), `mkdir "archive"`)
+			if mkdirSent != tt.wantMkdir {
+				t.Errorf("Expected mkdir sent=%v, commands: %v", tt.wantMkdir, executed)
+			}
+		})
+	}
+}
+
+func TestBatchHandler_RemovesStagedFiles(t *testing.T) {
+	setupTestSMBEnv()
+
+	var executed []string
+	origExec := smb.SetExecutor(newBatchSessionMock("no-such-command", &executed))
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/batch", BatchHandler)
+
+	batch := `{"operations": [
+		{"op": "upload", "path": "a.txt", "file": "doc", "overwrite": true},
+		{"op": "upload", "path": "b.txt", "file": "doc", "overwrite": true}
+	]}`
+	resp, err := app.Test(newBatchRequest(t, batch, map[string]string{"doc": "shared body"}), -1)
+	if err != nil {
+		t.Fatalf("Failed to test batch endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	var staged []string
+	for _, command := range executed {
+		if strings.HasPrefix(command, `put "`) {
+			staged = append(staged, strings.Split(command, `"`)[1])
+		}
+	}
+	if len(staged) != 2 || staged[0] != staged[1] {
+		t.Fatalf("Expected both uploads to share one staged file, got: %v", staged)
+	}
+	if _, err := os.Stat(filepath.Join(os.TempDir(), staged[0])); !os.IsNotExist(err) {
+		t.Errorf("Expected staged file %s to be removed, got: %v", staged[0], err)
+	}
+}
+
+func TestBatchHandler_InvalidRequests(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/batch", BatchHandler)
+
+	tests := []struct {
+		name    string
+		body    string
+		wantMsg string
+	}{
+		{name: "not json", body: `operations`, wantMsg: "JSON object"},
+		{name: "no operations", body: `{"operations": []}`, wantMsg: "at least one operation"},
+		{name: "unknown op", body: `{"operations": [{"op": "chmod", "path": "a.txt"}]}`, wantMsg: "unsupported operation"},
+		{name: "missing path", body: `{"operations": [{"op": "delete"}]}`, wantMsg: "operations[0]: path is required"},
+		{name: "rename without to", body: `{"operations": [{"op": "rename", "path": "a.txt"}]}`, wantMsg: "to is required"},
+		{
+			name:    "missing file part",
+			body:    `{"operations": [{"op": "mkdir", "path": "a"}, {"op": "upload", "path": "a/b.txt", "file": "doc"}]}`,
+			wantMsg: `operations[1]: file part \"doc\" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test batch endpoint: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.wantMsg) {
+				t.Errorf("Expected detail containing %q, got: %s", tt.wantMsg, string(body))
+			}
+		})
+	}
+
+	if mock.CallCount != 0 {
+		t.Errorf("Expected no SMB calls for invalid batches, got %d", mock.CallCount)
+	}
+}
+
+func TestBatchHandler_UploadPolicies(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name       string
+		env        map[string]string
+		path       string
+		content    string
+		wantStatus int
+		wantMsg    string
+	}{
+		{
+			name:       "file over the upload limit",
+			env:        map[string]string{"SMB_MAX_UPLOAD_BYTES": "10"},
+			path:       "inbox/report.txt",
+			content:    "more than ten bytes",
+			wantStatus: fiber.StatusRequestEntityTooLarge,
+			wantMsg:    "operations[0]: file is too large",
+		},
+		{
+			name:       "file type not allowed",
+			env:        map[string]string{"SMB_ALLOWED_MIME_TYPES": "application/pdf"},
+			path:       "inbox/report.txt",
+			content:    "plain text",
+			wantStatus: fiber.StatusUnsupportedMediaType,
+			wantMsg:    "operations[0]: unsupported file type: text/plain",
+		},
+		{
+			name:       "filename rejected",
+			env:        map[string]string{"SMB_SANITIZE_FILENAMES": "reject"},
+			path:       "inbox/re:port.txt",
+			content:    "plain text",
+			wantStatus: fiber.StatusBadRequest,
+			wantMsg:    `operations[0]: name \"re:port.txt\" contains`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestSMBEnv()
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			mock := smb.NewMockExecutor()
+			origExec := smb.SetExecutor(mock)
+			defer smb.SetExecutor(origExec)
+
+			app := fiber.New()
+			app.Post("/batch", BatchHandler)
+
+			batch := fmt.Sprintf(`{"operations": [{"op": "upload", "path": %q, "file": "doc"}]}`, tt.path)
+			resp, err := app.Test(newBatchRequest(t, batch, map[string]string{"doc": tt.content}), -1)
+			if err != nil {
+				t.Fatalf("Failed to test batch endpoint: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus || !strings.Contains(string(body), tt.wantMsg) {
+				t.Errorf("Expected %d with %q, got %d: %s", tt.wantStatus, tt.wantMsg, resp.StatusCode, string(body))
+			}
+			if mock.CallCount != 0 {
+				t.Errorf("Expected no SMB calls for a rejected batch, got %d", mock.CallCount)
+			}
+		})
+	}
+}
+
+func TestBatchHandler_SanitizesUploadPaths(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("SMB_SANITIZE_FILENAMES", "replace")
+
+	var executed []string
+	origExec := smb.SetExecutor(newBatchSessionMock("no-such-command", &executed))
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/batch", BatchHandler)
+
+	batch := `{"operations": [{"op": "upload", "path": "inbox/re:port.txt", "file": "doc", "overwrite": true}]}`
+	resp, err := app.Test(newBatchRequest(t, batch, map[string]string{"doc": "plain text"}), -1)
+	if err != nil {
+		t.Fatalf("Failed to test batch endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	if commands := strings.Join(executed, "\n"); !strings.Contains(commands, `"inbox/re_port.txt"`) {
+		t.Errorf("Expected the upload to use the sanitized path, got: %v", executed)
+	}
+}
+
+func TestErrorStatus_SentinelErrors(t *testing.T) {
+	// Messages are reworded on purpose: statuses must follow the wrapped sentinel, not the text
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name       string
+		err        error
+		listStatus int
+		stepStatus int
+	}{
+		{"not found", fmt.Errorf("gone: %w", smb.ErrNotFound), 404, 404},
+		{"access denied", fmt.Errorf("forbidden: %w", smb.ErrAccessDenied), 403, 403},
+		{"timeout", fmt.Errorf("too slow: %w", smb.ErrTimeout), 504, 504},
+		{"security refused", fmt.Errorf("unsigned: %w", smb.ErrSecurityRefused), 502, 502},
+		{"invalid path", fmt.Errorf("bad: %w", smb.ErrInvalidPath), 400, 400},
+		{"file exists", fmt.Errorf("taken: %w", smb.ErrFileExists), 500, 409},
+		{"is a directory", fmt.Errorf("folder: %w", smb.ErrIsDirectory), 500, 400},
+		{"insufficient storage", fmt.Errorf("full: %w", smb.ErrInsufficientStorage), 500, 507},
+		{"parent missing", fmt.Errorf("no parent: %w", smb.ErrParentNotFound), 500, 404},
+		{"text only", errors.New("file not found: a.txt"), 500, 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listErrorStatus(tt.err); got != tt.listStatus {
+				t.Errorf("listErrorStatus(%v) = %d, want %d", tt.err, got, tt.listStatus)
+			}
+			if got := batchStepErrorStatus(tt.err); got != tt.stepStatus {
+				t.Errorf("batchStepErrorStatus(%v) = %d, want %d", tt.err, got, tt.stepStatus)
+			}
+		})
+	}
+
+	uploadStatuses := map[error]int{
+		fmt.Errorf("taken: %w", smb.ErrFileExists):         409,
+		fmt.Errorf("folder: %w", smb.ErrIsDirectory):       400,
+		fmt.Errorf("full: %w", smb.ErrInsufficientStorage): 507,
+		fmt.Errorf("no parent: %w", smb.ErrParentNotFound): 404,
+		fmt.Errorf("too slow: %w", smb.ErrTimeout):         504,
+		fmt.Errorf("unsigned: %w", smb.ErrSecurityRefused): 502,
+		errors.New("remote file already exists: a.txt"):    500,
+	}
+	for err, want := range uploadStatuses {
+		if got, _ := uploadResult(context.Background(), err, uploadOptions{}, nil); got != want {
+			t.Errorf("uploadResult(%v) status = %d, want %d", err, got, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// byteRange is a satisfiable range of a file's bytes; end is inclusive
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+// length returns the number of bytes in the range
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+// parseByteRange parses a Range header against a file of the given size
+// ok is false when the header is to be ignored and the whole file sent: it is malformed, uses a
+// unit other than bytes or asks for several ranges. An error means no byte of the range exists.
+func parseByteRange(header string, size int64) (byteRange, bool, error) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+	startStr, endStr, found := strings.Cut(spec, "-")
+	startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+	if !found || (startStr == "" && endStr == "") {
+		return byteRange{}, false, nil
+	}
+
+	// A suffix range asks for the last n bytes
+	if startStr == "" {
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n < 0 {
+			return byteRange{}, false, nil
+		}
+		if n == 0 || size == 0 {
+			return byteRange{}, false, fmt.Errorf("range not satisfiable: %s (file is %d bytes)", header, size)
+		}
+		return byteRange{start: max(size-n, 0), end: size - 1}, true, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, false, nil
+	}
+	end := size - 1
+	if endStr != "" {
+		if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < start {
+			return byteRange{}, false, nil
+		}
+	}
+	if start >= size {
+		return byteRange{}, false, fmt.Errorf("range not satisfiable: %s (file is %d bytes)", header, size)
+	}
+	return byteRange{start: start, end: min(end, size-1)}, true, nil
+}
+
+// ifRangeMatches reports whether the request's If-Range, if any, names the current file
+// The validator is compared with the response's ETag or Last-Modified header. Weak entity tags
+// never match, as If-Range requires a strong comparison.
+func ifRangeMatches(c *fiber.Ctx) bool {
+	ifRange := c.Get(fiber.HeaderIfRange)
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		etag := c.GetRespHeader(fiber.HeaderETag)
+		return etag != "" && !strings.HasPrefix(etag, "W/") && ifRange == etag
+	}
+	lastModified := c.GetRespHeader(fiber.HeaderLastModified)
+	return lastModified != "" && ifRange == lastModified
+}
+
+// sectionFile streams part of a file and closes the file once the response is sent
+type sectionFile struct {
+	*io.SectionReader
+	file *os.File
+}
+
+// Close closes the underlying file
+func (s sectionFile) Close() error {
+	return s.file.Close()
+}
+
+// sendFileRange answers a Range request for content, a file of the given size
+// It reports false, having sent nothing, when the whole file should be sent instead: the request
+// has no usable Range header or its If-Range does not match. content is closed once sent.
+func sendFileRange(c *fiber.Ctx, content *os.File, size int64) (bool, error) {
+	header := c.Get(fiber.HeaderRange)
+	if header == "" || !ifRangeMatches(c) {
+		return false, nil
+	}
+
+	r, ok, err := parseByteRange(header, size)
+	if err != nil {
+		content.Close()
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+		return true, sendResponse(c, fiber.StatusRequestedRangeNotSatisfiable, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if !ok {
+		return false, nil
+	}
+
+	c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size))
+	c.Status(fiber.StatusPartialContent)
+	section := sectionFile{SectionReader: io.NewSectionReader(content, r.start, r.length()), file: content}
+	return true, c.SendStream(section, int(r.length()))
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"runtime"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// DiagnosticsHandler handles GET /diagnostics requests
+// It bundles the environment details needed for support tickets: the smbclient binary and
+// version, the Go runtime, and the effective feature flags. Secrets are never included.
+func DiagnosticsHandler(c *fiber.Ctx) error {
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	serverCfg := config.LoadServerConfig()
+
+	smbclient := fiber.Map{
+		"path": smb.BinaryPath(),
+	}
+	if version, err := smb.ClientVersion(); err != nil {
+		smbclient["error"] = err.Error()
+	} else {
+		smbclient["version"] = version
+	}
+
+	body := fiber.Map{
+		"smbclient":  smbclient,
+		"go_version": runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"features":   featureFlags(cfg, serverCfg),
+	}
+	if len(missing) > 0 {
+		body["missing_config"] = missing
+	}
+
+	return sendResponse(c, fiber.StatusOK, body)
+}
+
+// featureFlags reports the effective values of the optional behaviors enabled through configuration
+func featureFlags(cfg *config.SMBConfig, serverCfg *config.ServerConfig) fiber.Map {
+	timestampTimezone := ""
+	if cfg.TimestampLocation != nil {
+		timestampTimezone = cfg.TimestampLocation.String()
+	}
+
+	return fiber.Map{
+		"auth_protocol":            cfg.AuthProtocol,
+		"base_path":                cfg.BasePath,
+		"default_upload_path":      cfg.DefaultUploadPath,
+		"password_is_nt_hash":      cfg.PasswordIsNTHash,
+		"allow_smb1":               cfg.AllowSMB1,
+		"min_protocol":             cfg.MinProtocol,
+		"max_protocol":             cfg.MaxProtocol,
+		"require_signing":          cfg.RequireSigning,
+		"require_encryption":       cfg.RequireEncryption,
+		"log_smb_commands":         cfg.LogSmbCommands,
+		"max_retries":              cfg.MaxRetries,
+		"list_max_retries":         cfg.RetryFor(config.RetryList).MaxRetries,
+		"upload_max_retries":       cfg.RetryFor(config.RetryUpload).MaxRetries,
+		"max_path_depth":           cfg.MaxPathDepth,
+		"max_name_length":          cfg.MaxNameLength,
+		"drive_letter_policy":      cfg.DriveLetterPolicy,
+		"filename_policy":          cfg.FilenamePolicy,
+		"timestamp_timezone":       timestampTimezone,
+		"health_write_test":        cfg.HealthWriteTest,
+		"health_single_flight":     cfg.HealthSingleFlight,
+		"cleanup_on_failed_upload": cfg.CleanupOnFailedUpload,
+		"auto_mkdir":               !cfg.DisableAutoMkdir,
+		"read_only":                cfg.ReadOnly,
+		"allowed_prefixes":         cfg.AllowedPrefixes,
+		"create_base_path":         cfg.CreateBasePath,
+		"verify_upload":            cfg.VerifyUpload,
+		"dedup":                    cfg.Dedup,
+		"free_space_check_bytes":   cfg.FreeSpaceCheckBytes,
+		"require_https":            serverCfg.RequireHTTPS,
+		"debug_panics":             serverCfg.DebugPanics,
+		"access_log":               serverCfg.AccessLog,
+		"stream_uploads":           serverCfg.StreamUploads,
+		"max_http_connections":     serverCfg.MaxHTTPConnections,
+		"prometheus_enabled":       serverCfg.PrometheusEnabled,
+		"webdav_enabled":           serverCfg.WebDAVEnabled,
+		"validate_on_start":        serverCfg.ValidateOnStart,
+	}
+}
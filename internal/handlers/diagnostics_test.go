@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+func TestDiagnosticsHandler(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("HEALTH_WRITE_TEST", "true")
+	os.Setenv("SMB_DRIVE_LETTER_POLICY", "strip")
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if len(args) == 1 && args[0] == "--version" {
+			return "Version 4.19.5-Ubuntu\n", nil
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/diagnostics", DiagnosticsHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/diagnostics", nil))
+	if err != nil {
+		t.Fatalf("Failed to test diagnostics endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	var result struct {
+		SMBClient struct {
+			Path    string `json:"path"`
+			Version string `json:"version"`
+		} `json:"smbclient"`
+		GoVersion     string                 `json:"go_version"`
+		Features      map[string]interface{} `json:"features"`
+		MissingConfig []string               `json:"missing_config"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if result.SMBClient.Version != "4.19.5-Ubuntu" {
+		t.Errorf("Expected smbclient version 4.19.5-Ubuntu, got %q", result.SMBClient.Version)
+	}
+	if result.SMBClient.Path == "" {
+		t.Error("Expected the smbclient binary path to be reported")
+	}
+	if result.GoVersion != runtime.Version() {
+		t.Errorf("Expected go_version %s, got %s", runtime.Version(), result.GoVersion)
+	}
+	if result.Features["health_write_test"] != true || result.Features["drive_letter_policy"] != "strip" {
+		t.Errorf("Expected effective feature flags, got %v", result.Features)
+	}
+	if len(result.MissingConfig) != 0 {
+		t.Errorf("Expected no missing config, got %v", result.MissingConfig)
+	}
+	if strings.Contains(string(body), "testpass") {
+		t.Errorf("Expected no credentials in diagnostics, got: %s", string(body))
+	}
+}
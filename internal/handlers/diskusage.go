@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// DiskUsageHandler handles GET /diskusage requests
+// It reports the total, free and used bytes of the share as the SMB server reports them for the
+// configured account, so quotas are reflected.
+func DiskUsageHandler(c *fiber.Ctx) error {
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Missing SMB configuration environment variables: %s", strings.Join(missing, ", ")),
+		})
+	}
+
+	usage, err := smb.GetDiskUsageWithContext(c.UserContext(), cfg)
+	if err != nil {
+		return sendResponse(c, diskUsageErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	return sendResponse(c, fiber.StatusOK, usage)
+}
+
+// diskUsageErrorStatus maps a disk usage error to its HTTP status code
+func diskUsageErrorStatus(err error) int {
+	if errors.Is(err, smb.ErrNoDiskUsage) {
+		return fiber.StatusNotImplemented
+	}
+	return listErrorStatus(err)
+}
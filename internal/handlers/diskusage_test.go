@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+func TestDiskUsageHandler(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name           string
+		output         string
+		expectedStatus int
+		expected       smb.DiskUsage
+	}{
+		{
+			name:           "reported capacity",
+			output:         "\t\t65535 blocks of size 1024. 32768 blocks available\n",
+			expectedStatus: fiber.StatusOK,
+			expected:       smb.DiskUsage{TotalBytes: 67107840, FreeBytes: 33554432, UsedBytes: 33553408},
+		},
+		{
+			name:           "capacity not reported",
+			output:         "  .                                   D        0  Mon Jan  1 10:00:00 2024\n",
+			expectedStatus: fiber.StatusNotImplemented,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestSMBEnv()
+
+			mock := smb.NewMockExecutor()
+			mock.ExecuteFunc = func(_ []string) (string, error) {
+				return tt.output, nil
+			}
+			origExec := smb.SetExecutor(mock)
+			defer smb.SetExecutor(origExec)
+
+			app := fiber.New()
+			app.Get("/diskusage", DiskUsageHandler)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/diskusage", nil))
+			if err != nil {
+				t.Fatalf("Failed to test diskusage endpoint: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			var usage smb.DiskUsage
+			if err := json.Unmarshal(body, &usage); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if usage != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, usage)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	pathpkg "path"
+	"strings"
+	"time"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// DownloadHandler handles file downloads, and HEAD requests checking whether a file exists
+// The response carries an ETag and Last-Modified derived from the file's size and modification
+// time, and a request whose If-None-Match or If-Modified-Since shows the client's copy is current
+// gets 304 Not Modified without the file being transferred from the share.
+func DownloadHandler(c *fiber.Ctx) error {
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Missing SMB configuration environment variables: %s", strings.Join(missing, ", ")),
+		})
+	}
+
+	remotePath := c.Query("path")
+	if remotePath == "" {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "path is required",
+		})
+	}
+	remotePath, err = smb.PrepareRequestPath(remotePath, cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	file, err := smb.StatFileWithContext(c.UserContext(), remotePath, cfg)
+	if err != nil {
+		return sendResponse(c, downloadErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if file.IsDir {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": fmt.Sprintf("cannot download directory: %s", remotePath),
+		})
+	}
+
+	if file.ModTime != nil {
+		etag := fileETag(file)
+		c.Set(fiber.HeaderETag, etag)
+		c.Set(fiber.HeaderLastModified, file.ModTime.UTC().Format(http.TimeFormat))
+		if notModified(c, etag, *file.ModTime) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+	}
+
+	c.Attachment(pathpkg.Base(remotePath))
+
+	// HEAD answers whether the file exists, with its headers, without transferring it
+	if c.Method() == fiber.MethodHead {
+		c.Set(fiber.HeaderContentType, remoteContentType(remotePath))
+		c.Set(fiber.HeaderAcceptRanges, "bytes")
+		c.Response().Header.SetContentLength(int(file.Size))
+		c.Status(fiber.StatusOK)
+		return nil
+	}
+	return sendRemoteFile(c, remotePath, cfg, downloadErrorStatus)
+}
+
+// DownloadContentType returns the content type a file download will be sent with, or "" for a
+// request that does not download a file
+// Response compression runs before the handler, so it uses this to leave files that are already
+// compressed alone.
+func DownloadContentType(c *fiber.Ctx) string {
+	path := c.Path()
+	switch {
+	case c.Method() != fiber.MethodGet:
+		return ""
+	case path == "/download":
+		return remoteContentType(c.Query("path"))
+	case path == "/download-zip":
+		return "application/zip"
+	case strings.HasPrefix(path, "/objects/"), strings.HasPrefix(path, WebDAVPrefix+"/"):
+		return remoteContentType(path)
+	default:
+		return ""
+	}
+}
+
+// fileETag returns a weak entity tag for a listing entry from its size and modification time
+// Listings carry no content hash, so a file rewritten with the same size within the same second
+// keeps its tag.
+func fileETag(file smb.FileInfo) string {
+	return fmt.Sprintf(`W/"%x-%x"`, file.Size, file.ModTime.Unix())
+}
+
+// notModified reports whether the request's conditional headers show the client's copy is current
+// If-None-Match takes precedence over If-Modified-Since; entity tags are compared weakly.
+func notModified(c *fiber.Ctx, etag string, modified time.Time) bool {
+	if noneMatch := c.Get(fiber.HeaderIfNoneMatch); noneMatch != "" {
+		for _, tag := range strings.Split(noneMatch, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// downloadErrorStatus maps a download error to its HTTP status code
+func downloadErrorStatus(err error) int {
+	if errors.Is(err, smb.ErrIsDirectory) {
+		return fiber.StatusBadRequest
+	}
+	return listErrorStatus(err)
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newDownloadMock returns a mock share holding reports/q1.pdf and the reports/archive directory only
+// gets counts the files transferred from the share.
+func newDownloadMock(t *testing.T, gets *int) *smb.MockSmbClientExecutor {
+	t.Helper()
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if m := davGetCommand.FindStringSubmatch(cmd); m != nil {
+			*gets++
+			return "getting file", os.WriteFile(filepath.Join(m[1], m[3]), []byte("%PDF-1.4 figures"), 0600)
+		}
+		if cmd == `cd "reports"; ls` {
+			return "  .                                   D        0  Mon Jan  1 10:00:00 2024\n" +
+				"  ..                                  D        0  Mon Jan  1 09:00:00 2024\n" +
+				"  q1.pdf                              A       16  Tue Jan  2 12:30:00 2024\n" +
+				"  archive                             D        0  Wed Jan  3 08:00:00 2024\n", nil
+		}
+		if strings.HasPrefix(cmd, `cd "`) {
+			return "cd \\missing\\: NT_STATUS_OBJECT_PATH_NOT_FOUND", fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		t.Errorf("Unexpected command: %s", cmd)
+		return "", fmt.Errorf("smbclient command failed: exit status 1")
+	}
+	return mock
+}
+
+func TestDownloadHandler(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	gets := 0
+	origExec := smb.SetExecutor(newDownloadMock(t, &gets))
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/download", DownloadHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/download?path=reports/q1.pdf", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+	}
+	if string(body) != "%PDF-1.4 figures" {
+		t.Errorf("Expected the file content, got %q", string(body))
+	}
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if !strings.HasPrefix(etag, `W/"10-`) {
+		t.Errorf("Expected a weak ETag built from the size and modification time, got %q", etag)
+	}
+	if !strings.HasSuffix(lastModified, " GMT") {
+		t.Errorf("Expected an HTTP date in Last-Modified, got %q", lastModified)
+	}
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != `attachment; filename="q1.pdf"` {
+		t.Errorf("Expected an attachment disposition, got %q", disposition)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/pdf" {
+		t.Errorf("Expected Content-Type application/pdf, got %q", contentType)
+	}
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name           string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{"matching ETag", "If-None-Match", etag, fiber.StatusNotModified},
+		{"matching ETag in a list", "If-None-Match", `"other", ` + strings.TrimPrefix(etag, "W/"), fiber.StatusNotModified},
+		{"stale ETag", "If-None-Match", `W/"10-0"`, fiber.StatusOK},
+		{"unchanged since", "If-Modified-Since", lastModified, fiber.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Mon, 01 Jan 2024 00:00:00 GMT", fiber.StatusOK},
+		{"invalid date", "If-Modified-Since", "yesterday", fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gets = 0
+			req := httptest.NewRequest("GET", "/download?path=reports/q1.pdf", nil)
+			req.Header.Set(tt.header, tt.value)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+			if resp.Header.Get("ETag") != etag {
+				t.Errorf("Expected ETag %q, got %q", etag, resp.Header.Get("ETag"))
+			}
+
+			if tt.expectedStatus == fiber.StatusNotModified {
+				if len(body) != 0 || gets != 0 {
+					t.Errorf("Expected no body and no transfer, got %d bytes and %d gets", len(body), gets)
+				}
+			} else if string(body) != "%PDF-1.4 figures" || gets != 1 {
+				t.Errorf("Expected the file content from one transfer, got %q and %d gets", string(body), gets)
+			}
+		})
+	}
+}
+
+func TestDownloadHandler_Errors(t *testing.T) {
+	setupTestSMBEnv()
+
+	gets := 0
+	origExec := smb.SetExecutor(newDownloadMock(t, &gets))
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/download", DownloadHandler)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{"missing path", "/download", fiber.StatusBadRequest},
+		{"missing file", "/download?path=reports/q2.pdf", fiber.StatusNotFound},
+		{"directory", "/download?path=reports/archive", fiber.StatusBadRequest},
+		{"traversal", "/download?path=../secret.txt", fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.url, nil))
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+		})
+	}
+	if gets != 0 {
+		t.Errorf("Expected no transfers for failed downloads, got %d", gets)
+	}
+}
+
+func TestDownloadHandler_Range(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	gets := 0
+	origExec := smb.SetExecutor(newDownloadMock(t, &gets))
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/download", DownloadHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/download?path=reports/q1.pdf", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if acceptRanges := resp.Header.Get("Accept-Ranges"); acceptRanges != "bytes" {
+		t.Errorf("Expected Accept-Ranges: bytes, got %q", acceptRanges)
+	}
+	lastModified := resp.Header.Get("Last-Modified")
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name                 string
+		rangeHeader          string
+		ifRange              string
+		expectedStatus       int
+		expectedContentRange string
+		expectedBody         string
+	}{
+		{"valid range", "bytes=0-3", "", fiber.StatusPartialContent, "bytes 0-3/16", "%PDF"},
+		{"open-ended range", "bytes=9-", "", fiber.StatusPartialContent, "bytes 9-15/16", "figures"},
+		{"suffix range", "bytes=-7", "", fiber.StatusPartialContent, "bytes 9-15/16", "figures"},
+		{"end past the file", "bytes=9-100", "", fiber.StatusPartialContent, "bytes 9-15/16", "figures"},
+		{"out of bounds", "bytes=100-200", "", fiber.StatusRequestedRangeNotSatisfiable, "bytes */16", ""},
+		{"several ranges", "bytes=0-3,9-15", "", fiber.StatusOK, "", "%PDF-1.4 figures"},
+		{"malformed", "bytes=3-1", "", fiber.StatusOK, "", "%PDF-1.4 figures"},
+		{"other unit", "items=0-3", "", fiber.StatusOK, "", "%PDF-1.4 figures"},
+		{"current If-Range", "bytes=0-3", lastModified, fiber.StatusPartialContent, "bytes 0-3/16", "%PDF"},
+		{"stale If-Range", "bytes=0-3", "Mon, 01 Jan 2024 00:00:00 GMT", fiber.StatusOK, "", "%PDF-1.4 figures"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/download?path=reports/q1.pdf", nil)
+			req.Header.Set("Range", tt.rangeHeader)
+			if tt.ifRange != "" {
+				req.Header.Set("If-Range", tt.ifRange)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+			if contentRange := resp.Header.Get("Content-Range"); contentRange != tt.expectedContentRange {
+				t.Errorf("Expected Content-Range %q, got %q", tt.expectedContentRange, contentRange)
+			}
+			if tt.expectedStatus != fiber.StatusRequestedRangeNotSatisfiable && string(body) != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestDownloadHandler_Head(t *testing.T) {
+	setupTestSMBEnv()
+
+	gets := 0
+	origExec := smb.SetExecutor(newDownloadMock(t, &gets))
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/download", DownloadHandler)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"existing file", "reports/q1.pdf", fiber.StatusOK},
+		{"case-insensitive name", "reports/Q1.PDF", fiber.StatusOK},
+		{"missing file", "reports/q2.pdf", fiber.StatusNotFound},
+		{"missing directory", "archive/q1.pdf", fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("HEAD", "/download?path="+tt.path, nil))
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if len(body) != 0 {
+				t.Errorf("Expected no body, got %q", string(body))
+			}
+			if tt.expectedStatus == fiber.StatusOK {
+				if resp.Header.Get("Content-Length") != "16" || resp.Header.Get("ETag") == "" {
+					t.Errorf("Expected the file's Content-Length and ETag, got %v", resp.Header)
+				}
+			}
+		})
+	}
+	if gets != 0 {
+		t.Errorf("Expected HEAD requests not to transfer the file, got %d gets", gets)
+	}
+}
+
+func TestDownloadContentType(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		method   string
+		target   string
+		expected string
+	}{
+		{"GET", "/download?path=reports/q1.pdf", "application/pdf"},
+		{"GET", "/download?path=notes.txt", "text/plain; charset=utf-8"},
+		{"GET", "/download-zip?path=reports", "application/zip"},
+		{"GET", "/objects/archive/photo.jpg", "image/jpeg"},
+		{"GET", WebDAVPrefix + "/reports/q1.pdf", "application/pdf"},
+		{"GET", "/list?path=reports", ""},
+		{"HEAD", "/download?path=reports/q1.pdf", ""},
+	}
+
+	app := fiber.New()
+	var got string
+	app.Use(func(c *fiber.Ctx) error {
+		got = DownloadContentType(c)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	for _, tt := range tests {
+		if _, err := app.Test(httptest.NewRequest(tt.method, tt.target, nil)); err != nil {
+			t.Fatalf("Failed to test %s %s: %v", tt.method, tt.target, err)
+		}
+		if got != tt.expected {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.target, tt.expected, got)
+		}
+	}
+}
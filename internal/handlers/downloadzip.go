@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	pathpkg "path"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// DownloadZipHandler handles GET /download-zip requests for a directory as a ZIP archive
+// The directory is listed recursively and its files are fetched one at a time through a temp file
+// into an archive streamed to the client, so memory use does not grow with the directory. A file
+// that cannot be fetched is logged and left out; with strict=true every file is fetched before the
+// response starts, and the first failure is returned as an error instead.
+func DownloadZipHandler(c *fiber.Ctx) error {
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Missing SMB configuration environment variables: %s", strings.Join(missing, ", ")),
+		})
+	}
+
+	remotePath, err := smb.PrepareRequestPath(c.Query("path", ""), cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	strict := strings.ToLower(c.Query("strict")) == "true"
+
+	ctx := c.UserContext()
+	dir, err := smb.StatFileWithContext(ctx, remotePath, cfg)
+	if err != nil {
+		return sendResponse(c, listErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if !dir.IsDir {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": fmt.Sprintf("path %s: %s", smb.ErrNotDirectory, remotePath),
+		})
+	}
+
+	entries, err := smb.ListFilesRecursiveWithContext(ctx, remotePath, cfg, cfg.MaxListDepth)
+	if err != nil {
+		var partial *smb.PartialListError
+		switch {
+		case !errors.As(err, &partial):
+			return sendResponse(c, listErrorStatus(err), fiber.Map{
+				"detail": err.Error(),
+			})
+		case strict:
+			return sendResponse(c, fiber.StatusForbidden, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		logger.WarnContext(ctx, "ZIP archive of %q is incomplete: %v", remotePath, err)
+	}
+
+	archive := &zipArchive{ctx: ctx, cfg: cfg, root: remotePath, entries: entries}
+	if strict {
+		if err := archive.prefetch(); err != nil {
+			archive.cleanup()
+			return sendResponse(c, downloadErrorStatus(err), fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+	}
+
+	name := pathpkg.Base(remotePath)
+	if remotePath == "" {
+		name = cfg.ShareName
+	}
+	c.Attachment(name + ".zip")
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Context().SetBodyStreamWriter(archive.write)
+	return nil
+}
+
+// zipArchive writes the entries of a recursive listing below root into a ZIP archive
+// Entry names are relative to root, so the archive keeps the directory structure.
+type zipArchive struct {
+	ctx     context.Context
+	cfg     *config.SMBConfig
+	fetched map[string]string // local copies of files fetched before the response, by entry name
+	root    string
+	entries []smb.FileInfo
+}
+
+// prefetch fetches every file to a temp file, stopping at the first failure
+func (a *zipArchive) prefetch() error {
+	a.fetched = make(map[string]string)
+	for _, entry := range a.entries {
+		if entry.IsDir {
+			continue
+		}
+		localPath, err := fetchRemoteFile(a.ctx, pathpkg.Join(a.root, entry.Name), a.cfg)
+		if err != nil {
+			return err
+		}
+		a.fetched[entry.Name] = localPath
+	}
+	return nil
+}
+
+// cleanup removes the prefetched files not yet written to the archive
+func (a *zipArchive) cleanup() {
+	for name, localPath := range a.fetched {
+		removeStagedFile(localPath)
+		delete(a.fetched, name)
+	}
+}
+
+// write streams the archive to the response
+// A failed write means the client went away, so the archive is abandoned unfinished.
+func (a *zipArchive) write(w *bufio.Writer) {
+	defer a.cleanup()
+
+	zw := zip.NewWriter(w)
+	for _, entry := range a.entries {
+		if err := a.add(zw, entry); err != nil {
+			logger.WarnContext(a.ctx, "Abandoning ZIP archive of %q: %v", a.root, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		logger.WarnContext(a.ctx, "Failed to finish ZIP archive of %q: %v", a.root, err)
+	}
+}
+
+// add writes one listing entry to the archive, leaving out a file that cannot be fetched
+// Only an error writing the archive is returned.
+func (a *zipArchive) add(zw *zip.Writer, entry smb.FileInfo) error {
+	header := &zip.FileHeader{Name: entry.Name, Method: zip.Deflate}
+	if entry.ModTime != nil {
+		header.Modified = *entry.ModTime
+	}
+	if entry.IsDir {
+		header.Name += "/"
+		header.Method = zip.Store
+		_, err := zw.CreateHeader(header)
+		return err
+	}
+
+	localPath, ok := a.fetched[entry.Name]
+	if !ok {
+		var err error
+		localPath, err = fetchRemoteFile(a.ctx, pathpkg.Join(a.root, entry.Name), a.cfg)
+		if err != nil {
+			logger.WarnContext(a.ctx, "Leaving %q out of the ZIP archive of %q: %v", entry.Name, a.root, err)
+			return nil
+		}
+	}
+	// The open file stays readable after the removal until it has been copied
+	file, err := os.Open(localPath)
+	removeStagedFile(localPath)
+	delete(a.fetched, entry.Name)
+	if err != nil {
+		logger.WarnContext(a.ctx, "Leaving %q out of the ZIP archive of %q: %v", entry.Name, a.root, err)
+		return nil
+	}
+	defer file.Close()
+
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, file)
+	return err
+}
+
+// fetchRemoteFile downloads a remote file to a new staging file and returns its path
+// The caller removes the file with removeStagedFile.
+func fetchRemoteFile(ctx context.Context, remotePath string, cfg *config.SMBConfig) (string, error) {
+	localPath, err := createStagingFile(pathpkg.Base(remotePath))
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if err := smb.DownloadFileWithContext(ctx, remotePath, localPath, cfg); err != nil {
+		removeStagedFile(localPath)
+		return "", err
+	}
+	return localPath, nil
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newZipMock returns a mock share holding reports/q1.pdf, reports/locked.pdf, which cannot be read,
+// and reports/archive/old.pdf
+func newZipMock(t *testing.T) *smb.MockSmbClientExecutor {
+	t.Helper()
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if m := davGetCommand.FindStringSubmatch(cmd); m != nil {
+			if m[2] == "reports/locked.pdf" {
+				return "NT_STATUS_ACCESS_DENIED opening remote file \\reports\\locked.pdf",
+					fmt.Errorf("smbclient command failed: exit status 1")
+			}
+			return "getting file", os.WriteFile(filepath.Join(m[1], m[3]), []byte("content of "+m[2]), 0600)
+		}
+		switch cmd {
+		case "ls":
+			return "  reports                             D        0  Mon Jan  1 10:00:00 2024\n" +
+				"  readme.txt                          A       12  Mon Jan  1 10:00:00 2024\n", nil
+		case `cd "reports"; ls`:
+			return "  q1.pdf                              A       22  Tue Jan  2 12:30:00 2024\n" +
+				"  locked.pdf                          A       26  Tue Jan  2 12:30:00 2024\n" +
+				"  archive                             D        0  Wed Jan  3 08:00:00 2024\n", nil
+		case `cd "reports/archive"; ls`:
+			return "  old.pdf                             A       31  Mon Jan  1 09:00:00 2024\n", nil
+		}
+		t.Errorf("Unexpected command: %s", cmd)
+		return "", fmt.Errorf("smbclient command failed: exit status 1")
+	}
+	return mock
+}
+
+func TestDownloadZipHandler(t *testing.T) {
+	setupTestSMBEnv()
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	origExec := smb.SetExecutor(newZipMock(t))
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/download-zip", DownloadZipHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/download-zip?path=reports", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("Expected Content-Type application/zip, got %q", contentType)
+	}
+	if disposition := resp.Header.Get("Content-Disposition"); disposition != `attachment; filename="reports.zip"` {
+		t.Errorf("Expected an attachment disposition, got %q", disposition)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Expected a valid ZIP archive: %v", err)
+	}
+	contents := make(map[string]string)
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		contents[file.Name] = string(data)
+	}
+	sort.Strings(names)
+	expected := []string{"archive/", "archive/old.pdf", "q1.pdf"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected entries %v without the unreadable file, got %v", expected, names)
+	}
+	if contents["archive/old.pdf"] != "content of reports/archive/old.pdf" {
+		t.Errorf("Expected archive/old.pdf to hold the remote file, got %q", contents["archive/old.pdf"])
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(tmpDir, tempFilePrefix+"*"))
+	if len(leftovers) != 0 {
+		t.Errorf("Expected temp files to be removed, found %v", leftovers)
+	}
+}
+
+func TestDownloadZipHandler_Errors(t *testing.T) {
+	setupTestSMBEnv()
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	origExec := smb.SetExecutor(newZipMock(t))
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/download-zip", DownloadZipHandler)
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+	}{
+		{"strict with an unreadable file", "/download-zip?path=reports&strict=true", fiber.StatusForbidden},
+		{"file instead of directory", "/download-zip?path=readme.txt", fiber.StatusBadRequest},
+		{"missing directory", "/download-zip?path=missing", fiber.StatusNotFound},
+		{"invalid path", "/download-zip?path=../etc", fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.target, nil))
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+		})
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(tmpDir, tempFilePrefix+"*"))
+	if len(leftovers) != 0 {
+		t.Errorf("Expected prefetched files to be removed after a strict failure, found %v", leftovers)
+	}
+}
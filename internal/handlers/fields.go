@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// listFieldNames are the file entry fields that can be selected with the fields query parameter,
+// in the order they are reported in error messages
+var listFieldNames = []string{
+	"name", "size", "is_dir", "read_only", "hidden", "system", "modified", "modified_unix", "timestamp", "sha256",
+}
+
+// parseListFields parses a comma-separated fields query parameter
+// An empty value selects every field and returns nil.
+func parseListFields(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" || seen[field] {
+			continue
+		}
+		if !isListField(field) {
+			return nil, fmt.Errorf("unknown field: %q (valid fields: %s)", field, strings.Join(listFieldNames, ", "))
+		}
+		seen[field] = true
+		fields = append(fields, field)
+	}
+
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields must name at least one field")
+	}
+	return fields, nil
+}
+
+// isListField reports whether name is a selectable file entry field
+func isListField(name string) bool {
+	for _, field := range listFieldNames {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// projectFileFields reduces each file entry to the selected fields
+// Optional fields that are unset are left out, matching the full entry's encoding.
+func projectFileFields(files []smb.FileInfo, fields []string) []fiber.Map {
+	projected := make([]fiber.Map, 0, len(files))
+	for _, file := range files {
+		entry := fiber.Map{}
+		for _, field := range fields {
+			switch field {
+			case "name":
+				entry["name"] = file.Name
+			case "size":
+				entry["size"] = file.Size
+			case "is_dir":
+				entry["is_dir"] = file.IsDir
+			case "read_only":
+				entry["read_only"] = file.ReadOnly
+			case "hidden":
+				entry["hidden"] = file.Hidden
+			case "system":
+				entry["system"] = file.System
+			case "modified":
+				if file.ModTime != nil {
+					entry["modified"] = file.ModTime
+				}
+			case "modified_unix":
+				if file.ModifiedUnix != 0 {
+					entry["modified_unix"] = file.ModifiedUnix
+				}
+			case "timestamp":
+				if file.Timestamp != "" {
+					entry["timestamp"] = file.Timestamp
+				}
+			case "sha256":
+				if file.SHA256 != "" {
+					entry["sha256"] = file.SHA256
+				}
+			}
+		}
+		projected = append(projected, entry)
+	}
+	return projected
+}
@@ -2,10 +2,20 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 
@@ -14,14 +24,29 @@ import (
 	"github.com/bancey/document-smbrelay-service/internal/smb"
 )
 
+// LivezHandler handles GET /livez requests
+// It reports that the process is up and serving requests without touching the SMB backend,
+// so liveness probes don't restart the service while the share is unreachable.
+func LivezHandler(c *fiber.Ctx) error {
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
+		"status": "ok",
+	})
+}
+
 // HealthHandler handles GET /health requests
+// It reports readiness: 503 when the SMB backend is unreachable, with app_status still ok.
 func HealthHandler(c *fiber.Ctx) error {
-	cfg, missing := config.LoadFromEnv()
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
 
 	if len(missing) > 0 {
 		missingVars := strings.Join(missing, ", ")
 		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
-		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusServiceUnavailable, fiber.Map{
 			"status":               "unhealthy",
 			"app_status":           "ok",
 			"smb_connection":       "not_configured",
@@ -33,67 +58,381 @@ func HealthHandler(c *fiber.Ctx) error {
 	result := smb.CheckHealth(cfg)
 
 	if result.Status == "healthy" {
-		return c.JSON(result)
+		return sendResponse(c, fiber.StatusOK, result)
 	}
 
-	return c.Status(fiber.StatusServiceUnavailable).JSON(result)
+	return sendResponse(c, fiber.StatusServiceUnavailable, result)
 }
 
 // ListHandler handles GET /list requests
 func ListHandler(c *fiber.Ctx) error {
 	// Load configuration
-	cfg, missing := config.LoadFromEnv()
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
 	if len(missing) > 0 {
 		missingVars := strings.Join(missing, ", ")
 		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
 			"detail": errorMsg,
 		})
 	}
 
 	// Get path from query parameter (default to root)
-	path := c.Query("path", "")
+	path, err := smb.PrepareRequestPath(c.Query("path", ""), cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	// Optionally include checksums from .sha256 companion files
+	withChecksums := strings.ToLower(c.Query("with_checksums")) == "true"
+
+	// Optionally project each entry down to the requested fields
+	fields, err := parseListFields(c.Query("fields"))
+	if err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
 
-	// List files with context
-	files, err := smb.ListFilesWithContext(c.UserContext(), path, cfg)
+	// Optionally narrow the listing to names matching a glob pattern
+	filter, err := parseListFilter(c)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	// Sort the listing and return one page of it
+	page, err := parseListPage(c)
+	if err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	// List files with context, walking subdirectories if requested
+	var files []smb.FileInfo
+	var warning string
+	if strings.ToLower(c.Query("recursive")) == "true" {
+		files, err = smb.ListFilesRecursiveWithContext(c.UserContext(), path, cfg, cfg.MaxListDepth)
+		var partial *smb.PartialListError
+		if errors.As(err, &partial) {
+			warning = partial.Error()
+			err = nil
+		}
+	} else {
+		files, err = smb.ListFilesWithContext(c.UserContext(), path, cfg)
+	}
+	if err != nil {
+		return sendResponse(c, listErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	if withChecksums {
+		smb.AttachCompanionChecksums(c.UserContext(), path, files, cfg)
+	}
+	if filter != nil {
+		files = filter.apply(files)
+	}
+	total := len(files)
+	files, hasMore := page.apply(files)
+
+	response := fiber.Map{
+		"path":     path,
+		"files":    files,
+		"total":    total,
+		"has_more": hasMore,
+	}
+	if fields != nil {
+		response["files"] = projectFileFields(files, fields)
+	}
+	if warning != "" {
+		response["warning"] = warning
+	}
+
+	return sendResponse(c, fiber.StatusOK, response)
+}
+
+// listErrorStatus maps a listing error to its HTTP status code
+func listErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, smb.ErrTimeout):
+		return fiber.StatusGatewayTimeout
+	case errors.Is(err, smb.ErrSecurityRefused):
+		return fiber.StatusBadGateway
+	case errors.Is(err, smb.ErrInvalidPath):
+		return fiber.StatusBadRequest
+	case errors.Is(err, smb.ErrNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, smb.ErrAccessDenied), errors.Is(err, smb.ErrPathNotAllowed):
+		return fiber.StatusForbidden
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// pathErrorStatus returns the status for a request path that failed validation
+// A path outside SMB_ALLOWED_PREFIXES is forbidden; any other invalid path is a bad request.
+func pathErrorStatus(err error) int {
+	if errors.Is(err, smb.ErrPathNotAllowed) {
+		return fiber.StatusForbidden
+	}
+	return fiber.StatusBadRequest
+}
+
+// maxBatchListPaths caps the number of directories a single /list/batch request may list
+const maxBatchListPaths = 100
+
+// batchListRequest is the JSON body accepted by POST /list/batch
+type batchListRequest struct {
+	Paths []string `json:"paths"`
+}
+
+// BatchListHandler handles POST /list/batch requests
+// All paths are listed in one SMB session; a path that cannot be listed gets its own
+// error entry instead of failing the whole request.
+func BatchListHandler(c *fiber.Ctx) error {
+	// Load configuration
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		missingVars := strings.Join(missing, ", ")
+		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": errorMsg,
+		})
+	}
+
+	var req batchListRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "request body must be a JSON object with a paths array",
+		})
+	}
+	if len(req.Paths) == 0 {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "paths must contain at least one path",
+		})
+	}
+	if len(req.Paths) > maxBatchListPaths {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": fmt.Sprintf("too many paths: %d exceeds the maximum of %d", len(req.Paths), maxBatchListPaths),
+		})
+	}
+
+	results := make(map[string]fiber.Map, len(req.Paths))
+	// Listings come back in toList order; requested holds the path each was requested as
+	toList := make([]string, 0, len(req.Paths))
+	requested := make([]string, 0, len(req.Paths))
+	failed := 0
+	for _, path := range req.Paths {
+		if _, seen := results[path]; seen {
+			continue
+		}
+		prepared, err := smb.PrepareRequestPath(path, cfg)
+		if err != nil {
+			results[path] = batchListErrorEntry(err)
+			failed++
+			continue
+		}
+		// Placeholder so duplicates are skipped; replaced once the listing returns
+		results[path] = nil
+		requested = append(requested, path)
+		toList = append(toList, prepared)
+	}
+
+	listings, err := smb.ListFilesBatchWithContext(c.UserContext(), toList, cfg)
+	if err != nil {
+		return sendResponse(c, listErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	for i, listing := range listings {
+		if listing.Err != nil {
+			results[requested[i]] = batchListErrorEntry(listing.Err)
+			failed++
+			continue
+		}
+		results[requested[i]] = fiber.Map{"files": listing.Files}
+	}
+
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
+		"results": results,
+		"failed":  failed,
+	})
+}
+
+// batchListErrorEntry builds the per-path error entry of a /list/batch response
+func batchListErrorEntry(err error) fiber.Map {
+	return fiber.Map{
+		"detail":      err.Error(),
+		"status_code": listErrorStatus(err),
+	}
+}
+
+// StaleHandler handles GET /stale requests
+// It recursively lists files older than the older_than window as candidates for
+// retention cleanup; nothing is deleted.
+func StaleHandler(c *fiber.Ctx) error {
+	// Load configuration
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		missingVars := strings.Join(missing, ", ")
+		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": errorMsg,
+		})
+	}
+
+	olderThanStr := c.Query("older_than")
+	if olderThanStr == "" {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "older_than is required",
+		})
+	}
+	olderThan, err := parseRetentionDuration(olderThanStr)
+	if err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	path, err := smb.PrepareRequestPath(c.Query("path", ""), cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+
+	files, err := smb.FindStaleFilesWithContext(c.UserContext(), path, cutoff, cfg)
+	if err != nil {
+		if errors.Is(err, smb.ErrNotFound) {
+			return sendResponse(c, fiber.StatusNotFound, fiber.Map{
 				"detail": err.Error(),
 			})
 		}
-		if strings.Contains(err.Error(), "access denied") {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		if errors.Is(err, smb.ErrAccessDenied) {
+			return sendResponse(c, fiber.StatusForbidden, fiber.Map{
 				"detail": err.Error(),
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
 			"detail": err.Error(),
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"path":  path,
-		"files": files,
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
+		"path":       path,
+		"older_than": olderThanStr,
+		"cutoff":     cutoff.UTC().Format(time.RFC3339),
+		"files":      files,
 	})
 }
 
+// parseRetentionDuration parses a retention window such as "30d", "2w" or "12h"
+// Day and week suffixes are accepted in addition to Go duration syntax
+func parseRetentionDuration(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(value, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(value, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	var d time.Duration
+	if unit > 0 {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid older_than duration: %s", value)
+		}
+		d = time.Duration(n) * unit
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid older_than duration: %s", value)
+		}
+		d = parsed
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("older_than must be a positive duration: %s", value)
+	}
+	return d, nil
+}
+
+// defaultRemotePath returns remote_path, or the SMB_DEFAULT_UPLOAD_PATH directory when it is absent
+// The directory ends with a slash, so the uploaded file keeps its original filename in it.
+func defaultRemotePath(remotePath string, cfg *config.SMBConfig) string {
+	if remotePath == "" && cfg.DefaultUploadPath != "" {
+		return strings.TrimRight(cfg.DefaultUploadPath, "/\\") + "/"
+	}
+	return remotePath
+}
+
+// streamsUpload reports whether an upload request is streamed rather than staged
+// Async uploads need a staged copy.
+func streamsUpload(c *fiber.Ctx) bool {
+	return config.LoadServerConfig().StreamUploads && c.Query("async") != "true" &&
+		strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm)
+}
+
+// StreamsRequestBody reports whether a request's handler reads its body as a stream and bounds it
+// itself, which only streamed uploads do
+func StreamsRequestBody(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPost && c.Path() == "/upload" && streamsUpload(c)
+}
+
 // UploadHandler handles POST /upload requests
 func UploadHandler(c *fiber.Ctx) error {
 	// Load configuration
-	cfg, missing := config.LoadFromEnv()
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
 	if len(missing) > 0 {
 		missingVars := strings.Join(missing, ", ")
 		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
 			"detail": errorMsg,
 		})
 	}
+	if cfg.ReadOnly {
+		return sendReadOnly(c)
+	}
+
+	// Pipe the file straight to the share when streaming is enabled
+	if streamsUpload(c) {
+		return streamUpload(c, cfg)
+	}
 
 	// Get form parameters
-	remotePath := c.FormValue("remote_path")
+	remotePath := defaultRemotePath(c.FormValue("remote_path"), cfg)
 	if remotePath == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
 			"detail": "remote_path is required",
 		})
 	}
@@ -101,332 +440,595 @@ func UploadHandler(c *fiber.Ctx) error {
 	overwriteStr := c.FormValue("overwrite")
 	overwrite := overwriteStr == "true" || overwriteStr == "1"
 
+	readOnlyStr := c.FormValue("read_only")
+	readOnly := readOnlyStr == "true" || readOnlyStr == "1"
+
+	modifiedTime, err := parseModifiedTime(c.FormValue("modified_time"))
+	if err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	expectedSHA256 := strings.ToLower(strings.TrimSpace(c.FormValue("expected_sha256")))
+	if expectedSHA256 != "" && !isSHA256Hex(expectedSHA256) {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "expected_sha256 must be a 64-character hex SHA-256 digest",
+		})
+	}
+
 	// Get uploaded file
 	file, err := c.FormFile("file")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
 			"detail": "wfile is required",
 		})
 	}
 
+	// Several file parts are uploaded one by one with a result per file
+	if form, err := c.MultipartForm(); err == nil && len(form.File["file"]) > 1 {
+		if expectedSHA256 != "" || c.Query("async") == "true" {
+			return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+				"detail": "expected_sha256 and async are only supported when uploading a single file",
+			})
+		}
+		opts := uploadOptions{overwrite: overwrite, readOnly: readOnly, modifiedTime: modifiedTime}
+		return uploadMultipleFiles(c, form, opts, cfg)
+	}
+
 	// If remote_path is a directory (ends with / or \), append the uploaded filename
 	if strings.HasSuffix(remotePath, "/") || strings.HasSuffix(remotePath, "\\") {
 		remotePath = filepath.Join(remotePath, filepath.Base(file.Filename))
 	}
 
-	// Save uploaded file to temp location
-	tmpDir := os.TempDir()
-	tmpPath := filepath.Join(tmpDir, fmt.Sprintf("smb-upload-%s", filepath.Base(file.Filename)))
+	remotePath, err = smb.PrepareUploadPath(remotePath, cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	if detail := uploadTooLargeDetail(file.Size); detail != "" {
+		return sendResponse(c, fiber.StatusRequestEntityTooLarge, fiber.Map{
+			"detail": detail,
+		})
+	}
+	// Only the file's content decides its type, never the Content-Type the client sent
+	typeDetail, err := checkUploadedFileType(file)
+	if err != nil {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Failed to read uploaded file: %v", err),
+		})
+	}
+	if typeDetail != "" {
+		return sendResponse(c, fiber.StatusUnsupportedMediaType, fiber.Map{
+			"detail": typeDetail,
+		})
+	}
+
+	// Save uploaded file to a temp location of its own, as concurrent uploads may share a filename
+	tmpPath, err := createStagingFile(file.Filename)
+	if err != nil {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Failed to save uploaded file: %v", err),
+		})
+	}
 
 	err = c.SaveFile(file, tmpPath)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		removeStagedFile(tmpPath)
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
 			"detail": fmt.Sprintf("Failed to save uploaded file: %v", err),
 		})
 	}
-	defer func() {
-		if removeErr := os.Remove(tmpPath); removeErr != nil {
-			logger.Error("Failed to remove temp file %s: %v", tmpPath, removeErr)
+
+	// Reject a file that was corrupted on its way to the relay before it reaches the share
+	if expectedSHA256 != "" {
+		if detail := checkReceivedChecksum(tmpPath, expectedSHA256); detail != "" {
+			removeStagedFile(tmpPath)
+			return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+				"detail": detail,
+			})
+		}
+	}
+
+	opts := uploadOptions{
+		remotePath:   remotePath,
+		overwrite:    overwrite,
+		readOnly:     readOnly,
+		modifiedTime: modifiedTime,
+	}
+
+	// Optionally report what the server received to help debug client encoding issues
+	var echo fiber.Map
+	if c.Query("echo") == "true" {
+		echo = uploadEcho(file, tmpPath, remotePath, cfg)
+	}
+
+	// In async mode the SMB write continues in the background after the 202 response,
+	// so request-scoped values are copied and the staged file is cleaned up by the job
+	if c.Query("async") == "true" {
+		opts.remotePath = strings.Clone(remotePath)
+		job := uploadJobs.create(opts.remotePath, config.LoadServerConfig().UploadJobTTL)
+		ctx := context.WithoutCancel(c.UserContext())
+
+		// Tracked as one operation so graceful shutdown waits for the whole upload
+		endOperation := smb.BeginOperation()
+		go func() {
+			defer endOperation()
+			defer removeStagedFile(tmpPath)
+			uploadJobs.start(job.ID)
+			status, body := relayUpload(ctx, tmpPath, opts, cfg)
+			uploadJobs.finish(job.ID, status, body)
+		}()
+
+		accepted := fiber.Map{
+			"status":      jobStatusPending,
+			"job_id":      job.ID,
+			"remote_path": opts.remotePath,
+			"status_url":  "/jobs/" + job.ID,
+		}
+		if echo != nil {
+			accepted["echo"] = echo
+		}
+		return sendResponse(c, fiber.StatusAccepted, accepted)
+	}
+
+	defer removeStagedFile(tmpPath)
+
+	status, body := relayUpload(c.UserContext(), tmpPath, opts, cfg)
+	if echo != nil {
+		body["echo"] = echo
+	}
+	return sendResponse(c, status, body)
+}
+
+// isSHA256Hex reports whether s is a hex-encoded SHA-256 digest
+func isSHA256Hex(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// checkReceivedChecksum compares the staged upload with the digest the client expects
+// It returns the error detail for a mismatch, or an empty string when the file matches.
+func checkReceivedChecksum(tmpPath, expected string) string {
+	actual, err := smb.FileSHA256(tmpPath)
+	if err != nil {
+		return fmt.Sprintf("failed to hash uploaded file: %v", err)
+	}
+	if actual != expected {
+		return fmt.Sprintf("checksum mismatch: received file has sha256 %s, expected %s", actual, expected)
+	}
+	return ""
+}
+
+// uploadEcho describes an uploaded file as received by the server
+// The content type is sniffed from the staged file rather than trusted from the client.
+func uploadEcho(file *multipart.FileHeader, tmpPath, remotePath string, cfg *config.SMBConfig) fiber.Map {
+	echo := fiber.Map{
+		"filename":              file.Filename,
+		"declared_content_type": file.Header.Get(fiber.HeaderContentType),
+		"size":                  file.Size,
+		"resolved_path":         smb.ResolveRemotePath(remotePath, cfg),
+	}
+
+	if f, err := os.Open(tmpPath); err == nil {
+		defer f.Close()
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(f, head)
+		echo["content_type"] = http.DetectContentType(head[:n])
+	}
+
+	return echo
+}
+
+// uploadOptions holds the per-request options for relaying a staged upload
+type uploadOptions struct {
+	modifiedTime time.Time // Last write time to give the remote file (zero leaves the server's time)
+	remotePath   string
+	overwrite    bool
+	readOnly     bool
+}
+
+// parseModifiedTime parses the modified_time upload field, an RFC 3339 timestamp
+// An empty value returns the zero time.
+func parseModifiedTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	modified, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf(
+			"modified_time must be an RFC 3339 timestamp, e.g. 2024-03-01T09:30:00Z: %s", value)
+	}
+	return modified, nil
+}
+
+// relayUpload writes a staged file to the SMB share and returns the HTTP status and response body
+func relayUpload(ctx context.Context, tmpPath string, opts uploadOptions, cfg *config.SMBConfig) (int, fiber.Map) {
+	var digest string
+	if cfg.Dedup {
+		var duplicate string
+		digest, duplicate = findDuplicate(ctx, tmpPath, cfg)
+		if duplicate != "" {
+			logger.InfoContext(ctx, "Skipping upload to %s: identical content already at %s", opts.remotePath, duplicate)
+			return fiber.StatusOK, fiber.Map{
+				"status":         "ok",
+				"remote_path":    duplicate,
+				"requested_path": opts.remotePath,
+				"deduplicated":   true,
+			}
 		}
-	}()
+	}
 
 	// Upload to SMB share with context
-	err = smb.UploadFileWithContext(c.UserContext(), tmpPath, remotePath, cfg, overwrite)
+	err := smb.UploadFileWithContext(ctx, tmpPath, opts.remotePath, cfg, opts.overwrite)
+	status, body := uploadResult(ctx, err, opts, cfg)
+	// Recorded once the modification time is set, as the index entry is stamped with it
+	if status == fiber.StatusOK && digest != "" {
+		if err := smb.RecordDigest(ctx, digest, opts.remotePath, cfg); err != nil {
+			logger.WarnContext(ctx, "Uploaded %s but could not add it to the deduplication index: %v", opts.remotePath, err)
+		}
+	}
+	return status, body
+}
+
+// findDuplicate hashes a staged upload and looks its digest up in the deduplication index
+// It returns the digest and the path of a file on the share with the same content, if any.
+// Deduplication only saves a transfer, so a failure is logged and the file uploaded as usual.
+func findDuplicate(ctx context.Context, tmpPath string, cfg *config.SMBConfig) (digest, duplicate string) {
+	digest, err := smb.FileSHA256(tmpPath)
 	if err != nil {
+		logger.WarnContext(ctx, "Cannot hash %s for deduplication: %v", tmpPath, err)
+		return "", ""
+	}
+	duplicate, err = smb.LookupDuplicate(ctx, digest, cfg)
+	if err != nil {
+		logger.WarnContext(ctx, "Deduplication index lookup for sha256 %s failed: %v", digest, err)
+		return digest, ""
+	}
+	return digest, duplicate
+}
+
+// uploadTooLargeDetail returns the 413 detail for a file of size bytes over SMB_MAX_UPLOAD_BYTES, or ""
+func uploadTooLargeDetail(size int64) string {
+	limit := config.LoadServerConfig().MaxUploadBytes
+	if limit <= 0 || size <= limit {
+		return ""
+	}
+	return fmt.Sprintf("file is too large: %d bytes exceeds the maximum upload size of %d bytes", size, limit)
+}
+
+// uploadResult builds the HTTP status and response body for a finished upload
+// Successful uploads get their modification time and read-only attribute here when requested.
+// Every upload path (staged, streamed, multi-file and resumable) finishes here, so both are applied
+// and reported as warnings in one place; smb's upload functions can only return an error.
+func uploadResult(ctx context.Context, err error, opts uploadOptions, cfg *config.SMBConfig) (int, fiber.Map) {
+	if err != nil {
+		if errors.Is(err, smb.ErrTimeout) {
+			return fiber.StatusGatewayTimeout, fiber.Map{"detail": err.Error()}
+		}
+		if errors.Is(err, smb.ErrSecurityRefused) {
+			return fiber.StatusBadGateway, fiber.Map{"detail": err.Error()}
+		}
 		// Check if it's a file exists error
-		if strings.Contains(err.Error(), "already exists") {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"detail": err.Error(),
-			})
+		if errors.Is(err, smb.ErrFileExists) {
+			return fiber.StatusConflict, fiber.Map{"detail": err.Error()}
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"detail": err.Error(),
-		})
+		if errors.Is(err, smb.ErrIsDirectory) {
+			return fiber.StatusBadRequest, fiber.Map{"detail": err.Error()}
+		}
+		if errors.Is(err, smb.ErrInsufficientStorage) {
+			return fiber.StatusInsufficientStorage, fiber.Map{"detail": err.Error()}
+		}
+		if errors.Is(err, smb.ErrParentNotFound) {
+			return fiber.StatusNotFound, fiber.Map{"detail": err.Error()}
+		}
+		return fiber.StatusInternalServerError, fiber.Map{"detail": err.Error()}
 	}
 
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"status":      "ok",
-		"remote_path": remotePath,
-	})
+		"remote_path": opts.remotePath,
+	}
+
+	// Restore the document's own modification time before any read-only attribute is set.
+	// Like the attribute it is best-effort, as the file is already uploaded.
+	if !opts.modifiedTime.IsZero() {
+		if err := smb.SetModifiedTimeWithContext(ctx, opts.remotePath, opts.modifiedTime, cfg); err != nil {
+			logger.WarnContext(ctx, "Uploaded %s but could not set its modification time: %v", opts.remotePath, err)
+			response["modified_time"] = false
+			addUploadWarning(response, fmt.Sprintf("modification time not applied: %v", err))
+		} else {
+			response["modified_time"] = true
+		}
+	}
+
+	// Setting the read-only attribute is best-effort: the file is already uploaded,
+	// so a server that ignores DOS attributes is reported rather than failing the request
+	if opts.readOnly {
+		if err := smb.SetReadOnlyWithContext(ctx, opts.remotePath, cfg); err != nil {
+			logger.WarnContext(ctx, "Uploaded %s but could not set read-only attribute: %v", opts.remotePath, err)
+			response["read_only"] = false
+			addUploadWarning(response, fmt.Sprintf("read-only attribute not applied: %v", err))
+		} else {
+			response["read_only"] = true
+		}
+	}
+
+	return fiber.StatusOK, response
+}
+
+// addUploadWarning adds a warning to an upload response, joining it to any earlier one
+func addUploadWarning(response fiber.Map, warning string) {
+	if previous, ok := response["warning"].(string); ok {
+		warning = previous + "; " + warning
+	}
+	response["warning"] = warning
+}
+
+// removeStagedFile deletes a staged upload file and releases it from janitor protection
+func removeStagedFile(tmpPath string) {
+	defer releaseTempFile(tmpPath)
+	if removeErr := os.Remove(tmpPath); removeErr != nil {
+		logger.Error("Failed to remove temp file %s: %v", tmpPath, removeErr)
+	}
 }
 
 // DeleteHandler handles DELETE /delete requests
 func DeleteHandler(c *fiber.Ctx) error {
 	// Load configuration
-	cfg, missing := config.LoadFromEnv()
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
 	if len(missing) > 0 {
 		missingVars := strings.Join(missing, ", ")
 		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
 			"detail": errorMsg,
 		})
 	}
+	if cfg.ReadOnly {
+		return sendReadOnly(c)
+	}
 
 	// Get path from query parameter
 	remotePath := c.Query("path")
 	if remotePath == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
 			"detail": "path is required",
 		})
 	}
 
+	remotePath, err = smb.PrepareRequestPath(remotePath, cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
 	// Delete file from SMB share with context
-	err := smb.DeleteFileWithContext(c.UserContext(), remotePath, cfg)
+	err = smb.DeleteFileWithContext(c.UserContext(), remotePath, cfg)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		if errors.Is(err, smb.ErrTimeout) {
+			return sendResponse(c, fiber.StatusGatewayTimeout, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrSecurityRefused) {
+			return sendResponse(c, fiber.StatusBadGateway, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrNotFound) {
+			return sendResponse(c, fiber.StatusNotFound, fiber.Map{
 				"detail": err.Error(),
 			})
 		}
-		if strings.Contains(err.Error(), "access denied") {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		if errors.Is(err, smb.ErrAccessDenied) {
+			return sendResponse(c, fiber.StatusForbidden, fiber.Map{
 				"detail": err.Error(),
 			})
 		}
-		if strings.Contains(err.Error(), "invalid remote path") || strings.Contains(err.Error(), "cannot delete directory") {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		if errors.Is(err, smb.ErrInvalidPath) || errors.Is(err, smb.ErrIsDirectory) {
+			return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
 				"detail": err.Error(),
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
 			"detail": err.Error(),
 		})
 	}
 
-	return c.JSON(fiber.Map{
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
 		"status": "ok",
 		"path":   remotePath,
 	})
 }
 
-// GetOpenAPISpec returns the OpenAPI specification
-func GetOpenAPISpec(c *fiber.Ctx) error {
-	spec := map[string]interface{}{
-		"openapi": "3.0.0",
-		"info": map[string]interface{}{
-			"title":       "Document SMB Relay Service",
-			"version":     "1.0.0",
-			"description": "A minimal service that accepts file uploads via HTTP and writes them directly to SMB shares",
-		},
-		"paths": map[string]interface{}{
-			"/health": map[string]interface{}{
-				"get": map[string]interface{}{
-					"summary":     "Health check endpoint",
-					"description": "Verifies application responsiveness and SMB connectivity",
-					"responses": map[string]interface{}{
-						"200": map[string]interface{}{
-							"description": "Application and SMB server are healthy",
-						},
-						"503": map[string]interface{}{
-							"description": "Application is unhealthy or SMB server is inaccessible",
-						},
-					},
-				},
-			},
-			"/list": map[string]interface{}{
-				"get": map[string]interface{}{
-					"summary":     "List files and folders",
-					"description": "Lists files and folders at a given path on the SMB share",
-					"parameters": []map[string]interface{}{
-						{
-							"name":        "path",
-							"in":          "query",
-							"description": "Path within the SMB share (defaults to root)",
-							"required":    false,
-							"schema": map[string]interface{}{
-								"type":    "string",
-								"default": "",
-							},
-						},
-					},
-					"responses": map[string]interface{}{
-						"200": map[string]interface{}{
-							"description": "List of files and folders",
-							"content": map[string]interface{}{
-								"application/json": map[string]interface{}{
-									"schema": map[string]interface{}{
-										"type": "object",
-										"properties": map[string]interface{}{
-											"path": map[string]interface{}{
-												"type": "string",
-											},
-											"files": map[string]interface{}{
-												"type": "array",
-												"items": map[string]interface{}{
-													"type": "object",
-													"properties": map[string]interface{}{
-														"name": map[string]interface{}{
-															"type": "string",
-														},
-														"size": map[string]interface{}{
-															"type": "integer",
-														},
-														"is_dir": map[string]interface{}{
-															"type": "boolean",
-														},
-														"timestamp": map[string]interface{}{
-															"type": "string",
-														},
-													},
-												},
-											},
-										},
-									},
-								},
-							},
-						},
-						"404": map[string]interface{}{
-							"description": "Path not found",
-						},
-						"403": map[string]interface{}{
-							"description": "Access denied",
-						},
-						"500": map[string]interface{}{
-							"description": "Server error",
-						},
-					},
-				},
-			},
-			"/upload": map[string]interface{}{
-				"post": map[string]interface{}{
-					"summary":     "Upload file to SMB share",
-					"description": "Accepts multipart/form-data and writes file to SMB share",
-					"requestBody": map[string]interface{}{
-						"required": true,
-						"content": map[string]interface{}{
-							"multipart/form-data": map[string]interface{}{
-								"schema": map[string]interface{}{
-									"type": "object",
-									"properties": map[string]interface{}{
-										"file": map[string]interface{}{
-											"type":        "string",
-											"format":      "binary",
-											"description": "The file to upload",
-										},
-										"remote_path": map[string]interface{}{
-											"type": "string",
-											"description": `Path within the SMB share.
-											 If it ends with / or \\, the uploaded filename will be automatically appended.`,
-										},
-										"overwrite": map[string]interface{}{
-											"type":        "boolean",
-											"description": "Whether to overwrite existing files",
-											"default":     false,
-										},
-									},
-									"required": []string{"file", "remote_path"},
-								},
-							},
-						},
-					},
-					"responses": map[string]interface{}{
-						"200": map[string]interface{}{
-							"description": "Upload successful",
-						},
-						"409": map[string]interface{}{
-							"description": "File exists and overwrite is false",
-						},
-						"500": map[string]interface{}{
-							"description": "Upload failed",
-						},
-					},
-				},
-			},
-			"/delete": map[string]interface{}{
-				"delete": map[string]interface{}{
-					"summary":     "Delete file from SMB share",
-					"description": "Deletes a file at the specified path on the SMB share",
-					"parameters": []map[string]interface{}{
-						{
-							"name":        "path",
-							"in":          "query",
-							"description": "Path to the file within the SMB share",
-							"required":    true,
-							"schema": map[string]interface{}{
-								"type": "string",
-							},
-						},
-					},
-					"responses": map[string]interface{}{
-						"200": map[string]interface{}{
-							"description": "File deleted successfully",
-							"content": map[string]interface{}{
-								"application/json": map[string]interface{}{
-									"schema": map[string]interface{}{
-										"type": "object",
-										"properties": map[string]interface{}{
-											"status": map[string]interface{}{
-												"type": "string",
-											},
-											"path": map[string]interface{}{
-												"type": "string",
-											},
-										},
-									},
-								},
-							},
-						},
-						"400": map[string]interface{}{
-							"description": "Invalid path or attempting to delete directory",
-						},
-						"403": map[string]interface{}{
-							"description": "Access denied",
-						},
-						"404": map[string]interface{}{
-							"description": "File not found",
-						},
-						"500": map[string]interface{}{
-							"description": "Server error",
-						},
-					},
-				},
-			},
-		},
-	}
-
-	return c.JSON(spec)
+// mkdirRequest is the JSON body accepted by POST /mkdir
+type mkdirRequest struct {
+	Path string `json:"path"`
 }
 
-// ServeSwaggerUI serves a simple Swagger UI HTML page
-func ServeSwaggerUI(c *fiber.Ctx) error {
-	html := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Document SMB Relay Service - Swagger UI</title>
-    <link rel="stylesheet" type="text/css" href="https://unpkg.com/swagger-ui-dist@5.9.0/swagger-ui.css">
-    <style>
-        body {
-            margin: 0;
-            padding: 0;
-        }
-    </style>
-</head>
-<body>
-    <div id="swagger-ui"></div>
-    <script src="https://unpkg.com/swagger-ui-dist@5.9.0/swagger-ui-bundle.js"></script>
-    <script src="https://unpkg.com/swagger-ui-dist@5.9.0/swagger-ui-standalone-preset.js"></script>
-    <script>
-        window.onload = function() {
-            SwaggerUIBundle({
-                url: "/openapi.json",
-                dom_id: '#swagger-ui',
-                deepLinking: true,
-                presets: [
-                    SwaggerUIBundle.presets.apis,
-                    SwaggerUIStandalonePreset
-                ],
-                plugins: [
-                    SwaggerUIBundle.plugins.DownloadUrl
-                ],
-                layout: "StandaloneLayout"
-            });
-        };
-    </script>
-</body>
-</html>`
-
-	c.Set("Content-Type", "text/html")
-	return c.SendString(html)
+// MkdirHandler handles POST /mkdir requests
+// Missing parent directories are created too, and an existing directory is not an error.
+func MkdirHandler(c *fiber.Ctx) error {
+	// Load configuration
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		missingVars := strings.Join(missing, ", ")
+		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": errorMsg,
+		})
+	}
+	if cfg.ReadOnly {
+		return sendReadOnly(c)
+	}
+
+	var req mkdirRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "request body must be a JSON object with a path",
+		})
+	}
+	if req.Path == "" {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "path is required",
+		})
+	}
+
+	remotePath, err := smb.PrepareRequestPath(req.Path, cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	// Create directory on SMB share with context
+	err = smb.CreateDirectoryWithContext(c.UserContext(), remotePath, cfg)
+	if err != nil {
+		if errors.Is(err, smb.ErrTimeout) {
+			return sendResponse(c, fiber.StatusGatewayTimeout, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrSecurityRefused) {
+			return sendResponse(c, fiber.StatusBadGateway, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrAccessDenied) {
+			return sendResponse(c, fiber.StatusForbidden, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrInvalidPath) {
+			return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrNotDirectory) {
+			return sendResponse(c, fiber.StatusConflict, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
+		"status": "ok",
+		"path":   remotePath,
+	})
+}
+
+// moveRequest is the JSON body accepted by POST /move
+type moveRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// MoveHandler handles POST /move requests
+func MoveHandler(c *fiber.Ctx) error {
+	// Load configuration
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		missingVars := strings.Join(missing, ", ")
+		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": errorMsg,
+		})
+	}
+	if cfg.ReadOnly {
+		return sendReadOnly(c)
+	}
+
+	var req moveRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "request body must be a JSON object with source and destination",
+		})
+	}
+	if req.Source == "" || req.Destination == "" {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "source and destination are required",
+		})
+	}
+
+	source, err := smb.PrepareRequestPath(req.Source, cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	destination, err := smb.PrepareRequestPath(req.Destination, cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	// Move file on SMB share with context
+	err = smb.MoveFileWithContext(c.UserContext(), source, destination, cfg)
+	if err != nil {
+		if errors.Is(err, smb.ErrTimeout) {
+			return sendResponse(c, fiber.StatusGatewayTimeout, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrSecurityRefused) {
+			return sendResponse(c, fiber.StatusBadGateway, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrFileExists) {
+			return sendResponse(c, fiber.StatusConflict, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrNotFound) || errors.Is(err, smb.ErrParentNotFound) {
+			return sendResponse(c, fiber.StatusNotFound, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrAccessDenied) {
+			return sendResponse(c, fiber.StatusForbidden, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if errors.Is(err, smb.ErrInvalidPath) {
+			return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
+		"status":      "ok",
+		"source":      source,
+		"destination": destination,
+	})
 }
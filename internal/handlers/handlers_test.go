@@ -2,15 +2,27 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
 )
 
 func TestHealthHandler_MissingConfig(t *testing.T) {
@@ -36,6 +48,55 @@ func TestHealthHandler_MissingConfig(t *testing.T) {
 	}
 }
 
+func TestLivezHandler_DeadBackend(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return "Connection to testserver failed (Error NT_STATUS_HOST_UNREACHABLE)",
+			fmt.Errorf("smbclient command failed: exit status 1")
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/livez", LivezHandler)
+	app.Get("/health", HealthHandler)
+
+	// Liveness doesn't depend on the backend
+	resp, err := app.Test(httptest.NewRequest("GET", "/livez", nil))
+	if err != nil {
+		t.Fatalf("Failed to test livez endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected /livez status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if strings.TrimSpace(string(body)) != `{"status":"ok"}` {
+		t.Errorf("Expected {\"status\":\"ok\"}, got: %s", string(body))
+	}
+	if mock.CallCount != 0 {
+		t.Errorf("Expected /livez not to run smbclient, got %d calls", mock.CallCount)
+	}
+
+	// Readiness reports the dead backend while the app itself is ok
+	resp, err = app.Test(httptest.NewRequest("GET", "/health", nil))
+	if err != nil {
+		t.Fatalf("Failed to test health endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("Expected /health status %d, got %d", fiber.StatusServiceUnavailable, resp.StatusCode)
+	}
+	var result smb.HealthCheckResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if result.Status != "unhealthy" || result.AppStatus != "ok" {
+		t.Errorf("Expected an unhealthy backend with app_status ok, got status %q, app_status %q",
+			result.Status, result.AppStatus)
+	}
+}
+
 func TestHealthHandler_WithConfig(t *testing.T) {
 	// Set up test environment variables
 	os.Clearenv()
@@ -68,6 +129,46 @@ func TestHealthHandler_WithConfig(t *testing.T) {
 	}
 }
 
+func TestHandlers_SmbClientNotInstalled(t *testing.T) {
+	setupTestSMBEnv()
+	missingBinary := filepath.Join(t.TempDir(), "smbclient")
+	os.Setenv("SMBCLIENT_PATH", missingBinary)
+
+	origExec := smb.SetExecutor(&smb.DefaultSmbClientExecutor{BinaryPath: missingBinary})
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/health", HealthHandler)
+	app.Get("/list", ListHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/health", nil))
+	if err != nil {
+		t.Fatalf("Failed to test health endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", fiber.StatusServiceUnavailable, resp.StatusCode)
+	}
+	var health smb.HealthCheckResult
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if health.SMBClientAvailable {
+		t.Errorf("Expected smbclient_available false, got %+v", health)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/list", nil))
+	if err != nil {
+		t.Fatalf("Failed to test list endpoint: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d: %s", fiber.StatusInternalServerError, resp.StatusCode, string(body))
+	}
+	if !strings.Contains(string(body), "smbclient binary not found") {
+		t.Errorf("Expected a clear missing binary message, got: %s", string(body))
+	}
+}
+
 func TestUploadHandler_MissingConfig(t *testing.T) {
 	// Clear all SMB environment variables
 	os.Clearenv()
@@ -1267,3 +1368,1878 @@ func TestUploadHandler_AutoFilename_SpecialCharacters(t *testing.T) {
 		})
 	}
 }
+
+// TestUploadHandler_RemotePathIsDirectory tests that uploading onto an existing directory returns 400
+func TestUploadHandler_RemotePathIsDirectory(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if strings.HasPrefix(args[len(args)-1], "ls ") {
+			return "  reports                             D        0  Mon Jan  1 00:00:00 2024\n", nil
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	req := newUploadRequest(t, "/upload", "report.pdf", []byte("test content"), map[string]string{
+		"remote_path": "inbox/reports",
+		"overwrite":   "true",
+	})
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test upload: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(respBody), "remote path is a directory") {
+		t.Errorf("Expected directory error message, got: %s", string(respBody))
+	}
+}
+
+// setupTestSMBEnv sets the SMB environment variables required by the handlers
+func setupTestSMBEnv() {
+	os.Clearenv()
+	os.Setenv("SMB_SERVER_NAME", "testserver")
+	os.Setenv("SMB_SERVER_IP", "127.0.0.1")
+	os.Setenv("SMB_SHARE_NAME", "testshare")
+	os.Setenv("SMB_USERNAME", "testuser")
+	os.Setenv("SMB_PASSWORD", "testpass")
+	os.Setenv("SMB_MAX_RETRIES", "0")
+}
+
+// newUploadRequest builds a multipart upload request with a file part and the given form fields
+func newUploadRequest(t *testing.T, target, filename string, content []byte, fields map[string]string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	fileWriter, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	if _, err := fileWriter.Write(content); err != nil {
+		t.Fatalf("Failed to write form file: %v", err)
+	}
+
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			t.Fatalf("Failed to write form field %s: %v", key, err)
+		}
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", target, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// TestUploadHandler_ConcurrentSameFilename tests that concurrent uploads of files with the same name
+// are staged in distinct temp files, so each relay sends its own content
+func TestUploadHandler_ConcurrentSameFilename(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	putCommand := regexp.MustCompile(`^lcd "(.*)"; put "(.*)" "(.*)"$`)
+	var mu sync.Mutex
+	staged := make(map[string]string)  // remote path -> staged temp file
+	relayed := make(map[string]string) // remote path -> content sent to the share
+	bothStarted := make(chan struct{}, 2)
+
+	mock := smb.SetupSuccessfulMock()
+	successful := mock.ExecuteFunc
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		m := putCommand.FindStringSubmatch(args[len(args)-1])
+		if m == nil {
+			return successful(args)
+		}
+
+		// Hold each put until both uploads have staged their files
+		bothStarted <- struct{}{}
+		for deadline := time.Now().Add(5 * time.Second); len(bothStarted) < 2 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+
+		localPath := filepath.Join(m[1], m[2])
+		content, err := os.ReadFile(localPath)
+		mu.Lock()
+		defer mu.Unlock()
+		staged[m[3]] = localPath
+		relayed[m[3]] = string(content)
+		return "putting file", err
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	contents := map[string]string{"inbox/a/report.pdf": "first report", "inbox/b/report.pdf": "second report"}
+	var wg sync.WaitGroup
+	for remotePath, content := range contents {
+		req := newUploadRequest(t, "/upload", "../../report.pdf", []byte(content), map[string]string{
+			"remote_path": remotePath,
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := app.Test(req, 5000)
+			if err != nil {
+				t.Errorf("Failed to test upload: %v", err)
+				return
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				t.Errorf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+			}
+		}()
+	}
+	wg.Wait()
+
+	first, second := staged["inbox/a/report.pdf"], staged["inbox/b/report.pdf"]
+	if first == "" || first == second {
+		t.Errorf("Expected distinct staged files, got %q and %q", first, second)
+	}
+	for _, stagedPath := range []string{first, second} {
+		if filepath.Dir(stagedPath) != os.Getenv("TMPDIR") || !strings.HasSuffix(stagedPath, "-report.pdf") {
+			t.Errorf("Expected a staged file named after the upload in the temp directory, got %q", stagedPath)
+		}
+	}
+	for remotePath, content := range contents {
+		if relayed[remotePath] != content {
+			t.Errorf("Expected %s to receive %q, got %q", remotePath, content, relayed[remotePath])
+		}
+	}
+}
+
+// TestUploadHandler_ReadOnly tests that read_only=true issues a setmode command after the put
+func TestUploadHandler_ReadOnly(t *testing.T) {
+	setupTestSMBEnv()
+
+	var commands []string
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		commands = append(commands, cmd)
+		if strings.Contains(cmd, "put") {
+			return "putting file report.pdf as inbox/report.pdf\n", nil
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	req := newUploadRequest(t, "/upload", "report.pdf", []byte("test content"), map[string]string{
+		"remote_path": "inbox/report.pdf",
+		"overwrite":   "true",
+		"read_only":   "true",
+	})
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test upload: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(respBody), `"read_only":true`) {
+		t.Errorf("Expected read_only true in response, got: %s", string(respBody))
+	}
+
+	if len(commands) == 0 || commands[len(commands)-1] != `setmode "inbox/report.pdf" +r` {
+		t.Errorf("Expected setmode to be the final command, got: %v", commands)
+	}
+}
+
+func TestUploadHandler_ModifiedTime(t *testing.T) {
+	setupTestSMBEnv()
+
+	var commands []string
+	utimesOutput := ""
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		commands = append(commands, cmd)
+		if strings.Contains(cmd, "put") {
+			return "putting file report.pdf as inbox/report.pdf\n", nil
+		}
+		if strings.HasPrefix(cmd, "utimes") && utimesOutput != "" {
+			return utimesOutput, fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	upload := func(modifiedTime string) (int, string) {
+		t.Helper()
+		commands = nil
+		req := newUploadRequest(t, "/upload", "report.pdf", []byte("test content"), map[string]string{
+			"remote_path":   "inbox/report.pdf",
+			"overwrite":     "true",
+			"modified_time": modifiedTime,
+		})
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(respBody)
+	}
+
+	status, body := upload("2023-11-05T14:30:15+02:00")
+	if status != fiber.StatusOK || !strings.Contains(body, `"modified_time":true`) {
+		t.Errorf("Expected 200 with modified_time true, got %d: %s", status, body)
+	}
+	parsed, _ := time.Parse(time.RFC3339, "2023-11-05T14:30:15+02:00")
+	expectedCmd := fmt.Sprintf(`utimes "inbox/report.pdf" -1 -1 "%s" -1`, parsed.Local().Format("2006:01:02-15:04:05"))
+	if len(commands) == 0 || commands[len(commands)-1] != expectedCmd {
+		t.Errorf("Expected %q to be the final command, got: %v", expectedCmd, commands)
+	}
+
+	// A server that rejects the change still keeps the upload
+	utimesOutput = "NT_STATUS_NOT_SUPPORTED"
+	status, body = upload("2023-11-05T14:30:15Z")
+	if status != fiber.StatusOK || !strings.Contains(body, `"modified_time":false`) ||
+		!strings.Contains(body, "modification time not applied") {
+		t.Errorf("Expected 200 with a modification time warning, got %d: %s", status, body)
+	}
+
+	status, body = upload("05/11/2023")
+	if status != fiber.StatusBadRequest || !strings.Contains(body, "RFC 3339") {
+		t.Errorf("Expected 400 for an invalid modified_time, got %d: %s", status, body)
+	}
+	if len(commands) != 0 {
+		t.Errorf("Expected no SMB calls for an invalid modified_time, got: %v", commands)
+	}
+}
+
+// TestUploadHandler_ReadOnlyUnsupported tests that an unsupported setmode still returns success with a warning
+func TestUploadHandler_ReadOnlyUnsupported(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if strings.Contains(cmd, "put") {
+			return "putting file report.pdf as inbox/report.pdf\n", nil
+		}
+		if strings.HasPrefix(cmd, "setmode") {
+			return "NT_STATUS_NOT_SUPPORTED", fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	req := newUploadRequest(t, "/upload", "report.pdf", []byte("test content"), map[string]string{
+		"remote_path": "inbox/report.pdf",
+		"overwrite":   "true",
+		"read_only":   "true",
+	})
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test upload: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(respBody), `"read_only":false`) || !strings.Contains(string(respBody), "not supported") {
+		t.Errorf("Expected read_only false with warning in response, got: %s", string(respBody))
+	}
+}
+
+func TestParseRetentionDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{value: "30d", expected: 30 * 24 * time.Hour},
+		{value: "2w", expected: 14 * 24 * time.Hour},
+		{value: "12h", expected: 12 * time.Hour},
+		{value: "90m", expected: 90 * time.Minute},
+		{value: "0d", wantErr: true},
+		{value: "-5d", wantErr: true},
+		{value: "xd", wantErr: true},
+		{value: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := parseRetentionDuration(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q, got %v", tt.value, d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error for %q: %v", tt.value, err)
+			}
+			if d != tt.expected {
+				t.Errorf("parseRetentionDuration(%q) = %v, want %v", tt.value, d, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStaleHandler_MissingOlderThan(t *testing.T) {
+	setupTestSMBEnv()
+
+	app := fiber.New()
+	app.Get("/stale", StaleHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/stale?path=archive", nil))
+	if err != nil {
+		t.Fatalf("Failed to test stale endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestStaleHandler_ListsOldFiles(t *testing.T) {
+	setupTestSMBEnv()
+
+	old := time.Now().Add(-60 * 24 * time.Hour).Format("Mon Jan _2 15:04:05 2006")
+	recent := time.Now().Add(-time.Hour).Format("Mon Jan _2 15:04:05 2006")
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		switch args[len(args)-1] {
+		case `cd "archive"; ls`:
+			return fmt.Sprintf("  old.pdf                             A     1024  %s\n"+
+				"  new.pdf                             A     2048  %s\n"+
+				"  sub                                 D        0  %s\n", old, recent, old), nil
+		case `cd "archive/sub"; ls`:
+			return fmt.Sprintf("  older.txt                           A       10  %s\n", old), nil
+		}
+		return "NT_STATUS_OBJECT_NAME_NOT_FOUND", fmt.Errorf("smbclient command failed: exit status 1")
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/stale", StaleHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/stale?path=archive&older_than=30d", nil))
+	if err != nil {
+		t.Fatalf("Failed to test stale endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	bodyStr := string(body)
+	for _, name := range []string{`"old.pdf"`, `"sub/older.txt"`} {
+		if !strings.Contains(bodyStr, name) {
+			t.Errorf("Expected %s in stale files, got: %s", name, bodyStr)
+		}
+	}
+	if strings.Contains(bodyStr, "new.pdf") {
+		t.Errorf("Expected recent file to be excluded, got: %s", bodyStr)
+	}
+}
+
+func TestListHandler_WithChecksums(t *testing.T) {
+	setupTestSMBEnv()
+
+	digest := strings.Repeat("0f", 32)
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if strings.Contains(cmd, "get ") {
+			if !strings.Contains(cmd, `get "docs/report.pdf.sha256"`) {
+				return "NT_STATUS_OBJECT_NAME_NOT_FOUND", fmt.Errorf("smbclient command failed: exit status 1")
+			}
+			// Command format: lcd "<dir>"; get "<remote>" "<file>"
+			parts := strings.Split(cmd, `"`)
+			if err := os.WriteFile(filepath.Join(parts[1], parts[5]), []byte(digest+"\n"), 0600); err != nil {
+				return "", err
+			}
+			return "getting file", nil
+		}
+		return "  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n" +
+			"  report.pdf.sha256                   A       65  Mon Jan  1 12:00:00 2024\n" +
+			"  notes.txt                           A       10  Mon Jan  1 12:00:00 2024\n", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+
+	for _, tc := range []struct {
+		query    string
+		expected bool
+	}{
+		{query: "/list?path=docs&with_checksums=true", expected: true},
+		{query: "/list?path=docs", expected: false},
+	} {
+		resp, err := app.Test(httptest.NewRequest("GET", tc.query, nil))
+		if err != nil {
+			t.Fatalf("Failed to test list endpoint: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		hasChecksum := strings.Contains(string(body), `"sha256":"`+digest+`"`)
+		if hasChecksum != tc.expected {
+			t.Errorf("%s: expected checksum present=%v, got: %s", tc.query, tc.expected, string(body))
+		}
+		if strings.Count(string(body), `"sha256"`) > 1 {
+			t.Errorf("%s: expected only report.pdf to carry a checksum, got: %s", tc.query, string(body))
+		}
+	}
+}
+
+func TestListHandler_Fields(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return "  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n" +
+			"  archive                             D        0  Mon Jan  1 10:00:00 2024\n", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+
+	t.Run("projects selected fields", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/list?fields=name,%20size", nil))
+		if err != nil {
+			t.Fatalf("Failed to test list endpoint: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+		}
+
+		var result struct {
+			Files []map[string]interface{} `json:"files"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(result.Files) != 2 {
+			t.Fatalf("Expected 2 files, got %d", len(result.Files))
+		}
+		for _, file := range result.Files {
+			if len(file) != 2 || file["name"] == nil || file["size"] == nil {
+				t.Errorf("Expected only name and size, got: %v", file)
+			}
+		}
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		calls := mock.CallCount
+		resp, err := app.Test(httptest.NewRequest("GET", "/list?fields=name,owner", nil))
+		if err != nil {
+			t.Fatalf("Failed to test list endpoint: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if !strings.Contains(string(body), `unknown field: \"owner\"`) {
+			t.Errorf("Expected unknown field detail, got: %s", string(body))
+		}
+		if mock.CallCount != calls {
+			t.Error("Expected no SMB call for an invalid fields parameter")
+		}
+	})
+}
+
+func TestListHandler_Recursive(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		switch cmd := args[len(args)-1]; {
+		case strings.Contains(cmd, `cd "archive"`):
+			return "  old.pdf                             A      512  Mon Jan  1 09:00:00 2024\n", nil
+		case strings.Contains(cmd, `cd "private"`):
+			return "NT_STATUS_ACCESS_DENIED listing \\private\\*", fmt.Errorf("smbclient command failed: exit status 1")
+		default:
+			return "  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n" +
+				"  archive                             D        0  Mon Jan  1 10:00:00 2024\n" +
+				"  private                             D        0  Mon Jan  1 10:00:00 2024\n", nil
+		}
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/list?recursive=true&fields=name", nil))
+	if err != nil {
+		t.Fatalf("Failed to test list endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	var result struct {
+		Warning string              `json:"warning"`
+		Files   []map[string]string `json:"files"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	names := make([]string, 0, len(result.Files))
+	for _, file := range result.Files {
+		names = append(names, file["name"])
+	}
+	if strings.Join(names, ",") != "archive,archive/old.pdf,private,report.pdf" {
+		t.Errorf("Expected nested entries with relative names in name order, got: %v", names)
+	}
+	if !strings.Contains(result.Warning, "private") {
+		t.Errorf("Expected a warning naming the skipped directory, got: %q", result.Warning)
+	}
+}
+
+func TestListHandler_Pattern(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return "  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n" +
+			"  Scan.PDF                            A     2048  Mon Jan  1 12:00:00 2024\n" +
+			"  report-01.xlsx                      A      512  Mon Jan  1 12:00:00 2024\n" +
+			"  report-2024.xlsx                    A      512  Mon Jan  1 12:00:00 2024\n" +
+			"  archive.pdf                         D        0  Mon Jan  1 10:00:00 2024\n", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"/list", "archive.pdf,report-01.xlsx,report-2024.xlsx,report.pdf,Scan.PDF"},
+		{"/list?pattern=*.pdf", "archive.pdf,report.pdf,Scan.PDF"},
+		{"/list?pattern=*.pdf&match_case=true", "archive.pdf,report.pdf"},
+		{"/list?pattern=*.pdf&files_only=true", "report.pdf,Scan.PDF"},
+		{"/list?pattern=report-%3F%3F.xlsx", "report-01.xlsx"},
+		{"/list?pattern=*.docx", ""},
+		{"/list?files_only=true", "report-01.xlsx,report-2024.xlsx,report.pdf,Scan.PDF"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.query, nil))
+			if err != nil {
+				t.Fatalf("Failed to test list endpoint: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+			}
+
+			var result struct {
+				Files []smb.FileInfo `json:"files"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if result.Files == nil {
+				t.Fatal("Expected files to be an array, got null")
+			}
+			names := make([]string, 0, len(result.Files))
+			for _, file := range result.Files {
+				names = append(names, file.Name)
+			}
+			if strings.Join(names, ",") != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, strings.Join(names, ","))
+			}
+		})
+	}
+
+	t.Run("rejects an invalid pattern", func(t *testing.T) {
+		calls := mock.CallCount
+		resp, err := app.Test(httptest.NewRequest("GET", "/list?pattern=%5B", nil))
+		if err != nil {
+			t.Fatalf("Failed to test list endpoint: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+		}
+		if mock.CallCount != calls {
+			t.Error("Expected no SMB call for an invalid pattern")
+		}
+	})
+}
+
+func TestListHandler_Pagination(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return "  delta.txt                           A      300  Wed Jan  3 12:00:00 2024\n" +
+			"  Bravo.txt                           A      100  Mon Jan  1 12:00:00 2024\n" +
+			"  echo.txt                            A      100  Tue Jan  2 12:00:00 2024\n" +
+			"  alpha.txt                           A      200  Tue Jan  2 12:00:00 2024\n" +
+			"  charlie                             D        0  Fri Jan  5 12:00:00 2024\n", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		query    string
+		expected string
+		total    int
+		hasMore  bool
+	}{
+		{"/list", "alpha.txt,Bravo.txt,charlie,delta.txt,echo.txt", 5, false},
+		{"/list?order=desc", "echo.txt,delta.txt,charlie,Bravo.txt,alpha.txt", 5, false},
+		{"/list?sort=size", "charlie,Bravo.txt,echo.txt,alpha.txt,delta.txt", 5, false},
+		{"/list?sort=size&order=desc", "delta.txt,alpha.txt,Bravo.txt,echo.txt,charlie", 5, false},
+		{"/list?sort=time", "Bravo.txt,alpha.txt,echo.txt,delta.txt,charlie", 5, false},
+		{"/list?limit=2", "alpha.txt,Bravo.txt", 5, true},
+		{"/list?limit=2&offset=2", "charlie,delta.txt", 5, true},
+		{"/list?limit=2&offset=3", "delta.txt,echo.txt", 5, false},
+		{"/list?limit=2&offset=4", "echo.txt", 5, false},
+		{"/list?offset=5", "", 5, false},
+		{"/list?offset=50", "", 5, false},
+		{"/list?files_only=true&limit=1", "alpha.txt", 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.query, nil))
+			if err != nil {
+				t.Fatalf("Failed to test list endpoint: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+			}
+
+			var result struct {
+				Files   []smb.FileInfo `json:"files"`
+				Total   int            `json:"total"`
+				HasMore bool           `json:"has_more"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			names := make([]string, 0, len(result.Files))
+			for _, file := range result.Files {
+				names = append(names, file.Name)
+			}
+			if strings.Join(names, ",") != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, strings.Join(names, ","))
+			}
+			if result.Total != tt.total || result.HasMore != tt.hasMore {
+				t.Errorf("Expected total %d and has_more %v, got %d and %v",
+					tt.total, tt.hasMore, result.Total, result.HasMore)
+			}
+		})
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "limit=10001", "offset=-1", "sort=owner", "order=up"} {
+		t.Run("rejects "+query, func(t *testing.T) {
+			calls := mock.CallCount
+			resp, err := app.Test(httptest.NewRequest("GET", "/list?"+query, nil))
+			if err != nil {
+				t.Fatalf("Failed to test list endpoint: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+			if mock.CallCount != calls {
+				t.Error("Expected no SMB call for invalid paging parameters")
+			}
+		})
+	}
+}
+
+func TestMkdirHandler(t *testing.T) {
+	setupTestSMBEnv()
+
+	// Every mkdir collides (the directories exist) except under "locked", which is denied
+	const delimiter = "Current directory is \\\\testserver\\testshare\\\n"
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		var output strings.Builder
+		for _, command := range strings.Split(args[len(args)-1], "; ") {
+			switch {
+			case command == "pwd":
+				output.WriteString(delimiter)
+			case strings.Contains(command, "locked"):
+				output.WriteString("NT_STATUS_ACCESS_DENIED making remote directory\n")
+			case strings.HasPrefix(command, "mkdir"):
+				output.WriteString("NT_STATUS_OBJECT_NAME_COLLISION making remote directory\n")
+			}
+		}
+		return output.String(), nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/mkdir", MkdirHandler)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantDetail string
+		wantStatus int
+	}{
+		{name: "existing directory", body: `{"path": "a/b/c/d"}`, wantDetail: `"path":"a/b/c/d"`, wantStatus: fiber.StatusOK},
+		{name: "access denied", body: `{"path": "locked/b"}`, wantDetail: "access denied", wantStatus: fiber.StatusForbidden},
+		{name: "root path", body: `{"path": "/"}`, wantDetail: "invalid remote path", wantStatus: fiber.StatusBadRequest},
+		{name: "empty path", body: `{"path": ""}`, wantDetail: "path is required", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/mkdir", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test mkdir endpoint: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.wantDetail) {
+				t.Errorf("Expected response containing %q, got: %s", tt.wantDetail, string(body))
+			}
+		})
+	}
+}
+
+func TestMoveHandler(t *testing.T) {
+	setupTestSMBEnv()
+
+	var commands []string
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		commands = append(commands, cmd)
+		switch {
+		case strings.Contains(cmd, `"taken.txt"`):
+			return "NT_STATUS_OBJECT_NAME_COLLISION renaming files", fmt.Errorf("smbclient command failed: exit status 1")
+		case strings.Contains(cmd, `"missing.txt"`):
+			return "NT_STATUS_OBJECT_NAME_NOT_FOUND renaming files", fmt.Errorf("smbclient command failed: exit status 1")
+		case cmd == `cd "inbox"; ls`:
+			return "  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n", nil
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/move", MoveHandler)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantDetail string
+		wantStatus int
+	}{
+		{
+			name:       "cross directory move",
+			body:       `{"source": "inbox/report.pdf", "destination": "archive/2024/report.pdf"}`,
+			wantDetail: `"destination":"archive/2024/report.pdf"`,
+			wantStatus: fiber.StatusOK,
+		},
+		{
+			name:       "destination exists",
+			body:       `{"source": "inbox/report.pdf", "destination": "taken.txt"}`,
+			wantDetail: "remote file already exists: taken.txt",
+			wantStatus: fiber.StatusConflict,
+		},
+		{
+			name:       "source missing",
+			body:       `{"source": "missing.txt", "destination": "archive/missing.txt"}`,
+			wantDetail: "file not found: missing.txt",
+			wantStatus: fiber.StatusNotFound,
+		},
+		{
+			name:       "missing destination",
+			body:       `{"source": "inbox/report.pdf"}`,
+			wantDetail: "source and destination are required",
+			wantStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/move", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test move endpoint: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.wantDetail) {
+				t.Errorf("Expected response containing %q, got: %s", tt.wantDetail, string(body))
+			}
+		})
+	}
+
+	if len(commands) < 3 || commands[0] != `cd "inbox"; ls` || commands[1] != `mkdir "archive/2024"` ||
+		commands[2] != `rename "inbox/report.pdf" "archive/2024/report.pdf"` {
+		t.Errorf("Expected a check of the source and mkdir of the destination directory before the rename, got: %v",
+			commands)
+	}
+	for _, cmd := range commands {
+		if cmd == `mkdir "archive"` {
+			t.Errorf("Expected no directories to be created for a missing source, got: %v", commands)
+		}
+	}
+}
+
+func TestMoveHandler_MissingDestinationDirectory(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("SMB_AUTO_MKDIR", "false")
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if args[len(args)-1] == `cd "inbox"; ls` {
+			return "  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n", nil
+		}
+		return "NT_STATUS_OBJECT_PATH_NOT_FOUND renaming files", fmt.Errorf("smbclient command failed: exit status 1")
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/move", MoveHandler)
+
+	req := httptest.NewRequest("POST", "/move",
+		strings.NewReader(`{"source": "inbox/report.pdf", "destination": "archive/2024/report.pdf"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test move endpoint: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", fiber.StatusNotFound, resp.StatusCode)
+	}
+	if !strings.Contains(string(body), "destination parent directory does not exist: archive/2024") {
+		t.Errorf("Expected the destination directory in the detail, got: %s", string(body))
+	}
+}
+
+func TestHandlers_MaxPathDepth(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_MAX_PATH_DEPTH", "3")
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+	app.Post("/upload", UploadHandler)
+	app.Delete("/delete", DeleteHandler)
+
+	tests := []struct {
+		req            *http.Request
+		name           string
+		expectedStatus int
+	}{
+		{name: "list beyond limit", req: httptest.NewRequest("GET", "/list?path=a/b/c/d", nil),
+			expectedStatus: fiber.StatusBadRequest},
+		{name: "delete beyond limit", req: httptest.NewRequest("DELETE", "/delete?path=a/b/c/d.txt", nil),
+			expectedStatus: fiber.StatusBadRequest},
+		{name: "upload beyond limit", req: newUploadRequest(t, "/upload", "d.txt", []byte("x"),
+			map[string]string{"remote_path": "a/b/c/d.txt"}), expectedStatus: fiber.StatusBadRequest},
+		{name: "upload directory target beyond limit", req: newUploadRequest(t, "/upload", "d.txt", []byte("x"),
+			map[string]string{"remote_path": "a/b/c/"}), expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.CallCount = 0
+
+			resp, err := app.Test(tt.req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), "path too deep") {
+				t.Errorf("Expected path too deep message, got: %s", string(body))
+			}
+			if mock.CallCount != 0 {
+				t.Errorf("Expected no SMB calls, got %d", mock.CallCount)
+			}
+		})
+	}
+
+	// A path at the limit is accepted and reaches the SMB layer
+	mock.CallCount = 0
+	resp, err := app.Test(httptest.NewRequest("GET", "/list?path=a/b/c", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode == fiber.StatusBadRequest || mock.CallCount == 0 {
+		t.Errorf("Expected path at the limit to be accepted, got status %d with %d SMB calls",
+			resp.StatusCode, mock.CallCount)
+	}
+}
+
+func TestBatchListHandler_MixedDirectories(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_MAX_PATH_DEPTH", "2")
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		var out strings.Builder
+		var err error
+		for _, cmd := range strings.Split(args[len(args)-1], "; ") {
+			switch cmd {
+			case "pwd":
+				out.WriteString("Current directory is \\\\testserver\\testshare\\\n")
+				continue
+			case `ls "missing/*"`:
+				out.WriteString("NT_STATUS_OBJECT_NAME_NOT_FOUND listing \\missing\\*\n")
+				err = fmt.Errorf("smbclient command failed: exit status 1")
+				continue
+			case `ls "private/*"`:
+				out.WriteString("NT_STATUS_ACCESS_DENIED listing \\private\\*\n")
+				err = fmt.Errorf("smbclient command failed: exit status 1")
+				continue
+			case `ls "docs/*"`:
+				out.WriteString("  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n")
+			}
+			out.WriteString("\n\t\t1234 blocks of size 4096. 567 blocks available\n")
+			err = nil
+		}
+		return out.String(), err
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/list/batch", BatchListHandler)
+
+	reqBody := `{"paths": ["docs", "missing", "private", "a/b/c", "empty", "docs"]}`
+	req := httptest.NewRequest("POST", "/list/batch", strings.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test batch list endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	if mock.CallCount != 1 {
+		t.Errorf("Expected a single smbclient session, got %d calls", mock.CallCount)
+	}
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	var result struct {
+		Results map[string]struct {
+			Detail     string         `json:"detail"`
+			StatusCode int            `json:"status_code"`
+			Files      []smb.FileInfo `json:"files"`
+		} `json:"results"`
+		Failed int `json:"failed"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(result.Results) != 5 || result.Failed != 3 {
+		t.Fatalf("Expected 5 results with 3 failures, got: %s", string(body))
+	}
+	if docs := result.Results["docs"]; len(docs.Files) != 1 || docs.Files[0].Name != "report.pdf" {
+		t.Errorf("Unexpected docs listing: %+v", docs)
+	}
+	if empty := result.Results["empty"]; empty.Detail != "" || empty.Files == nil || len(empty.Files) != 0 {
+		t.Errorf("Expected an empty listing for empty, got: %+v", empty)
+	}
+
+	for path, expected := range map[string]int{
+		"missing": fiber.StatusNotFound,
+		"private": fiber.StatusForbidden,
+		"a/b/c":   fiber.StatusBadRequest,
+	} {
+		entry := result.Results[path]
+		if entry.StatusCode != expected || entry.Detail == "" {
+			t.Errorf("%s: expected status_code %d with detail, got: %+v", path, expected, entry)
+		}
+	}
+}
+
+func TestBatchListHandler_InvalidRequest(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/list/batch", BatchListHandler)
+
+	tooMany := `{"paths": ["` + strings.TrimSuffix(strings.Repeat(`p", "`, maxBatchListPaths+1), `", "`) + `"]}`
+	for _, body := range []string{"not json", `{"paths": []}`, `{}`, tooMany} {
+		req := httptest.NewRequest("POST", "/list/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test batch list endpoint: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Body %.40q: expected status %d, got %d", body, fiber.StatusBadRequest, resp.StatusCode)
+		}
+	}
+	if mock.CallCount != 0 {
+		t.Errorf("Expected no SMB calls for invalid requests, got %d", mock.CallCount)
+	}
+}
+
+func TestUploadHandler_Echo(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_BASE_PATH", "base")
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	content := []byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\nreport body")
+	fields := map[string]string{"remote_path": "docs/"}
+
+	resp, err := app.Test(newUploadRequest(t, "/upload?echo=true", "report.pdf", content, fields))
+	if err != nil {
+		t.Fatalf("Failed to test upload endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	var result struct {
+		Echo struct {
+			Filename            string `json:"filename"`
+			ContentType         string `json:"content_type"`
+			DeclaredContentType string `json:"declared_content_type"`
+			ResolvedPath        string `json:"resolved_path"`
+			Size                int64  `json:"size"`
+		} `json:"echo"`
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	echo := result.Echo
+	if echo.Filename != "report.pdf" {
+		t.Errorf("Expected filename report.pdf, got %q", echo.Filename)
+	}
+	if echo.ContentType != "application/pdf" {
+		t.Errorf("Expected detected content type application/pdf, got %q", echo.ContentType)
+	}
+	if echo.DeclaredContentType != "application/octet-stream" {
+		t.Errorf("Expected declared content type application/octet-stream, got %q", echo.DeclaredContentType)
+	}
+	if echo.Size != int64(len(content)) {
+		t.Errorf("Expected size %d, got %d", len(content), echo.Size)
+	}
+	if echo.ResolvedPath != "base/docs/report.pdf" {
+		t.Errorf("Expected resolved path base/docs/report.pdf, got %q", echo.ResolvedPath)
+	}
+
+	// Without echo=true the response carries no echo block
+	resp, err = app.Test(newUploadRequest(t, "/upload", "report.pdf", content, fields))
+	if err != nil {
+		t.Fatalf("Failed to test upload endpoint: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	if strings.Contains(string(body), `"echo"`) {
+		t.Errorf("Expected no echo block without echo=true, got: %s", string(body))
+	}
+}
+
+func TestUploadHandler_DiskFull(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if strings.Contains(cmd, "put") {
+			return "NT_STATUS_DISK_FULL closing remote file \\inbox\\report.pdf",
+				fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	req := newUploadRequest(t, "/upload", "report.pdf", []byte("test content"), map[string]string{
+		"remote_path": "inbox/report.pdf",
+		"overwrite":   "true",
+	})
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test upload: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusInsufficientStorage {
+		t.Errorf("Expected status %d, got %d", fiber.StatusInsufficientStorage, resp.StatusCode)
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(respBody), "share is full") {
+		t.Errorf("Expected disk full detail in response, got: %s", string(respBody))
+	}
+}
+
+func TestUploadHandler_NearFullShare(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_FREE_SPACE_CHECK_BYTES", "1024")
+
+	puts := 0
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		switch {
+		case strings.Contains(cmd, "put "):
+			puts++
+			return "", nil
+		case cmd == `ls "inbox"`:
+			return "  inbox                               D        0  Mon Jan  1 10:00:00 2024\n\n" +
+				"\t\t65535 blocks of size 1024. 2 blocks available\n", nil
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	req := newUploadRequest(t, "/upload", "report.pdf", bytes.Repeat([]byte("x"), 4096), map[string]string{
+		"remote_path": "inbox/report.pdf",
+		"overwrite":   "true",
+	})
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test upload: %v", err)
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusInsufficientStorage {
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusInsufficientStorage, resp.StatusCode, string(respBody))
+	}
+	if !strings.Contains(string(respBody), "2048 bytes free") {
+		t.Errorf("Expected the free space in the detail, got: %s", string(respBody))
+	}
+	if puts != 0 {
+		t.Errorf("Expected the file not to be transferred, got %d puts", puts)
+	}
+}
+
+func TestUploadHandler_MaxUploadBytes(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_MAX_UPLOAD_BYTES", "1024")
+	defer os.Unsetenv("SMB_MAX_UPLOAD_BYTES")
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	tests := []struct {
+		name       string
+		size       int
+		wantStatus int
+	}{
+		{"at the limit", 1024, fiber.StatusOK},
+		{"one byte over", 1025, fiber.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.CallCount = 0
+			req := newUploadRequest(t, "/upload", "report.pdf", bytes.Repeat([]byte("a"), tt.size), map[string]string{
+				"remote_path": "inbox/report.pdf",
+				"overwrite":   "true",
+			})
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test upload: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if tt.wantStatus != fiber.StatusRequestEntityTooLarge {
+				return
+			}
+
+			respBody, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(respBody), "maximum upload size of 1024 bytes") {
+				t.Errorf("Expected the limit in the response, got: %s", string(respBody))
+			}
+			if mock.CallCount != 0 {
+				t.Errorf("Expected no smbclient calls for an oversized file, got %d", mock.CallCount)
+			}
+		})
+	}
+}
+
+func TestUploadHandler_AllowedMIMETypes(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_ALLOWED_MIME_TYPES", "application/pdf")
+	defer os.Unsetenv("SMB_ALLOWED_MIME_TYPES")
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	tests := []struct {
+		name       string
+		filename   string
+		content    []byte
+		wantStatus int
+	}{
+		{"pdf", "report.pdf", []byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog >>\nendobj\n"), fiber.StatusOK},
+		{"png named as pdf", "report.pdf", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), fiber.StatusUnsupportedMediaType},
+		{"plain text", "notes.pdf", []byte("just some notes"), fiber.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.CallCount = 0
+			req := newUploadRequest(t, "/upload", tt.filename, tt.content, map[string]string{
+				"remote_path": "inbox/" + tt.filename,
+				"overwrite":   "true",
+			})
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test upload: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if tt.wantStatus != fiber.StatusUnsupportedMediaType {
+				return
+			}
+
+			respBody, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(respBody), "unsupported file type") {
+				t.Errorf("Expected unsupported type detail, got: %s", string(respBody))
+			}
+			if mock.CallCount != 0 {
+				t.Errorf("Expected no smbclient calls for a disallowed type, got %d", mock.CallCount)
+			}
+		})
+	}
+}
+
+func TestUploadHandler_AutoMkdirDisabled(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_AUTO_MKDIR", "false")
+	defer os.Unsetenv("SMB_AUTO_MKDIR")
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if strings.Contains(cmd, "mkdir") {
+			t.Errorf("Expected no mkdir with SMB_AUTO_MKDIR=false, got: %s", cmd)
+		}
+		if strings.Contains(cmd, "put") {
+			return "NT_STATUS_OBJECT_PATH_NOT_FOUND opening remote file \\missing\\report.pdf",
+				fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	req := newUploadRequest(t, "/upload", "report.pdf", []byte("test content"), map[string]string{
+		"remote_path": "missing/report.pdf",
+		"overwrite":   "true",
+	})
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test upload: %v", err)
+	}
+
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", fiber.StatusNotFound, resp.StatusCode)
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(respBody), "parent directory does not exist") {
+		t.Errorf("Expected missing parent detail in response, got: %s", string(respBody))
+	}
+}
+
+func TestUploadHandler_ExpectedSHA256(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	// sha256("test content")
+	const contentSHA256 = "6ae8a75555209fd6c44157c0aed8016e763ff435a19cf186f76863140143ff72"
+
+	tests := []struct {
+		name       string
+		expected   string
+		wantDetail string
+		wantStatus int
+		wantSMB    bool
+	}{
+		{name: "matching digest", expected: contentSHA256, wantStatus: fiber.StatusOK, wantSMB: true},
+		{name: "uppercase digest", expected: strings.ToUpper(contentSHA256), wantStatus: fiber.StatusOK, wantSMB: true},
+		{
+			name:       "mismatched digest",
+			expected:   strings.Repeat("0", 64),
+			wantStatus: fiber.StatusBadRequest,
+			wantDetail: "checksum mismatch: received file has sha256 " + contentSHA256,
+		},
+		{
+			name:       "malformed digest",
+			expected:   "abc123",
+			wantStatus: fiber.StatusBadRequest,
+			wantDetail: "expected_sha256 must be a 64-character hex SHA-256 digest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := mock.CallCount
+			req := newUploadRequest(t, "/upload", "report.pdf", []byte("test content"), map[string]string{
+				"remote_path":     "inbox/report.pdf",
+				"overwrite":       "true",
+				"expected_sha256": tt.expected,
+			})
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test upload: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+
+			respBody, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(respBody), tt.wantDetail) {
+				t.Errorf("Expected detail containing %q, got: %s", tt.wantDetail, string(respBody))
+			}
+			if (mock.CallCount != calls) != tt.wantSMB {
+				t.Errorf("Expected SMB calls = %v, got %d", tt.wantSMB, mock.CallCount-calls)
+			}
+		})
+	}
+}
+
+func TestHandlers_MaxNameLength(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+	app.Post("/upload", UploadHandler)
+	app.Delete("/delete", DeleteHandler)
+
+	atLimit := strings.Repeat("a", 251) + ".txt"
+	overLimit := strings.Repeat("a", 252) + ".txt"
+
+	tests := []struct {
+		req            *http.Request
+		name           string
+		expectedStatus int
+	}{
+		{name: "upload at limit", req: newUploadRequest(t, "/upload", "x.txt", []byte("x"),
+			map[string]string{"remote_path": "inbox/" + atLimit, "overwrite": "true"}), expectedStatus: fiber.StatusOK},
+		{name: "upload over limit", req: newUploadRequest(t, "/upload", "x.txt", []byte("x"),
+			map[string]string{"remote_path": "inbox/" + overLimit}), expectedStatus: fiber.StatusBadRequest},
+		{name: "upload directory target with long filename", req: newUploadRequest(t, "/upload", overLimit, []byte("x"),
+			map[string]string{"remote_path": "inbox/"}), expectedStatus: fiber.StatusBadRequest},
+		{name: "delete over limit", req: httptest.NewRequest("DELETE", "/delete?path=inbox/"+overLimit, nil),
+			expectedStatus: fiber.StatusBadRequest},
+		{name: "list over limit", req: httptest.NewRequest("GET", "/list?path="+overLimit, nil),
+			expectedStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(tt.req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+			if tt.expectedStatus == fiber.StatusBadRequest && !strings.Contains(string(body), overLimit) {
+				t.Errorf("Expected the offending segment to be named, got: %s", string(body))
+			}
+		})
+	}
+}
+
+func TestUploadHandler_DriveLetterPolicy(t *testing.T) {
+	tests := []struct {
+		policy         string
+		expectedStatus int
+		expectedDetail string
+	}{
+		{policy: "reject", expectedStatus: fiber.StatusBadRequest, expectedDetail: "drive letter paths are not supported"},
+		{policy: "strip", expectedStatus: fiber.StatusOK, expectedDetail: `"remote_path":"folder/report.pdf"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			setupTestSMBEnv()
+			os.Setenv("SMB_DRIVE_LETTER_POLICY", tt.policy)
+
+			var putCommand string
+			mock := smb.NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				cmd := args[len(args)-1]
+				if strings.Contains(cmd, "put ") {
+					putCommand = cmd
+					return "putting file report.pdf\n", nil
+				}
+				return "", nil
+			}
+			origExec := smb.SetExecutor(mock)
+			defer smb.SetExecutor(origExec)
+
+			app := fiber.New()
+			app.Post("/upload", UploadHandler)
+
+			req := newUploadRequest(t, "/upload", "report.pdf", []byte("test content"), map[string]string{
+				"remote_path": `C:\folder\report.pdf`,
+				"overwrite":   "true",
+			})
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test upload: %v", err)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+			if !strings.Contains(string(body), tt.expectedDetail) {
+				t.Errorf("Expected %s in response, got: %s", tt.expectedDetail, string(body))
+			}
+			if tt.policy == "strip" && !strings.Contains(putCommand, `"folder/report.pdf"`) {
+				t.Errorf("Expected the stripped path to be uploaded, got command: %s", putCommand)
+			}
+			if tt.policy == "reject" && putCommand != "" {
+				t.Errorf("Expected no upload when rejected, got command: %s", putCommand)
+			}
+		})
+	}
+}
+
+func TestUploadHandler_SanitizeFilenames(t *testing.T) {
+	tests := []struct {
+		policy         string
+		expectedStatus int
+		expectedDetail string
+	}{
+		{policy: "reject", expectedStatus: fiber.StatusBadRequest, expectedDetail: `contains ':'`},
+		{policy: "replace", expectedStatus: fiber.StatusOK, expectedDetail: `"remote_path":"inbox/Q1_ report.pdf"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			setupTestSMBEnv()
+			os.Setenv("SMB_SANITIZE_FILENAMES", tt.policy)
+
+			var putCommand string
+			mock := smb.NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				cmd := args[len(args)-1]
+				if strings.Contains(cmd, "put ") {
+					putCommand = cmd
+					return "putting file report.pdf\n", nil
+				}
+				return "", nil
+			}
+			origExec := smb.SetExecutor(mock)
+			defer smb.SetExecutor(origExec)
+
+			app := fiber.New()
+			app.Post("/upload", UploadHandler)
+
+			// The client's filename has a colon and trailing dots, which Windows shares refuse or drop
+			req := newUploadRequest(t, "/upload", "Q1: report.pdf..", []byte("test content"), map[string]string{
+				"remote_path": "inbox/",
+				"overwrite":   "true",
+			})
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test upload: %v", err)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+			if !strings.Contains(string(body), tt.expectedDetail) {
+				t.Errorf("Expected %s in response, got: %s", tt.expectedDetail, string(body))
+			}
+			if tt.policy == "replace" && !strings.Contains(putCommand, `"inbox/Q1_ report.pdf"`) {
+				t.Errorf("Expected the sanitized path to be uploaded, got command: %s", putCommand)
+			}
+			if tt.policy == "reject" && putCommand != "" {
+				t.Errorf("Expected no upload when rejected, got command: %s", putCommand)
+			}
+		})
+	}
+}
+
+func TestUploadHandler_DefaultUploadPath(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name         string
+		fields       map[string]string
+		expectedPath string
+	}{
+		{"default applied", map[string]string{}, "drop/report.pdf"},
+		{"remote_path overrides", map[string]string{"remote_path": "inbox/custom.pdf"}, "inbox/custom.pdf"},
+		{"remote_path directory overrides", map[string]string{"remote_path": "inbox/"}, "inbox/report.pdf"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestSMBEnv()
+			os.Setenv("SMB_DEFAULT_UPLOAD_PATH", "drop/")
+
+			var putCommand string
+			mock := smb.NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				cmd := args[len(args)-1]
+				if strings.Contains(cmd, "put ") {
+					putCommand = cmd
+					return "putting file report.pdf\n", nil
+				}
+				return "", nil
+			}
+			origExec := smb.SetExecutor(mock)
+			defer smb.SetExecutor(origExec)
+
+			app := fiber.New()
+			app.Post("/upload", UploadHandler)
+
+			req := newUploadRequest(t, "/upload", "report.pdf", []byte("%PDF-1.4 test"), tt.fields)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test upload: %v", err)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+			}
+			if !strings.Contains(string(body), `"remote_path":"`+tt.expectedPath+`"`) ||
+				!strings.Contains(putCommand, `"`+tt.expectedPath+`"`) {
+				t.Errorf("Expected an upload to %s, got command %q and response %s", tt.expectedPath, putCommand, string(body))
+			}
+		})
+	}
+}
+
+func TestHandlers_CommandTimeout(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_COMMAND_TIMEOUT", "50ms")
+
+	release := make(chan struct{})
+	defer close(release)
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		// Hang past the command timeout like an unresponsive server
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+	app.Delete("/delete", DeleteHandler)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/list?path=docs", nil),
+		httptest.NewRequest("DELETE", "/delete?path=docs/file.txt", nil),
+	} {
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test %s: %v", req.URL.Path, err)
+		}
+		if resp.StatusCode != fiber.StatusGatewayTimeout {
+			t.Errorf("%s: expected status %d, got %d", req.URL.Path, fiber.StatusGatewayTimeout, resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if !strings.Contains(string(body), "smbclient command timed out after 50ms") {
+			t.Errorf("%s: expected timeout detail, got: %s", req.URL.Path, string(body))
+		}
+	}
+}
+
+func TestHandlers_RejectPathTraversal(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+	app.Post("/upload", UploadHandler)
+	app.Delete("/delete", DeleteHandler)
+	app.Post("/move", MoveHandler)
+
+	moveRequest := func(source, destination string) *http.Request {
+		body, _ := json.Marshal(map[string]string{"source": source, "destination": destination})
+		req := httptest.NewRequest("POST", "/move", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	requests := map[string]*http.Request{
+		"list":         httptest.NewRequest("GET", "/list?path=../../etc", nil),
+		"list encoded": httptest.NewRequest("GET", "/list?path=%252e%252e/etc", nil),
+		"upload": newUploadRequest(t, "/upload", "a.txt", []byte("x"),
+			map[string]string{"remote_path": "foo/../../bar/a.txt"}),
+		"delete":           httptest.NewRequest("DELETE", "/delete?path=..%5C..%5Cboot.ini", nil),
+		"move source":      moveRequest("../outside.txt", "inbox/a.txt"),
+		"move destination": moveRequest("inbox/a.txt", "inbox/../../a.txt"),
+	}
+
+	for name, req := range requests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), "invalid remote path: traversal not allowed") {
+				t.Errorf("Expected traversal detail, got: %s", string(body))
+			}
+		})
+	}
+
+	if mock.CallCount != 0 {
+		t.Errorf("Expected no smbclient calls for traversal paths, got %d", mock.CallCount)
+	}
+}
+
+// memoryDedupIndex is a deduplication index held in a map, standing in for the share index
+type memoryDedupIndex map[string]string
+
+func (m memoryDedupIndex) Lookup(_ context.Context, digest string, _ *config.SMBConfig) (string, error) {
+	return m[digest], nil
+}
+
+func (m memoryDedupIndex) Record(_ context.Context, digest, remotePath string, _ *config.SMBConfig) error {
+	m[digest] = remotePath
+	return nil
+}
+
+func TestUploadHandler_Dedup(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_DEDUP", "true")
+
+	existing := []byte("%PDF-1.4 already on the share")
+	sum := sha256.Sum256(existing)
+	index := memoryDedupIndex{hex.EncodeToString(sum[:]): "archive/report.pdf"}
+	origIndex := smb.SetDedupIndex(index)
+	defer smb.SetDedupIndex(origIndex)
+
+	var puts int
+	mock := smb.SetupSuccessfulMock()
+	successful := mock.ExecuteFunc
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if strings.Contains(args[len(args)-1], "put ") {
+			puts++
+		}
+		return successful(args)
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	t.Run("duplicate is skipped", func(t *testing.T) {
+		resp, err := app.Test(newUploadRequest(t, "/upload", "report.pdf", existing, map[string]string{
+			"remote_path": "inbox/report.pdf",
+		}), -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+		}
+		if !strings.Contains(string(body), `"deduplicated":true`) ||
+			!strings.Contains(string(body), `"remote_path":"archive/report.pdf"`) {
+			t.Errorf("Expected the upload to point at the existing file, got %s", string(body))
+		}
+		if puts != 0 {
+			t.Errorf("Expected no transfer for a duplicate, got %d puts", puts)
+		}
+	})
+
+	t.Run("novel file is uploaded", func(t *testing.T) {
+		content := []byte("%PDF-1.4 new content")
+		resp, err := app.Test(newUploadRequest(t, "/upload", "new.pdf", content, map[string]string{
+			"remote_path": "inbox/new.pdf",
+		}), -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusOK || strings.Contains(string(body), "deduplicated") {
+			t.Fatalf("Expected a regular upload, got %d: %s", resp.StatusCode, string(body))
+		}
+		if puts != 1 {
+			t.Errorf("Expected the novel file to be transferred once, got %d puts", puts)
+		}
+		sum := sha256.Sum256(content)
+		if index[hex.EncodeToString(sum[:])] != "inbox/new.pdf" {
+			t.Errorf("Expected the uploaded file to be added to the index, got %v", index)
+		}
+	})
+}
+
+func TestHandlers_AllowedPrefixes(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	gets := 0
+	mock := newDownloadMock(t, &gets)
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+	app.Get("/download", DownloadHandler)
+	app.Post("/upload", UploadHandler)
+
+	// Without SMB_ALLOWED_PREFIXES every path reaches the share
+	resp, err := app.Test(httptest.NewRequest("GET", "/list?path=missing", nil))
+	if err != nil || resp.StatusCode != fiber.StatusNotFound {
+		t.Fatalf("Expected an unrestricted listing to reach the share, got %v, %v", resp, err)
+	}
+
+	os.Setenv("SMB_ALLOWED_PREFIXES", "reports,inbox")
+	mock.CallCount = 0
+
+	for _, path := range []string{"/list?path=reports", "/download?path=reports/q1.pdf"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("Failed to test %s: %v", path, err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("Expected %s within an allowed prefix to succeed, got %d", path, resp.StatusCode)
+		}
+	}
+	calls := mock.CallCount
+
+	refused := []*http.Request{
+		httptest.NewRequest("GET", "/list?path=reports-old", nil),
+		httptest.NewRequest("GET", "/list", nil),
+		httptest.NewRequest("GET", "/download?path=payroll/q1.pdf", nil),
+		newUploadRequest(t, "/upload", "q1.pdf", []byte("%PDF-1.4"), map[string]string{"remote_path": "payroll/q1.pdf"}),
+	}
+	for _, req := range refused {
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test %s: %v", req.URL, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusForbidden || !strings.Contains(string(body), "path not allowed") {
+			t.Errorf("Expected %s %s outside the allowed prefixes to get 403, got %d: %s",
+				req.Method, req.URL, resp.StatusCode, string(body))
+		}
+	}
+	if mock.CallCount != calls {
+		t.Errorf("Expected refused paths not to reach the share, got %d more smbclient calls", mock.CallCount-calls)
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// indexEndpoint is one route listed by GET /
+type indexEndpoint struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// IndexHandler handles GET / requests with an index of the service's endpoints
+// The endpoints are the routes registered on the app, so optional ones such as /metrics and /dav
+// appear only when enabled. HEAD routes Fiber adds alongside GET routes are left out.
+func IndexHandler(c *fiber.Ctx) error {
+	routes := c.App().GetRoutes(true)
+	gets := make(map[string]bool)
+	for _, route := range routes {
+		if route.Method == fiber.MethodGet {
+			gets[route.Path] = true
+		}
+	}
+
+	seen := make(map[indexEndpoint]bool)
+	endpoints := []indexEndpoint{}
+	for _, route := range routes {
+		endpoint := indexEndpoint{Method: route.Method, Path: route.Path}
+		if seen[endpoint] || (route.Method == fiber.MethodHead && gets[route.Path]) {
+			continue
+		}
+		seen[endpoint] = true
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
+		"service":   c.App().Config().AppName,
+		"version":   serviceBuildInfo()["version"],
+		"docs":      "/docs",
+		"openapi":   "/openapi.json",
+		"endpoints": endpoints,
+	})
+}
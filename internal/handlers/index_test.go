@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIndexHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{AppName: "Document SMB Relay Service"})
+	app.Get("/", IndexHandler)
+	app.Get("/health", HealthHandler)
+	app.Post("/upload", UploadHandler)
+	app.Head("/uploads/:id", UploadSessionHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	var index struct {
+		Service   string          `json:"service"`
+		Version   string          `json:"version"`
+		Endpoints []indexEndpoint `json:"endpoints"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if index.Service != "Document SMB Relay Service" || index.Version == "" {
+		t.Errorf("Expected the service name and version, got %q %q", index.Service, index.Version)
+	}
+
+	expected := []indexEndpoint{
+		{"GET", "/"},
+		{"GET", "/health"},
+		{"POST", "/upload"},
+		{"HEAD", "/uploads/:id"},
+	}
+	if len(index.Endpoints) != len(expected) {
+		t.Fatalf("Expected endpoints %v, got %v", expected, index.Endpoints)
+	}
+	for i, endpoint := range expected {
+		if index.Endpoints[i] != endpoint {
+			t.Errorf("Expected endpoint %d to be %v, got %v", i, endpoint, index.Endpoints[i])
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	jobStatusPending   = "pending"
+	jobStatusRunning   = "running"
+	jobStatusCompleted = "completed"
+	jobStatusFailed    = "failed"
+)
+
+// uploadJob tracks the state of an asynchronous upload
+// Fields are ordered for optimal memory alignment
+type uploadJob struct {
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Result      fiber.Map  `json:"result,omitempty"`
+	expiresAt   time.Time
+	ttl         time.Duration
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	RemotePath  string `json:"remote_path"`
+	Error       string `json:"error,omitempty"`
+	StatusCode  int    `json:"status_code,omitempty"`
+}
+
+// jobStore is an in-memory registry of asynchronous upload jobs
+// Finished jobs are kept until their TTL elapses and are purged lazily
+type jobStore struct {
+	jobs map[string]*uploadJob
+	mu   sync.Mutex
+}
+
+var uploadJobs = &jobStore{jobs: make(map[string]*uploadJob)}
+
+// newJobID returns a random hex identifier for a job
+func newJobID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is unrecoverable; fall back to a time-based ID
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
+
+// create registers a new pending job and returns a snapshot of it
+func (s *jobStore) create(remotePath string, ttl time.Duration) uploadJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purgeExpiredLocked(now)
+
+	job := &uploadJob{
+		ID:         newJobID(),
+		Status:     jobStatusPending,
+		RemotePath: remotePath,
+		CreatedAt:  now,
+		ttl:        ttl,
+	}
+	s.jobs[job.ID] = job
+
+	return *job
+}
+
+// start marks a job as running
+func (s *jobStore) start(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		job.Status = jobStatusRunning
+	}
+}
+
+// finish records the outcome of a job from the relay's HTTP status and response body
+func (s *jobStore) finish(id string, statusCode int, body fiber.Map) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	job.CompletedAt = &now
+	job.expiresAt = now.Add(job.ttl)
+	job.StatusCode = statusCode
+
+	if statusCode >= fiber.StatusBadRequest {
+		job.Status = jobStatusFailed
+		if detail, ok := body["detail"].(string); ok {
+			job.Error = detail
+		}
+		return
+	}
+
+	job.Status = jobStatusCompleted
+	job.Result = body
+}
+
+// get returns a snapshot of a job if it exists and has not expired
+func (s *jobStore) get(id string) (uploadJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpiredLocked(time.Now())
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return uploadJob{}, false
+	}
+	return *job, true
+}
+
+// purgeExpiredLocked removes finished jobs past their TTL; callers must hold s.mu
+func (s *jobStore) purgeExpiredLocked(now time.Time) {
+	for id, job := range s.jobs {
+		if job.CompletedAt != nil && now.After(job.expiresAt) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// JobStatusHandler handles GET /jobs/:id requests
+func JobStatusHandler(c *fiber.Ctx) error {
+	job, ok := uploadJobs.get(c.Params("id"))
+	if !ok {
+		return sendResponse(c, fiber.StatusNotFound, fiber.Map{
+			"detail": "job not found",
+		})
+	}
+
+	return sendResponse(c, fiber.StatusOK, job)
+}
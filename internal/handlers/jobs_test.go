@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// setupJobsApp creates an app with the upload and job status routes
+func setupJobsApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+	app.Get("/jobs/:id", JobStatusHandler)
+	return app
+}
+
+// pollJob polls the job status endpoint until the job finishes or the deadline passes
+func pollJob(t *testing.T, app *fiber.App, id string) map[string]interface{} {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/jobs/"+id, nil), -1)
+		if err != nil {
+			t.Fatalf("Failed to poll job: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("Expected status %d polling job, got %d", fiber.StatusOK, resp.StatusCode)
+		}
+
+		var job map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+			t.Fatalf("Failed to decode job: %v", err)
+		}
+		if job["status"] == jobStatusCompleted || job["status"] == jobStatusFailed {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Job %s did not finish in time", id)
+	return nil
+}
+
+// startAsyncUpload posts an async upload and returns the job ID from the 202 response
+func startAsyncUpload(t *testing.T, app *fiber.App) string {
+	t.Helper()
+
+	req := newUploadRequest(t, "/upload?async=true", "report.pdf", []byte("test content"), map[string]string{
+		"remote_path": "inbox/report.pdf",
+		"overwrite":   "true",
+	})
+
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test upload: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusAccepted, resp.StatusCode)
+	}
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	id, _ := body["job_id"].(string)
+	if id == "" {
+		t.Fatalf("Expected job_id in response, got: %v", body)
+	}
+	if body["status_url"] != "/jobs/"+id {
+		t.Errorf("Expected status_url /jobs/%s, got: %v", id, body["status_url"])
+	}
+	return id
+}
+
+func TestUploadHandler_AsyncCompletes(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if strings.Contains(args[len(args)-1], "put") {
+			return "putting file report.pdf as inbox/report.pdf\n", nil
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := setupJobsApp()
+	id := startAsyncUpload(t, app)
+
+	job := pollJob(t, app, id)
+	if job["status"] != jobStatusCompleted {
+		t.Errorf("Expected job to complete, got: %v", job)
+	}
+	if job["remote_path"] != "inbox/report.pdf" {
+		t.Errorf("Expected remote_path inbox/report.pdf, got: %v", job["remote_path"])
+	}
+	if job["completed_at"] == nil {
+		t.Error("Expected completed_at to be set")
+	}
+}
+
+func TestUploadHandler_AsyncFailure(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if strings.Contains(args[len(args)-1], "put") {
+			return "NT_STATUS_ACCESS_DENIED", fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := setupJobsApp()
+	id := startAsyncUpload(t, app)
+
+	job := pollJob(t, app, id)
+	if job["status"] != jobStatusFailed {
+		t.Errorf("Expected job to fail, got: %v", job)
+	}
+	if !strings.Contains(fmt.Sprint(job["error"]), "access denied") {
+		t.Errorf("Expected access denied error, got: %v", job["error"])
+	}
+	if job["status_code"] != float64(fiber.StatusInternalServerError) {
+		t.Errorf("Expected status_code %d, got: %v", fiber.StatusInternalServerError, job["status_code"])
+	}
+}
+
+func TestJobStatusHandler_NotFound(t *testing.T) {
+	app := setupJobsApp()
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/jobs/doesnotexist", nil))
+	if err != nil {
+		t.Fatalf("Failed to test job status: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", fiber.StatusNotFound, resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "job not found") {
+		t.Errorf("Expected job not found message, got: %s", string(body))
+	}
+}
+
+func TestJobStore_ExpiresFinishedJobs(t *testing.T) {
+	store := &jobStore{jobs: make(map[string]*uploadJob)}
+
+	finished := store.create("a.txt", time.Millisecond)
+	running := store.create("b.txt", time.Millisecond)
+	store.start(running.ID)
+	store.finish(finished.ID, fiber.StatusOK, fiber.Map{"status": "ok"})
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := store.get(finished.ID); ok {
+		t.Error("Expected finished job to expire after its TTL")
+	}
+	if job, ok := store.get(running.ID); !ok || job.Status != jobStatusRunning {
+		t.Errorf("Expected running job to be kept, got: %v (found=%v)", job, ok)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// listFilter selects the entries of a directory listing to return
+type listFilter struct {
+	pattern   string
+	matchCase bool
+	filesOnly bool
+}
+
+// parseListFilter reads the pattern, match_case and files_only query parameters
+// A nil filter is returned when none of them narrows the listing.
+func parseListFilter(c *fiber.Ctx) (*listFilter, error) {
+	filter := &listFilter{
+		pattern:   c.Query("pattern"),
+		matchCase: strings.ToLower(c.Query("match_case")) == "true",
+		filesOnly: strings.ToLower(c.Query("files_only")) == "true",
+	}
+	if filter.pattern == "" && !filter.filesOnly {
+		return nil, nil
+	}
+
+	if _, err := filepath.Match(filter.pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %q", filter.pattern)
+	}
+	if !filter.matchCase {
+		filter.pattern = strings.ToLower(filter.pattern)
+	}
+	return filter, nil
+}
+
+// matches reports whether a listing entry passes the filter
+// Patterns are matched against the entry's last path segment, so nested entries of a recursive
+// listing match by their own name.
+func (f *listFilter) matches(file smb.FileInfo) bool {
+	if f.filesOnly && file.IsDir {
+		return false
+	}
+	if f.pattern == "" {
+		return true
+	}
+
+	name := path.Base(file.Name)
+	if !f.matchCase {
+		name = strings.ToLower(name)
+	}
+	matched, _ := filepath.Match(f.pattern, name)
+	return matched
+}
+
+// apply returns the entries of files that pass the filter, in their original order
+func (f *listFilter) apply(files []smb.FileInfo) []smb.FileInfo {
+	filtered := make([]smb.FileInfo, 0, len(files))
+	for _, file := range files {
+		if f.matches(file) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
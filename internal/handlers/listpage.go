@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// defaultListLimit is the page size of a listing when no limit is given
+	defaultListLimit = 1000
+	// maxListLimit is the largest page size a listing accepts
+	maxListLimit = 10000
+)
+
+// listPage selects the order of a directory listing and the window of it to return
+type listPage struct {
+	sort   string
+	limit  int
+	offset int
+	desc   bool
+}
+
+// parseListPage reads the limit, offset, sort and order query parameters
+// Listings default to the first defaultListLimit entries by name, ascending.
+func parseListPage(c *fiber.Ctx) (*listPage, error) {
+	page := &listPage{sort: "name", limit: defaultListLimit}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return nil, fmt.Errorf("limit must be an integer between 1 and %d: %s", maxListLimit, raw)
+		}
+		page.limit = limit
+	}
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("offset must be a non-negative integer: %s", raw)
+		}
+		page.offset = offset
+	}
+
+	switch sortBy := strings.ToLower(c.Query("sort", "name")); sortBy {
+	case "name", "size", "time":
+		page.sort = sortBy
+	default:
+		return nil, fmt.Errorf("sort must be one of name, size, time: %s", sortBy)
+	}
+	switch order := strings.ToLower(c.Query("order", "asc")); order {
+	case "asc":
+	case "desc":
+		page.desc = true
+	default:
+		return nil, fmt.Errorf("order must be asc or desc: %s", order)
+	}
+	return page, nil
+}
+
+// less orders two listing entries by the page's sort key, falling back to their names
+func (p *listPage) less(a, b smb.FileInfo) bool {
+	switch p.sort {
+	case "size":
+		if a.Size != b.Size {
+			return (a.Size < b.Size) != p.desc
+		}
+	case "time":
+		at, bt := modTime(a), modTime(b)
+		if !at.Equal(bt) {
+			return at.Before(bt) != p.desc
+		}
+	}
+
+	an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name)
+	if an != bn {
+		return (an < bn) != (p.desc && p.sort == "name")
+	}
+	return false
+}
+
+// modTime returns an entry's modification time, or the zero time when it has none
+func modTime(file smb.FileInfo) time.Time {
+	if file.ModTime == nil {
+		return time.Time{}
+	}
+	return *file.ModTime
+}
+
+// apply sorts files and returns the page of them, along with whether more entries follow it
+// Entries that compare equal keep their listing order.
+func (p *listPage) apply(files []smb.FileInfo) ([]smb.FileInfo, bool) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return p.less(files[i], files[j])
+	})
+
+	if p.offset >= len(files) {
+		return []smb.FileInfo{}, false
+	}
+	end := min(p.offset+p.limit, len(files))
+	return files[p.offset:end], end < len(files)
+}
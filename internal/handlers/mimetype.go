@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+)
+
+// sniffLength is the number of leading bytes http.DetectContentType considers
+const sniffLength = 512
+
+// disallowedTypeDetail returns the 415 detail when content sniffed from head is not in
+// SMB_ALLOWED_MIME_TYPES, or "" when it is allowed or no allowlist is configured
+// The client-supplied Content-Type is never consulted.
+func disallowedTypeDetail(head []byte) string {
+	allowed := config.LoadServerConfig().AllowedMIMETypes
+	if len(allowed) == 0 {
+		return ""
+	}
+
+	detected := http.DetectContentType(head)
+	mediaType, _, err := mime.ParseMediaType(detected)
+	if err != nil {
+		mediaType = detected
+	}
+	for _, t := range allowed {
+		if strings.EqualFold(t, mediaType) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("unsupported file type: %s (allowed types: %s)", mediaType, strings.Join(allowed, ", "))
+}
+
+// checkUploadedFileType returns the 415 detail when an uploaded file's sniffed type is not allowed, or ""
+func checkUploadedFileType(file *multipart.FileHeader) (string, error) {
+	if len(config.LoadServerConfig().AllowedMIMETypes) == 0 {
+		return "", nil
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return sniffDisallowedType(f)
+}
+
+// checkStagedFileType returns the 415 detail when a staged file's sniffed type is not allowed, or ""
+func checkStagedFileType(path string) (string, error) {
+	if len(config.LoadServerConfig().AllowedMIMETypes) == 0 {
+		return "", nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return sniffDisallowedType(f)
+}
+
+// sniffDisallowedType reads the head of r and returns the 415 detail when its type is not allowed
+func sniffDisallowedType(r io.Reader) (string, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return disallowedTypeDetail(head[:n]), nil
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"fmt"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Outcomes reported for each file of a multi-file upload
+const (
+	uploadFileOK     = "ok"
+	uploadFileFailed = "failed"
+)
+
+// uploadMultipleFiles handles a POST /upload request carrying more than one file part
+// Files are paired with remote_path values in order, or all go to a single remote_path that
+// ends with a slash, or SMB_DEFAULT_UPLOAD_PATH when none or an empty one is sent. Every file is attempted; the 207
+// response reports each one's outcome.
+func uploadMultipleFiles(c *fiber.Ctx, form *multipart.Form, opts uploadOptions, cfg *config.SMBConfig) error {
+	files := form.File["file"]
+	values := form.Value["remote_path"]
+	if len(values) == 0 && cfg.DefaultUploadPath != "" {
+		values = []string{""}
+	}
+	// An empty remote_path means SMB_DEFAULT_UPLOAD_PATH, as for a single file
+	resolved := make([]string, len(values))
+	for i, value := range values {
+		resolved[i] = defaultRemotePath(value, cfg)
+	}
+	remotePaths, err := pairRemotePaths(resolved, files)
+	if err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	results := make([]fiber.Map, 0, len(files))
+	for i, file := range files {
+		fileOpts := opts
+		fileOpts.remotePath = remotePaths[i]
+
+		status, body := uploadOneOfMany(c, file, fileOpts, cfg)
+		results = append(results, uploadFileResult(fileOpts.remotePath, status, body))
+	}
+
+	return sendUploadResults(c, results)
+}
+
+// uploadFileResult builds the result entry of one file of a multi-file upload from the status and
+// body the single-file upload would have responded with
+func uploadFileResult(remotePath string, status int, body fiber.Map) fiber.Map {
+	result := fiber.Map{
+		"remote_path": remotePath,
+		"status_code": status,
+	}
+	if status == fiber.StatusOK {
+		result["status"] = uploadFileOK
+		for _, key := range []string{"read_only", "modified_time", "warning"} {
+			if value, ok := body[key]; ok {
+				result[key] = value
+			}
+		}
+	} else {
+		result["status"] = uploadFileFailed
+		result["error"] = body["detail"]
+	}
+	return result
+}
+
+// sendUploadResults sends the 207 response of a multi-file upload
+func sendUploadResults(c *fiber.Ctx, results []fiber.Map) error {
+	succeeded := 0
+	for _, result := range results {
+		if result["status"] == uploadFileOK {
+			succeeded++
+		}
+	}
+	return sendResponse(c, fiber.StatusMultiStatus, fiber.Map{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
+
+// pairRemotePaths returns the remote path of each file part
+// A single value ending with / or \ is a directory that every file is uploaded into under its
+// own filename; otherwise there must be exactly one remote_path per file.
+func pairRemotePaths(values []string, files []*multipart.FileHeader) ([]string, error) {
+	remotePaths := make([]string, len(files))
+
+	if len(values) == 1 && (strings.HasSuffix(values[0], "/") || strings.HasSuffix(values[0], "\\")) {
+		for i, file := range files {
+			remotePaths[i] = filepath.Join(values[0], filepath.Base(file.Filename))
+		}
+		return remotePaths, nil
+	}
+
+	if len(values) != len(files) {
+		return nil, fmt.Errorf("got %d files and %d remote_path values: send one remote_path per file, "+
+			"or a single directory ending with /", len(files), len(values))
+	}
+	for i, value := range values {
+		if value == "" {
+			return nil, fmt.Errorf("remote_path %d is empty", i+1)
+		}
+		if strings.HasSuffix(value, "/") || strings.HasSuffix(value, "\\") {
+			value = filepath.Join(value, filepath.Base(files[i].Filename))
+		}
+		remotePaths[i] = value
+	}
+	return remotePaths, nil
+}
+
+// uploadOneOfMany stages and relays one file of a multi-file upload
+// It returns the status and body the single-file upload would have responded with.
+func uploadOneOfMany(
+	c *fiber.Ctx,
+	file *multipart.FileHeader,
+	opts uploadOptions,
+	cfg *config.SMBConfig,
+) (int, fiber.Map) {
+	remotePath, err := smb.PrepareUploadPath(opts.remotePath, cfg)
+	if err != nil {
+		return pathErrorStatus(err), fiber.Map{"detail": err.Error()}
+	}
+	opts.remotePath = remotePath
+
+	if detail := uploadTooLargeDetail(file.Size); detail != "" {
+		return fiber.StatusRequestEntityTooLarge, fiber.Map{"detail": detail}
+	}
+	typeDetail, err := checkUploadedFileType(file)
+	if err != nil {
+		return fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Failed to read uploaded file: %v", err),
+		}
+	}
+	if typeDetail != "" {
+		return fiber.StatusUnsupportedMediaType, fiber.Map{"detail": typeDetail}
+	}
+
+	// Files may share a name, so each gets its own unique staging file
+	tmpPath, err := createStagingFile(file.Filename)
+	if err != nil {
+		return fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Failed to save uploaded file: %v", err),
+		}
+	}
+	defer removeStagedFile(tmpPath)
+
+	if err := c.SaveFile(file, tmpPath); err != nil {
+		return fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Failed to save uploaded file: %v", err),
+		}
+	}
+
+	return relayUpload(c.UserContext(), tmpPath, opts, cfg)
+}
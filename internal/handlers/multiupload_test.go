@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// newMultiUploadRequest builds an upload request with one file part per filename and one
+// remote_path field per entry of remotePaths
+func newMultiUploadRequest(t *testing.T, target string, filenames, remotePaths []string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, filename := range filenames {
+		part, err := writer.CreateFormFile("file", filename)
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write([]byte("content of " + filename)); err != nil {
+			t.Fatalf("Failed to write form file: %v", err)
+		}
+	}
+	for _, remotePath := range remotePaths {
+		if err := writer.WriteField("remote_path", remotePath); err != nil {
+			t.Fatalf("Failed to write remote_path: %v", err)
+		}
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", target, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadHandler_MultipleFiles(t *testing.T) {
+	setupTestSMBEnv()
+
+	var puts []string
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		switch {
+		case strings.HasPrefix(cmd, "ls "):
+			return "NT_STATUS_NO_SUCH_FILE listing", fmt.Errorf("smbclient command failed: exit status 1")
+		case strings.Contains(cmd, "put "):
+			puts = append(puts, cmd)
+			if strings.Contains(cmd, "inbox/taken.txt") {
+				return "NT_STATUS_OBJECT_NAME_COLLISION opening remote file \\inbox\\taken.txt",
+					fmt.Errorf("smbclient command failed: exit status 1")
+			}
+			return "putting file", nil
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	req := newMultiUploadRequest(t, "/upload",
+		[]string{"a.txt", "b.txt", "c.txt"},
+		[]string{"inbox/a.txt", "inbox/taken.txt", "inbox/"})
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("Failed to test upload: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusMultiStatus {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusMultiStatus, resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Results []struct {
+			RemotePath string `json:"remote_path"`
+			Status     string `json:"status"`
+			Error      string `json:"error"`
+			StatusCode int    `json:"status_code"`
+		} `json:"results"`
+		Succeeded int `json:"succeeded"`
+		Failed    int `json:"failed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(result.Results) != 3 || result.Succeeded != 2 || result.Failed != 1 {
+		t.Fatalf("Expected 2 succeeded and 1 failed of 3, got: %+v", result)
+	}
+	if result.Results[0].RemotePath != "inbox/a.txt" || result.Results[0].Status != "ok" {
+		t.Errorf("Expected inbox/a.txt to succeed, got: %+v", result.Results[0])
+	}
+	if result.Results[1].Status != "failed" || result.Results[1].StatusCode != fiber.StatusConflict ||
+		result.Results[1].Error != "remote file already exists: inbox/taken.txt" {
+		t.Errorf("Expected inbox/taken.txt to conflict, got: %+v", result.Results[1])
+	}
+	if result.Results[2].RemotePath != "inbox/c.txt" || result.Results[2].Status != "ok" {
+		t.Errorf("Expected the file after the failure to upload into inbox/, got: %+v", result.Results[2])
+	}
+	if len(puts) != 3 {
+		t.Errorf("Expected every file to be put, got: %v", puts)
+	}
+}
+
+func TestUploadHandler_MultipleFilesInvalid(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutor()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	tests := []struct {
+		name        string
+		target      string
+		remotePaths []string
+		wantDetail  string
+	}{
+		{
+			name:        "fewer remote paths than files",
+			target:      "/upload",
+			remotePaths: []string{"inbox/a.txt"},
+			wantDetail:  "got 2 files and 1 remote_path values",
+		},
+		{
+			name:        "async",
+			target:      "/upload?async=true",
+			remotePaths: []string{"inbox/"},
+			wantDetail:  "only supported when uploading a single file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newMultiUploadRequest(t, tt.target, []string{"a.txt", "b.txt"}, tt.remotePaths)
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test upload: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.wantDetail) {
+				t.Errorf("Expected detail containing %q, got: %s", tt.wantDetail, string(body))
+			}
+		})
+	}
+
+	if mock.CallCount != 0 {
+		t.Errorf("Expected no SMB calls for invalid multi-file uploads, got %d", mock.CallCount)
+	}
+}
+
+func TestUploadHandler_MultipleFilesDefaultPath(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("SMB_DEFAULT_UPLOAD_PATH", "drop/")
+
+	var puts []string
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		switch {
+		case strings.HasPrefix(cmd, "ls "):
+			return "NT_STATUS_NO_SUCH_FILE listing", fmt.Errorf("smbclient command failed: exit status 1")
+		case strings.Contains(cmd, "put "):
+			puts = append(puts, cmd)
+			return "putting file", nil
+		}
+		return "", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Post("/upload", UploadHandler)
+
+	tests := []struct {
+		name        string
+		remotePaths []string
+		wantPuts    []string
+	}{
+		{name: "no remote_path", wantPuts: []string{`"drop/a.txt"`, `"drop/b.txt"`}},
+		{name: "empty remote_path", remotePaths: []string{""}, wantPuts: []string{`"drop/a.txt"`, `"drop/b.txt"`}},
+		{
+			name:        "empty remote_path for one file",
+			remotePaths: []string{"inbox/first.txt", ""},
+			wantPuts:    []string{`"inbox/first.txt"`, `"drop/b.txt"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			puts = nil
+			req := newMultiUploadRequest(t, "/upload", []string{"a.txt", "b.txt"}, tt.remotePaths)
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test upload: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusMultiStatus {
+				body, _ := io.ReadAll(resp.Body)
+				t.Fatalf("Expected status %d, got %d: %s", fiber.StatusMultiStatus, resp.StatusCode, string(body))
+			}
+			if len(puts) != len(tt.wantPuts) {
+				t.Fatalf("Expected %d puts, got: %v", len(tt.wantPuts), puts)
+			}
+			for i, want := range tt.wantPuts {
+				if !strings.Contains(puts[i], want) {
+					t.Errorf("Expected put %d to target %s, got: %s", i, want, puts[i])
+				}
+			}
+		})
+	}
+}
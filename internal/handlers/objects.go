@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ObjectPutHandler handles PUT /objects/{key} requests
+// The raw request body is stored at the remote path named by the key, replacing any existing file.
+func ObjectPutHandler(c *fiber.Ctx) error {
+	// Load configuration
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		missingVars := strings.Join(missing, ", ")
+		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": errorMsg,
+		})
+	}
+	if cfg.ReadOnly {
+		return sendReadOnly(c)
+	}
+
+	key, err := objectKey(c, cfg, smb.PrepareUploadPath)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	body := c.Body()
+	if status, detail := rawUploadRejection(body); detail != "" {
+		return sendResponse(c, status, fiber.Map{
+			"detail": detail,
+		})
+	}
+
+	if err := smb.UploadStreamWithContext(c.UserContext(), bytes.NewReader(body), key, cfg, true); err != nil {
+		return sendResponse(c, batchStepErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	return sendResponse(c, fiber.StatusOK, fiber.Map{
+		"status": "ok",
+		"key":    key,
+		"size":   len(body),
+	})
+}
+
+// ObjectGetHandler handles GET /objects/{key} requests
+// The file at the remote path named by the key is returned as the response body.
+func ObjectGetHandler(c *fiber.Ctx) error {
+	// Load configuration
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		missingVars := strings.Join(missing, ", ")
+		errorMsg := fmt.Sprintf("Missing SMB configuration environment variables: %s", missingVars)
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": errorMsg,
+		})
+	}
+
+	key, err := objectKey(c, cfg, smb.PrepareRequestPath)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	return sendRemoteFile(c, key, cfg, batchStepErrorStatus)
+}
+
+// objectKey returns the validated remote path named by the key in an /objects/* request path
+// prepare is smb.PrepareUploadPath for a key about to be written, smb.PrepareRequestPath otherwise.
+func objectKey(
+	c *fiber.Ctx, cfg *config.SMBConfig, prepare func(string, *config.SMBConfig) (string, error),
+) (string, error) {
+	raw, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return "", fmt.Errorf("invalid object key: %v", err)
+	}
+	key, err := prepare(raw, cfg)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", fmt.Errorf("object key is required")
+	}
+	return key, nil
+}
+
+// rawUploadRejection checks a raw request body against the limits of POST /upload
+// It returns the status and detail of the rejection, or an empty detail if the body is accepted.
+func rawUploadRejection(body []byte) (int, string) {
+	if detail := uploadTooLargeDetail(int64(len(body))); detail != "" {
+		return fiber.StatusRequestEntityTooLarge, detail
+	}
+	if detail := disallowedTypeDetail(body[:min(len(body), sniffLength)]); detail != "" {
+		return fiber.StatusUnsupportedMediaType, detail
+	}
+	return fiber.StatusOK, ""
+}
+
+// sendRemoteFile downloads a file through a temporary file and streams it as the response body
+// A Range request for a single byte range gets just those bytes, as 206 Partial Content. errorStatus
+// maps a failed download to the response status.
+func sendRemoteFile(c *fiber.Ctx, path string, cfg *config.SMBConfig, errorStatus func(error) int) error {
+	tmpFile, err := os.CreateTemp("", tempFilePrefix+"*")
+	if err != nil {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("failed to create temp file: %v", err),
+		})
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	trackTempFile(tmpPath)
+	defer removeStagedFile(tmpPath)
+
+	if err := smb.DownloadFileWithContext(c.UserContext(), path, tmpPath, cfg); err != nil {
+		return sendResponse(c, errorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	// The open file stays readable after the deferred removal until the response is sent
+	content, err := os.Open(tmpPath)
+	if err != nil {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("failed to read downloaded file: %v", err),
+		})
+	}
+	info, err := content.Stat()
+	if err != nil {
+		content.Close()
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("failed to read downloaded file: %v", err),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, remoteContentType(path))
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	if sent, err := sendFileRange(c, content, info.Size()); sent {
+		return err
+	}
+	return c.SendStream(content, int(info.Size()))
+}
+
+// remoteContentType guesses a remote file's content type from its extension
+func remoteContentType(path string) string {
+	if contentType := mime.TypeByExtension(pathpkg.Ext(path)); contentType != "" {
+		return contentType
+	}
+	return fiber.MIMEOctetStream
+}
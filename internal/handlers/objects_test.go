@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestObjectHandlers_PutGetRoundTrip(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_BASE_PATH", "relay")
+	t.Setenv("TMPDIR", t.TempDir())
+
+	mock := newWebDAVMock(t, nil)
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Put("/objects/*", ObjectPutHandler)
+	app.Get("/objects/*", ObjectGetHandler)
+
+	content := []byte("invoice 2024-117, total 420.00")
+	resp, err := app.Test(httptest.NewRequest("PUT", "/objects/invoices/2024/117.txt", bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("Failed to test PUT: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+	}
+	var stored map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&stored); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if stored["key"] != "invoices/2024/117.txt" || stored["size"] != float64(len(content)) {
+		t.Errorf("Expected the key and size of the stored object, got %v", stored)
+	}
+	if cmd := mock.LastArgs[len(mock.LastArgs)-1]; cmd != `put - "relay/invoices/2024/117.txt"` {
+		t.Errorf("Expected the object under the base path, got command %s", cmd)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/objects/invoices/2024/117.txt", nil))
+	if err != nil {
+		t.Fatalf("Failed to test GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+	}
+	if !bytes.Equal(body, content) {
+		t.Errorf("Expected the stored object back, got %q", string(body))
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("Expected a text/plain Content-Type, got %q", contentType)
+	}
+}
+
+func TestObjectGetHandler_Errors(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	mock := newWebDAVMock(t, nil)
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/objects/*", ObjectGetHandler)
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+		expectedDetail string
+	}{
+		{"/objects/missing/report.pdf", fiber.StatusNotFound, "file not found: missing/report.pdf"},
+		{"/objects/", fiber.StatusBadRequest, "object key is required"},
+		{"/objects/a/../../secret", fiber.StatusBadRequest, "traversal not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatalf("Failed to test GET: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), tt.expectedDetail) {
+				t.Errorf("Expected detail %q, got: %s", tt.expectedDetail, string(body))
+			}
+		})
+	}
+}
+
+func TestObjectPutHandler_SanitizesKey(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	mock := newWebDAVMock(t, nil)
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Put("/objects/*", ObjectPutHandler)
+
+	t.Setenv("SMB_SANITIZE_FILENAMES", "replace")
+	resp, err := app.Test(httptest.NewRequest("PUT", "/objects/inbox/Q1%3A%20report.txt", strings.NewReader("figures")))
+	if err != nil {
+		t.Fatalf("Failed to test PUT: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(body), `"key":"inbox/Q1_ report.txt"`) {
+		t.Fatalf("Expected the sanitized key, got %d: %s", resp.StatusCode, string(body))
+	}
+	if cmd := mock.LastArgs[len(mock.LastArgs)-1]; cmd != `put - "inbox/Q1_ report.txt"` {
+		t.Errorf("Expected the sanitized name to be written, got command %s", cmd)
+	}
+
+	t.Setenv("SMB_SANITIZE_FILENAMES", "reject")
+	calls := mock.CallCount
+	resp, err = app.Test(httptest.NewRequest("PUT", "/objects/inbox/Q1%3A%20report.txt", strings.NewReader("figures")))
+	if err != nil {
+		t.Fatalf("Failed to test PUT: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	}
+	if mock.CallCount != calls {
+		t.Errorf("Expected no SMB calls for a rejected key, got %d", mock.CallCount-calls)
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// targetParameterRef refers to the shared target parameter of the SMB endpoints
+	targetParameterRef = "#/components/parameters/target"
+	// errorSchemaRef refers to the JSON body of error responses
+	errorSchemaRef = "#/components/schemas/Error"
+)
+
+// routeParamPattern matches the :name parameters of a Fiber route path
+var routeParamPattern = regexp.MustCompile(`:(\w+)`)
+
+// document is an OpenAPI 3.0 document
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+	Info       info                             `json:"info"`
+	OpenAPI    string                           `json:"openapi"`
+}
+
+// info describes the API in an OpenAPI document
+type info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// components holds the definitions operations refer to with $ref
+type components struct {
+	Parameters      map[string]parameter      `json:"parameters"`
+	Schemas         map[string]*schema        `json:"schemas"`
+	SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+}
+
+// operation documents one HTTP method of a path
+type operation struct {
+	Responses   map[string]response   `json:"responses"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// parameter documents a query, path or header parameter, or refers to a shared one with Ref
+type parameter struct {
+	Schema      *schema `json:"schema,omitempty"`
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+}
+
+// requestBody documents the body an operation accepts, by content type
+type requestBody struct {
+	Content  map[string]mediaType `json:"content"`
+	Required bool                 `json:"required,omitempty"`
+}
+
+// mediaType documents a body of one content type
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// response documents one status code of an operation
+type response struct {
+	Headers     map[string]header    `json:"headers,omitempty"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+	Description string               `json:"description"`
+}
+
+// header documents a response header
+type header struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is the subset of JSON Schema used by the spec
+type schema struct {
+	Default              interface{}        `json:"default,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Maximum              *int               `json:"maximum,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	MinItems             int                `json:"minItems,omitempty"`
+	MaxItems             int                `json:"maxItems,omitempty"`
+}
+
+// securityScheme documents a way of authenticating requests
+type securityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// jsonContent returns the content of a JSON body described by s
+func jsonContent(s *schema) map[string]mediaType {
+	return map[string]mediaType{fiber.MIMEApplicationJSON: {Schema: s}}
+}
+
+// intPtr returns a pointer to v, for optional schema bounds
+func intPtr(v int) *int {
+	return &v
+}
+
+// objectKeyParameter documents the key path parameter of the /objects routes
+var objectKeyParameter = parameter{
+	Name:        "key",
+	In:          "path",
+	Description: "Object key, a path within the SMB share such as reports/2024/q1.pdf",
+	Required:    true,
+	Schema:      &schema{Type: "string"},
+}
+
+// GetOpenAPISpec returns the OpenAPI specification
+func GetOpenAPISpec(c *fiber.Ctx) error {
+	return c.JSON(openAPIDocument(Routes()))
+}
+
+// openAPIDocument generates the OpenAPI document of routes
+// Endpoints accepting a target get the shared target parameter and its 400 and 401 responses, and
+// error responses without a body of their own get the JSON error body every handler returns.
+func openAPIDocument(routes []Route) *document {
+	doc := &document{
+		OpenAPI: "3.0.0",
+		Info: info{
+			Title:       "Document SMB Relay Service",
+			Version:     "1.0.0",
+			Description: "A minimal service that accepts file uploads via HTTP and writes them directly to SMB shares",
+		},
+		Paths:      make(map[string]map[string]*operation),
+		Components: openAPIComponents(),
+	}
+
+	for _, route := range routes {
+		op := route.doc
+		if op == nil {
+			continue
+		}
+		if route.target {
+			op.Parameters = append(op.Parameters, parameter{Ref: targetParameterRef})
+			addResponse(op, "400", "Unknown SMB target")
+			addResponse(op, "401", "Missing SMB credentials while SMB_AUTH_FROM_REQUEST is set")
+		}
+		if route.Method != fiber.MethodHead {
+			addErrorBodies(op)
+		}
+
+		path := route.openAPIPath()
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*operation)
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+	return doc
+}
+
+// openAPIPath returns the OpenAPI path of a route, e.g. /jobs/{id} for /jobs/:id
+func (r Route) openAPIPath() string {
+	if r.specPath != "" {
+		return r.specPath
+	}
+	return routeParamPattern.ReplaceAllString(r.Path, "{$1}")
+}
+
+// addResponse documents a response of op unless it already documents that status
+func addResponse(op *operation, status, description string) {
+	if _, ok := op.Responses[status]; !ok {
+		op.Responses[status] = response{Description: description}
+	}
+}
+
+// addErrorBodies documents the JSON error body of op's error responses that describe no body
+func addErrorBodies(op *operation) {
+	for status, resp := range op.Responses {
+		if code, err := strconv.Atoi(status); err == nil && code >= fiber.StatusBadRequest && resp.Content == nil {
+			resp.Content = jsonContent(&schema{Ref: errorSchemaRef})
+			op.Responses[status] = resp
+		}
+	}
+}
+
+// openAPIComponents returns the parameters, schemas and security schemes shared by operations
+func openAPIComponents() components {
+	return components{
+		Parameters: map[string]parameter{
+			"target": {
+				Name: "target",
+				In:   "query",
+				Description: "Named SMB target, defined by SMB_TARGET_<NAME>_* variables, to use instead of " +
+					"the default configuration. Uploads also accept it as a form field. Unknown targets get 400",
+				Schema: &schema{Type: "string"},
+			},
+		},
+		Schemas: map[string]*schema{
+			"Error": {
+				Type:     "object",
+				Required: []string{"detail"},
+				Properties: map[string]*schema{
+					"detail":     {Type: "string", Description: "What went wrong"},
+					"request_id": {Type: "string", Description: "ID of the request, as in the X-Request-ID header"},
+					"trace_id":   {Type: "string", Description: "OpenTelemetry trace ID, when tracing is active"},
+				},
+			},
+		},
+		SecuritySchemes: map[string]securityScheme{
+			"adminToken": {
+				Type:   "http",
+				Scheme: "bearer",
+			},
+			"apiKey": {
+				Type: "apiKey",
+				In:   "header",
+				Name: "X-API-Key",
+				Description: "Required on every endpoint except /health, /docs and /openapi.json when " +
+					"SERVICE_API_KEY is set; may also be sent as Authorization: Bearer <key>",
+			},
+			"smbCredentials": {
+				Type:   "http",
+				Scheme: "basic",
+				Description: "SMB username and password for the request when SMB_AUTH_FROM_REQUEST is set; " +
+					"a DOMAIN\\user name also sets the domain. Requests without them get 401",
+			},
+		},
+	}
+}
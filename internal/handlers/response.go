@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// xmlRootElement is the root element name of XML responses
+const xmlRootElement = "response"
+
+// xmlListItemElement is the element name used for each entry of an array in XML responses
+const xmlListItemElement = "item"
+
+// xmlMapEntryElement is the element name used for object keys that are not valid XML names,
+// such as paths; the key is written to its key attribute instead
+const xmlMapEntryElement = "entry"
+
+// xmlNamePattern matches the object keys that can be used directly as XML element names
+var xmlNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// sendResponse writes body with the given status code, as JSON by default or as XML when the
+// client's Accept header prefers application/xml or text/xml
+// The XML document mirrors the JSON field names so both formats carry the same data.
+func sendResponse(c *fiber.Ctx, status int, body interface{}) error {
+	c.Status(status)
+
+	switch c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMEApplicationXML, fiber.MIMETextXML) {
+	case fiber.MIMEApplicationXML, fiber.MIMETextXML:
+		out, err := marshalXMLResponse(body)
+		if err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationXMLCharsetUTF8)
+		return c.Send(out)
+	default:
+		return c.JSON(body)
+	}
+}
+
+// marshalXMLResponse renders a response body as an XML document
+// The body is first converted through its JSON form so structs and fiber.Map values
+// produce the same element names as their JSON keys.
+func marshalXMLResponse(body interface{}) ([]byte, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	encoder := xml.NewEncoder(&buf)
+	if err := encodeXMLValue(encoder, xmlRootElement, generic); err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeXMLValue writes a decoded JSON value as an XML element with the given name
+// Objects become nested elements in key order (keys that are not valid element names become
+// <entry key="..."> elements), arrays become repeated <item> elements, and null values become
+// empty elements.
+func encodeXMLValue(encoder *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !xmlNamePattern.MatchString(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: xmlMapEntryElement},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encodeXMLValue(encoder, k, v[k]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXMLValue(encoder, xmlListItemElement, item); err != nil {
+				return err
+			}
+		}
+	case nil:
+		// Empty element
+	default:
+		if err := encoder.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeToken(start.End())
+}
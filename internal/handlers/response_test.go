@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// xmlListing mirrors the XML form of a /list response
+type xmlListing struct {
+	XMLName xml.Name `xml:"response"`
+	Path    string   `xml:"path"`
+	Files   []struct {
+		Name  string `xml:"name"`
+		Size  int64  `xml:"size"`
+		IsDir bool   `xml:"is_dir"`
+	} `xml:"files>item"`
+}
+
+func TestListHandler_XMLResponse(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := smb.NewMockExecutorWithOutput(
+		"  document.pdf                        A     1024  Mon Jan  1 12:34:56 2024\n" +
+			"  reports                             D        0  Mon Jan  1 10:00:00 2024\n")
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+
+	req := httptest.NewRequest("GET", "/list?path=docs", nil)
+	req.Header.Set("Accept", "application/xml")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test list endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, fiber.MIMEApplicationXML) {
+		t.Errorf("Expected XML content type, got %s", ct)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var listing xmlListing
+	if err := xml.Unmarshal(body, &listing); err != nil {
+		t.Fatalf("Expected valid XML, got error %v for: %s", err, string(body))
+	}
+
+	if listing.Path != "docs" {
+		t.Errorf("Expected path 'docs', got %q", listing.Path)
+	}
+	if len(listing.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %d: %s", len(listing.Files), string(body))
+	}
+	if listing.Files[0].Name != "document.pdf" || listing.Files[0].Size != 1024 || listing.Files[0].IsDir {
+		t.Errorf("Unexpected first file: %+v", listing.Files[0])
+	}
+	if listing.Files[1].Name != "reports" || !listing.Files[1].IsDir {
+		t.Errorf("Unexpected second file: %+v", listing.Files[1])
+	}
+}
+
+func TestListHandler_XMLErrorResponse(t *testing.T) {
+	os.Clearenv()
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+
+	req := httptest.NewRequest("GET", "/list", nil)
+	req.Header.Set("Accept", "text/xml")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test list endpoint: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", fiber.StatusInternalServerError, resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var errorBody struct {
+		XMLName xml.Name `xml:"response"`
+		Detail  string   `xml:"detail"`
+	}
+	if err := xml.Unmarshal(body, &errorBody); err != nil {
+		t.Fatalf("Expected valid XML, got error %v for: %s", err, string(body))
+	}
+	if !strings.Contains(errorBody.Detail, "Missing SMB configuration") {
+		t.Errorf("Expected missing config detail, got %q", errorBody.Detail)
+	}
+}
+
+func TestSendResponse_DefaultsToJSON(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		return sendResponse(c, fiber.StatusOK, fiber.Map{"status": "ok"})
+	})
+
+	for _, accept := range []string{"", "*/*", "application/json", "application/json, application/xml;q=0.5"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, fiber.MIMEApplicationJSON) {
+			t.Errorf("Accept %q: expected JSON content type, got %s", accept, ct)
+		}
+	}
+}
+
+func TestMarshalXMLResponse_EscapesValues(t *testing.T) {
+	out, err := marshalXMLResponse(fiber.Map{"detail": "a < b & c", "empty": nil})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := xml.Header + "<response><detail>a &lt; b &amp; c</detail><empty></empty></response>"
+	if string(out) != expected {
+		t.Errorf("Expected %s, got %s", expected, string(out))
+	}
+}
+
+func TestMarshalXMLResponse_PathKeys(t *testing.T) {
+	out, err := marshalXMLResponse(fiber.Map{"results": fiber.Map{"docs": 1, "archive/2024": 2}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := xml.Header + `<response><results><entry key="archive/2024">2</entry><docs>1</docs></results></response>`
+	if string(out) != expected {
+		t.Errorf("Expected %s, got %s", expected, string(out))
+	}
+}
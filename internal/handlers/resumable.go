@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+const (
+	// headerUploadOffset carries the byte offset of a resumable upload chunk, as in tus
+	headerUploadOffset = "Upload-Offset"
+	// headerUploadLength carries the total size of a resumable upload, as in tus
+	headerUploadLength = "Upload-Length"
+)
+
+var (
+	errSessionNotFound = errors.New("upload session not found")
+	errSessionBusy     = errors.New("a chunk is already being written to this upload session")
+	errOffsetMismatch  = errors.New("chunk offset does not match the upload session")
+)
+
+// uploadSession is a resumable upload being assembled in a staging file
+// Fields are ordered for optimal memory alignment
+type uploadSession struct {
+	expiresAt time.Time
+	opts      uploadOptions
+	cfg       *config.SMBConfig
+	ID        string
+	tmpPath   string
+	length    int64
+	offset    int64
+	ttl       time.Duration
+	busy      bool
+}
+
+// uploadSessionStore is an in-memory registry of resumable upload sessions
+// A session expires when no chunk has arrived for its TTL; expired sessions are purged lazily and
+// by the temp file janitor, removing their staging files.
+type uploadSessionStore struct {
+	sessions map[string]*uploadSession
+	mu       sync.Mutex
+}
+
+var uploadSessions = &uploadSessionStore{sessions: make(map[string]*uploadSession)}
+
+// create registers a new session and returns its ID
+func (s *uploadSessionStore) create(session *uploadSession) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.purgeExpiredLocked(now)
+
+	session.ID = newJobID()
+	session.expiresAt = now.Add(session.ttl)
+	s.sessions[session.ID] = session
+	return session.ID
+}
+
+// get returns the offset and length of a session if it exists and has not expired
+func (s *uploadSessionStore) get(id string) (offset, length int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpiredLocked(time.Now())
+	session, ok := s.sessions[id]
+	if !ok {
+		return 0, 0, errSessionNotFound
+	}
+	return session.offset, session.length, nil
+}
+
+// claim reserves a session for writing the chunk at offset
+// The caller owns the session until it calls release.
+func (s *uploadSessionStore) claim(id string, offset int64) (*uploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpiredLocked(time.Now())
+	session, ok := s.sessions[id]
+	switch {
+	case !ok:
+		return nil, errSessionNotFound
+	case session.busy:
+		return session, errSessionBusy
+	case offset != session.offset:
+		return session, errOffsetMismatch
+	}
+	session.busy = true
+	return session, nil
+}
+
+// release records the bytes written by the owner of a claimed session
+// A complete session is removed from the store and handed to the caller to relay.
+func (s *uploadSessionStore) release(session *uploadSession, written int64) (complete bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session.busy = false
+	session.offset += written
+	session.expiresAt = time.Now().Add(session.ttl)
+	if session.offset < session.length {
+		return false
+	}
+	delete(s.sessions, session.ID)
+	return true
+}
+
+// purgeExpired removes expired sessions and their staging files
+func (s *uploadSessionStore) purgeExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purgeExpiredLocked(now)
+}
+
+// purgeExpiredLocked removes expired sessions not being written; callers must hold s.mu
+func (s *uploadSessionStore) purgeExpiredLocked(now time.Time) {
+	for id, session := range s.sessions {
+		if !session.busy && now.After(session.expiresAt) {
+			delete(s.sessions, id)
+			removeStagedFile(session.tmpPath)
+		}
+	}
+}
+
+// uploadSessionRequest is the JSON body accepted by POST /uploads
+type uploadSessionRequest struct {
+	RemotePath   string `json:"remote_path"`
+	ModifiedTime string `json:"modified_time"`
+	Length       int64  `json:"length"`
+	Overwrite    bool   `json:"overwrite"`
+	ReadOnly     bool   `json:"read_only"`
+}
+
+// CreateUploadSessionHandler handles POST /uploads, starting a resumable upload
+// The total size comes from the length field or an Upload-Length header. Chunks are then sent
+// with PATCH /uploads/{id}, and the file is relayed to the share once the last one arrives.
+func CreateUploadSessionHandler(c *fiber.Ctx) error {
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Missing SMB configuration environment variables: %s", strings.Join(missing, ", ")),
+		})
+	}
+	if cfg.ReadOnly {
+		return sendReadOnly(c)
+	}
+
+	var req uploadSessionRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "request body must be a JSON object with a remote_path",
+		})
+	}
+	if req.Length == 0 && c.Get(headerUploadLength) != "" {
+		req.Length, _ = strconv.ParseInt(c.Get(headerUploadLength), 10, 64)
+	}
+	if req.RemotePath == "" {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "remote_path is required",
+		})
+	}
+	if req.Length <= 0 {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "length must be a positive number of bytes",
+		})
+	}
+	if detail := uploadTooLargeDetail(req.Length); detail != "" {
+		return sendResponse(c, fiber.StatusRequestEntityTooLarge, fiber.Map{
+			"detail": detail,
+		})
+	}
+
+	remotePath, err := smb.PrepareUploadPath(req.RemotePath, cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	modifiedTime, err := parseModifiedTime(req.ModifiedTime)
+	if err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	tmpPath, err := createStagingFile(pathpkg.Base(remotePath))
+	if err != nil {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Failed to create upload session: %v", err),
+		})
+	}
+
+	id := uploadSessions.create(&uploadSession{
+		opts: uploadOptions{
+			remotePath:   remotePath,
+			overwrite:    req.Overwrite,
+			readOnly:     req.ReadOnly,
+			modifiedTime: modifiedTime,
+		},
+		cfg:     cfg,
+		tmpPath: tmpPath,
+		length:  req.Length,
+		ttl:     config.LoadServerConfig().UploadSessionTTL,
+	})
+
+	uploadURL := "/uploads/" + id
+	c.Set(fiber.HeaderLocation, uploadURL)
+	return sendResponse(c, fiber.StatusCreated, fiber.Map{
+		"id":          id,
+		"upload_url":  uploadURL,
+		"remote_path": remotePath,
+		"offset":      0,
+		"length":      req.Length,
+	})
+}
+
+// UploadSessionHandler handles HEAD /uploads/{id}, reporting how much of an upload has arrived
+// A client resuming after a failure continues from the returned Upload-Offset.
+func UploadSessionHandler(c *fiber.Ctx) error {
+	offset, length, err := uploadSessions.get(c.Params("id"))
+	if err != nil {
+		return c.SendStatus(fiber.StatusNotFound)
+	}
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(headerUploadOffset, strconv.FormatInt(offset, 10))
+	c.Set(headerUploadLength, strconv.FormatInt(length, 10))
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// UploadChunkHandler handles PATCH /uploads/{id}, appending the body at the Upload-Offset header
+// The offset must be where the previous chunk ended, otherwise the chunk is rejected with 409 and the
+// current offset. The chunk completing the file relays it to the share and returns the upload
+// result; earlier chunks get 204 with the new Upload-Offset.
+func UploadChunkHandler(c *fiber.Ctx) error {
+	offset, err := strconv.ParseInt(c.Get(headerUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "Upload-Offset header must be a non-negative number of bytes",
+		})
+	}
+
+	session, err := uploadSessions.claim(c.Params("id"), offset)
+	switch {
+	case errors.Is(err, errSessionNotFound):
+		return sendResponse(c, fiber.StatusNotFound, fiber.Map{
+			"detail": err.Error(),
+		})
+	case errors.Is(err, errOffsetMismatch):
+		current, _, _ := uploadSessions.get(session.ID)
+		c.Set(headerUploadOffset, strconv.FormatInt(current, 10))
+		return sendResponse(c, fiber.StatusConflict, fiber.Map{
+			"detail": fmt.Sprintf("%v: expected offset %d, got %d", err, current, offset),
+			"offset": current,
+		})
+	case err != nil:
+		return sendResponse(c, fiber.StatusConflict, fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	written, status, detail := writeChunk(session, offset, c.Body())
+	if detail != "" {
+		uploadSessions.release(session, 0)
+		return sendResponse(c, status, fiber.Map{
+			"detail": detail,
+		})
+	}
+
+	complete := uploadSessions.release(session, written)
+	c.Set(headerUploadOffset, strconv.FormatInt(offset+written, 10))
+	if !complete {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+
+	defer removeStagedFile(session.tmpPath)
+	// The client chooses the chunk sizes, so the type is sniffed from the assembled file
+	typeDetail, err := checkStagedFileType(session.tmpPath)
+	if err != nil {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Failed to read uploaded file: %v", err),
+		})
+	}
+	if typeDetail != "" {
+		return sendResponse(c, fiber.StatusUnsupportedMediaType, fiber.Map{
+			"detail": typeDetail,
+		})
+	}
+
+	status, body := relayUpload(c.UserContext(), session.tmpPath, session.opts, session.cfg)
+	return sendResponse(c, status, body)
+}
+
+// writeChunk writes a chunk at offset into a claimed session's staging file
+// It returns the bytes written, or the status and detail of a rejected chunk.
+func writeChunk(session *uploadSession, offset int64, chunk []byte) (int64, int, string) {
+	if offset+int64(len(chunk)) > session.length {
+		return 0, fiber.StatusBadRequest, fmt.Sprintf(
+			"chunk of %d bytes at offset %d exceeds the upload length of %d bytes", len(chunk), offset, session.length)
+	}
+	f, err := os.OpenFile(session.tmpPath, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, fiber.StatusInternalServerError, fmt.Sprintf("Failed to save chunk: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(chunk, offset); err != nil {
+		return 0, fiber.StatusInternalServerError, fmt.Sprintf("Failed to save chunk: %v", err)
+	}
+	return int64(len(chunk)), fiber.StatusOK, ""
+}
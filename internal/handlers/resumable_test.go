@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// newResumableApp returns an app serving the resumable upload routes
+func newResumableApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/uploads", CreateUploadSessionHandler)
+	app.Head("/uploads/:id", UploadSessionHandler)
+	app.Patch("/uploads/:id", UploadChunkHandler)
+	return app
+}
+
+// createUploadSession starts a resumable upload and returns its upload URL
+func createUploadSession(t *testing.T, app *fiber.App, body string) string {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/uploads", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to create upload session: %v", err)
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusCreated, resp.StatusCode, string(respBody))
+	}
+	var created struct {
+		UploadURL string `json:"upload_url"`
+	}
+	if err := json.Unmarshal(respBody, &created); err != nil || created.UploadURL == "" {
+		t.Fatalf("Expected an upload_url, got %s", string(respBody))
+	}
+	if resp.Header.Get("Location") != created.UploadURL {
+		t.Errorf("Expected Location %q, got %q", created.UploadURL, resp.Header.Get("Location"))
+	}
+	return created.UploadURL
+}
+
+// sendChunk sends a chunk of a resumable upload at offset
+func sendChunk(t *testing.T, app *fiber.App, uploadURL string, offset int, chunk string) (int, string, string) {
+	t.Helper()
+	req := httptest.NewRequest("PATCH", uploadURL, strings.NewReader(chunk))
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to send chunk: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, resp.Header.Get("Upload-Offset"), string(body)
+}
+
+func TestResumableUpload_TwoChunks(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	putCommand := regexp.MustCompile(`^lcd "(.*)"; put "(.*)" "(.*)"$`)
+	var relayed, remotePath string
+	mock := smb.SetupSuccessfulMock()
+	successful := mock.ExecuteFunc
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		m := putCommand.FindStringSubmatch(args[len(args)-1])
+		if m == nil {
+			return successful(args)
+		}
+		content, err := os.ReadFile(filepath.Join(m[1], m[2]))
+		relayed, remotePath = string(content), m[3]
+		return "putting file", err
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := newResumableApp()
+	uploadURL := createUploadSession(t, app, `{"remote_path": "inbox/report.pdf", "length": 22}`)
+
+	status, offset, body := sendChunk(t, app, uploadURL, 0, "%PDF-1.4 first")
+	if status != fiber.StatusNoContent || offset != "14" {
+		t.Fatalf("Expected 204 at offset 14 after the first chunk, got %d at %q: %s", status, offset, body)
+	}
+
+	// A client resuming after a dropped connection asks where to continue
+	resp, err := app.Test(httptest.NewRequest("HEAD", uploadURL, nil))
+	if err != nil {
+		t.Fatalf("Failed to query upload session: %v", err)
+	}
+	if resp.Header.Get("Upload-Offset") != "14" || resp.Header.Get("Upload-Length") != "22" {
+		t.Errorf("Expected offset 14 of 22, got %q of %q",
+			resp.Header.Get("Upload-Offset"), resp.Header.Get("Upload-Length"))
+	}
+
+	status, offset, body = sendChunk(t, app, uploadURL, 14, " second.")
+	if status != fiber.StatusOK || offset != "22" {
+		t.Fatalf("Expected 200 at offset 22 after the last chunk, got %d at %q: %s", status, offset, body)
+	}
+	if !strings.Contains(body, `"remote_path":"inbox/report.pdf"`) {
+		t.Errorf("Expected the upload result, got %s", body)
+	}
+	if relayed != "%PDF-1.4 first second." || remotePath != "inbox/report.pdf" {
+		t.Errorf("Expected the assembled file at inbox/report.pdf, got %q at %q", relayed, remotePath)
+	}
+
+	// The finished session is gone
+	if status, _, _ := sendChunk(t, app, uploadURL, 22, "more"); status != fiber.StatusNotFound {
+		t.Errorf("Expected 404 for a finished session, got %d", status)
+	}
+}
+
+func TestResumableUpload_OffsetMismatch(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := newResumableApp()
+	uploadURL := createUploadSession(t, app, `{"remote_path": "inbox/report.pdf", "length": 20}`)
+
+	status, offset, body := sendChunk(t, app, uploadURL, 10, "second half")
+	if status != fiber.StatusConflict || offset != "0" {
+		t.Fatalf("Expected 409 reporting offset 0 for an out-of-order chunk, got %d at %q: %s", status, offset, body)
+	}
+
+	if status, _, body := sendChunk(t, app, uploadURL, 0, strings.Repeat("x", 21)); status != fiber.StatusBadRequest {
+		t.Errorf("Expected 400 for a chunk past the upload length, got %d: %s", status, body)
+	}
+
+	status, offset, _ = sendChunk(t, app, uploadURL, 0, "first half")
+	if status != fiber.StatusNoContent || offset != "10" {
+		t.Errorf("Expected the in-order chunk to be accepted at offset 10, got %d at %q", status, offset)
+	}
+	if mock.CallCount != 0 {
+		t.Errorf("Expected nothing relayed before the upload is complete, got %d smbclient calls", mock.CallCount)
+	}
+}
+
+func TestResumableUpload_AllowedMIMETypes(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("SMB_ALLOWED_MIME_TYPES", "text/plain")
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := newResumableApp()
+	uploadURL := createUploadSession(t, app, `{"remote_path": "inbox/notes.txt", "length": 9}`)
+
+	// A one-byte first chunk sniffs as text; the binary payload follows in the next chunk
+	if status, _, body := sendChunk(t, app, uploadURL, 0, "a"); status != fiber.StatusNoContent {
+		t.Fatalf("Expected 204 after the first chunk, got %d: %s", status, body)
+	}
+	status, _, body := sendChunk(t, app, uploadURL, 1, "\x7fELF\x02\x01\x01\x00")
+	if status != fiber.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415 for the assembled binary file, got %d: %s", status, body)
+	}
+	if mock.CallCount != 0 {
+		t.Errorf("Expected nothing relayed for a disallowed file type, got %d smbclient calls", mock.CallCount)
+	}
+}
+
+func TestCreateUploadSession_Validation(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("SMB_MAX_UPLOAD_BYTES", "100")
+
+	app := newResumableApp()
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"not JSON", "remote_path=a", fiber.StatusBadRequest},
+		{"missing remote_path", `{"length": 10}`, fiber.StatusBadRequest},
+		{"missing length", `{"remote_path": "a.pdf"}`, fiber.StatusBadRequest},
+		{"invalid path", `{"remote_path": "../a.pdf", "length": 10}`, fiber.StatusBadRequest},
+		{"too large", `{"remote_path": "a.pdf", "length": 101}`, fiber.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("POST", "/uploads", strings.NewReader(tt.body)))
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, string(body))
+			}
+		})
+	}
+
+	if resp, err := app.Test(httptest.NewRequest("HEAD", "/uploads/unknown", nil)); err != nil ||
+		resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown session, got %v, %v", resp, err)
+	}
+}
+
+func TestUploadSessionStore_PurgesExpired(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	tmpPath, err := createStagingFile("report.pdf")
+	if err != nil {
+		t.Fatalf("Failed to create staging file: %v", err)
+	}
+	id := uploadSessions.create(&uploadSession{tmpPath: tmpPath, length: 10, ttl: time.Minute})
+
+	uploadSessions.purgeExpired(time.Now())
+	if _, _, err := uploadSessions.get(id); err != nil {
+		t.Fatalf("Expected the session to be kept within its TTL, got %v", err)
+	}
+
+	uploadSessions.purgeExpired(time.Now().Add(2 * time.Minute))
+	if _, _, err := uploadSessions.get(id); !errors.Is(err, errSessionNotFound) {
+		t.Errorf("Expected the expired session to be purged, got %v", err)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the staging file to be removed, got %v", err)
+	}
+	if isTempFileInFlight(tmpPath) {
+		t.Error("Expected the staging file to be released from janitor protection")
+	}
+}
@@ -0,0 +1,976 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// Route is an HTTP endpoint of the service with its OpenAPI documentation
+// Fields are ordered for optimal memory alignment
+type Route struct {
+	// Handler serves the route; nil for routes the server registers itself, such as those that
+	// depend on its configuration, and for HEAD operations served by a GET route
+	Handler fiber.Handler
+	doc     *operation
+	// Method is the HTTP method; GET routes also answer HEAD requests
+	Method string
+	// Path is the Fiber route path; :name parameters become OpenAPI path parameters
+	Path     string
+	specPath string // OpenAPI path of a route whose Fiber path has a wildcard
+	// Admin routes require the ADMIN_TOKEN bearer token
+	Admin  bool
+	target bool // accepts the target parameter naming an SMB target
+}
+
+// timeoutDescription documents the 504 response of an SMB command that timed out
+const timeoutDescription = "The smbclient command did not finish within SMB_COMMAND_TIMEOUT"
+
+// readOnlyDescription documents the 403 response of a write to a share configured with SMB_READ_ONLY
+const readOnlyDescription = "The share is read-only (SMB_READ_ONLY)"
+
+// Routes returns the service's HTTP routes
+// The OpenAPI spec at /openapi.json is generated from them, so a route added here is documented
+// there with its parameters, request body and responses.
+func Routes() []Route {
+	return []Route{
+		{
+			Method: fiber.MethodGet,
+			Path:   "/",
+			doc: &operation{
+				Summary: "Service index",
+				Description: "Lists the service name, version and registered endpoints. With ROOT_REDIRECT set, " +
+					"redirects there instead, e.g. to /docs",
+				Responses: map[string]response{
+					"200": {Description: "Endpoint index"},
+					"302": {Description: "Redirect to ROOT_REDIRECT"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/livez",
+			Handler: LivezHandler,
+			doc: &operation{
+				Summary: "Liveness check endpoint",
+				Description: "Reports that the process is up without checking SMB connectivity. Use it for liveness " +
+					"probes and /health for readiness probes",
+				Responses: map[string]response{
+					"200": {Description: "Application is running"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/health",
+			Handler: HealthHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "Readiness check endpoint",
+				Description: "Verifies application responsiveness and SMB connectivity. With HEALTH_WRITE_TEST enabled, " +
+					"also verifies the share is writable (smb_writable). smbclient_available is false when the smbclient " +
+					"binary is not installed",
+				Responses: map[string]response{
+					"200": {Description: "Application and SMB server are healthy"},
+					"503": {
+						Description: "Application is unhealthy or SMB server is inaccessible",
+						Content:     jsonContent(&schema{Type: "object", Description: "Health report naming the failed checks"}),
+					},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/list",
+			Handler: ListHandler,
+			target:  true,
+			doc: &operation{
+				Summary:     "List files and folders",
+				Description: "Lists files and folders at a given path on the SMB share",
+				Parameters: []parameter{
+					{
+						Name:        "path",
+						In:          "query",
+						Description: "Path within the SMB share (defaults to root)",
+						Schema:      &schema{Type: "string", Default: ""},
+					},
+					{
+						Name:        "with_checksums",
+						In:          "query",
+						Description: "Include each file's SHA-256 from a sibling <name>.sha256 companion file, when one exists",
+						Schema:      &schema{Type: "boolean", Default: false},
+					},
+					{
+						Name:        "recursive",
+						In:          "query",
+						Description: "Also list subdirectories, up to SMB_MAX_LIST_DEPTH levels deep; names are relative to path",
+						Schema:      &schema{Type: "boolean", Default: false},
+					},
+					{
+						Name: "fields",
+						In:   "query",
+						Description: "Comma-separated file entry fields to return (name, size, is_dir, read_only, hidden, " +
+							"system, modified, modified_unix, timestamp, sha256); all fields when omitted",
+						Schema: &schema{Type: "string"},
+					},
+					{
+						Name: "pattern",
+						In:   "query",
+						Description: "Glob pattern entry names must match, e.g. *.pdf or report-??.xlsx; case-insensitive " +
+							"unless match_case is true",
+						Schema: &schema{Type: "string"},
+					},
+					{
+						Name:        "match_case",
+						In:          "query",
+						Description: "Match pattern case-sensitively",
+						Schema:      &schema{Type: "boolean", Default: false},
+					},
+					{
+						Name:        "files_only",
+						In:          "query",
+						Description: "Leave directories out of the listing",
+						Schema:      &schema{Type: "boolean", Default: false},
+					},
+					{
+						Name:        "sort",
+						In:          "query",
+						Description: "Field to sort entries by; ties are ordered by name",
+						Schema:      &schema{Type: "string", Enum: []string{"name", "size", "time"}, Default: "name"},
+					},
+					{
+						Name:        "order",
+						In:          "query",
+						Description: "Sort order",
+						Schema:      &schema{Type: "string", Enum: []string{"asc", "desc"}, Default: "asc"},
+					},
+					{
+						Name:        "limit",
+						In:          "query",
+						Description: "Maximum number of entries to return",
+						Schema: &schema{
+							Type:    "integer",
+							Default: defaultListLimit,
+							Minimum: intPtr(1),
+							Maximum: intPtr(maxListLimit),
+						},
+					},
+					{
+						Name:        "offset",
+						In:          "query",
+						Description: "Number of sorted entries to skip",
+						Schema:      &schema{Type: "integer", Default: 0, Minimum: intPtr(0)},
+					},
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "List of files and folders",
+						Content: jsonContent(&schema{
+							Type: "object",
+							Properties: map[string]*schema{
+								"files": {
+									Type: "array",
+									Items: &schema{
+										Type: "object",
+										Properties: map[string]*schema{
+											"hidden":        &schema{Type: "boolean"},
+											"is_dir":        &schema{Type: "boolean"},
+											"modified":      &schema{Type: "string", Format: "date-time"},
+											"modified_unix": {Type: "integer", Description: "Modification time in seconds since the Unix epoch"},
+											"name":          &schema{Type: "string"},
+											"read_only":     &schema{Type: "boolean"},
+											"sha256":        &schema{Type: "string"},
+											"size":          &schema{Type: "integer"},
+											"system":        &schema{Type: "boolean"},
+											"timestamp":     &schema{Type: "string"},
+										},
+									},
+								},
+								"has_more": {Type: "boolean", Description: "Whether entries follow this page"},
+								"path":     &schema{Type: "string"},
+								"total":    &schema{Type: "integer", Description: "Number of entries in the whole listing, after filtering"},
+								"warning": {
+									Type:        "string",
+									Description: "Set when a recursive listing skipped subdirectories it could not access",
+								},
+							},
+						}),
+					},
+					"400": {
+						Description: "Path exceeds SMB_MAX_PATH_DEPTH or SMB_MAX_NAME_LENGTH, or an invalid fields, pattern, " +
+							"sort, order, limit or offset parameter",
+					},
+					"403": {Description: "Access denied"},
+					"404": {Description: "Path not found"},
+					"500": {Description: "Server error"},
+					"504": {Description: timeoutDescription},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodPost,
+			Path:    "/list/batch",
+			Handler: BatchListHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "List several directories",
+				Description: "Lists up to 100 directories in a single SMB session. Paths that cannot be listed get a " +
+					"per-path error entry instead of failing the request",
+				RequestBody: &requestBody{
+					Required: true,
+					Content: jsonContent(&schema{
+						Type:     "object",
+						Required: []string{"paths"},
+						Properties: map[string]*schema{
+							"paths": {Type: "array", MinItems: 1, MaxItems: maxBatchListPaths, Items: &schema{Type: "string"}},
+						},
+					}),
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "Map of each path to its listing ({files}) or error ({detail, status_code})",
+						Content: jsonContent(&schema{
+							Type: "object",
+							Properties: map[string]*schema{
+								"failed": {Type: "integer"},
+								"results": {
+									Type: "object",
+									AdditionalProperties: &schema{
+										Type: "object",
+										Properties: map[string]*schema{
+											"detail":      &schema{Type: "string"},
+											"files":       &schema{Type: "array", Items: &schema{Type: "object"}},
+											"status_code": {Type: "integer"},
+										},
+									},
+								},
+							},
+						}),
+					},
+					"400": {Description: "Missing, empty or oversized paths array"},
+					"500": {Description: "SMB session failed or server error"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/stale",
+			Handler: StaleHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "List stale files",
+				Description: "Recursively lists files last modified before the older_than window, as candidates for " +
+					"retention cleanup. Nothing is deleted",
+				Parameters: []parameter{
+					{
+						Name:        "path",
+						In:          "query",
+						Description: "Path within the SMB share to search (defaults to root)",
+						Schema:      &schema{Type: "string", Default: ""},
+					},
+					{
+						Name:        "older_than",
+						In:          "query",
+						Description: "Minimum age of files to return, e.g. 30d, 2w, 12h or 90m",
+						Required:    true,
+						Schema:      &schema{Type: "string"},
+					},
+				},
+				Responses: map[string]response{
+					"200": {Description: "Files older than the window, with names relative to path"},
+					"400": {Description: "Missing or invalid older_than"},
+					"403": {Description: "Access denied"},
+					"404": {Description: "Path not found"},
+					"500": {Description: "Server error"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodPost,
+			Path:    "/upload",
+			Handler: UploadHandler,
+			target:  true,
+			doc: &operation{
+				Summary:     "Upload file to SMB share",
+				Description: "Accepts multipart/form-data and writes file to SMB share",
+				Parameters: []parameter{
+					{
+						Name:        "async",
+						In:          "query",
+						Description: "Return 202 immediately and perform the SMB write in the background",
+						Schema:      &schema{Type: "boolean", Default: false},
+					},
+					{
+						Name: "echo",
+						In:   "query",
+						Description: "Include an echo object describing the received file: detected content_type, " +
+							"declared_content_type, filename, size and resolved_path",
+						Schema: &schema{Type: "boolean", Default: false},
+					},
+				},
+				RequestBody: &requestBody{
+					Required: true,
+					Content: map[string]mediaType{
+						fiber.MIMEMultipartForm: {Schema: &schema{
+							Type:     "object",
+							Required: []string{"file"},
+							Properties: map[string]*schema{
+								"expected_sha256": {
+									Type:        "string",
+									Description: "SHA-256 of the file; the upload is rejected if the received file does not match",
+									Pattern:     "^[0-9a-fA-F]{64}$",
+								},
+								"file": {
+									Type:        "string",
+									Format:      "binary",
+									Description: "The file to upload; repeat the part to upload several files",
+								},
+								"modified_time": {
+									Type:        "string",
+									Format:      "date-time",
+									Description: "RFC 3339 last write time to give the uploaded file (best-effort)",
+								},
+								"overwrite": {Type: "boolean", Description: "Whether to overwrite existing files", Default: false},
+								"read_only": {
+									Type:        "boolean",
+									Description: "Set the DOS read-only attribute on the uploaded file (best-effort)",
+									Default:     false,
+								},
+								"remote_path": {
+									Type: "string",
+									Description: "Path within the SMB share. If it ends with / or \\, the uploaded filename is " +
+										"appended. Repeat it once per file when uploading several files. Required unless " +
+										"SMB_DEFAULT_UPLOAD_PATH is set, in which case files sent without it go to that " +
+										"directory under their original filename.",
+								},
+							},
+						}},
+					},
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "Upload successful. With SMB_DEDUP, a file whose content is already on the share " +
+							"is not transferred; deduplicated is true and remote_path names the existing file",
+					},
+					"202": {
+						Description: "Upload accepted for background processing (async=true); poll status_url for the outcome",
+					},
+					"207": {Description: "Several files were sent; results holds each file's outcome"},
+					"400": {
+						Description: "Invalid request, received file does not match expected_sha256, or remote path is an " +
+							"existing directory",
+					},
+					"403": {Description: readOnlyDescription},
+					"404": {Description: "Parent directory does not exist and SMB_AUTO_MKDIR is disabled"},
+					"409": {Description: "File exists and overwrite is false"},
+					"413": {Description: "File is larger than SMB_MAX_UPLOAD_BYTES"},
+					"415": {Description: "File content is not one of SMB_ALLOWED_MIME_TYPES"},
+					"500": {Description: "Upload failed, or SMB_VERIFY_UPLOAD found the stored file does not match"},
+					"504": {Description: timeoutDescription},
+					"507": {Description: "The SMB share is full, or has less free space than the file needs"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/download",
+			Handler: DownloadHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "Download file from SMB share",
+				Description: "Returns the file at the specified path as an attachment. The ETag and Last-Modified " +
+					"headers are derived from the file's size and modification time; requests with a matching " +
+					"If-None-Match or a current If-Modified-Since get 304 without the file being transferred",
+				Parameters: []parameter{
+					{
+						Name:        "path",
+						In:          "query",
+						Description: "Path to the file within the SMB share",
+						Required:    true,
+						Schema:      &schema{Type: "string"},
+					},
+					{
+						Name:        "If-None-Match",
+						In:          "header",
+						Description: "ETag of the client's cached copy",
+						Schema:      &schema{Type: "string"},
+					},
+					{
+						Name:        "If-Modified-Since",
+						In:          "header",
+						Description: "Last-Modified of the client's cached copy; ignored when If-None-Match is sent",
+						Schema:      &schema{Type: "string"},
+					},
+					{
+						Name:        "Range",
+						In:          "header",
+						Description: "A single byte range, e.g. bytes=0-1023, bytes=1024- or bytes=-512",
+						Schema:      &schema{Type: "string"},
+					},
+					{
+						Name:        "If-Range",
+						In:          "header",
+						Description: "Last-Modified the Range applies to; the whole file is sent if it changed",
+						Schema:      &schema{Type: "string"},
+					},
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "File content",
+						Headers: map[string]header{
+							"ETag":          {Schema: &schema{Type: "string"}},
+							"Last-Modified": {Schema: &schema{Type: "string"}},
+						},
+						Content: map[string]mediaType{
+							fiber.MIMEOctetStream: {Schema: &schema{Type: "string", Format: "binary"}},
+						},
+					},
+					"206": {Description: "The requested byte range, described by the Content-Range header"},
+					"304": {Description: "The client's cached copy is current"},
+					"400": {Description: "Missing or invalid path, or the path is a directory"},
+					"403": {Description: "Access denied"},
+					"404": {Description: "File not found"},
+					"416": {Description: "The byte range starts past the end of the file"},
+					"500": {Description: "Missing SMB configuration or server error"},
+					"504": {Description: timeoutDescription},
+				},
+			},
+		},
+		{
+			Method: fiber.MethodHead,
+			Path:   "/download",
+			target: true,
+			doc: &operation{
+				Summary: "Check whether a file exists",
+				Description: "Returns the headers of GET /download, including Content-Length, without a body and " +
+					"without transferring the file. Cheaper than GET /list for a single path",
+				Parameters: []parameter{
+					{
+						Name:        "path",
+						In:          "query",
+						Description: "Path to the file within the SMB share",
+						Required:    true,
+						Schema:      &schema{Type: "string"},
+					},
+				},
+				Responses: map[string]response{
+					"200": {Description: "The file exists"},
+					"400": {Description: "Missing or invalid path, or the path is a directory"},
+					"403": {Description: "Access denied"},
+					"404": {Description: "File not found"},
+					"500": {Description: "Missing SMB configuration or server error"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/download-zip",
+			Handler: DownloadZipHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "Download a directory as a ZIP archive",
+				Description: "Streams the files below a directory as a ZIP archive keeping their relative paths. Files " +
+					"that cannot be fetched are logged and left out unless strict is set",
+				Parameters: []parameter{
+					{
+						Name:        "path",
+						In:          "query",
+						Description: "Path to the directory within the SMB share (default: the share root)",
+						Schema:      &schema{Type: "string"},
+					},
+					{
+						Name:        "strict",
+						In:          "query",
+						Description: "Fetch every file before responding and fail instead of leaving files out",
+						Schema:      &schema{Type: "boolean", Default: false},
+					},
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "ZIP archive of the directory",
+						Content: map[string]mediaType{
+							"application/zip": {Schema: &schema{Type: "string", Format: "binary"}},
+						},
+					},
+					"400": {Description: "Invalid path, or the path is not a directory"},
+					"403": {Description: "Access denied, or in strict mode a subdirectory could not be read"},
+					"404": {Description: "Directory not found, or in strict mode a file disappeared"},
+					"500": {Description: "Missing SMB configuration or server error"},
+					"504": {Description: timeoutDescription},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/diskusage",
+			Handler: DiskUsageHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "Share capacity",
+				Description: "Total, free and used bytes of the share as reported by the SMB server for the configured " +
+					"account, so quotas are reflected",
+				Responses: map[string]response{
+					"200": {
+						Description: "Share capacity in bytes",
+						Content: jsonContent(&schema{
+							Type: "object",
+							Properties: map[string]*schema{
+								"free_bytes":  &schema{Type: "integer"},
+								"total_bytes": {Type: "integer"},
+								"used_bytes":  &schema{Type: "integer"},
+							},
+						}),
+					},
+					"403": {Description: "Access to the share is denied"},
+					"404": {Description: "The share or SMB_BASE_PATH does not exist"},
+					"500": {Description: "SMB configuration is incomplete or the listing failed"},
+					"501": {Description: "The SMB server does not report the share's capacity"},
+					"504": {Description: timeoutDescription},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodDelete,
+			Path:    "/delete",
+			Handler: DeleteHandler,
+			target:  true,
+			doc: &operation{
+				Summary:     "Delete file from SMB share",
+				Description: "Deletes a file at the specified path on the SMB share",
+				Parameters: []parameter{
+					{
+						Name:        "path",
+						In:          "query",
+						Description: "Path to the file within the SMB share",
+						Required:    true,
+						Schema:      &schema{Type: "string"},
+					},
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "File deleted successfully",
+						Content: jsonContent(&schema{
+							Type: "object",
+							Properties: map[string]*schema{
+								"path":   &schema{Type: "string"},
+								"status": {Type: "string"},
+							},
+						}),
+					},
+					"400": {Description: "Invalid path or attempting to delete directory"},
+					"403": {Description: "Access denied, or the share is read-only (SMB_READ_ONLY)"},
+					"404": {Description: "File not found"},
+					"500": {Description: "Server error"},
+					"504": {Description: timeoutDescription},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodPost,
+			Path:    "/mkdir",
+			Handler: MkdirHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "Create a directory",
+				Description: "Creates a directory on the SMB share along with any missing parents. Succeeds if the " +
+					"directory already exists",
+				RequestBody: &requestBody{
+					Required: true,
+					Content: jsonContent(&schema{
+						Type:     "object",
+						Required: []string{"path"},
+						Properties: map[string]*schema{
+							"path": {Type: "string"},
+						},
+					}),
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "Directory exists",
+						Content: jsonContent(&schema{
+							Type: "object",
+							Properties: map[string]*schema{
+								"path":   &schema{Type: "string"},
+								"status": {Type: "string"},
+							},
+						}),
+					},
+					"400": {Description: "Missing, invalid or root path"},
+					"403": {Description: "Access denied, or the share is read-only (SMB_READ_ONLY)"},
+					"409": {Description: "A file exists at the path or one of its parents"},
+					"500": {Description: "Server error"},
+					"504": {Description: timeoutDescription},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodPost,
+			Path:    "/move",
+			Handler: MoveHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "Move or rename a file",
+				Description: "Moves a file to another path on the same share without downloading it. The destination's " +
+					"parent directory is created unless SMB_AUTO_MKDIR is disabled",
+				RequestBody: &requestBody{
+					Required: true,
+					Content: jsonContent(&schema{
+						Type:     "object",
+						Required: []string{"source", "destination"},
+						Properties: map[string]*schema{
+							"destination": {Type: "string"},
+							"source":      &schema{Type: "string"},
+						},
+					}),
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "File moved successfully",
+						Content: jsonContent(&schema{
+							Type: "object",
+							Properties: map[string]*schema{
+								"destination": {Type: "string"},
+								"source":      &schema{Type: "string"},
+								"status":      &schema{Type: "string"},
+							},
+						}),
+					},
+					"400": {Description: "Missing or invalid source or destination"},
+					"403": {Description: "Access denied, or the share is read-only (SMB_READ_ONLY)"},
+					"404": {Description: "Source file not found"},
+					"409": {Description: "Destination already exists"},
+					"500": {Description: "Server error"},
+					"504": {Description: timeoutDescription},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodPost,
+			Path:    "/batch",
+			Handler: BatchHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "Run several operations in order",
+				Description: "Runs up to 100 upload, delete, rename and mkdir operations in order. With stop_on_error " +
+					"(the default) nothing after the first failure is run; otherwise all operations run in one SMB " +
+					"session. Uploads reference a file part of the multipart request",
+				RequestBody: &requestBody{
+					Required: true,
+					Content: map[string]mediaType{
+						fiber.MIMEApplicationJSON: {Schema: batchRequestSchema()},
+						fiber.MIMEMultipartForm: {Schema: &schema{
+							Type:     "object",
+							Required: []string{batchFormField},
+							Properties: map[string]*schema{
+								"batch": {
+									Type:        "string",
+									Description: "The batch description as JSON; other parts hold uploaded files",
+								},
+							},
+							AdditionalProperties: &schema{Type: "string", Format: "binary"},
+						}},
+					},
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "Result of each operation: ok, failed (with detail and status_code) or skipped",
+						Content: jsonContent(&schema{
+							Type: "object",
+							Properties: map[string]*schema{
+								"failed": {Type: "integer"},
+								"results": {
+									Type: "array",
+									Items: &schema{
+										Type: "object",
+										Properties: map[string]*schema{
+											"detail":      &schema{Type: "string"},
+											"op":          &schema{Type: "string"},
+											"path":        &schema{Type: "string"},
+											"status":      &schema{Type: "string", Enum: []string{batchStepOK, batchStepFailed, batchStepSkipped}},
+											"status_code": {Type: "integer"},
+											"to":          &schema{Type: "string"},
+										},
+									},
+								},
+								"skipped":   &schema{Type: "integer"},
+								"succeeded": {Type: "integer"},
+							},
+						}),
+					},
+					"400": {Description: "Invalid batch; no operation was run"},
+					"403": {Description: readOnlyDescription},
+					"500": {Description: "Missing SMB configuration"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/jobs/:id",
+			Handler: JobStatusHandler,
+			doc: &operation{
+				Summary:     "Get async upload job status",
+				Description: "Returns the status of an upload started with async=true",
+				Parameters: []parameter{
+					{
+						Name:        "id",
+						In:          "path",
+						Description: "Job ID returned by POST /upload?async=true",
+						Required:    true,
+						Schema:      &schema{Type: "string"},
+					},
+				},
+				Responses: map[string]response{
+					"200": {Description: "Job status (pending, running, completed or failed)"},
+					"404": {Description: "Job not found or expired"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodPost,
+			Path:    "/uploads",
+			Handler: CreateUploadSessionHandler,
+			target:  true,
+			doc: &operation{
+				Summary: "Start a resumable upload",
+				Description: "Creates an upload session for a file of the given length. Send its content in chunks with " +
+					"PATCH /uploads/{id}; the file is relayed to the share when the last chunk arrives",
+				RequestBody: &requestBody{
+					Required: true,
+					Content: jsonContent(&schema{
+						Type:     "object",
+						Required: []string{"remote_path", "length"},
+						Properties: map[string]*schema{
+							"length":        &schema{Type: "integer"},
+							"modified_time": {Type: "string", Format: "date-time"},
+							"overwrite":     &schema{Type: "boolean"},
+							"read_only":     &schema{Type: "boolean"},
+							"remote_path":   &schema{Type: "string"},
+						},
+					}),
+				},
+				Responses: map[string]response{
+					"201": {Description: "Session created; upload_url and the Location header name it"},
+					"400": {Description: "Missing or invalid remote_path or length"},
+					"403": {Description: readOnlyDescription},
+					"413": {Description: "length exceeds SMB_MAX_UPLOAD_BYTES"},
+					"500": {Description: "Missing SMB configuration, or the staging file could not be created"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodHead,
+			Path:    "/uploads/:id",
+			Handler: UploadSessionHandler,
+			doc: &operation{
+				Summary:     "Get the offset of a resumable upload",
+				Description: "Returns the bytes received so far as Upload-Offset and the total as Upload-Length",
+				Parameters: []parameter{
+					{
+						Name:        "id",
+						In:          "path",
+						Description: "Upload session ID returned by POST /uploads",
+						Required:    true,
+						Schema:      &schema{Type: "string"},
+					},
+				},
+				Responses: map[string]response{
+					"200": {Description: "Session found"},
+					"404": {Description: "Session not found, finished or expired"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodPatch,
+			Path:    "/uploads/:id",
+			Handler: UploadChunkHandler,
+			doc: &operation{
+				Summary: "Send a chunk of a resumable upload",
+				Description: "Writes the body at the Upload-Offset header, which must equal the bytes received so far. " +
+					"The chunk completing the file relays it to the share and returns the upload result",
+				Parameters: []parameter{
+					{
+						Name:        "id",
+						In:          "path",
+						Description: "Upload session ID returned by POST /uploads",
+						Required:    true,
+						Schema:      &schema{Type: "string"},
+					},
+				},
+				RequestBody: &requestBody{
+					Required: true,
+					Content: map[string]mediaType{
+						"application/offset+octet-stream": {Schema: &schema{Type: "string", Format: "binary"}},
+					},
+				},
+				Responses: map[string]response{
+					"200": {Description: "Last chunk received and the file uploaded; same body as POST /upload"},
+					"204": {Description: "Chunk received; Upload-Offset holds the new offset"},
+					"400": {Description: "Missing Upload-Offset, or the chunk runs past the upload length"},
+					"404": {Description: "Session not found, finished or expired"},
+					"409": {Description: "Upload-Offset does not match the bytes received, which Upload-Offset reports"},
+					"415": {Description: "The file type is not in SMB_ALLOWED_MIME_TYPES"},
+					"500": {Description: "Relaying the completed file to the share failed"},
+					"504": {Description: timeoutDescription},
+					"507": {Description: "The SMB share is full, or has less free space than the file needs"},
+				},
+			},
+		},
+		{
+			Method:   fiber.MethodPut,
+			Path:     "/objects/*",
+			specPath: "/objects/{key}",
+			Handler:  ObjectPutHandler,
+			target:   true,
+			doc: &operation{
+				Summary: "Store an object",
+				Description: "Stores the raw request body at the remote path named by the key (below SMB_BASE_PATH), " +
+					"replacing any existing file",
+				Parameters: []parameter{
+					objectKeyParameter,
+				},
+				RequestBody: &requestBody{
+					Required: true,
+					Content: map[string]mediaType{
+						fiber.MIMEOctetStream: {Schema: &schema{Type: "string", Format: "binary"}},
+					},
+				},
+				Responses: map[string]response{
+					"200": {Description: "Object stored"},
+					"400": {Description: "Missing or invalid key"},
+					"403": {Description: "Access denied, or the share is read-only (SMB_READ_ONLY)"},
+					"413": {Description: "Object exceeds SMB_MAX_UPLOAD_BYTES"},
+					"415": {Description: "Object content type not in SMB_ALLOWED_MIME_TYPES"},
+					"500": {Description: "Missing SMB configuration or server error"},
+					"504": {Description: timeoutDescription},
+					"507": {Description: "The SMB share is full"},
+				},
+			},
+		},
+		{
+			Method:   fiber.MethodGet,
+			Path:     "/objects/*",
+			specPath: "/objects/{key}",
+			Handler:  ObjectGetHandler,
+			target:   true,
+			doc: &operation{
+				Summary:     "Fetch an object",
+				Description: "Returns the file at the remote path named by the key as the response body",
+				Parameters: []parameter{
+					objectKeyParameter,
+				},
+				Responses: map[string]response{
+					"200": {
+						Description: "Object content",
+						Content: map[string]mediaType{
+							fiber.MIMEOctetStream: {Schema: &schema{Type: "string", Format: "binary"}},
+						},
+					},
+					"403": {Description: "Access denied"},
+					"404": {Description: "Object not found"},
+					"504": {Description: timeoutDescription},
+					"500": {Description: "Missing SMB configuration or server error"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/version",
+			Handler: VersionHandler,
+			doc: &operation{
+				Summary: "Service and smbclient versions",
+				Description: "Reports the service version and build, the Go version and the smbclient version. The " +
+					"status is degraded when smbclient cannot be run",
+				Responses: map[string]response{
+					"200": {Description: "Version report"},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/diagnostics",
+			Handler: DiagnosticsHandler,
+			Admin:   true,
+			target:  true,
+			doc: &operation{
+				Summary: "Environment diagnostics",
+				Description: "Reports the smbclient binary path and version, Go runtime version and effective feature " +
+					"flags for support tickets. Requires the ADMIN_TOKEN bearer token",
+				Responses: map[string]response{
+					"200": {Description: "Diagnostics report"},
+					"401": {Description: "Missing or invalid admin token"},
+					"403": {Description: "Admin endpoints are disabled because ADMIN_TOKEN is not set"},
+				},
+				Security: []map[string][]string{{"adminToken": {}}},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/openapi.json",
+			Handler: GetOpenAPISpec,
+			doc: &operation{
+				Summary:     "OpenAPI specification",
+				Description: "This document, generated from the service's routes",
+				Responses: map[string]response{
+					"200": {
+						Description: "OpenAPI 3.0 document",
+						Content:     jsonContent(&schema{Type: "object"}),
+					},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/docs",
+			Handler: ServeSwaggerUI,
+			doc: &operation{
+				Summary:     "API documentation",
+				Description: "Swagger UI for the OpenAPI specification at /openapi.json",
+				Responses: map[string]response{
+					"200": {
+						Description: "Swagger UI page",
+						Content: map[string]mediaType{
+							fiber.MIMETextHTML: {Schema: &schema{Type: "string"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			Method:  fiber.MethodGet,
+			Path:    "/docs/swagger-ui-dist/:file",
+			Handler: SwaggerUIAssetHandler,
+			doc: &operation{
+				Summary:     "Swagger UI asset",
+				Description: "Stylesheet or script of the Swagger UI page, embedded in the server so /docs needs no CDN",
+				Parameters: []parameter{{
+					Name:     "file",
+					In:       "path",
+					Required: true,
+					Schema: &schema{
+						Type: "string",
+						Enum: []string{"swagger-ui.css", "swagger-ui-bundle.js", "swagger-ui-standalone-preset.js"},
+					},
+				}},
+				Responses: map[string]response{
+					"200": {Description: "Asset content"},
+					"404": {Description: "Unknown asset"},
+				},
+			},
+		},
+		{
+			Method: fiber.MethodGet,
+			Path:   "/metrics",
+			doc: &operation{
+				Summary: "Prometheus metrics",
+				Description: "Request counts by route and status, smbclient process durations and in-flight smbclient " +
+					"processes in the Prometheus text format. Only served when PROMETHEUS_ENABLED is true",
+				Responses: map[string]response{
+					"200": {
+						Description: "Metrics in the Prometheus text exposition format",
+						Content: map[string]mediaType{
+							fiber.MIMETextPlain: {Schema: &schema{Type: "string"}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxStreamFieldSize caps the size of a form field read while streaming an upload
+const maxStreamFieldSize = 64 * 1024
+
+var errStreamTooLarge = errors.New("streamed file exceeds the maximum upload size")
+
+// uploadLimitReader fails a streamed upload once more than limit bytes of the file have been read
+// It cancels the upload's context before failing, so smbclient is killed rather than left to store
+// the truncated file it would see when its stdin closes.
+type uploadLimitReader struct {
+	r        io.Reader
+	cancel   context.CancelFunc
+	limit    int64
+	n        int64
+	exceeded atomic.Bool
+}
+
+// Read reads from the file part, counting the bytes against the limit
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		l.exceeded.Store(true)
+		l.cancel()
+		return 0, errStreamTooLarge
+	}
+	return n, err
+}
+
+// streamUpload handles POST /upload when SMB_STREAM_UPLOADS is enabled
+// The multipart body is read part by part and each file is piped to smbclient as it arrives, so it
+// is never written to the temp directory. Form fields must therefore precede the file part they
+// apply to. Several file parts are paired with remote_path values like a staged multi-file upload
+// and get a 207 response with a result per file. With SMB_MAX_UPLOAD_BYTES set, a body declaring a
+// larger Content-Length is rejected before it is read, and a body of unknown length, such as a
+// chunked one, is cut off as soon as a file passes the limit.
+func streamUpload(c *fiber.Ctx, cfg *config.SMBConfig) error {
+	// The file size is not known up front, so the limit is applied to the declared body size
+	serverCfg := config.LoadServerConfig()
+	if length := c.Context().Request.Header.ContentLength(); serverCfg.MaxUploadBytes > 0 &&
+		length > serverCfg.BodyLimit() {
+		// The unread body must not be parsed as the next request on this connection
+		c.Set(fiber.HeaderConnection, "close")
+		return sendResponse(c, fiber.StatusRequestEntityTooLarge, fiber.Map{
+			"detail": fmt.Sprintf("request body is too large: %d bytes exceeds the maximum upload size of %d bytes",
+				length, serverCfg.MaxUploadBytes),
+		})
+	}
+
+	body := c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	reader := multipart.NewReader(body, string(c.Context().Request.Header.MultipartFormBoundary()))
+
+	fields := make(map[string]string)
+	var remotePaths []string
+	var results []fiber.Map
+	var single fiber.Map
+	status := fiber.StatusOK
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+				"detail": fmt.Sprintf("invalid multipart form: %v", err),
+			})
+		}
+
+		if part.FormName() == "file" {
+			remotePath := streamedRemotePath(remotePaths, len(results), part.FileName(), cfg)
+			var cutOff bool
+			status, single, cutOff = streamUploadPart(c, part, remotePath, len(results), fields, cfg)
+			if !cutOff {
+				cutOff, err = skipStreamedFile(part, serverCfg.MaxUploadBytes)
+			}
+			if err != nil {
+				return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+					"detail": fmt.Sprintf("invalid multipart form: %v", err),
+				})
+			}
+			if cutOff {
+				// The rest of the body is left unread
+				c.Set(fiber.HeaderConnection, "close")
+				return sendResponse(c, fiber.StatusRequestEntityTooLarge, fiber.Map{
+					"detail": fmt.Sprintf("file is too large: exceeds the maximum upload size of %d bytes",
+						serverCfg.MaxUploadBytes),
+				})
+			}
+			results = append(results, uploadFileResult(remotePath, status, single))
+			continue
+		}
+
+		value, err := readStreamedField(part)
+		if err != nil {
+			return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		if part.FormName() == "remote_path" {
+			remotePaths = append(remotePaths, value)
+		}
+		if _, seen := fields[part.FormName()]; !seen {
+			fields[part.FormName()] = value
+		}
+	}
+
+	switch len(results) {
+	case 0:
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": "file is required",
+		})
+	case 1:
+		return sendResponse(c, status, single)
+	default:
+		return sendUploadResults(c, results)
+	}
+}
+
+// readStreamedField reads the value of a form field part of a streamed upload
+func readStreamedField(part *multipart.Part) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxStreamFieldSize+1))
+	if err != nil {
+		return "", fmt.Errorf("invalid multipart form: %v", err)
+	}
+	if len(value) > maxStreamFieldSize {
+		return "", fmt.Errorf("form field %q is too large", part.FormName())
+	}
+	return string(value), nil
+}
+
+// skipStreamedFile reads what is left of a file part that was not uploaded, e.g. one with a
+// disallowed type, so the next part can be read. With a limit set, a file passing it is not read
+// further and cutOff is reported instead.
+func skipStreamedFile(part *multipart.Part, limit int64) (cutOff bool, err error) {
+	if limit <= 0 {
+		_, err = io.Copy(io.Discard, part)
+		return false, err
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(part, limit+1))
+	return n > limit, err
+}
+
+// streamedRemotePath returns the remote path for the index-th file part of a streamed upload
+// Values sent before the file are paired with files in order, or a single value ending with / or \
+// is a directory every file goes into, as in uploadMultipleFiles; an empty value means
+// SMB_DEFAULT_UPLOAD_PATH. "" means no value was sent before the file: a remote_path that follows
+// it cannot be told apart from none at all, so the default does not apply then.
+func streamedRemotePath(values []string, index int, filename string, cfg *config.SMBConfig) string {
+	var remotePath string
+	if index < len(values) {
+		remotePath = defaultRemotePath(values[index], cfg)
+	}
+	if len(values) == 1 {
+		if first := defaultRemotePath(values[0], cfg); strings.HasSuffix(first, "/") || strings.HasSuffix(first, "\\") {
+			remotePath = first
+		}
+	}
+
+	// If remote_path is a directory (ends with / or \), append the uploaded filename
+	if strings.HasSuffix(remotePath, "/") || strings.HasSuffix(remotePath, "\\") {
+		remotePath = filepath.Join(remotePath, filepath.Base(filename))
+	}
+	return remotePath
+}
+
+// streamUploadPart pipes one file part to remotePath using the form fields read before it
+// It returns the response the single-file upload would send; cutOff is set when the file passed
+// SMB_MAX_UPLOAD_BYTES, which leaves the rest of the body unread.
+func streamUploadPart(
+	c *fiber.Ctx,
+	part *multipart.Part,
+	remotePath string,
+	index int,
+	fields map[string]string,
+	cfg *config.SMBConfig,
+) (status int, body fiber.Map, cutOff bool) {
+	if remotePath == "" && index == 0 {
+		return fiber.StatusBadRequest, fiber.Map{
+			"detail": "remote_path is required and must be sent before the file when uploads are streamed",
+		}, false
+	}
+	if remotePath == "" {
+		return fiber.StatusBadRequest, fiber.Map{
+			"detail": fmt.Sprintf("no remote_path for file %d: send one remote_path before each file, "+
+				"or a single directory ending with /", index+1),
+		}, false
+	}
+	if fields["expected_sha256"] != "" {
+		return fiber.StatusBadRequest, fiber.Map{
+			"detail": "expected_sha256 is not supported when uploads are streamed",
+		}, false
+	}
+
+	remotePath, err := smb.PrepareUploadPath(remotePath, cfg)
+	if err != nil {
+		return pathErrorStatus(err), fiber.Map{"detail": err.Error()}, false
+	}
+
+	modifiedTime, err := parseModifiedTime(fields["modified_time"])
+	if err != nil {
+		return fiber.StatusBadRequest, fiber.Map{"detail": err.Error()}, false
+	}
+
+	opts := uploadOptions{
+		remotePath:   remotePath,
+		overwrite:    fields["overwrite"] == "true" || fields["overwrite"] == "1",
+		readOnly:     fields["read_only"] == "true" || fields["read_only"] == "1",
+		modifiedTime: modifiedTime,
+	}
+
+	// Peek at the head of the file to sniff its type without consuming it
+	content := bufio.NewReaderSize(part, sniffLength)
+	if len(config.LoadServerConfig().AllowedMIMETypes) > 0 {
+		head, err := content.Peek(sniffLength)
+		if err != nil && err != io.EOF {
+			return fiber.StatusBadRequest, fiber.Map{"detail": fmt.Sprintf("invalid multipart form: %v", err)}, false
+		}
+		if detail := disallowedTypeDetail(head); detail != "" {
+			return fiber.StatusUnsupportedMediaType, fiber.Map{"detail": detail}, false
+		}
+	}
+
+	// A chunked body declares no length, so the limit is also enforced while the file is read
+	ctx := c.UserContext()
+	var src io.Reader = content
+	var limited *uploadLimitReader
+	if limit := config.LoadServerConfig().MaxUploadBytes; limit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		limited = &uploadLimitReader{r: content, cancel: cancel, limit: limit}
+		src = limited
+	}
+
+	err = smb.UploadStreamWithContext(ctx, src, opts.remotePath, cfg, opts.overwrite)
+	if limited != nil && limited.exceeded.Load() {
+		return fiber.StatusRequestEntityTooLarge, nil, true
+	}
+	status, body = uploadResult(c.UserContext(), err, opts, cfg)
+	return status, body, false
+}
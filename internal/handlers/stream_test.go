@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+func TestUploadHandler_Stream(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_STREAM_UPLOADS", "true")
+	defer os.Unsetenv("SMB_STREAM_UPLOADS")
+
+	// Any staged copy of the upload would land here
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	const size = 5 * 1024 * 1024
+	var received int64
+	mock := smb.SetupSuccessfulMock()
+	mock.ExecuteWithStdinFunc = func(args []string, stdin io.Reader) (string, error) {
+		if cmd := args[len(args)-1]; cmd != `put - "inbox/big.bin"` {
+			t.Errorf("Unexpected streaming command: %s", cmd)
+		}
+		n, err := io.Copy(io.Discard, stdin)
+		received = n
+		return "putting file - as \\inbox\\big.bin", err
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New(fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true})
+	app.Post("/upload", UploadHandler)
+
+	t.Run("pipes the file to smbclient", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if err := writer.WriteField("remote_path", "inbox/"); err != nil {
+			t.Fatalf("Failed to write remote_path: %v", err)
+		}
+		part, err := writer.CreateFormFile("file", "big.bin")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write(bytes.Repeat([]byte("x"), size)); err != nil {
+			t.Fatalf("Failed to write form file: %v", err)
+		}
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			respBody, _ := io.ReadAll(resp.Body)
+			t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(respBody))
+		}
+		if received != size {
+			t.Errorf("Expected %d bytes on stdin, got %d", size, received)
+		}
+
+		entries, err := os.ReadDir(tmpDir)
+		if err != nil {
+			t.Fatalf("Failed to read temp dir: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("Expected nothing staged in the temp dir, found %d entries", len(entries))
+		}
+	})
+
+	t.Run("rejects a body over the upload limit", func(t *testing.T) {
+		t.Setenv("SMB_MAX_UPLOAD_BYTES", "1024")
+		calls := mock.CallCount
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if err := writer.WriteField("remote_path", "inbox/"); err != nil {
+			t.Fatalf("Failed to write remote_path: %v", err)
+		}
+		part, err := writer.CreateFormFile("file", "big.bin")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write(bytes.Repeat([]byte("x"), size)); err != nil {
+			t.Fatalf("Failed to write form file: %v", err)
+		}
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusRequestEntityTooLarge {
+			t.Errorf("Expected status %d, got %d", fiber.StatusRequestEntityTooLarge, resp.StatusCode)
+		}
+		if mock.CallCount != calls {
+			t.Error("Expected no SMB calls for an oversized body")
+		}
+	})
+
+	t.Run("cuts off a chunked body over the upload limit", func(t *testing.T) {
+		t.Setenv("SMB_MAX_UPLOAD_BYTES", "1024")
+		received = 0
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if err := writer.WriteField("remote_path", "inbox/"); err != nil {
+			t.Fatalf("Failed to write remote_path: %v", err)
+		}
+		part, err := writer.CreateFormFile("file", "big.bin")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write(bytes.Repeat([]byte("x"), size)); err != nil {
+			t.Fatalf("Failed to write form file: %v", err)
+		}
+		writer.Close()
+
+		// A chunked body has no Content-Length to check up front
+		req := httptest.NewRequest("POST", "/upload", io.MultiReader(body))
+		req.TransferEncoding = []string{"chunked"}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusRequestEntityTooLarge {
+			respBody, _ := io.ReadAll(resp.Body)
+			t.Errorf("Expected status %d, got %d: %s", fiber.StatusRequestEntityTooLarge, resp.StatusCode, string(respBody))
+		}
+		if received > 1024 {
+			t.Errorf("Expected at most 1024 bytes on stdin, got %d", received)
+		}
+	})
+
+	t.Run("rejects a disallowed file type", func(t *testing.T) {
+		t.Setenv("SMB_ALLOWED_MIME_TYPES", "application/pdf")
+		calls := mock.CallCount
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if err := writer.WriteField("remote_path", "inbox/"); err != nil {
+			t.Fatalf("Failed to write remote_path: %v", err)
+		}
+		part, err := writer.CreateFormFile("file", "big.bin")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write([]byte("plain text, not a PDF")); err != nil {
+			t.Fatalf("Failed to write form file: %v", err)
+		}
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusUnsupportedMediaType {
+			t.Errorf("Expected status %d, got %d", fiber.StatusUnsupportedMediaType, resp.StatusCode)
+		}
+		if mock.CallCount != calls {
+			t.Error("Expected no SMB calls for a disallowed file type")
+		}
+	})
+
+	t.Run("uploads every file part", func(t *testing.T) {
+		putStream := mock.ExecuteWithStdinFunc
+		defer func() { mock.ExecuteWithStdinFunc = putStream }()
+		var puts []string
+		mock.ExecuteWithStdinFunc = func(args []string, stdin io.Reader) (string, error) {
+			puts = append(puts, args[len(args)-1])
+			_, err := io.Copy(io.Discard, stdin)
+			return "", err
+		}
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for _, name := range []string{"a.txt", "b.txt"} {
+			if err := writer.WriteField("remote_path", "inbox/"+name); err != nil {
+				t.Fatalf("Failed to write remote_path: %v", err)
+			}
+			part, err := writer.CreateFormFile("file", name)
+			if err != nil {
+				t.Fatalf("Failed to create form file: %v", err)
+			}
+			if _, err := part.Write([]byte("content of " + name)); err != nil {
+				t.Fatalf("Failed to write form file: %v", err)
+			}
+		}
+		// A third file without a remote_path of its own fails alone
+		part, err := writer.CreateFormFile("file", "c.txt")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write([]byte("content of c.txt")); err != nil {
+			t.Fatalf("Failed to write form file: %v", err)
+		}
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusMultiStatus {
+			t.Fatalf("Expected status %d, got %d: %s", fiber.StatusMultiStatus, resp.StatusCode, string(respBody))
+		}
+		if !strings.Contains(string(respBody), `"succeeded":2`) ||
+			!strings.Contains(string(respBody), "no remote_path for file 3") {
+			t.Errorf("Expected two uploads and a failed third file, got: %s", string(respBody))
+		}
+		want := []string{`put - "inbox/a.txt"`, `put - "inbox/b.txt"`}
+		if strings.Join(puts, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Expected puts %v, got %v", want, puts)
+		}
+	})
+
+	t.Run("applies the modification time", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for key, value := range map[string]string{"remote_path": "inbox/", "modified_time": "2023-11-05T14:30:15Z"} {
+			if err := writer.WriteField(key, value); err != nil {
+				t.Fatalf("Failed to write %s: %v", key, err)
+			}
+		}
+		part, err := writer.CreateFormFile("file", "big.bin")
+		if err != nil {
+			t.Fatalf("Failed to create form file: %v", err)
+		}
+		if _, err := part.Write([]byte("content")); err != nil {
+			t.Fatalf("Failed to write form file: %v", err)
+		}
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(respBody), `"modified_time":true`) {
+			t.Errorf("Expected 200 with modified_time true, got %d: %s", resp.StatusCode, string(respBody))
+		}
+		if cmd := mock.LastArgs[len(mock.LastArgs)-1]; !strings.HasPrefix(cmd, `utimes "inbox/big.bin"`) {
+			t.Errorf("Expected utimes to be the final command, got: %s", cmd)
+		}
+	})
+
+	t.Run("requires fields before the file", func(t *testing.T) {
+		// The default must not stand in for a remote_path that merely arrives late
+		t.Setenv("SMB_DEFAULT_UPLOAD_PATH", "drop/")
+		calls := mock.CallCount
+		req := newUploadRequest(t, "/upload", "big.bin", []byte("content"), map[string]string{
+			"remote_path": "inbox/big.bin",
+		})
+
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatalf("Failed to test upload: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		if !strings.Contains(string(respBody), "must be sent before the file") {
+			t.Errorf("Expected field order detail, got: %s", string(respBody))
+		}
+		if mock.CallCount != calls {
+			t.Error("Expected no SMB calls when remote_path follows the file")
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"embed"
+	"io/fs"
+	pathpkg "path"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// swaggerUIAssetPrefix is the URL path the embedded Swagger UI assets are served below
+const swaggerUIAssetPrefix = "/docs/swagger-ui-dist/"
+
+// swaggerUIAssets holds the swagger-ui-dist files the Swagger UI page loads, so /docs works
+// without access to a CDN
+//
+//go:embed swagger-ui-dist/*.css swagger-ui-dist/*.js
+var swaggerUIAssets embed.FS
+
+// swaggerUIHTML is the Swagger UI page served at /docs
+const swaggerUIHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Document SMB Relay Service - Swagger UI</title>
+    <link rel="stylesheet" type="text/css" href="` + swaggerUIAssetPrefix + `swagger-ui.css">
+    <style>
+        body {
+            margin: 0;
+            padding: 0;
+        }
+    </style>
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="` + swaggerUIAssetPrefix + `swagger-ui-bundle.js"></script>
+    <script src="` + swaggerUIAssetPrefix + `swagger-ui-standalone-preset.js"></script>
+    <script>
+        window.onload = function() {
+            SwaggerUIBundle({
+                url: "/openapi.json",
+                dom_id: '#swagger-ui',
+                deepLinking: true,
+                presets: [
+                    SwaggerUIBundle.presets.apis,
+                    SwaggerUIStandalonePreset
+                ],
+                plugins: [
+                    SwaggerUIBundle.plugins.DownloadUrl
+                ],
+                layout: "StandaloneLayout"
+            });
+        };
+    </script>
+</body>
+</html>`
+
+// ServeSwaggerUI serves a simple Swagger UI HTML page
+func ServeSwaggerUI(c *fiber.Ctx) error {
+	c.Set("Content-Type", "text/html")
+	return c.SendString(swaggerUIHTML)
+}
+
+// SwaggerUIAssetHandler handles GET /docs/swagger-ui-dist/{file}, serving an embedded Swagger UI asset
+func SwaggerUIAssetHandler(c *fiber.Ctx) error {
+	content, err := swaggerUIAssets.ReadFile("swagger-ui-dist/" + c.Params("file"))
+	if err != nil {
+		return sendResponse(c, fiber.StatusNotFound, fiber.Map{
+			"detail": "asset not found",
+		})
+	}
+	// The assets only change with a new release, so browsers may reuse them for an hour
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	c.Type(pathpkg.Ext(c.Params("file")))
+	return c.Send(content)
+}
+
+// SwaggerUIAssetPaths returns the URL paths of the embedded Swagger UI assets
+// They are public like /docs itself, so the server exempts them from SERVICE_API_KEY.
+func SwaggerUIAssetPaths() []string {
+	names, _ := fs.Glob(swaggerUIAssets, "swagger-ui-dist/*")
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, swaggerUIAssetPrefix+pathpkg.Base(name))
+	}
+	return paths
+}
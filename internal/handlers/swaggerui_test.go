@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestSwaggerUIAssetHandler(t *testing.T) {
+	app := fiber.New()
+	app.Get("/docs", ServeSwaggerUI)
+	app.Get("/docs/swagger-ui-dist/:file", SwaggerUIAssetHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/docs", nil))
+	if err != nil {
+		t.Fatalf("Failed to test docs endpoint: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	if strings.Contains(string(page), "https://") {
+		t.Errorf("Expected the Swagger UI page to load no external assets, got:\n%s", string(page))
+	}
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		file        string
+		contentType string
+	}{
+		{"swagger-ui.css", "text/css"},
+		{"swagger-ui-bundle.js", "javascript"},
+		{"swagger-ui-standalone-preset.js", "javascript"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := "/docs/swagger-ui-dist/" + tt.file
+			if !strings.Contains(string(page), path) {
+				t.Errorf("Expected the Swagger UI page to reference %s", path)
+			}
+			resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+			if err != nil {
+				t.Fatalf("Failed to test %s: %v", path, err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != fiber.StatusOK || len(body) == 0 {
+				t.Fatalf("Expected the asset with status %d, got %d and %d bytes", fiber.StatusOK, resp.StatusCode, len(body))
+			}
+			if contentType := resp.Header.Get("Content-Type"); !strings.Contains(contentType, tt.contentType) {
+				t.Errorf("Expected Content-Type %s, got %q", tt.contentType, contentType)
+			}
+		})
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/docs/swagger-ui-dist/NOTICE", nil))
+	if err != nil {
+		t.Fatalf("Failed to test unknown asset: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Expected 404 for a file that is not an asset, got %d", resp.StatusCode)
+	}
+}
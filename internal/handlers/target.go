@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+)
+
+// errCredentialsRequired is returned when SMB_AUTH_FROM_REQUEST is set and a request has no usable
+// Basic Authorization header
+var errCredentialsRequired = errors.New("SMB credentials required as an Authorization: Basic header")
+
+// errReadOnly is returned for writes to a target configured with SMB_READ_ONLY
+var errReadOnly = errors.New("share is read-only")
+
+// requestTarget returns the SMB target named by the target query parameter or, for bodies that
+// are not streamed, the target form field
+func requestTarget(c *fiber.Ctx) string {
+	if target := c.Query("target"); target != "" {
+		return target
+	}
+	// Reading a form field would consume a streamed body before the handler gets to it
+	if c.App().Config().StreamRequestBody {
+		return ""
+	}
+	return c.FormValue("target")
+}
+
+// loadTargetConfig loads the SMB configuration of the target a request names, or the default
+// configuration when it names none. An unknown target is an error.
+// With AuthFromRequest set, the username and password come from the request's Basic
+// Authorization header instead, and a request without one fails with errCredentialsRequired.
+func loadTargetConfig(c *fiber.Ctx) (*config.SMBConfig, []string, error) {
+	cfg, missing, err := config.LoadTargetFromEnv(requestTarget(c))
+	if err != nil || !cfg.AuthFromRequest {
+		return cfg, missing, err
+	}
+
+	username, password, ok := basicCredentials(c.Get(fiber.HeaderAuthorization))
+	if !ok {
+		return nil, nil, errCredentialsRequired
+	}
+	// A DOMAIN\user name selects the domain as well
+	if domain, user, found := strings.Cut(username, `\`); found {
+		cfg.Domain, username = domain, user
+	}
+	cfg.Username, cfg.Password = username, password
+	return cfg, missing, nil
+}
+
+// basicCredentials decodes the username and password of a Basic Authorization header
+func basicCredentials(header string) (username, password string, ok bool) {
+	scheme, encoded, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", "", false
+	}
+	username, password, found = strings.Cut(string(decoded), ":")
+	if !found || username == "" || password == "" {
+		return "", "", false
+	}
+	return username, password, true
+}
+
+// sendReadOnly answers a write to a read-only target with 403, without contacting the server
+func sendReadOnly(c *fiber.Ctx) error {
+	return sendResponse(c, fiber.StatusForbidden, fiber.Map{
+		"detail": errReadOnly.Error(),
+	})
+}
+
+// targetErrorStatus returns the status for an error loading a request's SMB configuration
+// Missing per-request credentials are 401 with a Basic challenge; an unknown target is 400.
+func targetErrorStatus(c *fiber.Ctx, err error) int {
+	if errors.Is(err, errCredentialsRequired) {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="SMB", charset="UTF-8"`)
+		return fiber.StatusUnauthorized
+	}
+	return fiber.StatusBadRequest
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+func TestHandlers_Target(t *testing.T) {
+	setupTestSMBEnv()
+	os.Setenv("SMB_TARGET_HR_SERVER_IP", "10.0.0.9")
+	os.Setenv("SMB_TARGET_HR_SHARE_NAME", "hrshare")
+
+	var services []string
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "//") {
+				services = append(services, arg)
+			}
+		}
+		return "  report.pdf                          A       12  Mon Jan  1 12:00:00 2024\n", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+	app.Delete("/delete", DeleteHandler)
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		expectedStatus int
+		wantService    string
+	}{
+		{"named target", "GET", "/list?target=hr", fiber.StatusOK, "//10.0.0.9/hrshare"},
+		{"target names are case-insensitive", "GET", "/list?target=HR", fiber.StatusOK, "//10.0.0.9/hrshare"},
+		{"default configuration", "GET", "/list", fiber.StatusOK, "//127.0.0.1/testshare"},
+		{"unknown target", "GET", "/list?target=payroll", fiber.StatusBadRequest, ""},
+		{"unknown target on delete", "DELETE", "/delete?path=a.txt&target=payroll", fiber.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			services = nil
+			resp, err := app.Test(httptest.NewRequest(tt.method, tt.url, nil))
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			if tt.wantService == "" {
+				var body map[string]string
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if body["detail"] != "unknown SMB target: payroll" {
+					t.Errorf("Expected unknown target detail, got %q", body["detail"])
+				}
+				if len(services) != 0 {
+					t.Errorf("Expected no smbclient calls for an unknown target, got %v", services)
+				}
+				return
+			}
+			if len(services) == 0 || services[0] != tt.wantService {
+				t.Errorf("Expected smbclient to connect to %s, got %v", tt.wantService, services)
+			}
+		})
+	}
+}
+
+func TestGetOpenAPISpec_TargetParameter(t *testing.T) {
+	app := fiber.New()
+	app.Get("/openapi.json", GetOpenAPISpec)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/openapi.json", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Parameters []map[string]interface{} `json:"parameters"`
+		} `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatalf("Failed to decode spec: %v", err)
+	}
+
+	for _, route := range Routes() {
+		if !route.target {
+			continue
+		}
+		path, method := route.openAPIPath(), strings.ToLower(route.Method)
+		op, ok := spec.Paths[path][method]
+		if !ok {
+			t.Errorf("Expected %s %s to be documented", method, path)
+			continue
+		}
+		found := false
+		for _, param := range op.Parameters {
+			if param["$ref"] == "#/components/parameters/target" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s %s to accept the target parameter", method, path)
+		}
+	}
+}
+
+func TestLoadTargetConfig_AuthFromRequest(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("SMB_AUTH_FROM_REQUEST", "true")
+	t.Setenv("SMB_USERNAME", "")
+	t.Setenv("SMB_PASSWORD", "")
+
+	app := fiber.New()
+	app.Get("/config", func(c *fiber.Ctx) error {
+		cfg, missing, err := loadTargetConfig(c)
+		if err != nil {
+			return c.Status(targetErrorStatus(c, err)).SendString(err.Error())
+		}
+		return c.JSON(fiber.Map{"username": cfg.Username, "password": cfg.Password, "domain": cfg.Domain, "missing": missing})
+	})
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+		username       string
+		password       string
+		domain         string
+	}{
+		{"basic credentials", "Basic " + basicAuth("alice", "s3cret:pass"), fiber.StatusOK, "alice", "s3cret:pass", ""},
+		{"domain user", "basic " + basicAuth(`CORP\bob`, "pw"), fiber.StatusOK, "bob", "pw", "CORP"},
+		{"missing header", "", fiber.StatusUnauthorized, "", "", ""},
+		{"bearer token", "Bearer abc", fiber.StatusUnauthorized, "", "", ""},
+		{"not base64", "Basic !!!", fiber.StatusUnauthorized, "", "", ""},
+		{"no password", "Basic " + basicAuth("alice", ""), fiber.StatusUnauthorized, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/config", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedStatus != fiber.StatusOK {
+				if challenge := resp.Header.Get("WWW-Authenticate"); !strings.HasPrefix(challenge, "Basic") {
+					t.Errorf("Expected a Basic challenge, got %q", challenge)
+				}
+				return
+			}
+
+			var got struct {
+				Username string   `json:"username"`
+				Password string   `json:"password"`
+				Domain   string   `json:"domain"`
+				Missing  []string `json:"missing"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got.Username != tt.username || got.Password != tt.password || got.Domain != tt.domain {
+				t.Errorf("Expected %s\\%s:%s, got %s\\%s:%s",
+					tt.domain, tt.username, tt.password, got.Domain, got.Username, got.Password)
+			}
+			if len(got.Missing) != 0 {
+				t.Errorf("Expected no missing configuration, got %v", got.Missing)
+			}
+		})
+	}
+}
+
+func TestHandlers_AuthFromRequest(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("SMB_AUTH_FROM_REQUEST", "true")
+
+	mock := smb.SetupSuccessfulMock()
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := fiber.New()
+	app.Get("/list", ListHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/list", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("Expected status %d without credentials, got %d", fiber.StatusUnauthorized, resp.StatusCode)
+	}
+	if mock.CallCount != 0 {
+		t.Errorf("Expected no smbclient calls without credentials, got %d", mock.CallCount)
+	}
+
+	req := httptest.NewRequest("GET", "/list", nil)
+	req.Header.Set("Authorization", "Basic "+basicAuth("alice", "s3cret"))
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d with credentials, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	args := strings.Join(mock.LastArgs, " ")
+	if !strings.Contains(args, "-U alice") || strings.Contains(args, "testuser") || strings.Contains(args, "s3cret") {
+		t.Errorf("Expected smbclient to connect as alice without the password in its arguments, got %v", mock.LastArgs)
+	}
+}
+
+// basicAuth encodes a username and password for a Basic Authorization header
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+func TestHandlers_ReadOnly(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+	os.Setenv("SMB_READ_ONLY", "true")
+
+	gets := 0
+	mock := newDownloadMock(t, &gets)
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := newWebDAVApp()
+	app.Get("/list", ListHandler)
+	app.Get("/download", DownloadHandler)
+	app.Post("/upload", UploadHandler)
+	app.Delete("/delete", DeleteHandler)
+	app.Post("/mkdir", MkdirHandler)
+	app.Post("/move", MoveHandler)
+	app.Post("/batch", BatchHandler)
+	app.Post("/uploads", CreateUploadSessionHandler)
+	app.Put("/objects/*", ObjectPutHandler)
+
+	writes := []*http.Request{
+		newUploadRequest(t, "/upload", "report.pdf", []byte("%PDF-1.4"), map[string]string{"remote_path": "report.pdf"}),
+		httptest.NewRequest("DELETE", "/delete?path=reports/q1.pdf", nil),
+		httptest.NewRequest("POST", "/mkdir", strings.NewReader(`{"path": "reports/2024"}`)),
+		httptest.NewRequest("POST", "/move", strings.NewReader(`{"source": "reports/q1.pdf", "destination": "q1.pdf"}`)),
+		httptest.NewRequest("POST", "/batch", strings.NewReader(`{"operations": [{"op": "delete", "path": "q1.pdf"}]}`)),
+		httptest.NewRequest("POST", "/uploads", strings.NewReader(`{"remote_path": "report.pdf", "length": 8}`)),
+		httptest.NewRequest("PUT", "/objects/report.pdf", strings.NewReader("%PDF-1.4")),
+		httptest.NewRequest("PUT", WebDAVPrefix+"/report.pdf", strings.NewReader("%PDF-1.4")),
+		httptest.NewRequest(methodMkcol, WebDAVPrefix+"/reports/2024", nil),
+	}
+	for _, req := range writes {
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test %s %s: %v", req.Method, req.URL.Path, err)
+		}
+		var body map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != fiber.StatusForbidden || body["detail"] != "share is read-only" {
+			t.Errorf("Expected %s %s to be refused as read-only, got %d: %v",
+				req.Method, req.URL.Path, resp.StatusCode, body)
+		}
+	}
+	if mock.CallCount != 0 {
+		t.Errorf("Expected refused writes not to contact the server, got %d smbclient calls", mock.CallCount)
+	}
+
+	// Reads still reach the share
+	for _, path := range []string{"/list?path=reports", "/download?path=reports/q1.pdf"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("Failed to test %s: %v", path, err)
+		}
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("Expected %s to succeed on a read-only share, got %d", path, resp.StatusCode)
+		}
+	}
+	if gets != 1 {
+		t.Errorf("Expected the download to fetch the file, got %d transfers", gets)
+	}
+}
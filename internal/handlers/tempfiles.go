@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+)
+
+const (
+	// tempFilePrefix identifies upload files staged by this service in the temp directory
+	tempFilePrefix = "smb-upload-"
+	// minJanitorInterval prevents the janitor from spinning on very small max ages
+	minJanitorInterval = time.Minute
+)
+
+var (
+	inFlightMu    sync.Mutex
+	inFlightFiles = make(map[string]struct{})
+)
+
+// unsafeStagingChars matches the characters not kept from a client filename in a staging file name
+var unsafeStagingChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// stagingName reduces a client-supplied filename to a safe suffix for a staging file name
+// Directory components in either slash style are dropped and other unsafe characters replaced,
+// so a crafted name can neither escape the temp directory nor disturb os.CreateTemp's pattern.
+func stagingName(filename string) string {
+	name := filename[strings.LastIndexAny(filename, `/\`)+1:]
+	name = unsafeStagingChars.ReplaceAllString(name, "_")
+	if strings.Trim(name, ".") == "" {
+		return "file"
+	}
+	return name
+}
+
+// createStagingFile creates a uniquely named staging file for an uploaded file in the temp directory
+// The file is tracked as in flight; callers remove it with removeStagedFile once the upload is done.
+func createStagingFile(filename string) (string, error) {
+	tmpFile, err := os.CreateTemp("", tempFilePrefix+"*-"+stagingName(filename))
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+
+	// Protect the staged file from the temp file janitor while the upload is in flight
+	trackTempFile(tmpPath)
+	return tmpPath, nil
+}
+
+// trackTempFile marks a staged file as belonging to an in-flight upload
+func trackTempFile(path string) {
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+	inFlightFiles[path] = struct{}{}
+}
+
+// releaseTempFile marks a staged file as no longer in use
+func releaseTempFile(path string) {
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+	delete(inFlightFiles, path)
+}
+
+// isTempFileInFlight reports whether a staged file belongs to an in-flight upload
+func isTempFileInFlight(path string) bool {
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+	_, ok := inFlightFiles[path]
+	return ok
+}
+
+// StartTempFileJanitor periodically removes orphaned staged upload files older than maxAge
+// Files left behind by a crash mid-upload would otherwise accumulate in the temp directory.
+// The janitor stops when ctx is canceled; a zero maxAge disables it.
+func StartTempFileJanitor(ctx context.Context, maxAge time.Duration) {
+	if maxAge <= 0 {
+		logger.Info("Temp file janitor disabled")
+		return
+	}
+
+	interval := maxAge / 2
+	if interval < minJanitorInterval {
+		interval = minJanitorInterval
+	}
+
+	logger.Info("Temp file janitor enabled (max age %v, interval %v)", maxAge, interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				uploadSessions.purgeExpired(time.Now())
+				cleanupStaleTempFiles(os.TempDir(), maxAge, time.Now())
+			}
+		}
+	}()
+}
+
+// cleanupStaleTempFiles removes staged upload files in dir last modified before now-maxAge
+// Files belonging to in-flight uploads are never removed. Returns the number of files removed.
+func cleanupStaleTempFiles(dir string, maxAge time.Duration, now time.Time) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		logger.Error("Temp file janitor failed to read %s: %v", dir, err)
+		return 0
+	}
+
+	cutoff := now.Add(-maxAge)
+	removed := 0
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if isTempFileInFlight(path) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			logger.Error("Temp file janitor failed to remove %s: %v", path, err)
+			continue
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		logger.Info("Temp file janitor removed %d stale staged file(s)", removed)
+	}
+
+	return removed
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanupStaleTempFiles(t *testing.T) {
+	dir, err := os.MkdirTemp("", "janitor-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	oldTime := now.Add(-2 * time.Hour)
+
+	files := map[string]time.Time{
+		tempFilePrefix + "old.pdf":      oldTime,
+		tempFilePrefix + "recent.pdf":   now,
+		tempFilePrefix + "inflight.pdf": oldTime,
+		"unrelated-old.txt":             oldTime,
+	}
+	for name, modTime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("staged"), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set mtime on %s: %v", name, err)
+		}
+	}
+
+	inFlightPath := filepath.Join(dir, tempFilePrefix+"inflight.pdf")
+	trackTempFile(inFlightPath)
+	defer releaseTempFile(inFlightPath)
+
+	removed := cleanupStaleTempFiles(dir, time.Hour, now)
+	if removed != 1 {
+		t.Errorf("Expected 1 file removed, got %d", removed)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, tempFilePrefix+"old.pdf")); !os.IsNotExist(err) {
+		t.Error("Expected old staged file to be removed")
+	}
+	for _, name := range []string{tempFilePrefix + "recent.pdf", tempFilePrefix + "inflight.pdf", "unrelated-old.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be kept, got: %v", name, err)
+		}
+	}
+}
+
+func TestCleanupStaleTempFiles_MissingDir(t *testing.T) {
+	removed := cleanupStaleTempFiles("/nonexistent/janitor/dir", time.Hour, time.Now())
+	if removed != 0 {
+		t.Errorf("Expected 0 files removed for missing dir, got %d", removed)
+	}
+}
+
+func TestTrackTempFile(t *testing.T) {
+	path := filepath.Join(os.TempDir(), tempFilePrefix+"tracked.txt")
+
+	trackTempFile(path)
+	if !isTempFileInFlight(path) {
+		t.Error("Expected tracked file to be in flight")
+	}
+
+	releaseTempFile(path)
+	if isTempFileInFlight(path) {
+		t.Error("Expected released file to no longer be in flight")
+	}
+}
+
+func TestStagingName(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd", "passwd"},
+		{`..\..\Windows\win.ini`, "win.ini"},
+		{"C:\\Users\\me\\Q1 report*.pdf", "Q1_report_.pdf"},
+		{"..", "file"},
+		{"", "file"},
+		{"dir/", "file"},
+	}
+
+	for _, tt := range tests {
+		if got := stagingName(tt.filename); got != tt.expected {
+			t.Errorf("stagingName(%q): expected %q, got %q", tt.filename, tt.expected, got)
+		}
+	}
+}
+
+func TestCreateStagingFile(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	first, err := createStagingFile("../report.pdf")
+	if err != nil {
+		t.Fatalf("Failed to create staging file: %v", err)
+	}
+	defer removeStagedFile(first)
+	second, err := createStagingFile("../report.pdf")
+	if err != nil {
+		t.Fatalf("Failed to create staging file: %v", err)
+	}
+	defer removeStagedFile(second)
+
+	if first == second {
+		t.Errorf("Expected distinct staging files, got %q twice", first)
+	}
+	for _, path := range []string{first, second} {
+		if filepath.Dir(path) != os.Getenv("TMPDIR") || !strings.HasPrefix(filepath.Base(path), tempFilePrefix) {
+			t.Errorf("Expected a staging file in the temp directory, got %q", path)
+		}
+		if !isTempFileInFlight(path) {
+			t.Errorf("Expected %q to be tracked as in flight", path)
+		}
+	}
+}
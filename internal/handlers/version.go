@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// Version is the service version, set at build time with
+// -ldflags "-X github.com/bancey/document-smbrelay-service/internal/handlers.Version=1.2.3"
+var Version = ""
+
+// smbclientVersionPattern matches the numeric part of an smbclient version, e.g. 4.19.5 in "4.19.5-Ubuntu"
+var smbclientVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// VersionHandler handles GET /version requests
+// It reports the service version and build, the Go version and the smbclient version. The status is
+// degraded when smbclient cannot be run, since every SMB operation would fail.
+func VersionHandler(c *fiber.Ctx) error {
+	status := "ok"
+	smbclient := fiber.Map{
+		"path": smb.BinaryPath(),
+	}
+	if version, err := smb.ClientVersion(); err != nil {
+		status = "degraded"
+		smbclient["error"] = err.Error()
+	} else {
+		smbclient["version"] = version
+		if m := smbclientVersionPattern.FindStringSubmatch(version); m != nil {
+			smbclient["major"], _ = strconv.Atoi(m[1])
+			smbclient["minor"], _ = strconv.Atoi(m[2])
+			smbclient["patch"], _ = strconv.Atoi(m[3])
+		}
+	}
+
+	body := fiber.Map{
+		"status":     status,
+		"service":    serviceBuildInfo(),
+		"go_version": runtime.Version(),
+		"smbclient":  smbclient,
+	}
+	return sendResponse(c, fiber.StatusOK, body)
+}
+
+// serviceBuildInfo returns the service version with the VCS revision and time recorded by the Go toolchain
+// Without a Version set at build time, the main module version is used when the binary was built from
+// a tagged module, and "dev" otherwise.
+func serviceBuildInfo() fiber.Map {
+	info := fiber.Map{
+		"version": Version,
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if ok {
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info["commit"] = setting.Value
+			case "vcs.time":
+				info["build_time"] = setting.Value
+			case "vcs.modified":
+				info["modified"] = setting.Value == "true"
+			}
+		}
+	}
+
+	if Version == "" {
+		info["version"] = "dev"
+		if ok && build.Main.Version != "" && build.Main.Version != "(devel)" {
+			info["version"] = build.Main.Version
+		}
+	}
+	return info
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+)
+
+// versionResponse is the decoded body of GET /version
+//
+//nolint:govet // fieldalignment: test struct readability over memory optimization
+type versionResponse struct {
+	Status  string `json:"status"`
+	Service struct {
+		Version string `json:"version"`
+	} `json:"service"`
+	GoVersion string `json:"go_version"`
+	SMBClient struct {
+		Version string `json:"version"`
+		Major   int    `json:"major"`
+		Minor   int    `json:"minor"`
+		Patch   int    `json:"patch"`
+		Error   string `json:"error"`
+	} `json:"smbclient"`
+}
+
+func getVersion(t *testing.T, app *fiber.App) versionResponse {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/version", nil))
+	if err != nil {
+		t.Fatalf("Failed to test version endpoint: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+	}
+	var result versionResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return result
+}
+
+func TestVersionHandler(t *testing.T) {
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if len(args) != 1 || args[0] != "--version" {
+			t.Errorf("Expected only --version, got %v", args)
+		}
+		return "Version 4.19.5-Ubuntu\n", nil
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	origVersion := Version
+	Version = "1.4.0"
+	defer func() { Version = origVersion }()
+
+	app := fiber.New()
+	app.Get("/version", VersionHandler)
+
+	result := getVersion(t, app)
+	if result.Status != "ok" {
+		t.Errorf("Expected status ok, got %q", result.Status)
+	}
+	if result.Service.Version != "1.4.0" {
+		t.Errorf("Expected service version 1.4.0, got %q", result.Service.Version)
+	}
+	if result.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version %q, got %q", runtime.Version(), result.GoVersion)
+	}
+	if result.SMBClient.Version != "4.19.5-Ubuntu" || result.SMBClient.Major != 4 ||
+		result.SMBClient.Minor != 19 || result.SMBClient.Patch != 5 {
+		t.Errorf("Expected smbclient 4.19.5-Ubuntu parsed as 4.19.5, got %+v", result.SMBClient)
+	}
+
+	getVersion(t, app)
+	if mock.CallCount != 1 {
+		t.Errorf("Expected smbclient --version to run once and be cached, got %d runs", mock.CallCount)
+	}
+}
+
+func TestVersionHandler_SmbClientMissing(t *testing.T) {
+	mock := smb.NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return "", fmt.Errorf("exec: \"smbclient\": executable file not found in $PATH")
+	}
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	origVersion := Version
+	Version = ""
+	defer func() { Version = origVersion }()
+
+	app := fiber.New()
+	app.Get("/version", VersionHandler)
+
+	result := getVersion(t, app)
+	if result.Status != "degraded" {
+		t.Errorf("Expected status degraded, got %q", result.Status)
+	}
+	if result.SMBClient.Error == "" || result.SMBClient.Version != "" {
+		t.Errorf("Expected an smbclient error and no version, got %+v", result.SMBClient)
+	}
+	if result.Service.Version == "" {
+		t.Error("Expected a fallback service version when none is set at build time")
+	}
+}
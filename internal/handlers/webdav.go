@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	pathpkg "path"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// WebDAVPrefix is the path the WebDAV interface is mounted at
+	WebDAVPrefix = "/dav"
+
+	methodPropfind = "PROPFIND"
+	methodMkcol    = "MKCOL"
+
+	// davAllow lists the methods the WebDAV interface answers, for the Allow header
+	davAllow = "OPTIONS, PROPFIND, GET, PUT, DELETE, MKCOL"
+)
+
+// WebDAVMethods are the HTTP methods routed to WebDAVHandler
+var WebDAVMethods = []string{
+	fiber.MethodOptions, methodPropfind, fiber.MethodGet, fiber.MethodPut, fiber.MethodDelete, methodMkcol,
+}
+
+// WebDAVExtensionMethods are the WebDAV methods the HTTP server must be configured to accept
+var WebDAVExtensionMethods = []string{methodPropfind, methodMkcol}
+
+// davMultistatus is the body of a PROPFIND response
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	Namespace string        `xml:"xmlns:D,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+// davResponse describes one resource in a multistatus body
+type davResponse struct {
+	Href     string      `xml:"D:href"`
+	Propstat davPropstat `xml:"D:propstat"`
+}
+
+// davPropstat holds a resource's properties and the status of retrieving them
+type davPropstat struct {
+	Status string  `xml:"D:status"`
+	Prop   davProp `xml:"D:prop"`
+}
+
+// davProp holds the live properties reported for a resource
+type davProp struct {
+	ContentLength *int64          `xml:"D:getcontentlength,omitempty"`
+	ResourceType  davResourceType `xml:"D:resourcetype"`
+	DisplayName   string          `xml:"D:displayname"`
+	LastModified  string          `xml:"D:getlastmodified,omitempty"`
+	ContentType   string          `xml:"D:getcontenttype,omitempty"`
+}
+
+// davResourceType marks collections; it is empty for plain files
+type davResourceType struct {
+	Collection *struct{} `xml:"D:collection,omitempty"`
+}
+
+// WebDAVHandler serves the WebDAV interface at /dav, translating requests into SMB operations
+// Paths below /dav are paths within the SMB share. PROPFIND reports the same properties whatever
+// the request body asks for.
+func WebDAVHandler(c *fiber.Ctx) error {
+	if c.Method() == fiber.MethodOptions {
+		c.Set(fiber.HeaderAllow, davAllow)
+		c.Set("DAV", "1")
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	cfg, missing, err := loadTargetConfig(c)
+	if err != nil {
+		return sendResponse(c, targetErrorStatus(c, err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	if len(missing) > 0 {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("Missing SMB configuration environment variables: %s", strings.Join(missing, ", ")),
+		})
+	}
+	if cfg.ReadOnly && (c.Method() == fiber.MethodPut || c.Method() == fiber.MethodDelete || c.Method() == methodMkcol) {
+		return sendReadOnly(c)
+	}
+
+	rawPath, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return sendResponse(c, fiber.StatusBadRequest, fiber.Map{
+			"detail": fmt.Sprintf("invalid path: %v", err),
+		})
+	}
+	// Names written by PUT and MKCOL get the same filename policy as uploads
+	prepare := smb.PrepareRequestPath
+	if c.Method() == fiber.MethodPut || c.Method() == methodMkcol {
+		prepare = smb.PrepareUploadPath
+	}
+	path, err := prepare(rawPath, cfg)
+	if err != nil {
+		return sendResponse(c, pathErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	switch c.Method() {
+	case methodPropfind:
+		return davPropfind(c, path, cfg)
+	case fiber.MethodGet:
+		return davGet(c, path, cfg)
+	case fiber.MethodPut:
+		return davPut(c, path, cfg)
+	case fiber.MethodDelete:
+		return davDelete(c, path, cfg)
+	case methodMkcol:
+		return davMkcol(c, path, cfg)
+	default:
+		c.Set(fiber.HeaderAllow, davAllow)
+		return sendResponse(c, fiber.StatusMethodNotAllowed, fiber.Map{
+			"detail": fmt.Sprintf("method not allowed: %s", c.Method()),
+		})
+	}
+}
+
+// davPropfind reports a resource and, for a collection at Depth 1, its members
+// A missing Depth header is treated as 1; Depth infinity is refused.
+func davPropfind(c *fiber.Ctx, path string, cfg *config.SMBConfig) error {
+	depth := c.Get("Depth", "1")
+	if depth != "0" && depth != "1" {
+		return sendResponse(c, fiber.StatusForbidden, fiber.Map{
+			"detail": fmt.Sprintf("unsupported Depth: %s (use 0 or 1)", depth),
+		})
+	}
+
+	self, err := smb.StatFileWithContext(c.UserContext(), path, cfg)
+	if err != nil {
+		return sendResponse(c, davErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	status := davMultistatus{Namespace: "DAV:", Responses: []davResponse{davEntry(path, self)}}
+	if self.IsDir && depth == "1" {
+		children, err := smb.ListFilesWithContext(c.UserContext(), path, cfg)
+		if err != nil {
+			return sendResponse(c, davErrorStatus(err), fiber.Map{
+				"detail": err.Error(),
+			})
+		}
+		for _, child := range children {
+			status.Responses = append(status.Responses, davEntry(pathpkg.Join(path, child.Name), child))
+		}
+	}
+
+	body, err := xml.Marshal(status)
+	if err != nil {
+		return sendResponse(c, fiber.StatusInternalServerError, fiber.Map{
+			"detail": fmt.Sprintf("failed to encode multistatus: %v", err),
+		})
+	}
+	c.Set(fiber.HeaderContentType, "application/xml; charset=utf-8")
+	return c.Status(fiber.StatusMultiStatus).Send(append([]byte(xml.Header), body...))
+}
+
+// davEntry maps a listing entry at path to its multistatus response
+func davEntry(path string, file smb.FileInfo) davResponse {
+	prop := davProp{DisplayName: pathpkg.Base("/" + path)}
+	if file.ModTime != nil {
+		prop.LastModified = file.ModTime.UTC().Format(http.TimeFormat)
+	}
+	if file.IsDir {
+		prop.ResourceType.Collection = &struct{}{}
+	} else {
+		size := file.Size
+		prop.ContentLength = &size
+		prop.ContentType = remoteContentType(path)
+	}
+
+	return davResponse{
+		Href:     davHref(path, file.IsDir),
+		Propstat: davPropstat{Prop: prop, Status: "HTTP/1.1 200 OK"},
+	}
+}
+
+// davHref returns the escaped URL path of a resource; collections end in a slash
+func davHref(path string, isDir bool) string {
+	href := WebDAVPrefix + "/"
+	if path != "" {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		href += strings.Join(segments, "/")
+		if isDir {
+			href += "/"
+		}
+	}
+	return href
+}
+
+// davGet downloads a file; collections cannot be downloaded
+func davGet(c *fiber.Ctx, path string, cfg *config.SMBConfig) error {
+	return sendRemoteFile(c, path, cfg, davErrorStatus)
+}
+
+// davPut uploads the request body to path, replacing any existing file
+// The size and content type limits of POST /upload apply.
+func davPut(c *fiber.Ctx, path string, cfg *config.SMBConfig) error {
+	body := c.Body()
+	if status, detail := rawUploadRejection(body); detail != "" {
+		return sendResponse(c, status, fiber.Map{
+			"detail": detail,
+		})
+	}
+
+	if err := smb.UploadStreamWithContext(c.UserContext(), bytes.NewReader(body), path, cfg, true); err != nil {
+		return sendResponse(c, davErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// davDelete deletes a file; collections cannot be deleted through WebDAV
+func davDelete(c *fiber.Ctx, path string, cfg *config.SMBConfig) error {
+	if err := smb.DeleteFileWithContext(c.UserContext(), path, cfg); err != nil {
+		if errors.Is(err, smb.ErrIsDirectory) {
+			return sendResponse(c, fiber.StatusForbidden, fiber.Map{
+				"detail": fmt.Sprintf("deleting collections is not supported: %s", path),
+			})
+		}
+		return sendResponse(c, davErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// davMkcol creates a collection; request bodies are not supported
+// A path that already exists, as a file or a collection, gets 405 as RFC 4918 requires.
+func davMkcol(c *fiber.Ctx, path string, cfg *config.SMBConfig) error {
+	if len(c.Body()) > 0 {
+		return sendResponse(c, fiber.StatusUnsupportedMediaType, fiber.Map{
+			"detail": "MKCOL request bodies are not supported",
+		})
+	}
+
+	_, err := smb.StatFileWithContext(c.UserContext(), path, cfg)
+	if err == nil {
+		return sendResponse(c, fiber.StatusMethodNotAllowed, fiber.Map{
+			"detail": fmt.Sprintf("resource already exists: %s", path),
+		})
+	}
+	if !errors.Is(err, smb.ErrNotFound) {
+		return sendResponse(c, davErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+
+	if err := smb.CreateDirectoryWithContext(c.UserContext(), path, cfg); err != nil {
+		return sendResponse(c, davErrorStatus(err), fiber.Map{
+			"detail": err.Error(),
+		})
+	}
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// davErrorStatus maps an SMB error to the status code WebDAV clients expect
+// A missing parent is a conflict, as is a path segment that is a file.
+func davErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, smb.ErrParentNotFound), errors.Is(err, smb.ErrNotDirectory):
+		return fiber.StatusConflict
+	case errors.Is(err, smb.ErrIsDirectory):
+		return fiber.StatusMethodNotAllowed
+	default:
+		return batchStepErrorStatus(err)
+	}
+}
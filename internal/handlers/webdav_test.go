@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bancey/document-smbrelay-service/internal/smb"
+	"github.com/gofiber/fiber/v2"
+)
+
+// newWebDAVApp returns an app serving WebDAVHandler the way the server mounts it
+func newWebDAVApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		RequestMethods: append(append([]string{}, fiber.DefaultMethods...), WebDAVExtensionMethods...),
+	})
+	for _, method := range WebDAVMethods {
+		app.Add(method, WebDAVPrefix, WebDAVHandler)
+		app.Add(method, WebDAVPrefix+"/*", WebDAVHandler)
+	}
+	return app
+}
+
+// davGetCommand extracts the local directory, remote path and local file from a get command
+var davGetCommand = regexp.MustCompile(`^lcd "(.*)"; get "(.*)" "(.*)"$`)
+
+// newWebDAVMock returns a mock share that stores streamed uploads and serves them to get commands
+func newWebDAVMock(t *testing.T, listings map[string]string) *smb.MockSmbClientExecutor {
+	t.Helper()
+	stored := make(map[string][]byte)
+	mock := smb.NewMockExecutor()
+	mock.ExecuteWithStdinFunc = func(args []string, stdin io.Reader) (string, error) {
+		remote := strings.TrimSuffix(strings.TrimPrefix(args[len(args)-1], `put - "`), `"`)
+		content, err := io.ReadAll(stdin)
+		stored[remote] = content
+		return "putting file - as \\" + remote, err
+	}
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if m := davGetCommand.FindStringSubmatch(cmd); m != nil {
+			content, ok := stored[m[2]]
+			if !ok {
+				return "NT_STATUS_OBJECT_NAME_NOT_FOUND opening remote file", fmt.Errorf("smbclient command failed: exit status 1")
+			}
+			return "getting file", os.WriteFile(filepath.Join(m[1], m[3]), content, 0600)
+		}
+		switch {
+		case strings.HasPrefix(cmd, `ls "`):
+			return "NT_STATUS_NO_SUCH_FILE listing", fmt.Errorf("smbclient command failed: exit status 1")
+		case strings.HasPrefix(cmd, "mkdir "):
+			// Every step of a directory-creation session succeeds and is followed by its delimiter
+			return strings.Repeat("Current directory is \\\\testserver\\testshare\\\n", strings.Count(cmd, "; pwd")), nil
+		}
+		for dir, listing := range listings {
+			if cmd == fmt.Sprintf(`cd "%s"; ls`, dir) || (dir == "" && cmd == "ls") {
+				return listing, nil
+			}
+		}
+		t.Errorf("Unexpected command: %s", cmd)
+		return "", fmt.Errorf("smbclient command failed: exit status 1")
+	}
+	return mock
+}
+
+func TestWebDAVHandler_PropfindDirectory(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := newWebDAVMock(t, map[string]string{
+		"": "  .                                   D        0  Mon Jan  1 09:00:00 2024\n" +
+			"  reports                             D        0  Mon Jan  1 10:00:00 2024\n",
+		"reports": "  .                                   D        0  Mon Jan  1 10:00:00 2024\n" +
+			"  ..                                  D        0  Mon Jan  1 09:00:00 2024\n" +
+			"  Q1 summary.pdf                      A     2048  Tue Jan  2 12:30:00 2024\n" +
+			"  archive                             D        0  Wed Jan  3 08:00:00 2024\n",
+	})
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := newWebDAVApp()
+	req := httptest.NewRequest("PROPFIND", "/dav/reports", nil)
+	req.Header.Set("Depth", "1")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test PROPFIND: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusMultiStatus {
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusMultiStatus, resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Responses []struct {
+			Href string `xml:"href"`
+			Prop struct {
+				DisplayName   string `xml:"displayname"`
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"propstat>prop"`
+			Status string `xml:"propstat>status"`
+		} `xml:"response"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		t.Fatalf("Failed to decode multistatus: %v\n%s", err, string(body))
+	}
+	if !strings.Contains(string(body), `xmlns:D="DAV:"`) {
+		t.Errorf("Expected the DAV: namespace, got: %s", string(body))
+	}
+	if len(result.Responses) != 3 {
+		t.Fatalf("Expected the directory and its 2 entries, got %d: %s", len(result.Responses), string(body))
+	}
+
+	self, file, dir := result.Responses[0], result.Responses[1], result.Responses[2]
+	if self.Href != "/dav/reports/" || self.Prop.ResourceType.Collection == nil || self.Status != "HTTP/1.1 200 OK" {
+		t.Errorf("Unexpected entry for the directory itself: %+v", self)
+	}
+	if file.Href != "/dav/reports/Q1%20summary.pdf" || file.Prop.DisplayName != "Q1 summary.pdf" ||
+		file.Prop.ContentLength != "2048" || file.Prop.ResourceType.Collection != nil {
+		t.Errorf("Unexpected entry for the file: %+v", file)
+	}
+	if file.Prop.LastModified == "" || !strings.HasSuffix(file.Prop.LastModified, " GMT") {
+		t.Errorf("Expected an HTTP date for the file's last modified time, got %q", file.Prop.LastModified)
+	}
+	if dir.Href != "/dav/reports/archive/" || dir.Prop.ResourceType.Collection == nil || dir.Prop.ContentLength != "" {
+		t.Errorf("Unexpected entry for the subdirectory: %+v", dir)
+	}
+
+	t.Run("depth 0 reports only the resource", func(t *testing.T) {
+		req := httptest.NewRequest("PROPFIND", "/dav/reports", nil)
+		req.Header.Set("Depth", "0")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test PROPFIND: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusMultiStatus || strings.Count(string(body), "<D:response>") != 1 {
+			t.Errorf("Expected a single response, got %d: %s", resp.StatusCode, string(body))
+		}
+	})
+
+	t.Run("missing resource", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("PROPFIND", "/dav/missing", nil))
+		if err != nil {
+			t.Fatalf("Failed to test PROPFIND: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("Expected status %d, got %d", fiber.StatusNotFound, resp.StatusCode)
+		}
+	})
+
+	t.Run("infinite depth is refused", func(t *testing.T) {
+		req := httptest.NewRequest("PROPFIND", "/dav/reports", nil)
+		req.Header.Set("Depth", "infinity")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test PROPFIND: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", fiber.StatusForbidden, resp.StatusCode)
+		}
+	})
+}
+
+func TestWebDAVHandler_PutGetRoundTrip(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	mock := newWebDAVMock(t, nil)
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := newWebDAVApp()
+	content := []byte("%PDF-1.4 quarterly figures")
+
+	resp, err := app.Test(httptest.NewRequest("PUT", "/dav/reports/q1.pdf", bytes.NewReader(content)))
+	if err != nil {
+		t.Fatalf("Failed to test PUT: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusCreated, resp.StatusCode, string(body))
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/dav/reports/q1.pdf", nil))
+	if err != nil {
+		t.Fatalf("Failed to test GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", fiber.StatusOK, resp.StatusCode, string(body))
+	}
+	if !bytes.Equal(body, content) {
+		t.Errorf("Expected the uploaded content back, got %q", string(body))
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "application/pdf" {
+		t.Errorf("Expected Content-Type application/pdf, got %q", contentType)
+	}
+
+	// The download is staged in the temp directory and removed once sent
+	if leftovers, _ := filepath.Glob(filepath.Join(os.Getenv("TMPDIR"), tempFilePrefix+"*")); len(leftovers) != 0 {
+		t.Errorf("Expected no staged files left behind, got %v", leftovers)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/dav/reports/missing.pdf", nil))
+	if err != nil {
+		t.Fatalf("Failed to test GET: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("Expected status %d for a missing file, got %d", fiber.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestWebDAVHandler_SanitizesWrittenNames(t *testing.T) {
+	setupTestSMBEnv()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	mock := newWebDAVMock(t, nil)
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := newWebDAVApp()
+
+	t.Setenv("SMB_SANITIZE_FILENAMES", "replace")
+	resp, err := app.Test(httptest.NewRequest("PUT", "/dav/reports/Q1%3A%20figures.txt", strings.NewReader("figures")))
+	if err != nil {
+		t.Fatalf("Failed to test PUT: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusCreated, resp.StatusCode)
+	}
+	if cmd := mock.LastArgs[len(mock.LastArgs)-1]; cmd != `put - "reports/Q1_ figures.txt"` {
+		t.Errorf("Expected the sanitized name to be written, got command %s", cmd)
+	}
+
+	t.Setenv("SMB_SANITIZE_FILENAMES", "reject")
+	calls := mock.CallCount
+	resp, err = app.Test(httptest.NewRequest("MKCOL", "/dav/reports/Q1%3A", nil))
+	if err != nil {
+		t.Fatalf("Failed to test MKCOL: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", fiber.StatusBadRequest, resp.StatusCode)
+	}
+	if mock.CallCount != calls {
+		t.Errorf("Expected no SMB calls for a rejected name, got %d", mock.CallCount-calls)
+	}
+}
+
+func TestWebDAVHandler_Mkcol(t *testing.T) {
+	setupTestSMBEnv()
+
+	mock := newWebDAVMock(t, map[string]string{
+		"reports": "  archive                             D        0  Wed Jan  3 08:00:00 2024\n" +
+			"  Q1 summary.pdf                      A     2048  Tue Jan  2 12:30:00 2024\n",
+	})
+	origExec := smb.SetExecutor(mock)
+	defer smb.SetExecutor(origExec)
+
+	app := newWebDAVApp()
+
+	resp, err := app.Test(httptest.NewRequest("MKCOL", "/dav/reports/2024", nil))
+	if err != nil {
+		t.Fatalf("Failed to test MKCOL: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusCreated, resp.StatusCode)
+	}
+	if cmd := mock.LastArgs[len(mock.LastArgs)-1]; !strings.HasPrefix(cmd, "mkdir ") {
+		t.Errorf("Expected the directory to be created, got command %s", cmd)
+	}
+
+	// An existing collection or file is not created again
+	for _, name := range []string{"archive", "Archive", "Q1%20summary.pdf"} {
+		resp, err := app.Test(httptest.NewRequest("MKCOL", "/dav/reports/"+name, nil))
+		if err != nil {
+			t.Fatalf("Failed to test MKCOL: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusMethodNotAllowed {
+			t.Errorf("%s: expected status %d, got %d", name, fiber.StatusMethodNotAllowed, resp.StatusCode)
+		}
+		if cmd := mock.LastArgs[len(mock.LastArgs)-1]; cmd != `cd "reports"; ls` {
+			t.Errorf("%s: expected no mkdir after the listing, got command %s", name, cmd)
+		}
+	}
+}
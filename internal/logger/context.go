@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDKey is the context key holding the ID of the request being served
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying a request ID for the context-aware log functions
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or an empty string
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// TraceID returns the ID of the trace active in ctx, or an empty string when there is none
+func TraceID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		return spanContext.TraceID().String()
+	}
+	return ""
+}
+
+// ContextPrefix returns the log line prefix identifying the request and trace of ctx
+// e.g. "[request_id=abc trace_id=def] ", or an empty string when ctx carries neither.
+func ContextPrefix(ctx context.Context) string {
+	requestID, traceID := RequestID(ctx), TraceID(ctx)
+	switch {
+	case requestID != "" && traceID != "":
+		return fmt.Sprintf("[request_id=%s trace_id=%s] ", requestID, traceID)
+	case requestID != "":
+		return fmt.Sprintf("[request_id=%s] ", requestID)
+	case traceID != "":
+		return fmt.Sprintf("[trace_id=%s] ", traceID)
+	default:
+		return ""
+	}
+}
+
+// DebugContext logs a debug message tagged with the request and trace of ctx
+func DebugContext(ctx context.Context, format string, v ...interface{}) {
+	if currentLevel <= DEBUG {
+		log.Printf("[DEBUG] %s"+format, append([]interface{}{ContextPrefix(ctx)}, v...)...)
+	}
+}
+
+// InfoContext logs an info message tagged with the request and trace of ctx
+func InfoContext(ctx context.Context, format string, v ...interface{}) {
+	if currentLevel <= INFO {
+		log.Printf("[INFO] %s"+format, append([]interface{}{ContextPrefix(ctx)}, v...)...)
+	}
+}
+
+// WarnContext logs a warning message tagged with the request and trace of ctx
+func WarnContext(ctx context.Context, format string, v ...interface{}) {
+	if currentLevel <= WARN {
+		log.Printf("[WARN] %s"+format, append([]interface{}{ContextPrefix(ctx)}, v...)...)
+	}
+}
+
+// ErrorContext logs an error message tagged with the request and trace of ctx
+func ErrorContext(ctx context.Context, format string, v ...interface{}) {
+	if currentLevel <= ERROR {
+		log.Printf("[ERROR] %s"+format, append([]interface{}{ContextPrefix(ctx)}, v...)...)
+	}
+}
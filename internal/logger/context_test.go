@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextPrefix(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	traced := trace.ContextWithSpanContext(context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected string
+	}{
+		{"no IDs", context.Background(), ""},
+		{"request ID", WithRequestID(context.Background(), "req-1"), "[request_id=req-1] "},
+		{"trace ID", traced, "[trace_id=4bf92f3577b34da6a3ce929d0e0e4736] "},
+		{"both", WithRequestID(traced, "req-1"), "[request_id=req-1 trace_id=4bf92f3577b34da6a3ce929d0e0e4736] "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ContextPrefix(tt.ctx); got != tt.expected {
+				t.Errorf("Expected prefix %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestContextLogging(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := WithRequestID(context.Background(), "req-2")
+	WarnContext(ctx, "upload of %s is %d%% done", "a.txt", 50)
+	ErrorContext(ctx, "failed: %v", "boom")
+
+	output := buf.String()
+	if !strings.Contains(output, "[WARN] [request_id=req-2] upload of a.txt is 50% done") {
+		t.Errorf("Expected a tagged warning, got: %q", output)
+	}
+	if !strings.Contains(output, "[ERROR] [request_id=req-2] failed: boom") {
+		t.Errorf("Expected a tagged error, got: %q", output)
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	fiberlogger "github.com/gofiber/fiber/v2/middleware/logger"
+
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+)
+
+// accessLogFormat is the per-request access log line: request and trace IDs, method, path, status
+// and latency, followed by key=value fields for the bytes sent and the redacted query string
+const accessLogFormat = "${ids}${method} ${path} ${status} ${latency} bytes=${bytes}${query}\n"
+
+// redactedValue replaces the value of sensitive query parameters in the access log
+const redactedValue = "REDACTED"
+
+// sensitiveQueryParams are query parameters whose values are never logged, compared case-insensitively
+var sensitiveQueryParams = map[string]bool{
+	"access_token": true,
+	"api_key":      true,
+	"apikey":       true,
+	"auth":         true,
+	"key":          true,
+	"password":     true,
+	"secret":       true,
+	"sig":          true,
+	"signature":    true,
+	"token":        true,
+}
+
+// accessLogWriter forwards Fiber access log lines to the project logger
+type accessLogWriter struct{}
+
+func (accessLogWriter) Write(p []byte) (int, error) {
+	logger.Info("%s", strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// AccessLog returns a middleware that logs the method, path, status, latency and bytes sent of each request
+// Lines are written through the logger package so they share its format and level handling.
+// Requests to excludePaths (e.g. health probes) are not logged.
+func AccessLog(excludePaths ...string) fiber.Handler {
+	exclude := make(map[string]bool, len(excludePaths))
+	for _, p := range excludePaths {
+		exclude[p] = true
+	}
+
+	return fiberlogger.New(fiberlogger.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return exclude[c.Path()]
+		},
+		Format:        accessLogFormat,
+		Output:        accessLogWriter{},
+		DisableColors: true,
+		CustomTags: map[string]fiberlogger.LogFunc{
+			// The same prefix the context-aware log functions write, empty without a request ID
+			"ids": func(output fiberlogger.Buffer, c *fiber.Ctx, _ *fiberlogger.Data, _ string) (int, error) {
+				return output.WriteString(logger.ContextPrefix(c.UserContext()))
+			},
+			// The size of the response body; a streamed body reports its Content-Length, or - when unknown
+			"bytes": func(output fiberlogger.Buffer, c *fiber.Ctx, _ *fiberlogger.Data, _ string) (int, error) {
+				if !c.Response().IsBodyStream() {
+					return output.WriteString(strconv.Itoa(len(c.Response().Body())))
+				}
+				if length := c.Response().Header.ContentLength(); length >= 0 {
+					return output.WriteString(strconv.Itoa(length))
+				}
+				return output.WriteString("-")
+			},
+			// The query string with sensitive values redacted, left out when the request has none
+			"query": func(output fiberlogger.Buffer, c *fiber.Ctx, _ *fiberlogger.Data, _ string) (int, error) {
+				query := string(c.Request().URI().QueryString())
+				if query == "" {
+					return 0, nil
+				}
+				return output.WriteString(" query=" + strconv.Quote(redactQuery(query)))
+			},
+		},
+	})
+}
+
+// redactQuery replaces the values of sensitive parameters in a raw query string, keeping their order
+func redactQuery(query string) string {
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		rawName, _, hasValue := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		if hasValue && sensitiveQueryParams[strings.ToLower(name)] {
+			params[i] = rawName + "=" + redactedValue
+		}
+	}
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	app := fiber.New()
+	app.Use(AccessLog("/health"))
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/list", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNotFound) })
+
+	for _, path := range []string{"/list", "/health"} {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+		if err != nil {
+			t.Fatalf("Failed to test %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	output := buf.String()
+	if !strings.Contains(output, "[INFO] GET /list 404 ") {
+		t.Errorf("Expected an access log line for GET /list, got: %q", output)
+	}
+	if strings.Contains(output, "/health") {
+		t.Errorf("Expected /health to be excluded from the access log, got: %q", output)
+	}
+	if strings.Count(output, "\n") != 1 {
+		t.Errorf("Expected exactly one log line, got: %q", output)
+	}
+}
+
+func TestAccessLog_Fields(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	app := fiber.New()
+	app.Use(RequestID())
+	app.Use(AccessLog())
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusCreated).SendString("created")
+	})
+
+	req := httptest.NewRequest("POST", "/upload?target=archive&Token=s3cret&api%5Fkey=k3y", nil)
+	req.Header.Set(fiber.HeaderXRequestID, "req-123")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	resp.Body.Close()
+
+	output := buf.String()
+	for _, expected := range []string{
+		"[request_id=req-123] POST /upload 201 ",
+		" bytes=7",
+		`query="target=archive&Token=REDACTED&api%5Fkey=REDACTED"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in the access log, got: %q", expected, output)
+		}
+	}
+	if strings.Contains(output, "s3cret") || strings.Contains(output, "k3y") {
+		t.Errorf("Expected sensitive query values to be redacted, got: %q", output)
+	}
+}
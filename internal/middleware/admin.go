@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireAdminToken returns a middleware that restricts a route to callers presenting the admin token
+// The token is sent as "Authorization: Bearer <token>". When no token is configured the
+// route is disabled entirely rather than left open.
+func RequireAdminToken(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"detail": "admin endpoints are disabled: ADMIN_TOKEN is not set",
+			})
+		}
+
+		presented, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"detail": "valid admin token required",
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireAdminToken(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{"valid token", "s3cret", "Bearer s3cret", fiber.StatusOK},
+		{"wrong token", "s3cret", "Bearer guess", fiber.StatusUnauthorized},
+		{"missing header", "s3cret", "", fiber.StatusUnauthorized},
+		{"wrong scheme", "s3cret", "Basic s3cret", fiber.StatusUnauthorized},
+		{"disabled without a configured token", "", "Bearer ", fiber.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/diagnostics", RequireAdminToken(tt.token), func(c *fiber.Ctx) error {
+				return c.SendString("ok")
+			})
+
+			req := httptest.NewRequest("GET", "/diagnostics", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedStatus == fiber.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("Expected WWW-Authenticate: Bearer, got %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
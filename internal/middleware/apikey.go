@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HeaderAPIKey is the header an API key may be sent in instead of an Authorization bearer token
+const HeaderAPIKey = "X-API-Key"
+
+// RequireAPIKey returns a middleware that rejects requests not presenting the service API key
+// The key is sent as "Authorization: Bearer <key>" or "X-API-Key: <key>" and compared in constant
+// time. Requests to exemptPaths (e.g. liveness probes) are allowed without a key.
+func RequireAPIKey(key string, exemptPaths ...string) fiber.Handler {
+	exempt := make(map[string]bool, len(exemptPaths))
+	for _, p := range exemptPaths {
+		exempt[p] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		if exempt[c.Path()] || validAPIKey(c, key) {
+			return c.Next()
+		}
+
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"detail": "valid API key required",
+		})
+	}
+}
+
+// validAPIKey reports whether either supported header carries the key
+func validAPIKey(c *fiber.Ctx, key string) bool {
+	matches := func(presented string) bool {
+		return presented != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(key)) == 1
+	}
+
+	if bearer, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok && matches(bearer) {
+		return true
+	}
+	return matches(c.Get(HeaderAPIKey))
+}
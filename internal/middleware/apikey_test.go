@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	app := fiber.New()
+	app.Use(RequireAPIKey("s3cret", "/health", "/docs"))
+	for _, path := range []string{"/health", "/docs", "/list"} {
+		app.Get(path, func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+	}
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{"bearer key", "GET", "/list", map[string]string{"Authorization": "Bearer s3cret"}, fiber.StatusOK},
+		{"X-API-Key header", "POST", "/upload", map[string]string{"X-API-Key": "s3cret"}, fiber.StatusOK},
+		{"missing key", "POST", "/upload", nil, fiber.StatusUnauthorized},
+		{"wrong bearer key", "GET", "/list", map[string]string{"Authorization": "Bearer guess"}, fiber.StatusUnauthorized},
+		{"wrong X-API-Key", "GET", "/list", map[string]string{"X-API-Key": "guess"}, fiber.StatusUnauthorized},
+		{"wrong scheme", "GET", "/list", map[string]string{"Authorization": "Basic s3cret"}, fiber.StatusUnauthorized},
+		{"empty X-API-Key", "GET", "/list", map[string]string{"X-API-Key": ""}, fiber.StatusUnauthorized},
+		{"health stays open", "GET", "/health", nil, fiber.StatusOK},
+		{"docs stay open", "GET", "/docs", nil, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedStatus == fiber.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("Expected WWW-Authenticate: Bearer, got %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LimitStreamedBody returns a middleware that applies limit to request bodies when the server
+// streams them (StreamRequestBody)
+// Fiber then hands bodies larger than its BodyLimit, and chunked ones, to the handler unchecked, so
+// anything reading c.Body() or a form would buffer them whole. A body within the limit is read
+// here and served to the handler as usual; a larger one gets 413 without being read further.
+// Requests for which streamed returns true keep their stream, for handlers that bound it themselves.
+func LimitStreamedBody(limit int, streamed func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if limit <= 0 || streamed(c) {
+			return c.Next()
+		}
+
+		if length := c.Request().Header.ContentLength(); length > limit {
+			return rejectBody(c, limit)
+		}
+		stream := c.Context().RequestBodyStream()
+		if stream == nil {
+			return c.Next()
+		}
+
+		body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+		if err != nil {
+			c.Set(fiber.HeaderConnection, "close")
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"detail": fmt.Sprintf("failed to read request body: %v", err),
+			})
+		}
+		if len(body) > limit {
+			return rejectBody(c, limit)
+		}
+		c.Request().SetBody(body)
+		return c.Next()
+	}
+}
+
+// rejectBody responds 413 to a request whose body is over limit, leaving the body unread
+func rejectBody(c *fiber.Ctx, limit int) error {
+	// The unread body must not be parsed as the next request on this connection
+	c.Set(fiber.HeaderConnection, "close")
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"detail": fmt.Sprintf("request body is too large: exceeds the limit of %d bytes", limit),
+	})
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLimitStreamedBody(t *testing.T) {
+	const limit = 1024
+
+	app := fiber.New(fiber.Config{StreamRequestBody: true, BodyLimit: limit})
+	app.Use(LimitStreamedBody(limit, func(c *fiber.Ctx) bool {
+		return c.Path() == "/upload"
+	}))
+	echo := func(c *fiber.Ctx) error {
+		return c.SendString(string(c.Body()))
+	}
+	app.Post("/move", echo)
+	app.Post("/upload", func(c *fiber.Ctx) error {
+		stream := c.Context().RequestBodyStream()
+		if stream == nil {
+			return c.SendString("buffered")
+		}
+		if _, err := io.Copy(io.Discard, stream); err != nil {
+			return err
+		}
+		return c.SendString("streamed")
+	})
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name       string
+		path       string
+		size       int
+		chunked    bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "body within the limit", path: "/move", size: 100, wantStatus: fiber.StatusOK},
+		{name: "chunked body within the limit", path: "/move", size: limit, chunked: true, wantStatus: fiber.StatusOK},
+		{name: "body over the limit", path: "/move", size: 4 * limit, wantStatus: fiber.StatusRequestEntityTooLarge},
+		{
+			name:       "chunked body over the limit",
+			path:       "/move",
+			size:       4 * limit,
+			chunked:    true,
+			wantStatus: fiber.StatusRequestEntityTooLarge,
+		},
+		{name: "streamed route", path: "/upload", size: 4 * limit, wantStatus: fiber.StatusOK, wantBody: "streamed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := strings.Repeat("x", tt.size)
+			var body io.Reader = strings.NewReader(content)
+			if tt.chunked {
+				// Hide the length so the request is sent chunked
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest("POST", tt.path, body)
+			if tt.chunked {
+				req.TransferEncoding = []string{"chunked"}
+			}
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			respBody, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, resp.StatusCode, string(respBody))
+			}
+
+			switch {
+			case tt.wantStatus == fiber.StatusRequestEntityTooLarge:
+				if !resp.Close {
+					t.Error("Expected the rejected response to close the connection")
+				}
+			case tt.wantBody != "":
+				if string(respBody) != tt.wantBody {
+					t.Errorf("Expected %q, got %q", tt.wantBody, string(respBody))
+				}
+			case string(respBody) != content:
+				t.Errorf("Expected the handler to read the whole body, got %d bytes", len(respBody))
+			}
+		})
+	}
+}
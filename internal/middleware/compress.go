@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+)
+
+// compressedMediaTypes are content types whose data is already compressed, or is opaque binary
+// that rarely shrinks, so compressing it again only costs CPU
+var compressedMediaTypes = map[string]bool{
+	fiber.MIMEOctetStream:          true,
+	"application/zip":              true,
+	"application/gzip":             true,
+	"application/x-gzip":           true,
+	"application/x-bzip2":          true,
+	"application/x-xz":             true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/vnd.rar":          true,
+	"application/zstd":             true,
+	"application/pdf":              true,
+	"application/epub+zip":         true,
+}
+
+// compressedMediaTypePrefixes match families of compressed content types, such as the ZIP-based
+// Office formats
+var compressedMediaTypePrefixes = []string{
+	"image/",
+	"audio/",
+	"video/",
+	"application/vnd.openxmlformats-officedocument.",
+	"application/vnd.oasis.opendocument.",
+}
+
+// Compress returns a middleware compressing responses with gzip, deflate or brotli as the client's
+// Accept-Encoding allows
+// Fiber compresses the response once the handler has set it, so contentType must report up front
+// the type a request will be answered with, e.g. from a download's file extension, or "" when it
+// is not known; requests for content that is already compressed are left alone. Range responses
+// are never compressed, as their offsets refer to the uncompressed content.
+func Compress(contentType func(c *fiber.Ctx) string) fiber.Handler {
+	return compress.New(compress.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return c.Get(fiber.HeaderRange) != "" || isCompressedType(contentType(c))
+		},
+	})
+}
+
+// isCompressedType reports whether content of contentType is already compressed
+func isCompressedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	for _, prefix := range compressedMediaTypePrefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return compressedMediaTypes[mediaType]
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCompress(t *testing.T) {
+	content := strings.Repeat(`{"name":"report.pdf","size":1024},`, 100)
+
+	app := fiber.New()
+	app.Use(Compress(func(c *fiber.Ctx) string {
+		if c.Path() == "/archive.zip" {
+			return "application/zip"
+		}
+		return ""
+	}))
+	app.Get("/list", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.SendString(content)
+	})
+	app.Get("/archive.zip", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "application/zip")
+		return c.SendString(content)
+	})
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name             string
+		path             string
+		headers          map[string]string
+		expectedEncoding string
+	}{
+		{"gzip accepted", "/list", map[string]string{"Accept-Encoding": "gzip"}, "gzip"},
+		{"deflate accepted", "/list", map[string]string{"Accept-Encoding": "deflate"}, "deflate"},
+		{"no Accept-Encoding", "/list", nil, ""},
+		{"already compressed content", "/archive.zip", map[string]string{"Accept-Encoding": "gzip"}, ""},
+		{"range request", "/list", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-99"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.expectedEncoding {
+				t.Fatalf("Expected Content-Encoding %q, got %q", tt.expectedEncoding, got)
+			}
+
+			body := resp.Body
+			switch tt.expectedEncoding {
+			case "gzip":
+				body, err = gzip.NewReader(resp.Body)
+			case "deflate":
+				body, err = zlib.NewReader(resp.Body)
+			}
+			if err != nil {
+				t.Fatalf("Expected a %s body, got %v", tt.expectedEncoding, err)
+			}
+			if got, _ := io.ReadAll(body); string(got) != content {
+				t.Errorf("Expected the original content back, got %d bytes", len(got))
+			}
+		})
+	}
+}
+
+func TestIsCompressedType(t *testing.T) {
+	tests := map[string]bool{
+		"application/json; charset=utf-8": false,
+		"text/plain":                      false,
+		"image/svg+xml":                   false,
+		"":                                false,
+		"application/zip":                 true,
+		"application/pdf":                 true,
+		"image/jpeg":                      true,
+		"application/octet-stream":        true,
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
+	}
+	for contentType, expected := range tests {
+		if got := isCompressedType(contentType); got != expected {
+			t.Errorf("isCompressedType(%q) = %v, want %v", contentType, got, expected)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Package middleware provides HTTP middleware for the SMB relay service.
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireHTTPS returns a middleware that rejects requests not made over HTTPS
+// The scheme and redirect host come from c.Protocol() and c.Hostname(), which only honor
+// X-Forwarded-Proto and X-Forwarded-Host from proxies trusted by the Fiber app configuration,
+// so the service refuses to start with it enabled and no TRUSTED_PROXIES. Safe requests
+// (GET/HEAD) are redirected to the https URL; other methods get a 403 so request bodies are never replayed over plain HTTP.
+// Requests to exemptPaths (e.g. health probes hitting the container directly) are allowed.
+func RequireHTTPS(exemptPaths ...string) fiber.Handler {
+	exempt := make(map[string]bool, len(exemptPaths))
+	for _, p := range exemptPaths {
+		exempt[p] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		if c.Protocol() == "https" || exempt[c.Path()] {
+			return c.Next()
+		}
+
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			target := "https://" + c.Hostname() + string(c.Request().URI().RequestURI())
+			return c.Redirect(target, fiber.StatusMovedPermanently)
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"detail": "HTTPS is required",
+		})
+	}
+}
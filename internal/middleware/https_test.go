@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// setupHTTPSApp creates an app enforcing HTTPS that trusts the given proxies
+func setupHTTPSApp(trustedProxies []string) *fiber.App {
+	app := fiber.New(fiber.Config{
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          trustedProxies,
+	})
+	app.Use(RequireHTTPS("/health"))
+	app.Get("/list", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Post("/upload", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	return app
+}
+
+func TestRequireHTTPS(t *testing.T) {
+	// app.Test requests originate from 0.0.0.0
+	trusted := []string{"0.0.0.0"}
+	untrusted := []string{"10.0.0.1"}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		forwardedProto string
+		trustedProxies []string
+		expectedStatus int
+	}{
+		{"https from trusted proxy", "GET", "/list", "https", trusted, fiber.StatusOK},
+		{"https upload from trusted proxy", "POST", "/upload", "https", trusted, fiber.StatusOK},
+		{"http GET is redirected", "GET", "/list", "http", trusted, fiber.StatusMovedPermanently},
+		{"http POST is rejected", "POST", "/upload", "http", trusted, fiber.StatusForbidden},
+		{"missing header is rejected", "POST", "/upload", "", trusted, fiber.StatusForbidden},
+		{"https from untrusted source is ignored", "POST", "/upload", "https", untrusted, fiber.StatusForbidden},
+		{"exempt path allowed over http", "GET", "/health", "http", trusted, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := setupHTTPSApp(tt.trustedProxies)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.forwardedProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRequireHTTPS_RedirectLocation(t *testing.T) {
+	app := setupHTTPSApp([]string{"0.0.0.0"})
+
+	req := httptest.NewRequest("GET", "http://relay.example.com/list?path=inbox", nil)
+	req.Header.Set("X-Forwarded-Proto", "http")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+
+	expected := "https://relay.example.com/list?path=inbox"
+	if location := resp.Header.Get("Location"); location != expected {
+		t.Errorf("Expected redirect to %s, got %s", expected, location)
+	}
+}
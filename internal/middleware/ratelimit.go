@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// rateLimitSweepInterval is how often buckets of clients that have gone quiet are dropped
+const rateLimitSweepInterval = time.Minute
+
+// tokenBucket holds one client's remaining tokens as of the last update
+type tokenBucket struct {
+	updated time.Time
+	tokens  float64
+}
+
+// rateLimiter is a token bucket per client key, refilled at rate tokens per second up to burst
+type rateLimiter struct {
+	lastSweep time.Time
+	buckets   map[string]*tokenBucket
+	now       func() time.Time
+	rate      float64
+	burst     float64
+	mu        sync.Mutex
+}
+
+// newRateLimiter creates a limiter; a burst below 1 defaults to one second's worth of requests
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &rateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+		rate:    rate,
+		burst:   float64(burst),
+	}
+}
+
+// take spends a token from key's bucket, or reports how long until one is available
+func (l *rateLimiter) take(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst}
+		l.buckets[key] = bucket
+	} else {
+		bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	}
+	bucket.updated = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, as they are no different from new ones
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.updated) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimit returns a middleware that limits each client IP to rate requests per second with bursts
+// of up to burst requests, answering excess requests with 429 and a Retry-After header.
+// Requests to exemptPaths (e.g. health probes) are not counted.
+func RateLimit(rate float64, burst int, exemptPaths ...string) fiber.Handler {
+	limiter := newRateLimiter(rate, burst)
+	exempt := make(map[string]bool, len(exemptPaths))
+	for _, p := range exemptPaths {
+		exempt[p] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		if exempt[c.Path()] {
+			return c.Next()
+		}
+
+		allowed, wait := limiter.take(c.IP())
+		if allowed {
+			return c.Next()
+		}
+
+		retryAfter := int(math.Max(1, math.Ceil(wait.Seconds())))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"detail": fmt.Sprintf("rate limit exceeded, retry after %d seconds", retryAfter),
+		})
+	}
+}
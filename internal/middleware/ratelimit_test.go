@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRateLimit(t *testing.T) {
+	const burst = 3
+
+	app := fiber.New(fiber.Config{ProxyHeader: fiber.HeaderXForwardedFor})
+	app.Use(RateLimit(0.5, burst, "/health"))
+	app.Get("/list", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	request := func(path, ip string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(fiber.HeaderXForwardedFor, ip)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		return resp
+	}
+
+	for i := 0; i < burst; i++ {
+		if resp := request("/list", "10.0.0.1"); resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, fiber.StatusOK, resp.StatusCode)
+		}
+	}
+
+	resp := request("/list", "10.0.0.1")
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("Expected request %d to get status %d, got %d", burst+1, fiber.StatusTooManyRequests, resp.StatusCode)
+	}
+	if got := resp.Header.Get(fiber.HeaderRetryAfter); got != "2" {
+		t.Errorf("Expected Retry-After: 2 at 0.5 requests per second, got %q", got)
+	}
+
+	// Other clients have their own bucket, and exempt paths are never limited
+	if resp := request("/list", "10.0.0.2"); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected another client to be allowed, got status %d", resp.StatusCode)
+	}
+	if resp := request("/health", "10.0.0.1"); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected /health to be exempt, got status %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimiter_Refill(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := newRateLimiter(2, 0)
+	limiter.now = func() time.Time { return now }
+
+	// The burst defaults to one second's worth of requests
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.take("client"); !ok {
+			t.Fatalf("Expected token %d to be available", i+1)
+		}
+	}
+	ok, wait := limiter.take("client")
+	if ok {
+		t.Fatal("Expected the bucket to be empty")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("Expected to wait 500ms for the next token, got %v", wait)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.take("client"); !ok {
+		t.Error("Expected a token after refilling for 500ms")
+	}
+
+	// Idle buckets are swept once they have refilled
+	now = now.Add(rateLimitSweepInterval)
+	limiter.take("other")
+	if _, ok := limiter.buckets["client"]; ok {
+		t.Error("Expected the idle bucket to be swept")
+	}
+}
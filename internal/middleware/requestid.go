@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+)
+
+const (
+	// RequestIDHeader is the header a request ID is accepted from and echoed in
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the Fiber locals key holding the request ID
+	RequestIDKey = "request_id"
+)
+
+// requestIDPattern matches the client-supplied request IDs that are kept; others are replaced
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]{1,128}$`)
+
+// RequestID returns a middleware that tags each request with an ID for correlating logs
+// A valid X-Request-ID from the client is kept, otherwise one is generated. The ID is stored in
+// the Fiber locals and the request's user context, so context-aware log lines carry it, and is
+// echoed in the X-Request-ID response header. JSON error responses also get request_id and, when
+// a trace is active, trace_id fields. Register it before every other middleware so they see the ID,
+// except Compress, which must wrap it so error bodies are tagged before they are compressed.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		c.Locals(RequestIDKey, requestID)
+		c.SetUserContext(logger.WithRequestID(c.UserContext(), requestID))
+		c.Set(RequestIDHeader, requestID)
+
+		// Render errors here rather than in the app's error handler so they can be tagged too
+		if err := c.Next(); err != nil {
+			if handlerErr := c.App().Config().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		if c.Response().StatusCode() >= fiber.StatusBadRequest {
+			tagErrorBody(c, requestID)
+		}
+		return nil
+	}
+}
+
+// tagErrorBody adds the request and trace IDs to a JSON object error response
+// Other response bodies are left unchanged.
+func tagErrorBody(c *fiber.Ctx, requestID string) {
+	contentType := string(c.Response().Header.ContentType())
+	if !strings.HasPrefix(contentType, fiber.MIMEApplicationJSON) {
+		return
+	}
+	body := c.Response().Body()
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return
+	}
+	if _, ok := fields["request_id"]; !ok {
+		fields["request_id"], _ = json.Marshal(requestID)
+	}
+	if traceID := logger.TraceID(c.UserContext()); traceID != "" {
+		fields["trace_id"], _ = json.Marshal(traceID)
+	}
+
+	tagged, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	c.Response().SetBodyRaw(tagged)
+}
+
+// newRequestID returns a random identifier for a request that arrived without one
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"log"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+)
+
+// newRequestIDApp returns an app with the request ID middleware, a JSON error handler and routes that log and fail
+func newRequestIDApp() *fiber.App {
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"detail": err.Error()})
+		},
+	})
+	app.Use(RequestID())
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		logger.InfoContext(c.UserContext(), "handling %s", c.Path())
+		return c.SendString("ok")
+	})
+	app.Get("/missing", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"detail": "file not found: a.txt"})
+	})
+	app.Get("/error", func(_ *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusBadRequest, "bad request")
+	})
+	return app
+}
+
+func TestRequestID_GeneratesAndEchoes(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	app := newRequestIDApp()
+	resp, err := app.Test(httptest.NewRequest("GET", "/ok", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+
+	requestID := resp.Header.Get(RequestIDHeader)
+	if !regexp.MustCompile(`^[0-9a-f]{32}$`).MatchString(requestID) {
+		t.Fatalf("Expected a generated request ID in the response, got %q", requestID)
+	}
+	if !strings.Contains(buf.String(), "[INFO] [request_id="+requestID+"] handling /ok") {
+		t.Errorf("Expected the log line to carry the request ID, got: %q", buf.String())
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/ok", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if other := resp.Header.Get(RequestIDHeader); other == requestID {
+		t.Errorf("Expected each request to get its own ID, got %q twice", other)
+	}
+}
+
+func TestRequestID_PreservesClientID(t *testing.T) {
+	app := newRequestIDApp()
+
+	tests := []struct {
+		name     string
+		supplied string
+		kept     bool
+	}{
+		{"uuid", "4f1c2b7e-8a9d-4c3e-b5f6-0a1b2c3d4e5f", true},
+		{"opaque token", "upload:batch-7/part.2", true},
+		{"with spaces", "not a valid id", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/ok", nil)
+			req.Header.Set(RequestIDHeader, tt.supplied)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			echoed := resp.Header.Get(RequestIDHeader)
+			if (echoed == tt.supplied) != tt.kept {
+				t.Errorf("Expected supplied ID kept=%v, got %q", tt.kept, echoed)
+			}
+			if echoed == "" {
+				t.Error("Expected a request ID in the response")
+			}
+		})
+	}
+}
+
+func TestRequestID_TagsErrorResponses(t *testing.T) {
+	app := newRequestIDApp()
+
+	for _, path := range []string{"/missing", "/error"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set(RequestIDHeader, "req-123")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode < fiber.StatusBadRequest {
+				t.Fatalf("Expected an error status, got %d", resp.StatusCode)
+			}
+
+			var body map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if body["request_id"] != "req-123" {
+				t.Errorf("Expected request_id in the error body, got %v", body)
+			}
+			if _, ok := body["trace_id"]; ok {
+				t.Errorf("Expected no trace_id without an active trace, got %v", body)
+			}
+		})
+	}
+
+	t.Run("success bodies are untouched", func(t *testing.T) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/ok", nil))
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		var body bytes.Buffer
+		if _, err := body.ReadFrom(resp.Body); err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if body.String() != "ok" {
+			t.Errorf("Expected the body unchanged, got %q", body.String())
+		}
+	})
+}
+
+func TestRequestID_TagsCompressedErrorResponses(t *testing.T) {
+	// Registered as in main: Compress outside RequestID, so the body is tagged before it is compressed
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"detail": err.Error()})
+		},
+	})
+	app.Use(Compress(func(_ *fiber.Ctx) string { return "" }))
+	app.Use(RequestID())
+	detail := "file not found: " + strings.Repeat("reports/", 100) + "a.txt"
+	app.Get("/missing", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"detail": detail})
+	})
+	app.Get("/error", func(_ *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusBadRequest, detail)
+	})
+
+	for _, path := range []string{"/missing", "/error"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", path, nil)
+			req.Header.Set(RequestIDHeader, "req-123")
+			req.Header.Set("Accept-Encoding", "gzip")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if encoding := resp.Header.Get("Content-Encoding"); encoding != "gzip" {
+				t.Fatalf("Expected a gzip-compressed error response, got encoding %q", encoding)
+			}
+
+			reader, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to open gzip body: %v", err)
+			}
+			var body map[string]interface{}
+			if err := json.NewDecoder(reader).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode error response: %v", err)
+			}
+			if body["request_id"] != "req-123" || body["detail"] != detail {
+				t.Errorf("Expected the error body tagged with request_id, got %v", body)
+			}
+		})
+	}
+}
+
+func TestAccessLog_RequestID(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	app := fiber.New()
+	app.Use(RequestID())
+	app.Use(AccessLog())
+	app.Get("/list", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusNoContent) })
+
+	req := httptest.NewRequest("GET", "/list", nil)
+	req.Header.Set(RequestIDHeader, "req-456")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	resp.Body.Close()
+
+	if !strings.Contains(buf.String(), "[INFO] [request_id=req-456] GET /list 204 ") {
+		t.Errorf("Expected the access log line to carry the request ID, got: %q", buf.String())
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ShutdownGate rejects new requests with 503 once shutdown has begun, while requests already
+// past the gate run to completion
+type ShutdownGate struct {
+	closed atomic.Bool
+}
+
+// Close makes the gate reject every request from now on
+func (g *ShutdownGate) Close() {
+	g.closed.Store(true)
+}
+
+// Handler returns the middleware enforcing the gate
+// Rejected responses ask the client to close the connection so it reconnects to another instance.
+func (g *ShutdownGate) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !g.closed.Load() {
+			return c.Next()
+		}
+
+		c.Set(fiber.HeaderConnection, "close")
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"detail": "service is shutting down",
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestShutdownGate(t *testing.T) {
+	gate := &ShutdownGate{}
+	app := fiber.New()
+	app.Use(gate.Handler())
+	app.Get("/list", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/list", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected status %d before shutdown, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+
+	gate.Close()
+	resp, err = app.Test(httptest.NewRequest("GET", "/list", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusServiceUnavailable {
+		t.Errorf("Expected status %d after shutdown, got %d", fiber.StatusServiceUnavailable, resp.StatusCode)
+	}
+	if !resp.Close {
+		t.Error("Expected the rejected response to close the connection")
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// TrimTrailingSlash returns a middleware serving a path with a trailing slash as the path without it,
+// so /list/ is handled, logged and exempted exactly like /list
+// Fiber's routing already ignores the slash, but middleware matching exact paths, such as the API key
+// and rate limit exemptions, would not. Paths starting with one of keepPrefixes, whose trailing slash
+// is part of a share path (e.g. WebDAV collections), are left as they are.
+func TrimTrailingSlash(keepPrefixes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		path := c.Path()
+		if len(path) <= 1 || !strings.HasSuffix(path, "/") {
+			return c.Next()
+		}
+		for _, prefix := range keepPrefixes {
+			if strings.HasPrefix(path, prefix) {
+				return c.Next()
+			}
+		}
+
+		trimmed := strings.TrimRight(path, "/")
+		if trimmed == "" {
+			trimmed = "/"
+		}
+		c.Path(utils.CopyString(trimmed))
+		return c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTrimTrailingSlash(t *testing.T) {
+	app := fiber.New()
+	app.Use(TrimTrailingSlash("/dav/"))
+	app.Use(RequireAPIKey("k3y", "/health"))
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString(c.Path()) })
+	app.Get("/list", func(c *fiber.Ctx) error { return c.SendString(c.Path()) })
+	app.Get("/dav/*", func(c *fiber.Ctx) error { return c.SendString(c.Path()) })
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name           string
+		path           string
+		apiKey         string
+		expectedStatus int
+		expectedPath   string
+	}{
+		{"exempt path", "/health", "", fiber.StatusOK, "/health"},
+		{"exempt path with trailing slash", "/health/", "", fiber.StatusOK, "/health"},
+		{"several trailing slashes", "/health//", "", fiber.StatusOK, "/health"},
+		{"route with trailing slash", "/list/", "k3y", fiber.StatusOK, "/list"},
+		{"key still required", "/list/", "", fiber.StatusUnauthorized, ""},
+		{"kept prefix", "/dav/reports/", "k3y", fiber.StatusOK, "/dav/reports/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedPath == "" {
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.expectedPath {
+				t.Errorf("Expected the handler to see %q, got %q", tt.expectedPath, string(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package smb
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/telemetry"
+)
+
+// Operations accepted in a batch
+const (
+	BatchOpUpload = "upload"
+	BatchOpDelete = "delete"
+	BatchOpRename = "rename"
+	BatchOpMkdir  = "mkdir"
+)
+
+// batchDelimiterCommand is run after every command of a batch session; the line it prints
+// marks where the previous command's output ends
+const batchDelimiterCommand = "pwd"
+
+// batchDelimiterOutput is the start of the line printed by batchDelimiterCommand
+const batchDelimiterOutput = "Current directory is"
+
+// batchPromptPattern matches the prompts an interactive smbclient session prints before each command
+var batchPromptPattern = regexp.MustCompile(`^(smb: [^>]*> )+`)
+
+// BatchOperation is one step of a batch run against the share
+// Fields are ordered for optimal memory alignment
+type BatchOperation struct {
+	Op        string // One of BatchOpUpload, BatchOpDelete, BatchOpRename or BatchOpMkdir
+	Path      string // Target path relative to the share (and SMB_BASE_PATH)
+	To        string // Destination path for renames
+	LocalPath string // Staged local file for uploads
+	Overwrite bool   // Replace an existing file on upload or rename
+}
+
+// BatchOperationResult is the outcome of one batch step
+// Err is nil for a step that succeeded; Skipped is set for steps not run after an earlier failure.
+type BatchOperationResult struct {
+	Err     error
+	Op      string
+	Path    string
+	Skipped bool
+}
+
+// batchStep is a batch operation translated to smbclient commands
+// Only the last command decides the step's outcome; earlier ones (such as creating the
+// parent directory of an upload) are best-effort preparation. checkPath is set for uploads that
+// must not overwrite: the file at that share path must not exist when the step runs.
+type batchStep struct {
+	checkPath string
+	commands  []string
+	index     int
+}
+
+// RunBatch runs operations in order and returns one result per operation
+func RunBatch(ops []BatchOperation, cfg *config.SMBConfig, stopOnError bool) []BatchOperationResult {
+	return RunBatchWithContext(context.Background(), ops, cfg, stopOnError)
+}
+
+// RunBatchWithContext runs operations in order and returns one result per operation with context
+// Every operation runs in a single smbclient session. smbclient keeps going after a failed command,
+// so when stopOnError is true the session is interactive and each operation is only sent once the
+// previous one has succeeded; nothing after the first failure reaches the server.
+func RunBatchWithContext(
+	ctx context.Context,
+	ops []BatchOperation,
+	cfg *config.SMBConfig,
+	stopOnError bool,
+) []BatchOperationResult {
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "batch",
+		attribute.Int("smb.operation_count", len(ops)),
+		attribute.Bool("smb.stop_on_error", stopOnError),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	results := make([]BatchOperationResult, len(ops))
+	for i, op := range ops {
+		results[i] = BatchOperationResult{Op: op.Op, Path: op.Path, Skipped: true}
+	}
+
+	if stopOnError {
+		runBatchSequence(ctx, ops, results, cfg)
+	} else {
+		steps := make([]batchStep, 0, len(ops))
+		for i := range ops {
+			step, err := prepareBatchStep(i, ops[i], cfg)
+			if err == nil && step.checkPath != "" {
+				err = checkUploadTarget(ctx, step.checkPath, ops[i].Path, cfg)
+			}
+			if err != nil {
+				results[i].Err = err
+				results[i].Skipped = false
+				continue
+			}
+			steps = append(steps, step)
+		}
+		runBatchSession(ctx, steps, ops, results, cfg)
+	}
+
+	// Record metrics
+	var firstErr error
+	for _, result := range results {
+		if result.Err != nil {
+			firstErr = result.Err
+			break
+		}
+	}
+	recordOperation(ctx, "batch", startTime, cfg, firstErr, "")
+	telemetry.EndSpanWithError(span, firstErr)
+
+	return results
+}
+
+// prepareBatchStep validates an operation and builds its smbclient commands
+func prepareBatchStep(index int, op BatchOperation, cfg *config.SMBConfig) (batchStep, error) {
+	fullPath := normalizePathSegment(buildFullPath(op.Path, cfg))
+	if fullPath == "" || fullPath == "." {
+		return batchStep{}, fmt.Errorf("%w: %s cannot target the root directory", ErrInvalidPath, op.Op)
+	}
+
+	step := batchStep{index: index}
+	switch op.Op {
+	case BatchOpUpload:
+		if op.LocalPath == "" {
+			return batchStep{}, fmt.Errorf("upload requires a file")
+		}
+		if !op.Overwrite {
+			step.checkPath = fullPath
+		}
+		remoteDir := filepath.Dir(fullPath)
+		if !cfg.DisableAutoMkdir && remoteDir != "." {
+			step.commands = append(step.commands, fmt.Sprintf(`mkdir "%s"`, remoteDir))
+		}
+		step.commands = append(step.commands,
+			fmt.Sprintf(`lcd "%s"`, filepath.Dir(op.LocalPath)),
+			fmt.Sprintf(`put "%s" "%s"`, filepath.Base(op.LocalPath), fullPath))
+	case BatchOpDelete:
+		step.commands = append(step.commands, fmt.Sprintf(`del "%s"`, fullPath))
+	case BatchOpRename:
+		toPath := normalizePathSegment(buildFullPath(op.To, cfg))
+		if op.To == "" || toPath == "" || toPath == "." {
+			return batchStep{}, fmt.Errorf("rename requires a destination path")
+		}
+		command := fmt.Sprintf(`rename "%s" "%s"`, fullPath, toPath)
+		if op.Overwrite {
+			command += " -f"
+		}
+		step.commands = append(step.commands, command)
+	case BatchOpMkdir:
+		step.commands = append(step.commands, fmt.Sprintf(`mkdir "%s"`, fullPath))
+	default:
+		return batchStep{}, fmt.Errorf("unsupported operation: %q", op.Op)
+	}
+
+	return step, nil
+}
+
+// batchSequence drives an interactive smbclient session that runs operations one at a time
+// Fields are ordered for optimal memory alignment
+type batchSequence struct {
+	cfg      *config.SMBConfig
+	ops      []BatchOperation
+	results  []BatchOperationResult
+	segment  []string // Output of the command running
+	step     batchStep
+	next     int  // Index of the next operation to send
+	waiting  int  // Delimiters still due for the commands sent
+	checking bool // The upload target check of step is running
+	running  bool // step has been sent and has no result yet
+}
+
+// runBatchSequence runs operations in one interactive smbclient session, stopping at the first failure
+// Uploads that must not overwrite list their target in the session first, so the check sees the
+// share as the operations before them left it.
+func runBatchSequence(
+	ctx context.Context, ops []BatchOperation, results []BatchOperationResult, cfg *config.SMBConfig,
+) {
+	seq := &batchSequence{cfg: cfg, ops: ops, results: results}
+	input, done := seq.start()
+	if done {
+		return
+	}
+
+	args, env, err := buildSmbClientArgs(cfg, "")
+	if err != nil {
+		seq.finish(err)
+		return
+	}
+
+	// Not retried: a session that fails part-way may already have applied some steps
+	output, err := executeSmbClientSession(ctx, args, env, input, seq.reply, cfg)
+	if !seq.running {
+		return
+	}
+	op := ops[seq.step.index]
+	if err != nil && (strings.Contains(output, "session setup failed") ||
+		strings.Contains(output, "tree connect failed")) {
+		seq.finish(fmt.Errorf("failed to connect to share: %w", err))
+		return
+	}
+	seq.finish(fmt.Errorf("no result from smbclient for %s %s", op.Op, op.Path))
+}
+
+// start prepares the next operation and returns the commands to send for it
+// done is true when there is nothing more to send, because every operation has run or the next
+// one is invalid.
+func (s *batchSequence) start() (string, bool) {
+	if s.next >= len(s.ops) {
+		return "", true
+	}
+
+	step, err := prepareBatchStep(s.next, s.ops[s.next], s.cfg)
+	s.step = step
+	s.next++
+	if err != nil {
+		s.finish(err)
+		return "", true
+	}
+
+	s.running = true
+	if step.checkPath != "" {
+		s.checking = true
+		return s.send([]string{fmt.Sprintf(`ls "%s"`, step.checkPath)}), false
+	}
+	return s.send(step.commands), false
+}
+
+// send returns the input that runs commands, each followed by the delimiter
+func (s *batchSequence) send(commands []string) string {
+	var input strings.Builder
+	for _, command := range commands {
+		input.WriteString(command + "\n" + batchDelimiterCommand + "\n")
+	}
+	s.waiting = len(commands)
+	return input.String()
+}
+
+// reply takes one line of session output and returns what to send next once the step's commands are done
+func (s *batchSequence) reply(line string) (string, bool) {
+	line = batchPromptPattern.ReplaceAllString(line, "")
+	if !strings.HasPrefix(strings.TrimSpace(line), batchDelimiterOutput) {
+		s.segment = append(s.segment, line)
+		return "", false
+	}
+
+	output := strings.Join(s.segment, "\n")
+	s.segment = nil
+	if s.waiting--; s.waiting > 0 {
+		return "", false
+	}
+
+	op := s.ops[s.step.index]
+	if s.checking {
+		s.checking = false
+		// A target that cannot be listed does not exist
+		if !strings.Contains(output, "NT_STATUS_") {
+			if err := uploadTargetError(output, s.step.checkPath, op.Path); err != nil {
+				s.finish(err)
+				return "", true
+			}
+		}
+		return s.send(s.step.commands), false
+	}
+
+	if err := batchStepError(output, op, s.cfg); err != nil {
+		s.finish(err)
+		return "", true
+	}
+	s.finish(nil)
+	return s.start()
+}
+
+// finish records the outcome of the current step
+func (s *batchSequence) finish(err error) {
+	s.results[s.step.index].Err = err
+	s.results[s.step.index].Skipped = false
+	s.running = false
+}
+
+// runBatchSession runs steps in one smbclient session and records each step's outcome in results
+// A session that cannot be established, or output that ends early, fails the affected steps.
+func runBatchSession(
+	ctx context.Context, steps []batchStep, ops []BatchOperation, results []BatchOperationResult, cfg *config.SMBConfig,
+) {
+	if len(steps) == 0 {
+		return
+	}
+
+	commands := make([]string, 0, len(steps)*4)
+	for _, step := range steps {
+		for _, command := range step.commands {
+			commands = append(commands, command, batchDelimiterCommand)
+		}
+	}
+
+	fail := func(err error) {
+		for _, step := range steps {
+			results[step.index].Err = err
+			results[step.index].Skipped = false
+		}
+	}
+
+	args, env, err := buildSmbClientArgs(cfg, strings.Join(commands, "; "))
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	// Not retried: a session that fails part-way may already have applied some steps
+	output, err := executeSmbClient(ctx, args, env, cfg)
+	if err != nil && (strings.Contains(output, "session setup failed") ||
+		strings.Contains(output, "tree connect failed")) {
+		fail(fmt.Errorf("failed to connect to share: %w", err))
+		return
+	}
+
+	segments := splitBatchOutput(output)
+	next := 0
+	for _, step := range steps {
+		next += len(step.commands)
+		result := &results[step.index]
+		result.Skipped = false
+		if next > len(segments) {
+			result.Err = fmt.Errorf("no result from smbclient for %s %s", ops[step.index].Op, ops[step.index].Path)
+			continue
+		}
+		result.Err = batchStepError(segments[next-1], ops[step.index], cfg)
+	}
+}
+
+// splitBatchOutput splits batch session output into the output of each command
+// Output after the last delimiter belongs to a command that did not complete and is dropped.
+func splitBatchOutput(output string) []string {
+	var segments []string
+	var current []string
+
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), batchDelimiterOutput) {
+			segments = append(segments, strings.Join(current, "\n"))
+			current = nil
+			continue
+		}
+		current = append(current, line)
+	}
+
+	return segments
+}
+
+// batchStepError maps a command's output to the same errors the single-operation functions return
+func batchStepError(output string, op BatchOperation, cfg *config.SMBConfig) error {
+	if !strings.Contains(output, "NT_STATUS_") {
+		return nil
+	}
+
+	switch op.Op {
+	case BatchOpUpload:
+		switch {
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_COLLISION"):
+			return fmt.Errorf("remote file %w: %s", ErrFileExists, op.Path)
+		case strings.Contains(output, "NT_STATUS_FILE_IS_A_DIRECTORY"):
+			return fmt.Errorf("remote path %w: %s", ErrIsDirectory, op.Path)
+		case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+			return fmt.Errorf("%w: cannot write to %s", ErrAccessDenied, op.Path)
+		case strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND") && cfg.DisableAutoMkdir:
+			return fmt.Errorf("%w: %s", ErrParentNotFound, filepath.Dir(op.Path))
+		case strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"):
+			return fmt.Errorf("remote path %w: %s", ErrNotFound, filepath.Dir(op.Path))
+		case strings.Contains(output, "NT_STATUS_DISK_FULL"):
+			return fmt.Errorf("%w: share is full, cannot write %s", ErrInsufficientStorage, op.Path)
+		}
+	case BatchOpDelete:
+		switch {
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND"),
+			strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"),
+			strings.Contains(output, "NT_STATUS_NO_SUCH_FILE"):
+			return fmt.Errorf("file %w: %s", ErrNotFound, op.Path)
+		case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+			return fmt.Errorf("%w: cannot delete %s", ErrAccessDenied, op.Path)
+		case strings.Contains(output, "NT_STATUS_FILE_IS_A_DIRECTORY"):
+			return classify(ErrIsDirectory, "cannot delete directory: %s (use rmdir for directories)", op.Path)
+		}
+	case BatchOpRename:
+		switch {
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND"),
+			strings.Contains(output, "NT_STATUS_NO_SUCH_FILE"):
+			return fmt.Errorf("file %w: %s", ErrNotFound, op.Path)
+		case strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"):
+			return fmt.Errorf("%w: %s", ErrParentNotFound, filepath.Dir(op.To))
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_COLLISION"):
+			return fmt.Errorf("remote file %w: %s", ErrFileExists, op.To)
+		case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+			return fmt.Errorf("%w: cannot rename %s", ErrAccessDenied, op.Path)
+		}
+	case BatchOpMkdir:
+		switch {
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_COLLISION"):
+			return fmt.Errorf("directory %w: %s", ErrFileExists, op.Path)
+		case strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"):
+			return fmt.Errorf("%w: %s", ErrParentNotFound, filepath.Dir(op.Path))
+		case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+			return fmt.Errorf("%w: cannot create %s", ErrAccessDenied, op.Path)
+		}
+	}
+
+	return fmt.Errorf("%s failed: %s", op.Op, strings.TrimSpace(lastNTStatusLine(output)))
+}
+
+// lastNTStatusLine returns the last line of output that carries an NT_STATUS code
+func lastNTStatusLine(output string) string {
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.Contains(lines[i], "NT_STATUS_") {
+			return lines[i]
+		}
+	}
+	return output
+}
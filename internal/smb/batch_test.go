@@ -0,0 +1,397 @@
+package smb
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+)
+
+// newBatchSessionMock simulates smbclient running a chain of commands
+// Every command whose text contains a key of failures prints that NT_STATUS line; smbclient carries
+// on with the next command either way. All commands seen are appended to executed.
+func newBatchSessionMock(failures map[string]string, executed *[]string) *MockSmbClientExecutor {
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		var output strings.Builder
+		var err error
+		for _, command := range strings.Split(args[len(args)-1], "; ") {
+			*executed = append(*executed, command)
+			if command == batchDelimiterCommand {
+				output.WriteString("Current directory is \\\\testserver\\testshare\\\n")
+				continue
+			}
+			err = nil
+			for key, status := range failures {
+				if strings.Contains(command, key) {
+					output.WriteString(status + "\n")
+					err = fmt.Errorf("smbclient command failed: exit status 1")
+				}
+			}
+		}
+		return output.String(), err
+	}
+	return mock
+}
+
+func batchTestConfig() *config.SMBConfig {
+	return &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		AuthProtocol: "ntlm",
+		Port:         445,
+	}
+}
+
+func TestRunBatch_StopOnError(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	ops := []BatchOperation{
+		{Op: BatchOpMkdir, Path: "reports"},
+		{Op: BatchOpDelete, Path: "reports/missing.txt"},
+		{Op: BatchOpRename, Path: "reports/a.txt", To: "reports/b.txt"},
+	}
+	failures := map[string]string{
+		`del "reports/missing.txt"`: `NT_STATUS_OBJECT_NAME_NOT_FOUND deleting remote file \reports\missing.txt`,
+	}
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name         string
+		stopOnError  bool
+		wantSessions int
+		wantRenamed  bool
+		wantLast     string
+	}{
+		{name: "stop on error", stopOnError: true, wantSessions: 1, wantRenamed: false, wantLast: "skipped"},
+		{name: "continue on error", stopOnError: false, wantSessions: 1, wantRenamed: true, wantLast: "ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var executed []string
+			mock := newBatchSessionMock(failures, &executed)
+			smbClientExec = mock
+
+			results := RunBatch(ops, batchTestConfig(), tt.stopOnError)
+			if len(results) != len(ops) {
+				t.Fatalf("Expected %d results, got %d", len(ops), len(results))
+			}
+
+			if results[0].Err != nil || results[0].Skipped {
+				t.Errorf("Expected mkdir to succeed, got: %+v", results[0])
+			}
+			if results[1].Err == nil || results[1].Err.Error() != "file not found: reports/missing.txt" {
+				t.Errorf("Expected delete to fail with file not found, got: %+v", results[1])
+			}
+
+			last := "ok"
+			switch {
+			case results[2].Skipped:
+				last = "skipped"
+			case results[2].Err != nil:
+				last = "failed"
+			}
+			if last != tt.wantLast {
+				t.Errorf("Expected rename to be %s, got %s (%v)", tt.wantLast, last, results[2].Err)
+			}
+
+			if mock.CallCount != tt.wantSessions {
+				t.Errorf("Expected %d smbclient sessions, got %d", tt.wantSessions, mock.CallCount)
+			}
+			renamed := strings.Contains(strings.Join(executed, "\n"), `rename "reports/a.txt" "reports/b.txt"`)
+			if renamed != tt.wantRenamed {
+				t.Errorf("Expected rename sent=%v, commands: %v", tt.wantRenamed, executed)
+			}
+		})
+	}
+}
+
+func TestRunBatch_UploadCommands(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	var executed []string
+	smbClientExec = newBatchSessionMock(map[string]string{
+		// The parent directory already exists; the failed mkdir must not fail the upload
+		`mkdir "base/inbox"`: `NT_STATUS_OBJECT_NAME_COLLISION making remote directory \base\inbox`,
+	}, &executed)
+
+	cfg := batchTestConfig()
+	cfg.BasePath = "base"
+
+	results := RunBatch([]BatchOperation{
+		{Op: BatchOpUpload, Path: "inbox/report.pdf", LocalPath: "/tmp/staged-report.pdf", Overwrite: true},
+		{Op: BatchOpRename, Path: "inbox/report.pdf", To: "archive/report.pdf", Overwrite: true},
+	}, cfg, false)
+
+	for i, result := range results {
+		if result.Err != nil || result.Skipped {
+			t.Errorf("Expected step %d to succeed, got: %+v", i, result)
+		}
+	}
+
+	commands := strings.Join(executed, "\n")
+	for _, want := range []string{
+		`lcd "/tmp"`,
+		`put "staged-report.pdf" "base/inbox/report.pdf"`,
+		`rename "base/inbox/report.pdf" "base/archive/report.pdf" -f`,
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Expected command %q, got: %v", want, executed)
+		}
+	}
+}
+
+func TestRunBatch_UploadConflict(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	listing := "  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n" +
+		"\n\t\t65535 blocks of size 1024. 1000 blocks available\n"
+	ops := []BatchOperation{
+		{Op: BatchOpUpload, Path: "report.pdf", LocalPath: "/tmp/report.pdf"},
+		{Op: BatchOpDelete, Path: "old.pdf"},
+	}
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name        string
+		stopOnError bool
+	}{
+		{
+			name:        "stop on error checks in the session",
+			stopOnError: true,
+		},
+		{
+			name:        "continue on error checks before the session",
+			stopOnError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var executed []string
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				var output strings.Builder
+				for _, command := range strings.Split(args[len(args)-1], "; ") {
+					executed = append(executed, command)
+					switch {
+					case command == batchDelimiterCommand:
+						output.WriteString("Current directory is \\\\testserver\\testshare\\\n")
+					case strings.HasPrefix(command, "ls "):
+						output.WriteString(listing)
+					}
+				}
+				return output.String(), nil
+			}
+			smbClientExec = mock
+
+			results := RunBatch(ops, batchTestConfig(), tt.stopOnError)
+
+			if results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "remote file already exists") {
+				t.Errorf("Expected upload conflict, got: %+v", results[0])
+			}
+			if strings.Contains(strings.Join(executed, "\n"), "put ") {
+				t.Errorf("Expected the conflicting upload not to be sent, got: %v", executed)
+			}
+			if tt.stopOnError != results[1].Skipped {
+				t.Errorf("Expected delete skipped=%v, got: %+v", tt.stopOnError, results[1])
+			}
+		})
+	}
+}
+
+func TestRunBatch_UploadCheckSeesEarlierSteps(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	// The file exists until the batch deletes it
+	deleted := false
+	var executed []string
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		var output strings.Builder
+		for _, command := range strings.Split(args[len(args)-1], "; ") {
+			executed = append(executed, command)
+			switch {
+			case command == batchDelimiterCommand:
+				output.WriteString("Current directory is \\\\testserver\\testshare\\\n")
+			case strings.HasPrefix(command, "del "):
+				deleted = true
+			case strings.HasPrefix(command, "ls ") && deleted:
+				output.WriteString("NT_STATUS_NO_SUCH_FILE listing \\report.pdf\n")
+			case strings.HasPrefix(command, "ls "):
+				output.WriteString("  report.pdf    A     1024  Mon Jan  1 12:00:00 2024\n")
+			}
+		}
+		return output.String(), nil
+	}
+	smbClientExec = mock
+
+	results := RunBatch([]BatchOperation{
+		{Op: BatchOpDelete, Path: "report.pdf"},
+		{Op: BatchOpUpload, Path: "report.pdf", LocalPath: "/tmp/report.pdf"},
+	}, batchTestConfig(), true)
+
+	for i, result := range results {
+		if result.Err != nil || result.Skipped {
+			t.Errorf("Expected step %d to succeed, got: %+v", i, result)
+		}
+	}
+	if mock.CallCount != 1 {
+		t.Errorf("Expected a single smbclient session, got %d", mock.CallCount)
+	}
+	if !strings.Contains(strings.Join(executed, "\n"), `put "report.pdf" "report.pdf"`) {
+		t.Errorf("Expected the upload to be sent, got: %v", executed)
+	}
+}
+
+// writeFakeSmbClient writes a script that behaves like an interactive smbclient session: it prompts
+// for each command, answers pwd, fails every del and logs the commands it reads to logPath
+func writeFakeSmbClient(t *testing.T, logPath string) string {
+	t.Helper()
+
+	script := "#!/bin/sh\n" +
+		"while printf 'smb: \\\\> ' && read -r line; do\n" +
+		"  echo \"$line\" >> '" + logPath + "'\n" +
+		"  case \"$line\" in\n" +
+		"    pwd) echo 'Current directory is \\\\testserver\\testshare\\' ;;\n" +
+		"    del*) echo 'NT_STATUS_OBJECT_NAME_NOT_FOUND deleting remote file' ;;\n" +
+		"  esac\n" +
+		"done\n"
+	path := filepath.Join(t.TempDir(), "smbclient")
+	// #nosec G306 - the fake binary must be executable
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake smbclient: %v", err)
+	}
+	return path
+}
+
+func TestRunBatch_InteractiveSession(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	logPath := filepath.Join(t.TempDir(), "commands.log")
+	smbClientExec = &DefaultSmbClientExecutor{BinaryPath: writeFakeSmbClient(t, logPath)}
+
+	cfg := batchTestConfig()
+	cfg.CommandTimeout = 10 * time.Second
+	results := RunBatch([]BatchOperation{
+		{Op: BatchOpMkdir, Path: "reports"},
+		{Op: BatchOpDelete, Path: "reports/missing.txt"},
+		{Op: BatchOpRename, Path: "reports/a.txt", To: "reports/b.txt"},
+	}, cfg, true)
+
+	if results[0].Err != nil || results[0].Skipped {
+		t.Errorf("Expected mkdir to succeed, got: %+v", results[0])
+	}
+	if results[1].Err == nil || results[1].Err.Error() != "file not found: reports/missing.txt" {
+		t.Errorf("Expected delete to fail with file not found, got: %+v", results[1])
+	}
+	if !results[2].Skipped {
+		t.Errorf("Expected rename to be skipped, got: %+v", results[2])
+	}
+
+	logged, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read command log: %v", err)
+	}
+	want := "mkdir \"reports\"\npwd\ndel \"reports/missing.txt\"\npwd\n"
+	if string(logged) != want {
+		t.Errorf("Expected commands %q, got %q", want, string(logged))
+	}
+}
+
+func TestRunBatch_SessionFailures(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	ops := []BatchOperation{
+		{Op: BatchOpMkdir, Path: "a"},
+		{Op: BatchOpMkdir, Path: "b"},
+	}
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name    string
+		output  string
+		err     error
+		wantErr []string
+	}{
+		{
+			name:    "connection failure fails every step",
+			output:  "session setup failed: NT_STATUS_LOGON_FAILURE",
+			err:     fmt.Errorf("smbclient command failed: exit status 1"),
+			wantErr: []string{"failed to connect to share", "failed to connect to share"},
+		},
+		{
+			name:    "truncated output fails the unfinished step",
+			output:  "Current directory is \\\\testserver\\testshare\\\n",
+			wantErr: []string{"", "no result from smbclient for mkdir b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(_ []string) (string, error) {
+				return tt.output, tt.err
+			}
+			smbClientExec = mock
+
+			results := RunBatch(ops, batchTestConfig(), false)
+			for i, want := range tt.wantErr {
+				if want == "" {
+					if results[i].Err != nil {
+						t.Errorf("Step %d: expected success, got: %v", i, results[i].Err)
+					}
+					continue
+				}
+				if results[i].Err == nil || !strings.Contains(results[i].Err.Error(), want) {
+					t.Errorf("Step %d: expected error containing %q, got: %v", i, want, results[i].Err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunBatch_InvalidOperations(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	var executed []string
+	smbClientExec = newBatchSessionMock(nil, &executed)
+
+	results := RunBatch([]BatchOperation{
+		{Op: "chmod", Path: "a.txt"},
+		{Op: BatchOpDelete, Path: ""},
+		{Op: BatchOpRename, Path: "a.txt"},
+		{Op: BatchOpMkdir, Path: "ok"},
+	}, batchTestConfig(), false)
+
+	for i, want := range []string{"unsupported operation", "root directory", "destination path"} {
+		if results[i].Err == nil || !strings.Contains(results[i].Err.Error(), want) {
+			t.Errorf("Step %d: expected error containing %q, got: %v", i, want, results[i].Err)
+		}
+	}
+	if results[3].Err != nil {
+		t.Errorf("Expected valid mkdir to run, got: %v", results[3].Err)
+	}
+	if len(executed) != 2 {
+		t.Errorf("Expected only the valid step to be sent, got: %v", executed)
+	}
+}
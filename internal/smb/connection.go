@@ -2,7 +2,12 @@
 package smb
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/bancey/document-smbrelay-service/internal/config"
 )
@@ -23,15 +28,140 @@ type HealthCheckResult struct {
 	Server             string `json:"server"`
 	Share              string `json:"share"`
 	Error              string `json:"error,omitempty"`
+	SMBWritable        *bool  `json:"smb_writable,omitempty"` // Only reported when HEALTH_WRITE_TEST is enabled
 	SMBShareAccessible bool   `json:"smb_share_accessible"`
+	SMBClientAvailable bool   `json:"smbclient_available"`
+}
+
+// healthCall is an in-flight health check shared by concurrent identical callers
+type healthCall struct {
+	result *HealthCheckResult
+	wg     sync.WaitGroup
+	dups   int
+}
+
+// healthFlight tracks in-flight health checks by configuration key
+var healthFlight = struct {
+	calls map[string]*healthCall
+	mu    sync.Mutex
+}{calls: make(map[string]*healthCall)}
+
+// cachedHealth is a health check result and when it expires
+type cachedHealth struct {
+	expires time.Time
+	result  *HealthCheckResult
+}
+
+// healthResultCache holds recent health check results for reuse until they expire
+// Results are keyed by configuration, so a config change is never served a stale result.
+type healthResultCache struct {
+	entries map[string]cachedHealth
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+var healthCache = &healthResultCache{entries: make(map[string]cachedHealth), now: time.Now}
+
+// get returns a copy of the cached result for key if it has not expired
+func (h *healthResultCache) get(key string) (*HealthCheckResult, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, ok := h.entries[key]
+	if !ok || !h.now().Before(entry.expires) {
+		return nil, false
+	}
+	return copyHealthResult(entry.result), true
+}
+
+// set caches result for key for ttl, dropping expired results
+func (h *healthResultCache) set(key string, result *HealthCheckResult, ttl time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	for k, entry := range h.entries {
+		if !now.Before(entry.expires) {
+			delete(h.entries, k)
+		}
+	}
+	h.entries[key] = cachedHealth{result: copyHealthResult(result), expires: now.Add(ttl)}
 }
 
 // CheckHealth performs a health check on the SMB server and share using smbclient
+// With HealthCacheTTL set, a result is reused by checks against the same configuration until it expires.
+// With HealthSingleFlight enabled, concurrent checks against the same configuration share
+// one in-flight result instead of each connecting to the server.
 func CheckHealth(cfg *config.SMBConfig) *HealthCheckResult {
+	if cfg.HealthCacheTTL <= 0 {
+		return checkHealthShared(cfg)
+	}
+
+	key := healthCheckKey(cfg)
+	if result, ok := healthCache.get(key); ok {
+		return result
+	}
+	result := checkHealthShared(cfg)
+	healthCache.set(key, result, cfg.HealthCacheTTL)
+	return result
+}
+
+// checkHealthShared runs a health check, joining an identical one in flight if HealthSingleFlight is enabled
+func checkHealthShared(cfg *config.SMBConfig) *HealthCheckResult {
+	if !cfg.HealthSingleFlight {
+		return checkHealth(cfg)
+	}
+
+	key := healthCheckKey(cfg)
+
+	healthFlight.mu.Lock()
+	if call, ok := healthFlight.calls[key]; ok {
+		call.dups++
+		healthFlight.mu.Unlock()
+		call.wg.Wait()
+		return copyHealthResult(call.result)
+	}
+	call := &healthCall{}
+	call.wg.Add(1)
+	healthFlight.calls[key] = call
+	healthFlight.mu.Unlock()
+
+	call.result = checkHealth(cfg)
+	call.wg.Done()
+
+	healthFlight.mu.Lock()
+	delete(healthFlight.calls, key)
+	healthFlight.mu.Unlock()
+
+	return copyHealthResult(call.result)
+}
+
+// healthCheckKey identifies the target and credentials of a health check
+// Checks only share a result when every setting that affects the outcome matches. The settings
+// are hashed so the password is not kept in memory as a map key.
+func healthCheckKey(cfg *config.SMBConfig) string {
+	settings := fmt.Sprintf("%q|%q|%d|%q|%q|%q|%q|%q|%q|%q|%q|%t|%t|%q|%t|%t",
+		cfg.ServerName, cfg.ServerIP, cfg.Port, cfg.ShareName, cfg.BasePath,
+		cfg.Domain, cfg.Username, cfg.Password, cfg.AuthProtocol,
+		cfg.KerberosKeytab, cfg.KerberosPrincipal,
+		cfg.PasswordIsNTHash, cfg.HealthWriteTest, cfg.HealthWriteDir, cfg.CreateBasePath, cfg.ReadOnly)
+	sum := sha256.Sum256([]byte(settings))
+	return hex.EncodeToString(sum[:])
+}
+
+// copyHealthResult returns a copy of a shared result so callers can modify their own
+func copyHealthResult(result *HealthCheckResult) *HealthCheckResult {
+	c := *result
+	return &c
+}
+
+// checkHealth runs the health check against the SMB server
+func checkHealth(cfg *config.SMBConfig) *HealthCheckResult {
 	result := &HealthCheckResult{
-		AppStatus: statusOK,
-		Server:    cfg.GetServerDisplay(),
-		Share:     cfg.ShareName,
+		AppStatus:          statusOK,
+		Server:             cfg.GetServerDisplay(),
+		Share:              cfg.ShareName,
+		SMBClientAvailable: true,
 	}
 
 	// Test connection using smbclient
@@ -40,6 +170,7 @@ func CheckHealth(cfg *config.SMBConfig) *HealthCheckResult {
 		result.Status = statusUnhealthy
 		result.SMBConnection = statusFailed
 		result.SMBShareAccessible = false
+		result.SMBClientAvailable = !errors.Is(err, ErrNoSmbClient)
 		result.Error = err.Error()
 		return result
 	}
@@ -56,6 +187,21 @@ func CheckHealth(cfg *config.SMBConfig) *HealthCheckResult {
 		}
 	}
 
+	// Optionally verify the share is writable, since a read-only share passes the checks above;
+	// a relay configured with SMB_READ_ONLY never writes, so it skips the test
+	if cfg.HealthWriteTest && !cfg.ReadOnly {
+		err = testWrite(cfg)
+		writable := err == nil
+		result.SMBWritable = &writable
+		if err != nil {
+			result.Status = statusUnhealthy
+			result.SMBConnection = statusOK
+			result.SMBShareAccessible = true
+			result.Error = fmt.Sprintf("write test failed: %v", err)
+			return result
+		}
+	}
+
 	result.Status = statusHealthy
 	result.SMBConnection = statusOK
 	result.SMBShareAccessible = true
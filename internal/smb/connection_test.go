@@ -1,8 +1,14 @@
 package smb
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/bancey/document-smbrelay-service/internal/config"
 )
@@ -734,3 +740,394 @@ func TestCheckHealth_BasePathAccessDenied(t *testing.T) {
 		t.Error("Expected error message for access denied to base path")
 	}
 }
+
+// newWriteTestMock returns a mock that records commands and fails put commands when readOnly is set
+func newWriteTestMock(commands *[]string, readOnly bool) *MockSmbClientExecutor {
+	return &MockSmbClientExecutor{
+		ExecuteFunc: func(args []string) (string, error) {
+			cmd := args[len(args)-1]
+			*commands = append(*commands, cmd)
+			if strings.Contains(cmd, "put ") {
+				if readOnly {
+					return mockStatusAccessDenied, fmt.Errorf("smbclient command failed: exit status 1")
+				}
+				return "putting file probe as probe\n", nil
+			}
+			return "", nil
+		},
+	}
+}
+
+func TestCheckHealth_WriteTestWritable(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	var commands []string
+	smbClientExec = newWriteTestMock(&commands, false)
+
+	cfg := &config.SMBConfig{
+		ServerName:      "testserver",
+		ServerIP:        "192.168.1.100",
+		ShareName:       "testshare",
+		BasePath:        "apps/myapp",
+		Username:        "user",
+		Password:        "pass",
+		HealthWriteTest: true,
+		HealthWriteDir:  ".probe",
+	}
+
+	result := CheckHealth(cfg)
+
+	if result.Status != statusHealthy {
+		t.Errorf("Expected status 'healthy', got '%s' (error: %s)", result.Status, result.Error)
+	}
+	if result.SMBWritable == nil || !*result.SMBWritable {
+		t.Errorf("Expected smb_writable to be true, got %v", result.SMBWritable)
+	}
+
+	var putCmd, delCmd string
+	for _, cmd := range commands {
+		if strings.Contains(cmd, "put ") {
+			putCmd = cmd
+		}
+		if strings.HasPrefix(cmd, "del ") {
+			delCmd = cmd
+		}
+	}
+	if !strings.Contains(putCmd, `"apps/myapp/.probe/probe-`) {
+		t.Errorf("Expected probe upload into the health directory, got: %q", putCmd)
+	}
+	if !strings.Contains(delCmd, `"apps/myapp/.probe/probe-`) {
+		t.Errorf("Expected probe file to be deleted, got: %q", delCmd)
+	}
+}
+
+func TestCheckHealth_WriteTestNotWritable(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	var commands []string
+	smbClientExec = newWriteTestMock(&commands, true)
+
+	cfg := &config.SMBConfig{
+		ServerName:      "testserver",
+		ServerIP:        "192.168.1.100",
+		ShareName:       "testshare",
+		Username:        "user",
+		Password:        "pass",
+		HealthWriteTest: true,
+		HealthWriteDir:  ".probe",
+	}
+
+	result := CheckHealth(cfg)
+
+	if result.Status != statusUnhealthy {
+		t.Errorf("Expected status 'unhealthy', got '%s'", result.Status)
+	}
+	if result.SMBWritable == nil || *result.SMBWritable {
+		t.Errorf("Expected smb_writable to be false, got %v", result.SMBWritable)
+	}
+	if !result.SMBShareAccessible {
+		t.Error("Expected the share to still be reported as accessible")
+	}
+	if !strings.Contains(result.Error, "not writable") {
+		t.Errorf("Expected not writable error, got '%s'", result.Error)
+	}
+}
+
+func TestCheckHealth_WriteTestCleansUpFailedWrite(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	// The put fails part way, after the probe file was created
+	var commands []string
+	smbClientExec = &MockSmbClientExecutor{
+		ExecuteFunc: func(args []string) (string, error) {
+			cmd := args[len(args)-1]
+			commands = append(commands, cmd)
+			if strings.Contains(cmd, "put ") {
+				return "NT_STATUS_CONNECTION_RESET", fmt.Errorf("smbclient command failed: exit status 1")
+			}
+			return "", nil
+		},
+	}
+
+	cfg := &config.SMBConfig{
+		ServerName:      "testserver",
+		ServerIP:        "192.168.1.100",
+		ShareName:       "testshare",
+		Username:        "user",
+		Password:        "pass",
+		HealthWriteTest: true,
+		HealthWriteDir:  ".probe",
+	}
+
+	result := CheckHealth(cfg)
+
+	if result.Status != statusUnhealthy || result.SMBWritable == nil || *result.SMBWritable {
+		t.Errorf("Expected an unhealthy, unwritable result, got '%s' with smb_writable %v", result.Status, result.SMBWritable)
+	}
+	var putCmd, delCmd string
+	for _, cmd := range commands {
+		if strings.Contains(cmd, "put ") {
+			putCmd = cmd
+		}
+		if strings.HasPrefix(cmd, "del ") {
+			delCmd = cmd
+		}
+	}
+	probe, ok := strings.CutPrefix(delCmd, "del ")
+	if !ok || !strings.HasPrefix(probe, `".probe/probe-`) || !strings.HasSuffix(putCmd, " "+probe) {
+		t.Errorf("Expected the probe written by %q to be deleted, got %q", putCmd, delCmd)
+	}
+}
+
+func TestCheckHealth_WriteTestDisabled(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	var commands []string
+	smbClientExec = newWriteTestMock(&commands, true)
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ServerIP:   "192.168.1.100",
+		ShareName:  "testshare",
+		Username:   "user",
+		Password:   "pass",
+	}
+
+	result := CheckHealth(cfg)
+
+	if result.Status != statusHealthy {
+		t.Errorf("Expected status 'healthy', got '%s'", result.Status)
+	}
+	if result.SMBWritable != nil {
+		t.Errorf("Expected smb_writable to be omitted, got %v", *result.SMBWritable)
+	}
+	for _, cmd := range commands {
+		if strings.Contains(cmd, "put ") {
+			t.Errorf("Expected no write probe when disabled, got command: %q", cmd)
+		}
+	}
+}
+
+func TestCheckHealth_WriteTestReadOnly(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	var commands []string
+	smbClientExec = newWriteTestMock(&commands, true)
+
+	cfg := &config.SMBConfig{
+		ServerName:      "testserver",
+		ServerIP:        "192.168.1.100",
+		ShareName:       "testshare",
+		Username:        "user",
+		Password:        "pass",
+		HealthWriteTest: true,
+		HealthWriteDir:  ".probe",
+		ReadOnly:        true,
+	}
+
+	result := CheckHealth(cfg)
+
+	if result.Status != statusHealthy {
+		t.Errorf("Expected a read-only share to be healthy, got '%s' (error: %s)", result.Status, result.Error)
+	}
+	for _, cmd := range commands {
+		if strings.Contains(cmd, "put ") {
+			t.Errorf("Expected no write probe with SMB_READ_ONLY, got command: %q", cmd)
+		}
+	}
+}
+
+// blockingExecutor counts smbclient invocations and holds each one until released
+type blockingExecutor struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (e *blockingExecutor) Execute(_ []string) (string, error) {
+	e.calls.Add(1)
+	<-e.release
+	return "  .  D  0  Mon Jan  1 12:00:00 2024\n", nil
+}
+
+func (e *blockingExecutor) ExecuteWithStdin(args []string, _ map[string]string, _ io.Reader) (string, error) {
+	return e.Execute(args)
+}
+
+func TestCheckHealth_SingleFlight(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	exec := &blockingExecutor{release: make(chan struct{})}
+	smbClientExec = exec
+
+	cfg := &config.SMBConfig{
+		ServerName:         "testserver",
+		ServerIP:           "192.168.1.100",
+		ShareName:          "testshare",
+		Username:           "testuser",
+		Password:           "testpass",
+		Port:               445,
+		HealthSingleFlight: true,
+	}
+
+	const callers = 10
+	results := make([]*HealthCheckResult, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = CheckHealth(cfg)
+		}(i)
+	}
+
+	// Wait until every caller has joined the single in-flight check before letting it finish
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		healthFlight.mu.Lock()
+		joined := 0
+		for _, call := range healthFlight.calls {
+			joined = call.dups + 1
+		}
+		healthFlight.mu.Unlock()
+		if joined == callers {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for callers to join, %d of %d joined", joined, callers)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(exec.release)
+	wg.Wait()
+
+	if calls := exec.calls.Load(); calls != 1 {
+		t.Errorf("Expected the executor to be invoked once for %d concurrent checks, got %d", callers, calls)
+	}
+	for i, result := range results {
+		if result == nil || result.Status != statusHealthy {
+			t.Fatalf("Caller %d: expected healthy result, got %+v", i, result)
+		}
+	}
+	if results[0] == results[1] {
+		t.Error("Expected each caller to receive its own copy of the result")
+	}
+	if len(healthFlight.calls) != 0 {
+		t.Errorf("Expected no in-flight checks after completion, got %d", len(healthFlight.calls))
+	}
+}
+
+func TestHealthCheckKey(t *testing.T) {
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "s3cret-password",
+		Port:       445,
+	}
+	key := healthCheckKey(cfg)
+	if strings.Contains(key, cfg.Password) {
+		t.Errorf("Expected the key not to contain the password, got %q", key)
+	}
+	if healthCheckKey(cfg) != key {
+		t.Error("Expected the same settings to give the same key")
+	}
+
+	other := *cfg
+	other.Password = "another-password"
+	if healthCheckKey(&other) == key {
+		t.Error("Expected a different password to give a different key")
+	}
+}
+
+func TestCheckHealth_SingleFlightDisabled(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	exec := &blockingExecutor{release: make(chan struct{})}
+	close(exec.release)
+	smbClientExec = exec
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ServerIP:   "192.168.1.100",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		Port:       445,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			CheckHealth(cfg)
+		}()
+	}
+	wg.Wait()
+
+	if calls := exec.calls.Load(); calls != 3 {
+		t.Errorf("Expected one executor call per check with single-flight disabled, got %d", calls)
+	}
+}
+
+func TestCheckHealth_Cache(t *testing.T) {
+	origExec, origCache := smbClientExec, healthCache
+	defer func() { smbClientExec, healthCache = origExec, origCache }()
+
+	exec := &blockingExecutor{release: make(chan struct{})}
+	close(exec.release)
+	smbClientExec = exec
+
+	now := time.Unix(1700000000, 0)
+	healthCache = &healthResultCache{entries: make(map[string]cachedHealth), now: func() time.Time { return now }}
+
+	cfg := &config.SMBConfig{
+		ServerName:     "testserver",
+		ServerIP:       "192.168.1.100",
+		ShareName:      "testshare",
+		Username:       "testuser",
+		Password:       "testpass",
+		Port:           445,
+		HealthCacheTTL: 10 * time.Second,
+	}
+
+	first := CheckHealth(cfg)
+	now = now.Add(5 * time.Second)
+	second := CheckHealth(cfg)
+	if calls := exec.calls.Load(); calls != 1 {
+		t.Errorf("Expected one executor call for two checks within the TTL, got %d", calls)
+	}
+	if second.Status != statusHealthy || first == second {
+		t.Errorf("Expected a copy of the cached healthy result, got %+v", second)
+	}
+
+	// The result refreshes once it expires
+	now = now.Add(5 * time.Second)
+	CheckHealth(cfg)
+	if calls := exec.calls.Load(); calls != 2 {
+		t.Errorf("Expected the expired result to be refreshed, got %d executor calls", calls)
+	}
+
+	// A changed configuration is not served the cached result
+	changed := *cfg
+	changed.ShareName = "othershare"
+	CheckHealth(&changed)
+	if calls := exec.calls.Load(); calls != 3 {
+		t.Errorf("Expected a changed configuration to bypass the cache, got %d executor calls", calls)
+	}
+
+	// Caching is disabled with a zero TTL
+	cfg.HealthCacheTTL = 0
+	CheckHealth(cfg)
+	CheckHealth(cfg)
+	if calls := exec.calls.Load(); calls != 5 {
+		t.Errorf("Expected every check to run with caching disabled, got %d executor calls", calls)
+	}
+}
@@ -0,0 +1,111 @@
+package smb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"strings"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+)
+
+// DedupIndex finds files already on the share by the SHA-256 digest of their content
+type DedupIndex interface {
+	// Lookup returns the path of a file on the share with the given digest, or "" when there is none
+	Lookup(ctx context.Context, digest string, cfg *config.SMBConfig) (string, error)
+	// Record notes that the file at remotePath has the given digest
+	Record(ctx context.Context, digest, remotePath string, cfg *config.SMBConfig) error
+}
+
+// Global deduplication index that can be replaced, e.g. by one kept in a database
+var dedupIndex DedupIndex = ShareDedupIndex{}
+
+// SetDedupIndex replaces the global deduplication index and returns the previous one
+func SetDedupIndex(index DedupIndex) DedupIndex {
+	previous := dedupIndex
+	dedupIndex = index
+	return previous
+}
+
+// LookupDuplicate returns the path of a file on the share with the given digest, or ""
+func LookupDuplicate(ctx context.Context, digest string, cfg *config.SMBConfig) (string, error) {
+	return dedupIndex.Lookup(ctx, digest, cfg)
+}
+
+// RecordDigest adds the file at remotePath to the deduplication index
+func RecordDigest(ctx context.Context, digest, remotePath string, cfg *config.SMBConfig) error {
+	return dedupIndex.Record(ctx, digest, remotePath, cfg)
+}
+
+// ShareDedupIndex keeps the deduplication index on the share itself, so every relay writing to
+// it shares the index. Each digest has a hash-named entry file in cfg.DedupDir, fanned out by its
+// first two characters, holding the path of the file with that content and, on a second line, the
+// size and modification time the file had when it was recorded.
+type ShareDedupIndex struct{}
+
+// fileStamp returns the size and modification time of a listing entry as stored in index entries
+func fileStamp(info FileInfo) string {
+	var modified int64
+	if info.ModTime != nil {
+		modified = info.ModTime.Unix()
+	}
+	return fmt.Sprintf("%d %d", info.Size, modified)
+}
+
+// entryPath returns the path of the index entry of digest
+func (ShareDedupIndex) entryPath(digest string, cfg *config.SMBConfig) string {
+	return pathpkg.Join(cfg.DedupDir, digest[:2], digest)
+}
+
+// Lookup reads the index entry of digest
+// An entry is ignored when its file has since been deleted or changed, as its content may no
+// longer have the digest, and when the file is outside cfg.AllowedPrefixes.
+func (i ShareDedupIndex) Lookup(ctx context.Context, digest string, cfg *config.SMBConfig) (string, error) {
+	tmpFile, err := os.CreateTemp("", "smb-dedup-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpPath)
+
+	if err := DownloadFileWithContext(ctx, i.entryPath(digest, cfg), tmpPath, cfg); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	content, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return "", err
+	}
+	remotePath, stamp, _ := strings.Cut(strings.TrimSpace(string(content)), "\n")
+	if remotePath == "" || ValidateAllowedPrefix(remotePath, cfg) != nil {
+		return "", nil
+	}
+
+	info, err := StatFileWithContext(ctx, remotePath, cfg)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	if fileStamp(info) != strings.TrimSpace(stamp) {
+		return "", nil
+	}
+	return remotePath, nil
+}
+
+// Record writes the index entry of digest, replacing any earlier one
+// The file is listed to stamp the entry, so it must be recorded once fully written.
+func (i ShareDedupIndex) Record(ctx context.Context, digest, remotePath string, cfg *config.SMBConfig) error {
+	info, err := StatFileWithContext(ctx, remotePath, cfg)
+	if err != nil {
+		return err
+	}
+	entry := remotePath + "\n" + fileStamp(info) + "\n"
+	return UploadStreamWithContext(ctx, strings.NewReader(entry), i.entryPath(digest, cfg), cfg, true)
+}
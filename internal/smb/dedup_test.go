@@ -0,0 +1,98 @@
+package smb
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+)
+
+func TestShareDedupIndex_Lookup(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	digest := strings.Repeat("ab", 32)
+	entry := ".smbrelay-dedup/ab/" + digest
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		DedupDir:   ".smbrelay-dedup",
+	}
+	listing := "  report.pdf                          A       22  Tue Jan  2 12:30:00 2024\n"
+	smbClientExec = newDownloadMock(listing, nil)
+	info, err := StatFileWithContext(context.Background(), "archive/report.pdf", cfg)
+	if err != nil {
+		t.Fatalf("Failed to stat the indexed file: %v", err)
+	}
+	recorded := "archive/report.pdf\n" + fileStamp(info) + "\n"
+	overwritten := strings.Replace(listing, "22", "31", 1)
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name            string
+		remote          map[string]string
+		listing         string
+		allowedPrefixes []string
+		expected        string
+	}{
+		{"indexed file", map[string]string{entry: recorded}, listing, nil, "archive/report.pdf"},
+		{"unknown digest", map[string]string{}, listing, nil, ""},
+		{"indexed file since deleted", map[string]string{entry: recorded}, "", nil, ""},
+		{"indexed file since overwritten", map[string]string{entry: recorded}, overwritten, nil, ""},
+		{"entry without a stamp", map[string]string{entry: "archive/report.pdf\n"}, listing, nil, ""},
+		{"indexed file of another team", map[string]string{entry: recorded}, listing, []string{"hr"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smbClientExec = newDownloadMock(tt.listing, tt.remote)
+			cfg := *cfg
+			cfg.AllowedPrefixes = tt.allowedPrefixes
+			duplicate, err := ShareDedupIndex{}.Lookup(context.Background(), digest, &cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if duplicate != tt.expected {
+				t.Errorf("Expected duplicate %q, got %q", tt.expected, duplicate)
+			}
+		})
+	}
+}
+
+func TestShareDedupIndex_Record(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	var command, content string
+	smbClientExec = &MockSmbClientExecutor{
+		ExecuteFunc: func(_ []string) (string, error) {
+			return "  report.pdf                          A       22  Tue Jan  2 12:30:00 2024\n", nil
+		},
+		ExecuteWithStdinFunc: func(args []string, stdin io.Reader) (string, error) {
+			data, err := io.ReadAll(stdin)
+			command, content = args[len(args)-1], string(data)
+			return "", err
+		},
+	}
+
+	digest := strings.Repeat("cd", 32)
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		BasePath:   "apps",
+		DedupDir:   ".smbrelay-dedup",
+	}
+	if err := (ShareDedupIndex{}).Record(context.Background(), digest, "inbox/report.pdf", cfg); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := `put - "apps/.smbrelay-dedup/cd/` + digest + `"`
+	if command != expected || !strings.HasPrefix(content, "inbox/report.pdf\n22 ") {
+		t.Errorf("Expected the entry to be written with %q holding the path, got %q holding %q", expected, command, content)
+	}
+}
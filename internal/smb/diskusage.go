@@ -0,0 +1,152 @@
+package smb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+	"github.com/bancey/document-smbrelay-service/internal/telemetry"
+)
+
+// diskSpacePattern matches the line smbclient prints after a listing, e.g.
+// "65535 blocks of size 1024. 32768 blocks available"
+var diskSpacePattern = regexp.MustCompile(`(\d+) blocks of size (\d+)\. (\d+) blocks available`)
+
+// DiskUsage is the capacity of the share as reported by the SMB server, in bytes
+// Servers apply the user's quota, if any, so this is the space available to the configured account.
+type DiskUsage struct {
+	TotalBytes int64 `json:"total_bytes"`
+	FreeBytes  int64 `json:"free_bytes"`
+	UsedBytes  int64 `json:"used_bytes"`
+}
+
+// parseDiskUsage returns the share's capacity from smbclient ls output
+// ok is false when the output has no disk space line.
+func parseDiskUsage(output string) (DiskUsage, bool) {
+	m := diskSpacePattern.FindStringSubmatch(output)
+	if m == nil {
+		return DiskUsage{}, false
+	}
+	var values [3]int64
+	for i := range values {
+		v, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil {
+			return DiskUsage{}, false
+		}
+		values[i] = v
+	}
+	blocks, blockSize, available := values[0], values[1], values[2]
+	return DiskUsage{
+		TotalBytes: blocks * blockSize,
+		FreeBytes:  available * blockSize,
+		UsedBytes:  (blocks - available) * blockSize,
+	}, true
+}
+
+// listDiskUsage lists dir, a path within the share, and returns its output for the disk space line
+func listDiskUsage(ctx context.Context, dir string, kind config.RetryKind, cfg *config.SMBConfig) (string, error) {
+	cmd := "ls"
+	if dir != "" && dir != "." && dir != "/" {
+		cmd = fmt.Sprintf(`ls "%s"`, dir)
+	}
+	args, env, err := buildSmbClientArgs(cfg, cmd)
+	if err != nil {
+		return "", err
+	}
+
+	return executeWithRetry(ctx, "Check disk usage", cfg.RetryFor(kind), func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+}
+
+// GetDiskUsage returns the total, free and used space of the share
+func GetDiskUsage(cfg *config.SMBConfig) (DiskUsage, error) {
+	return GetDiskUsageWithContext(context.Background(), cfg)
+}
+
+// GetDiskUsageWithContext returns the total, free and used space of the share with context
+// The SMB base path is listed, so it must exist. Servers that report no capacity give
+// ErrNoDiskUsage.
+func GetDiskUsageWithContext(ctx context.Context, cfg *config.SMBConfig) (DiskUsage, error) {
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "diskusage",
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	output, err := listDiskUsage(ctx, normalizePathSegment(buildFullPath("", cfg)), config.RetryDefault, cfg)
+
+	var usage DiskUsage
+	switch {
+	case err == nil:
+		var ok bool
+		if usage, ok = parseDiskUsage(output); !ok || usage.TotalBytes == 0 {
+			err = classify(ErrNoDiskUsage, "the SMB server did not report the capacity of share %s", cfg.ShareName)
+		}
+	case strings.Contains(output, "NT_STATUS_BAD_NETWORK_NAME"):
+		err = fmt.Errorf("share %w: %s", ErrNotFound, cfg.ShareName)
+	case strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
+		strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"):
+		err = fmt.Errorf("base path %w: %s", ErrNotFound, cfg.BasePath)
+	case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+		err = fmt.Errorf("%w to share: %s", ErrAccessDenied, cfg.ShareName)
+	default:
+		err = fmt.Errorf("failed to read disk usage: %w", err)
+	}
+
+	// Record metrics
+	recordOperation(ctx, "diskusage", startTime, cfg, err, output)
+	telemetry.EndSpanWithError(span, err)
+
+	if err != nil {
+		return DiskUsage{}, err
+	}
+	return usage, nil
+}
+
+// freeSpace returns the free space on the share, in bytes, by listing the directory holding fullPath
+// ok is false when it cannot be determined, e.g. because the directory does not exist yet.
+func freeSpace(ctx context.Context, fullPath string, cfg *config.SMBConfig) (int64, bool) {
+	output, err := listDiskUsage(ctx, path.Dir(fullPath), config.RetryUpload, cfg)
+	if err != nil {
+		return 0, false
+	}
+	usage, ok := parseDiskUsage(output)
+	return usage.FreeBytes, ok
+}
+
+// checkFreeSpace returns ErrInsufficientStorage if the local file is larger than the share's free space
+// Files smaller than SMB_FREE_SPACE_CHECK_BYTES are not checked, and neither are uploads when the
+// free space cannot be determined, so the check never blocks an upload that might succeed.
+func checkFreeSpace(ctx context.Context, localPath, fullPath, remotePath string, cfg *config.SMBConfig) error {
+	if cfg.FreeSpaceCheckBytes <= 0 {
+		return nil
+	}
+	info, err := os.Stat(localPath)
+	if err != nil || info.Size() < cfg.FreeSpaceCheckBytes {
+		return nil
+	}
+
+	free, ok := freeSpace(ctx, fullPath, cfg)
+	if !ok {
+		logger.DebugContext(ctx, "Could not determine free space on the share before uploading %s", remotePath)
+		return nil
+	}
+	if info.Size() > free {
+		return classify(ErrInsufficientStorage, "insufficient storage: %s is %d bytes but the share has %d bytes free",
+			remotePath, info.Size(), free)
+	}
+	return nil
+}
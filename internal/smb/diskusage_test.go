@@ -0,0 +1,158 @@
+package smb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDiskUsage(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name     string
+		output   string
+		expected DiskUsage
+		ok       bool
+	}{
+		{
+			name: "listing",
+			output: "  .                                   D        0  Mon Jan  1 10:00:00 2024\n\n" +
+				"\t\t65535 blocks of size 1024. 32768 blocks available\n",
+			expected: DiskUsage{TotalBytes: 67107840, FreeBytes: 33554432, UsedBytes: 33553408},
+			ok:       true,
+		},
+		{
+			name:     "large blocks",
+			output:   "1234 blocks of size 4096. 567 blocks available",
+			expected: DiskUsage{TotalBytes: 5054464, FreeBytes: 2322432, UsedBytes: 2732032},
+			ok:       true,
+		},
+		{
+			name:     "full share",
+			output:   "65535 blocks of size 1024. 0 blocks available",
+			expected: DiskUsage{TotalBytes: 67107840, FreeBytes: 0, UsedBytes: 67107840},
+			ok:       true,
+		},
+		{name: "no disk space line", output: "NT_STATUS_NO_SUCH_FILE listing \\inbox", ok: false},
+		{name: "empty output", output: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, ok := parseDiskUsage(tt.output)
+			if ok != tt.ok || usage != tt.expected {
+				t.Errorf("parseDiskUsage() = %+v, %v, want %+v, %v", usage, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestGetDiskUsage(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name        string
+		basePath    string
+		output      string
+		outputErr   error
+		expectedCmd string
+		expectedErr error
+	}{
+		{"share root", "", "\t\t65535 blocks of size 1024. 32768 blocks available\n", nil, "ls", nil},
+		{"base path", "apps/myapp", "\t\t65535 blocks of size 1024. 32768 blocks available\n", nil,
+			`ls "apps/myapp"`, nil},
+		{"no capacity reported", "", "  .    D    0  Mon Jan  1 10:00:00 2024\n", nil, "ls", ErrNoDiskUsage},
+		{"zero capacity reported", "", "0 blocks of size 0. 0 blocks available\n", nil, "ls", ErrNoDiskUsage},
+		{"missing base path", "apps/missing", "NT_STATUS_OBJECT_NAME_NOT_FOUND listing \\apps\\missing",
+			fmt.Errorf("smbclient command failed: exit status 1"), `ls "apps/missing"`, ErrNotFound},
+		{"access denied", "", "NT_STATUS_ACCESS_DENIED listing \\*",
+			fmt.Errorf("smbclient command failed: exit status 1"), "ls", ErrAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				if cmd := args[len(args)-1]; cmd != tt.expectedCmd {
+					t.Errorf("Expected command %q, got %q", tt.expectedCmd, cmd)
+				}
+				return tt.output, tt.outputErr
+			}
+			origExec := SetExecutor(mock)
+			defer SetExecutor(origExec)
+
+			cfg := newRetryReadConfig()
+			cfg.MaxRetries = 0
+			cfg.BasePath = tt.basePath
+
+			usage, err := GetDiskUsage(cfg)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if usage.TotalBytes != 67107840 || usage.FreeBytes != 33554432 || usage.UsedBytes != 33553408 {
+				t.Errorf("Unexpected usage %+v", usage)
+			}
+		})
+	}
+}
+
+func TestCheckFreeSpace(t *testing.T) {
+	localFile := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(localFile, make([]byte, 4096), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name          string
+		threshold     int64
+		output        string
+		outputErr     error
+		expectedCalls int
+		expectFull    bool
+	}{
+		{"disabled", 0, "", nil, 0, false},
+		{"below the threshold", 8192, "", nil, 0, false},
+		{"enough space", 1024, "65535 blocks of size 1024. 8 blocks available", nil, 1, false},
+		{"exactly enough space", 1024, "65535 blocks of size 1024. 4 blocks available", nil, 1, false},
+		{"near-full share", 1024, "65535 blocks of size 1024. 3 blocks available", nil, 1, true},
+		{"unknown free space", 1024, "NT_STATUS_OBJECT_NAME_NOT_FOUND listing \\inbox",
+			fmt.Errorf("smbclient command failed: exit status 1"), 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var commands []string
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				commands = append(commands, args[len(args)-1])
+				return tt.output, tt.outputErr
+			}
+			origExec := SetExecutor(mock)
+			defer SetExecutor(origExec)
+
+			cfg := newRetryReadConfig()
+			cfg.MaxRetries = 0
+			cfg.FreeSpaceCheckBytes = tt.threshold
+
+			err := checkFreeSpace(context.Background(), localFile, "inbox/report.pdf", "inbox/report.pdf", cfg)
+			if errors.Is(err, ErrInsufficientStorage) != tt.expectFull {
+				t.Errorf("Expected insufficient storage: %v, got %v", tt.expectFull, err)
+			}
+			if len(commands) != tt.expectedCalls {
+				t.Fatalf("Expected %d commands, got %v", tt.expectedCalls, commands)
+			}
+			if len(commands) == 1 && commands[0] != `ls "inbox"` {
+				t.Errorf("Expected the parent directory to be listed, got %q", commands[0])
+			}
+		})
+	}
+}
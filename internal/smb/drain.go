@@ -0,0 +1,95 @@
+package smb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// operationTracker counts SMB operations in progress so shutdown can wait for them to finish
+type operationTracker struct {
+	idle     chan struct{}
+	active   int
+	drained  int
+	draining bool
+	mu       sync.Mutex
+}
+
+var activeOperations = &operationTracker{}
+
+// BeginOperation marks an SMB operation as in progress and returns a function to call when it ends
+// Every smbclient process is tracked; callers wrap work that spans several processes, such as
+// asynchronous uploads, so shutdown does not stop between them.
+func BeginOperation() func() {
+	return activeOperations.begin()
+}
+
+// BeginDrain starts counting the SMB operations that finish from now on, for WaitForOperations
+func BeginDrain() {
+	activeOperations.mu.Lock()
+	defer activeOperations.mu.Unlock()
+	activeOperations.draining = true
+	activeOperations.drained = 0
+}
+
+// ActiveOperations returns the number of SMB operations in progress
+func ActiveOperations() int {
+	activeOperations.mu.Lock()
+	defer activeOperations.mu.Unlock()
+	return activeOperations.active
+}
+
+// WaitForOperations waits until no SMB operations are in progress or ctx is done
+// It returns how many operations finished since BeginDrain.
+func WaitForOperations(ctx context.Context) (int, error) {
+	return activeOperations.wait(ctx)
+}
+
+func (t *operationTracker) begin() func() {
+	t.mu.Lock()
+	t.active++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(t.end)
+	}
+}
+
+func (t *operationTracker) end() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.active--
+	if t.draining {
+		t.drained++
+	}
+	if t.active == 0 && t.idle != nil {
+		close(t.idle)
+		t.idle = nil
+	}
+}
+
+func (t *operationTracker) wait(ctx context.Context) (int, error) {
+	for {
+		t.mu.Lock()
+		if t.active == 0 {
+			drained := t.drained
+			t.mu.Unlock()
+			return drained, nil
+		}
+		if t.idle == nil {
+			t.idle = make(chan struct{})
+		}
+		idle := t.idle
+		t.mu.Unlock()
+
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			return t.drained, fmt.Errorf("%d SMB operations still running: %w", t.active, ctx.Err())
+		}
+	}
+}
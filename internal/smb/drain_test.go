@@ -0,0 +1,81 @@
+package smb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForOperations(t *testing.T) {
+	orig := activeOperations
+	defer func() { activeOperations = orig }()
+	activeOperations = &operationTracker{}
+
+	endFirst := BeginOperation()
+	endSecond := BeginOperation()
+	if got := ActiveOperations(); got != 2 {
+		t.Fatalf("Expected 2 active operations, got %d", got)
+	}
+
+	BeginDrain()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		endFirst()
+		endFirst() // ending twice is harmless
+		time.Sleep(20 * time.Millisecond)
+		endSecond()
+	}()
+
+	drained, err := WaitForOperations(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if drained != 2 {
+		t.Errorf("Expected 2 drained operations, got %d", drained)
+	}
+	if got := ActiveOperations(); got != 0 {
+		t.Errorf("Expected no active operations, got %d", got)
+	}
+}
+
+func TestWaitForOperations_Timeout(t *testing.T) {
+	orig := activeOperations
+	defer func() { activeOperations = orig }()
+	activeOperations = &operationTracker{}
+
+	end := BeginOperation()
+	defer end()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := WaitForOperations(ctx)
+	if err == nil || !strings.Contains(err.Error(), "1 SMB operations still running") {
+		t.Errorf("Expected a still running error, got: %v", err)
+	}
+}
+
+func TestExecuteSmbClient_TracksOperation(t *testing.T) {
+	origExec, origOps := smbClientExec, activeOperations
+	defer func() { smbClientExec, activeOperations = origExec, origOps }()
+	activeOperations = &operationTracker{}
+
+	var during int
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		during = ActiveOperations()
+		return "ok", nil
+	}
+	smbClientExec = mock
+
+	if _, err := executeSmbClient(context.Background(), []string{"-c", "ls"}, nil, metricsTestConfig()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if during != 1 {
+		t.Errorf("Expected the command to be tracked while running, got %d active operations", during)
+	}
+	if got := ActiveOperations(); got != 0 {
+		t.Errorf("Expected the operation to end with the command, got %d active operations", got)
+	}
+}
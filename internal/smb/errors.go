@@ -0,0 +1,41 @@
+package smb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors classifying SMB operation failures
+// Operation errors wrap one of these, so callers can check them with errors.Is instead of
+// matching message text.
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrAccessDenied        = errors.New("access denied")
+	ErrFileExists          = errors.New("already exists")
+	ErrConnectionRefused   = errors.New("connection refused")
+	ErrIsDirectory         = errors.New("is a directory")
+	ErrNotDirectory        = errors.New("is not a directory")
+	ErrParentNotFound      = errors.New("parent directory does not exist")
+	ErrInsufficientStorage = errors.New("insufficient storage")
+	ErrInvalidPath         = errors.New("invalid remote path")
+	ErrPathNotAllowed      = errors.New("path not allowed")
+	ErrTimeout             = errors.New("timed out")
+	ErrSecurityRefused     = errors.New("required signing or encryption refused")
+	ErrNoDiskUsage         = errors.New("disk usage not reported")
+	ErrNoSmbClient         = errors.New("smbclient binary not found")
+)
+
+// classifiedError is an error with its own message that is classified under a sentinel error
+type classifiedError struct {
+	kind error
+	msg  string
+}
+
+func (e *classifiedError) Error() string { return e.msg }
+
+func (e *classifiedError) Unwrap() error { return e.kind }
+
+// classify formats an error message that does not contain the sentinel's text, wrapping kind
+func classify(kind error, format string, args ...interface{}) error {
+	return &classifiedError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,123 @@
+package smb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+)
+
+func TestOperationErrors_WrapSentinels(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		Port:         445,
+		AuthProtocol: "ntlm",
+		MaxPathDepth: 2,
+	}
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name     string
+		output   string
+		run      func() error
+		sentinel error
+		message  string
+	}{
+		{
+			name:     "delete missing file",
+			output:   testStatusObjectNameNotFound,
+			run:      func() error { return DeleteFile("docs/a.txt", cfg) },
+			sentinel: ErrNotFound,
+			message:  "file not found: docs/a.txt",
+		},
+		{
+			name:     "delete denied",
+			output:   testStatusAccessDenied,
+			run:      func() error { return DeleteFile("docs/a.txt", cfg) },
+			sentinel: ErrAccessDenied,
+			message:  "access denied: cannot delete docs/a.txt",
+		},
+		{
+			name:     "delete directory",
+			output:   testStatusFileIsADirectory,
+			run:      func() error { return DeleteFile("docs", cfg) },
+			sentinel: ErrIsDirectory,
+			message:  "cannot delete directory: docs (use rmdir for directories)",
+		},
+		{
+			name:     "list missing path",
+			output:   testStatusObjectNameNotFound,
+			run:      func() error { _, err := ListFiles("missing", cfg); return err },
+			sentinel: ErrNotFound,
+			message:  "path not found: missing",
+		},
+		{
+			name:     "traversal",
+			run:      func() error { return ValidatePath("docs/../secret", cfg) },
+			sentinel: ErrInvalidPath,
+			message:  "invalid remote path: traversal not allowed",
+		},
+		{
+			name:     "path too deep",
+			run:      func() error { return ValidatePath("a/b/c", cfg) },
+			sentinel: ErrInvalidPath,
+			message:  "path too deep: 3 segments exceeds the maximum of 2",
+		},
+		{
+			name:     "timeout",
+			run:      func() error { return commandError(expiredContext(t), errors.New("killed"), cfg) },
+			sentinel: ErrTimeout,
+			message:  "smbclient command timed out: context deadline exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := tt.output
+			smbClientExec = &MockSmbClientExecutor{
+				ExecuteFunc: func(_ []string) (string, error) {
+					return output, fmt.Errorf("smbclient command failed: exit status 1")
+				},
+			}
+
+			err := tt.run()
+			if !errors.Is(err, tt.sentinel) {
+				t.Fatalf("Expected error wrapping %v, got %v", tt.sentinel, err)
+			}
+			if err.Error() != tt.message {
+				t.Errorf("Expected message %q, got %q", tt.message, err.Error())
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	err := classify(ErrInvalidPath, "drive letter paths are not supported: %s", `C:\docs`)
+	if !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("Expected classified error to wrap ErrInvalidPath")
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected classified error not to wrap ErrNotFound")
+	}
+	if want := `drive letter paths are not supported: C:\docs`; err.Error() != want {
+		t.Errorf("Expected message %q, got %q", want, err.Error())
+	}
+}
+
+// expiredContext returns a context whose deadline has already passed
+func expiredContext(t *testing.T) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	t.Cleanup(cancel)
+	<-ctx.Done()
+	return ctx
+}
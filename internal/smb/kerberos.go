@@ -0,0 +1,143 @@
+package smb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/logger"
+)
+
+// kerberosTicketRefresh is how long a ticket obtained from the keytab is reused before kinit runs
+// again. It is kept well below common KDC ticket lifetimes (typically 10h) so tickets never expire
+// between refreshes.
+const kerberosTicketRefresh = time.Hour
+
+// runKinit runs kinit with the given arguments and returns its combined output
+// It can be replaced in tests.
+var runKinit = func(ctx context.Context, args []string) (string, error) {
+	// #nosec G204 - kinit is a fixed binary and the arguments are the keytab, cache and principal
+	// from the service configuration
+	cmd := exec.CommandContext(ctx, "kinit", args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	return output.String(), err
+}
+
+// kerberosTicket tracks the credentials cache populated from a keytab for one principal
+type kerberosTicket struct {
+	obtained time.Time
+	mu       sync.Mutex
+}
+
+// keytabTickets holds one ticket per keytab and principal, as named targets can log in as
+// different principals
+var keytabTickets = struct {
+	tickets map[string]*kerberosTicket
+	mu      sync.Mutex
+}{tickets: make(map[string]*kerberosTicket)}
+
+// kerberosTicketKey identifies the ticket of a principal from a keytab
+func kerberosTicketKey(keytab, principal string) string {
+	sum := sha256.Sum256([]byte(keytab + "\x00" + principal))
+	return hex.EncodeToString(sum[:8])
+}
+
+// keytabTicket returns the ticket state of a principal from a keytab, creating it on first use
+func keytabTicket(keytab, principal string) *kerberosTicket {
+	keytabTickets.mu.Lock()
+	defer keytabTickets.mu.Unlock()
+
+	key := kerberosTicketKey(keytab, principal)
+	ticket, ok := keytabTickets.tickets[key]
+	if !ok {
+		ticket = &kerberosTicket{}
+		keytabTickets.tickets[key] = ticket
+	}
+	return ticket
+}
+
+// kerberosCCachePath is the credentials cache kinit writes a principal's keytab ticket to
+// It is private to this process so it never clobbers a ticket cache used by anything else, and to
+// the keytab and principal so one target's kinit never replaces the ticket another is using.
+func kerberosCCachePath(keytab, principal string) string {
+	name := fmt.Sprintf("smbrelay-krb5cc-%d-%s", os.Getpid(), kerberosTicketKey(keytab, principal))
+	return "FILE:" + filepath.Join(os.TempDir(), name)
+}
+
+// buildKerberosArgs returns the smbclient authentication arguments for Kerberos
+// With a keytab configured, a ticket is obtained with kinit first and its cache passed explicitly.
+func buildKerberosArgs(cfg *config.SMBConfig) ([]string, error) {
+	args := []string{"--use-kerberos=required"}
+	if cfg.KerberosKeytab != "" {
+		ccache, err := ensureKerberosTicket(cfg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--use-krb5-ccache="+ccache)
+	}
+	// For Kerberos, username/password are optional (uses system ticket cache)
+	if cfg.Username != "" {
+		args = append(args, "-U", cfg.Username)
+	}
+	// Kerberos uses -N flag to avoid password prompt
+	return append(args, "-N"), nil
+}
+
+// kerberosPrincipal returns the principal to request a keytab ticket for
+func kerberosPrincipal(cfg *config.SMBConfig) string {
+	if cfg.KerberosPrincipal != "" {
+		return cfg.KerberosPrincipal
+	}
+	return cfg.Username
+}
+
+// ensureKerberosTicket makes sure the credentials cache holds a current ticket from the configured
+// keytab, running kinit when there is none yet or the last one is due for refresh, and returns the
+// cache to pass to smbclient
+func ensureKerberosTicket(cfg *config.SMBConfig) (string, error) {
+	principal := kerberosPrincipal(cfg)
+	if principal == "" {
+		return "", fmt.Errorf("kerberos principal is required: set SMB_KERBEROS_PRINCIPAL or SMB_USERNAME")
+	}
+
+	// Check the keytab up front, as kinit reports an unreadable keytab only vaguely
+	keytab, err := os.Open(cfg.KerberosKeytab)
+	if err != nil {
+		return "", fmt.Errorf("kerberos keytab is not readable: %w", err)
+	}
+	keytab.Close()
+
+	ccache := kerberosCCachePath(cfg.KerberosKeytab, principal)
+	ticket := keytabTicket(cfg.KerberosKeytab, principal)
+
+	ticket.mu.Lock()
+	defer ticket.mu.Unlock()
+
+	if time.Since(ticket.obtained) < kerberosTicketRefresh {
+		return ccache, nil
+	}
+
+	ctx, cancel := commandContext(context.Background(), cfg)
+	defer cancel()
+
+	output, err := runKinit(ctx, []string{"-kt", cfg.KerberosKeytab, "-c", ccache, principal})
+	if err != nil {
+		ticket.obtained = time.Time{}
+		return "", fmt.Errorf("kinit failed for %s: %w (output: %s)", principal, err, output)
+	}
+
+	logger.Debug("Obtained Kerberos ticket for %s from keytab", principal)
+	ticket.obtained = time.Now()
+	return ccache, nil
+}
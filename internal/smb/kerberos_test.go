@@ -0,0 +1,187 @@
+package smb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+)
+
+// mockKinit replaces runKinit for the duration of a test and records each invocation
+func mockKinit(t *testing.T, err error) *[][]string {
+	t.Helper()
+
+	origKinit := runKinit
+	resetTickets := func() {
+		keytabTickets.mu.Lock()
+		defer keytabTickets.mu.Unlock()
+		keytabTickets.tickets = make(map[string]*kerberosTicket)
+	}
+	t.Cleanup(func() {
+		runKinit = origKinit
+		resetTickets()
+	})
+	resetTickets()
+
+	var calls [][]string
+	runKinit = func(_ context.Context, args []string) (string, error) {
+		calls = append(calls, args)
+		if err != nil {
+			return "kinit: Preauthentication failed while getting initial credentials", err
+		}
+		return "", nil
+	}
+	return &calls
+}
+
+// writeTestKeytab creates a placeholder keytab file
+func writeTestKeytab(t *testing.T) string {
+	t.Helper()
+	keytab := filepath.Join(t.TempDir(), "relay.keytab")
+	if err := os.WriteFile(keytab, []byte("keytab"), 0o600); err != nil {
+		t.Fatalf("Failed to write keytab: %v", err)
+	}
+	return keytab
+}
+
+func kerberosTestConfig(keytab string) *config.SMBConfig {
+	return &config.SMBConfig{
+		ServerName:        "fileserver.example.com",
+		ShareName:         "share",
+		Port:              445,
+		AuthProtocol:      "kerberos",
+		KerberosKeytab:    keytab,
+		KerberosPrincipal: "relay@EXAMPLE.COM",
+	}
+}
+
+func TestBuildSmbClientArgs_KerberosKeytab(t *testing.T) {
+	calls := mockKinit(t, nil)
+	cfg := kerberosTestConfig(writeTestKeytab(t))
+
+	args, _, err := buildSmbClientArgs(cfg, "ls")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	ccache := kerberosCCachePath(cfg.KerberosKeytab, "relay@EXAMPLE.COM")
+	if !slices.Contains(args, "--use-krb5-ccache="+ccache) {
+		t.Errorf("Expected args to pass the credentials cache %s, got: %v", ccache, args)
+	}
+	if !slices.Contains(args, "--use-kerberos=required") {
+		t.Errorf("Expected args to require Kerberos, got: %v", args)
+	}
+
+	if len(*calls) != 1 {
+		t.Fatalf("Expected kinit to run once, got %d calls", len(*calls))
+	}
+	want := []string{"-kt", cfg.KerberosKeytab, "-c", ccache, "relay@EXAMPLE.COM"}
+	if !slices.Equal((*calls)[0], want) {
+		t.Errorf("Expected kinit args %v, got %v", want, (*calls)[0])
+	}
+
+	// The ticket is reused until it is due for refresh
+	if _, _, err := buildSmbClientArgs(cfg, "ls"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("Expected the cached ticket to be reused, got %d kinit calls", len(*calls))
+	}
+
+	keytabTicket(cfg.KerberosKeytab, "relay@EXAMPLE.COM").obtained = time.Now().Add(-kerberosTicketRefresh)
+	if _, _, err := buildSmbClientArgs(cfg, "ls"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(*calls) != 2 {
+		t.Errorf("Expected an expired ticket to be refreshed, got %d kinit calls", len(*calls))
+	}
+}
+
+func TestBuildSmbClientArgs_KerberosKeytabPrincipalDefault(t *testing.T) {
+	calls := mockKinit(t, nil)
+	cfg := kerberosTestConfig(writeTestKeytab(t))
+	cfg.KerberosPrincipal = ""
+	cfg.Username = "relay"
+
+	if _, _, err := buildSmbClientArgs(cfg, "ls"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(*calls) != 1 || (*calls)[0][len((*calls)[0])-1] != "relay" {
+		t.Errorf("Expected kinit to use SMB_USERNAME as the principal, got: %v", *calls)
+	}
+
+	cfg.Username = ""
+	_, _, err := buildSmbClientArgs(cfg, "ls")
+	if err == nil || !strings.Contains(err.Error(), "kerberos principal is required") {
+		t.Errorf("Expected missing principal error, got: %v", err)
+	}
+}
+
+func TestBuildSmbClientArgs_KerberosKeytabPerPrincipal(t *testing.T) {
+	calls := mockKinit(t, nil)
+	keytab := writeTestKeytab(t)
+	hr := kerberosTestConfig(keytab)
+	hr.KerberosPrincipal = ""
+	hr.Username = "hr-relay"
+	finance := kerberosTestConfig(keytab)
+	finance.KerberosPrincipal = ""
+	finance.Username = "finance-relay"
+
+	for _, cfg := range []*config.SMBConfig{hr, finance, hr, finance} {
+		args, _, err := buildSmbClientArgs(cfg, "ls")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		ccache := kerberosCCachePath(keytab, cfg.Username)
+		if !slices.Contains(args, "--use-krb5-ccache="+ccache) {
+			t.Errorf("Expected %s to use its own credentials cache %s, got: %v", cfg.Username, ccache, args)
+		}
+	}
+
+	// Each principal gets one kinit into its own cache, and keeps its ticket while the other is used
+	if len(*calls) != 2 {
+		t.Fatalf("Expected one kinit per principal, got: %v", *calls)
+	}
+	if (*calls)[0][3] == (*calls)[1][3] {
+		t.Errorf("Expected the principals to use different credentials caches, got: %v", *calls)
+	}
+}
+
+func TestBuildSmbClientArgs_KerberosKeytabErrors(t *testing.T) {
+	t.Run("unreadable keytab", func(t *testing.T) {
+		calls := mockKinit(t, nil)
+		cfg := kerberosTestConfig(filepath.Join(t.TempDir(), "missing.keytab"))
+
+		_, _, err := buildSmbClientArgs(cfg, "ls")
+		if err == nil || !strings.Contains(err.Error(), "kerberos keytab is not readable") {
+			t.Errorf("Expected unreadable keytab error, got: %v", err)
+		}
+		if len(*calls) != 0 {
+			t.Errorf("Expected kinit not to run, got %d calls", len(*calls))
+		}
+	})
+
+	t.Run("kinit failure", func(t *testing.T) {
+		calls := mockKinit(t, fmt.Errorf("exit status 1"))
+		cfg := kerberosTestConfig(writeTestKeytab(t))
+
+		_, _, err := buildSmbClientArgs(cfg, "ls")
+		if err == nil || !strings.Contains(err.Error(), "kinit failed for relay@EXAMPLE.COM") {
+			t.Errorf("Expected kinit failure, got: %v", err)
+		}
+
+		// A failed kinit is retried on the next operation
+		if _, _, err := buildSmbClientArgs(cfg, "ls"); err == nil {
+			t.Error("Expected kinit to fail again")
+		}
+		if len(*calls) != 2 {
+			t.Errorf("Expected kinit to be retried, got %d calls", len(*calls))
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package smb
+
+import (
+	"context"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/telemetry"
+)
+
+// Telemetry hooks used by the SMB operations, replaceable in tests
+var (
+	recordSMBOperation    = telemetry.RecordSMBOperation
+	recordSMBFileSize     = telemetry.RecordSMBFileSize
+	startSMBClientProcess = telemetry.StartSMBClientProcess
+)
+
+// ntStatusPattern matches an NT status code in smbclient output, e.g. NT_STATUS_ACCESS_DENIED
+var ntStatusPattern = regexp.MustCompile(`NT_STATUS_[A-Z_]+`)
+
+// operationOutcomes maps error message fragments to the outcome recorded for a failed operation
+// The first match wins, so more specific fragments come first.
+var operationOutcomes = []struct {
+	fragment string
+	outcome  string
+}{
+	{"timed out", "timeout"},
+	{"canceled", "canceled"},
+	{"already exists", "already_exists"},
+	{"not found", "not_found"},
+	{"does not exist", "not_found"},
+	{"access denied", "access_denied"},
+	{"not writable", "access_denied"},
+	{"is a directory", "is_directory"},
+	{"cannot delete directory", "is_directory"},
+	{"insufficient storage", "disk_full"},
+	{"checksum mismatch", "verification_failed"},
+	{"invalid remote path", "invalid_path"},
+	{"failed to connect", "connection_failed"},
+}
+
+// operationOutcome classifies the result of an SMB operation for metrics
+func operationOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	for _, o := range operationOutcomes {
+		if strings.Contains(err.Error(), o.fragment) {
+			return o.outcome
+		}
+	}
+	return "error"
+}
+
+// recordOperation records the duration and outcome of an SMB operation started at start
+// output is the smbclient output behind err, if available, and supplies the NT status code.
+func recordOperation(
+	ctx context.Context, operation string, start time.Time, cfg *config.SMBConfig, err error, output string,
+) {
+	attrs := []attribute.KeyValue{
+		attribute.String("smb.share", cfg.ShareName),
+		attribute.String("outcome", operationOutcome(err)),
+	}
+	if status := ntStatusPattern.FindString(output); err != nil && status != "" {
+		attrs = append(attrs, attribute.String("smb.nt_status", status))
+	}
+	recordSMBOperation(ctx, operation, float64(time.Since(start).Milliseconds()), err, attrs...)
+}
+
+// recordLocalFileSize records the size of a local file transferred by an SMB operation
+func recordLocalFileSize(ctx context.Context, operation string, localPath string) {
+	if info, err := os.Stat(localPath); err == nil {
+		recordSMBFileSize(ctx, operation, info.Size())
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
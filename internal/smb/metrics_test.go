@@ -0,0 +1,262 @@
+package smb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/bancey/document-smbrelay-service/internal/config"
+)
+
+// recordedOperation is one call to the recordSMBOperation hook
+type recordedOperation struct {
+	attrs     map[string]string
+	err       error
+	operation string
+	duration  float64
+}
+
+// captureMetrics replaces the telemetry hooks for the duration of a test
+func captureMetrics(t *testing.T) (*[]recordedOperation, map[string]int64) {
+	t.Helper()
+
+	origOperation, origFileSize := recordSMBOperation, recordSMBFileSize
+	t.Cleanup(func() {
+		recordSMBOperation, recordSMBFileSize = origOperation, origFileSize
+	})
+
+	var operations []recordedOperation
+	sizes := make(map[string]int64)
+	recordSMBOperation = func(_ context.Context, operation string, durationMs float64, err error,
+		attrs ...attribute.KeyValue) {
+		recorded := recordedOperation{operation: operation, duration: durationMs, err: err, attrs: map[string]string{}}
+		for _, attr := range attrs {
+			recorded.attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		operations = append(operations, recorded)
+	}
+	recordSMBFileSize = func(_ context.Context, operation string, sizeBytes int64) {
+		sizes[operation] += sizeBytes
+	}
+	return &operations, sizes
+}
+
+func metricsTestConfig() *config.SMBConfig {
+	return &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "docs",
+		Username:   "user",
+		Password:   "pass",
+		Port:       445,
+	}
+}
+
+func TestOperationMetrics(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	localFile := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(localFile, []byte("twelve bytes"), 0o600); err != nil {
+		t.Fatalf("Failed to write local file: %v", err)
+	}
+
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		switch {
+		case strings.Contains(cmd, `del "missing.txt"`):
+			return "NT_STATUS_OBJECT_NAME_NOT_FOUND deleting remote file \\missing.txt",
+				fmt.Errorf("smbclient command failed: exit status 1")
+		case strings.Contains(cmd, `cd "private"`):
+			return "NT_STATUS_ACCESS_DENIED listing \\private\\*", fmt.Errorf("smbclient command failed: exit status 1")
+		case strings.Contains(cmd, "put "):
+			return "putting file report.pdf as \\report.pdf (12.0 kb/s)", nil
+		case strings.HasPrefix(cmd, "ls "):
+			return "NT_STATUS_NO_SUCH_FILE listing \\report.pdf", fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		return "  report.pdf                          A       12  Mon Jan  1 12:00:00 2024\n", nil
+	}
+	smbClientExec = mock
+
+	cfg := metricsTestConfig()
+
+	tests := []struct {
+		run         func() error
+		name        string
+		operation   string
+		wantOutcome string
+		wantStatus  string
+	}{
+		{
+			name:        "upload",
+			run:         func() error { return UploadFile(localFile, "report.pdf", cfg, false) },
+			operation:   "upload",
+			wantOutcome: "success",
+		},
+		{
+			name: "list",
+			run: func() error {
+				_, err := ListFiles("reports", cfg)
+				return err
+			},
+			operation:   "list",
+			wantOutcome: "success",
+		},
+		{
+			name: "list access denied",
+			run: func() error {
+				_, err := ListFiles("private", cfg)
+				return err
+			},
+			operation:   "list",
+			wantOutcome: "access_denied",
+			wantStatus:  "NT_STATUS_ACCESS_DENIED",
+		},
+		{
+			name:        "delete not found",
+			run:         func() error { return DeleteFile("missing.txt", cfg) },
+			operation:   "delete",
+			wantOutcome: "not_found",
+			wantStatus:  "NT_STATUS_OBJECT_NAME_NOT_FOUND",
+		},
+		{
+			name:        "delete root",
+			run:         func() error { return DeleteFile("/", cfg) },
+			operation:   "delete",
+			wantOutcome: "invalid_path",
+		},
+		{
+			name:        "download root",
+			run:         func() error { return DownloadFile("/", localFile, cfg) },
+			operation:   "download",
+			wantOutcome: "invalid_path",
+		},
+		{
+			name:        "utimes root",
+			run:         func() error { return SetModifiedTime("/", time.Now(), cfg) },
+			operation:   "utimes",
+			wantOutcome: "invalid_path",
+		},
+		{
+			name:        "setmode root",
+			run:         func() error { return SetReadOnly("/", cfg) },
+			operation:   "setmode",
+			wantOutcome: "invalid_path",
+		},
+		{
+			name: "list batch unsupported auth",
+			run: func() error {
+				badAuth := *cfg
+				badAuth.AuthProtocol = "bogus"
+				_, err := ListFilesBatch([]string{"reports"}, &badAuth)
+				return err
+			},
+			operation:   "list_batch",
+			wantOutcome: "error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operations, sizes := captureMetrics(t)
+			err := tt.run()
+
+			if len(*operations) != 1 {
+				t.Fatalf("Expected one recorded operation, got %d", len(*operations))
+			}
+			recorded := (*operations)[0]
+			if recorded.operation != tt.operation {
+				t.Errorf("Expected operation %q, got %q", tt.operation, recorded.operation)
+			}
+			if recorded.duration < 0 {
+				t.Errorf("Expected a non-negative duration, got %v", recorded.duration)
+			}
+			if recorded.err != err {
+				t.Errorf("Expected the returned error to be recorded, got %v (returned %v)", recorded.err, err)
+			}
+			if got := recorded.attrs["outcome"]; got != tt.wantOutcome {
+				t.Errorf("Expected outcome %q, got %q", tt.wantOutcome, got)
+			}
+			if got := recorded.attrs["smb.share"]; got != "docs" {
+				t.Errorf("Expected share docs, got %q", got)
+			}
+			if got := recorded.attrs["smb.nt_status"]; got != tt.wantStatus {
+				t.Errorf("Expected NT status %q, got %q", tt.wantStatus, got)
+			}
+			if tt.operation == "upload" && sizes["upload"] != 12 {
+				t.Errorf("Expected 12 uploaded bytes to be recorded, got %d", sizes["upload"])
+			}
+		})
+	}
+}
+
+func TestOperationMetrics_UploadStreamBytes(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+	smbClientExec = SetupSuccessfulMock()
+
+	operations, sizes := captureMetrics(t)
+	if err := UploadStream(strings.NewReader("streamed content"), "inbox/a.txt", metricsTestConfig(), true); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(*operations) != 1 || (*operations)[0].operation != "upload_stream" {
+		t.Fatalf("Expected one upload_stream operation, got: %+v", *operations)
+	}
+	if sizes["upload_stream"] != int64(len("streamed content")) {
+		t.Errorf("Expected %d streamed bytes to be recorded, got %d", len("streamed content"), sizes["upload_stream"])
+	}
+}
+
+func TestOperationOutcome(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "success"},
+		{fmt.Errorf("remote file already exists: a.txt"), "already_exists"},
+		{fmt.Errorf("failed to list files: smbclient command timed out after 30s"), "timeout"},
+		{fmt.Errorf("insufficient storage: share is full"), "disk_full"},
+		{fmt.Errorf("failed to connect to SMB server: connection refused"), "connection_failed"},
+		{fmt.Errorf("smbclient command failed: exit status 1"), "error"},
+	}
+
+	for _, tt := range tests {
+		if got := operationOutcome(tt.err); got != tt.want {
+			t.Errorf("operationOutcome(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteSmbClient_RecordsProcess(t *testing.T) {
+	origExec, origStart := smbClientExec, startSMBClientProcess
+	defer func() { smbClientExec, startSMBClientProcess = origExec, origStart }()
+
+	var outcomes []string
+	startSMBClientProcess = func() func(string) {
+		return func(outcome string) { outcomes = append(outcomes, outcome) }
+	}
+
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if strings.Contains(args[len(args)-1], "fail") {
+			return "", fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		return "ok", nil
+	}
+	smbClientExec = mock
+
+	cfg := metricsTestConfig()
+	_, _ = executeSmbClient(context.Background(), []string{"-c", "ls"}, nil, cfg)
+	_, _ = executeSmbClientWithStdin(context.Background(), []string{"-c", "fail"}, nil, strings.NewReader(""), cfg)
+
+	if len(outcomes) != 2 || outcomes[0] != "success" || outcomes[1] != "error" {
+		t.Errorf("Expected outcomes [success error], got %v", outcomes)
+	}
+}
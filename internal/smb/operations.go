@@ -2,16 +2,23 @@ package smb
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf16"
 
 	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/bancey/document-smbrelay-service/internal/config"
+	"github.com/bancey/document-smbrelay-service/internal/logger"
 	"github.com/bancey/document-smbrelay-service/internal/telemetry"
 )
 
@@ -34,6 +41,59 @@ func normalizePathSegment(p string) string {
 	return p
 }
 
+// invalidNameChars are the characters Windows does not allow in file and directory names
+const invalidNameChars = `:*?"<>|`
+
+// filenameReplacement replaces each invalid character under config.FilenamePolicyReplace
+const filenameReplacement = '_'
+
+// sanitizePathSegments normalizes a path with normalizePathSegment, then applies the filename
+// policy to each segment: invalid characters, control characters and trailing spaces and dots
+// (which Windows drops silently) are either replaced and trimmed, or rejected.
+func sanitizePathSegments(p string, policy string) (string, error) {
+	segments := strings.Split(normalizePathSegment(p), "/")
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		if policy == config.FilenamePolicyReject {
+			if invalid := invalidNameChar(segment); invalid != "" {
+				return "", classify(ErrInvalidPath, "name %q contains %s, which SMB shares do not allow", segment, invalid)
+			}
+			if strings.TrimRight(segment, " .") != segment {
+				return "", classify(ErrInvalidPath, "name %q ends with a space or dot, which SMB shares drop", segment)
+			}
+			continue
+		}
+
+		sanitized := strings.Map(func(r rune) rune {
+			if r < ' ' || strings.ContainsRune(invalidNameChars, r) {
+				return filenameReplacement
+			}
+			return r
+		}, segment)
+		sanitized = strings.TrimRight(sanitized, " .")
+		if sanitized == "" {
+			return "", classify(ErrInvalidPath, "name %q is empty once trailing spaces and dots are removed", segment)
+		}
+		segments[i] = sanitized
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+// invalidNameChar describes the first character of name Windows does not allow, or returns ""
+func invalidNameChar(name string) string {
+	for _, r := range name {
+		if r < ' ' {
+			return fmt.Sprintf("control character %U", r)
+		}
+		if strings.ContainsRune(invalidNameChars, r) {
+			return fmt.Sprintf("%q", r)
+		}
+	}
+	return ""
+}
+
 // joinSmbPaths joins a base path and a relative path for SMB operations
 // It handles normalization and ensures the result is a clean path
 func joinSmbPaths(basePath, relativePath string) string {
@@ -56,16 +116,162 @@ func joinSmbPaths(basePath, relativePath string) string {
 }
 
 // buildFullPath constructs the full path including base path from config
+// A final pass collapses any remaining duplicate slashes and strips a trailing slash
+// so every command sees a uniform path; the root (".") is preserved as-is.
 func buildFullPath(relativePath string, cfg *config.SMBConfig) string {
-	return joinSmbPaths(cfg.BasePath, relativePath)
+	fullPath := joinSmbPaths(cfg.BasePath, relativePath)
+	if fullPath == "." {
+		return fullPath
+	}
+
+	for strings.Contains(fullPath, "//") {
+		fullPath = strings.ReplaceAll(fullPath, "//", "/")
+	}
+
+	return strings.TrimSuffix(fullPath, "/")
+}
+
+// ResolveRemotePath returns the share-relative path a request path maps to, including any base path
+func ResolveRemotePath(remotePath string, cfg *config.SMBConfig) string {
+	return normalizePathSegment(buildFullPath(remotePath, cfg))
+}
+
+// driveLetterPattern matches a leading Windows drive letter prefix such as "C:"
+var driveLetterPattern = regexp.MustCompile(`^[A-Za-z]:`)
+
+// PrepareRequestPath applies the drive letter policy to a request path and validates the result
+// Clients sometimes send local Windows paths such as C:\folder\file.txt; depending on
+// cfg.DriveLetterPolicy the prefix is stripped (and backslashes converted) or the path is rejected.
+func PrepareRequestPath(remotePath string, cfg *config.SMBConfig) (string, error) {
+	if driveLetterPattern.MatchString(remotePath) {
+		if cfg.DriveLetterPolicy != config.DriveLetterPolicyStrip {
+			return "", classify(ErrInvalidPath, "drive letter paths are not supported: %s", remotePath)
+		}
+		remotePath = strings.ReplaceAll(remotePath[2:], "\\", "/")
+		remotePath = strings.TrimLeft(remotePath, "/")
+	}
+
+	if err := ValidatePath(remotePath, cfg); err != nil {
+		return "", err
+	}
+	if err := ValidateAllowedPrefix(remotePath, cfg); err != nil {
+		return "", err
+	}
+	return remotePath, nil
+}
+
+// PrepareUploadPath prepares a request path like PrepareRequestPath for a file about to be written
+// With cfg.FilenamePolicy set, names Windows shares would refuse are then sanitized or rejected.
+func PrepareUploadPath(remotePath string, cfg *config.SMBConfig) (string, error) {
+	remotePath, err := PrepareRequestPath(remotePath, cfg)
+	if err != nil || cfg.FilenamePolicy == "" {
+		return remotePath, err
+	}
+	return sanitizePathSegments(remotePath, cfg.FilenamePolicy)
+}
+
+// ValidatePath applies the request path rules: no traversal, segment count and segment length
+func ValidatePath(remotePath string, cfg *config.SMBConfig) error {
+	if err := validateRemotePath(remotePath); err != nil {
+		return err
+	}
+	if err := ValidatePathDepth(remotePath, cfg); err != nil {
+		return err
+	}
+	return ValidatePathNameLength(remotePath, cfg)
+}
+
+// ValidateAllowedPrefix rejects a request path that resolves outside every cfg.AllowedPrefixes entry
+// Prefixes are share paths like the resolved path, base path included, and match whole segments
+// case-insensitively as the share does: teams/a allows teams/a/report.pdf but not teams/ab.
+// No prefixes leaves every path allowed.
+func ValidateAllowedPrefix(remotePath string, cfg *config.SMBConfig) error {
+	if len(cfg.AllowedPrefixes) == 0 {
+		return nil
+	}
+
+	resolved := ResolveRemotePath(remotePath, cfg)
+	for _, prefix := range cfg.AllowedPrefixes {
+		prefix = normalizePathSegment(prefix)
+		if prefix == "" || len(resolved) < len(prefix) || !strings.EqualFold(resolved[:len(prefix)], prefix) {
+			continue
+		}
+		if len(resolved) == len(prefix) || resolved[len(prefix)] == '/' {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q is outside the allowed prefixes", ErrPathNotAllowed, remotePath)
+}
+
+// validateRemotePath rejects a request path with a ".." segment, which would escape the base path
+// The path is also checked once percent-decoded, so encoded forms such as %2e%2e%2f are rejected too.
+// Dots within a name, as in file.multiple.dots.txt, are allowed.
+func validateRemotePath(remotePath string) error {
+	candidates := []string{remotePath}
+	if decoded, err := url.PathUnescape(remotePath); err == nil && decoded != remotePath {
+		candidates = append(candidates, decoded)
+	}
+
+	for _, candidate := range candidates {
+		for _, segment := range strings.Split(normalizePathSegment(candidate), "/") {
+			// Windows ignores trailing spaces in names, so ".. " would also resolve to the parent
+			if strings.TrimRight(segment, " ") == ".." {
+				return fmt.Errorf("%w: traversal not allowed", ErrInvalidPath)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidatePathNameLength rejects a request path with a segment longer than cfg.MaxNameLength
+// Length is counted in UTF-16 code units, as NTFS does; a zero MaxNameLength disables the check
+func ValidatePathNameLength(remotePath string, cfg *config.SMBConfig) error {
+	if cfg.MaxNameLength <= 0 {
+		return nil
+	}
+
+	for _, segment := range strings.Split(normalizePathSegment(remotePath), "/") {
+		if length := len(utf16.Encode([]rune(segment))); length > cfg.MaxNameLength {
+			return classify(ErrInvalidPath,
+				"path segment too long: %q is %d characters, exceeds the maximum of %d", segment, length, cfg.MaxNameLength)
+		}
+	}
+	return nil
+}
+
+// ValidatePathDepth rejects a request path with more segments than cfg.MaxPathDepth allows
+// The base path is not counted; a zero MaxPathDepth disables the check
+func ValidatePathDepth(remotePath string, cfg *config.SMBConfig) error {
+	if cfg.MaxPathDepth <= 0 {
+		return nil
+	}
+
+	depth := 0
+	for _, segment := range strings.Split(normalizePathSegment(remotePath), "/") {
+		if segment != "" && segment != "." {
+			depth++
+		}
+	}
+
+	if depth > cfg.MaxPathDepth {
+		return classify(ErrInvalidPath,
+			"path too deep: %d segments exceeds the maximum of %d", depth, cfg.MaxPathDepth)
+	}
+	return nil
 }
 
 // FileInfo represents information about a file or directory
 type FileInfo struct {
-	Name      string `json:"name"`
-	Timestamp string `json:"timestamp,omitempty"`
-	Size      int64  `json:"size"`
-	IsDir     bool   `json:"is_dir"`
+	ModTime      *time.Time `json:"modified,omitempty"`
+	Name         string     `json:"name"`
+	Timestamp    string     `json:"timestamp,omitempty"`
+	SHA256       string     `json:"sha256,omitempty"`
+	Size         int64      `json:"size"`
+	ModifiedUnix int64      `json:"modified_unix,omitempty"`
+	IsDir        bool       `json:"is_dir"`
+	ReadOnly     bool       `json:"read_only"`
+	Hidden       bool       `json:"hidden"`
+	System       bool       `json:"system"`
 }
 
 // ListFiles lists files and folders at the given path on the SMB share
@@ -101,32 +307,33 @@ func ListFilesWithContext(ctx context.Context, remotePath string, cfg *config.SM
 
 	args, env, err := buildSmbClientArgs(cfg, cmd)
 	if err != nil {
+		recordOperation(ctx, "list", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
 		return nil, err
 	}
 
 	// Execute with retry logic
-	output, err := executeWithRetry("List files", cfg, func() (string, error) {
-		return executeSmbClient(args, env, cfg)
+	output, err := executeWithRetry(ctx, "List files", cfg.RetryFor(config.RetryList), func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
 	})
 
-	// Record metrics
-	duration := float64(time.Since(startTime).Milliseconds())
-	telemetry.RecordSMBOperation(ctx, "list", duration, err)
-
 	if err != nil {
 		// Parse error messages
-		if strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
-			strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND") {
-			err = fmt.Errorf("path not found: %s", remotePath)
-			telemetry.EndSpanWithError(span, err)
-			return nil, err
-		}
-		if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") {
-			err = fmt.Errorf("access denied to path: %s", remotePath)
-			telemetry.EndSpanWithError(span, err)
-			return nil, err
+		switch {
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
+			strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"):
+			err = fmt.Errorf("path %w: %s", ErrNotFound, remotePath)
+		case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+			err = fmt.Errorf("%w to path: %s", ErrAccessDenied, remotePath)
+		default:
+			err = fmt.Errorf("failed to list files: %w", err)
 		}
-		err = fmt.Errorf("failed to list files: %w", err)
+	}
+
+	// Record metrics
+	recordOperation(ctx, "list", startTime, cfg, err, output)
+
+	if err != nil {
 		telemetry.EndSpanWithError(span, err)
 		return nil, err
 	}
@@ -134,6 +341,9 @@ func ListFilesWithContext(ctx context.Context, remotePath string, cfg *config.SM
 	// Parse the output
 	files := parseLsOutput(output)
 
+	// Normalize modification times into the configured time zone
+	localizeModTimes(files, cfg)
+
 	// Add file count to span
 	telemetry.AddSpanAttributes(span, attribute.Int("smb.file_count", len(files)))
 	telemetry.EndSpanWithError(span, nil)
@@ -141,16 +351,200 @@ func ListFilesWithContext(ctx context.Context, remotePath string, cfg *config.SM
 	return files, nil
 }
 
+// localizeModTimes converts parsed modification times into cfg.TimestampLocation, if set
+func localizeModTimes(files []FileInfo, cfg *config.SMBConfig) {
+	if cfg.TimestampLocation == nil {
+		return
+	}
+	for i := range files {
+		if files[i].ModTime != nil {
+			converted := files[i].ModTime.In(cfg.TimestampLocation)
+			files[i].ModTime = &converted
+		}
+	}
+}
+
+// BatchListResult holds the listing, or the error, for one path of a batch listing
+type BatchListResult struct {
+	Err   error
+	Path  string
+	Files []FileInfo
+}
+
+// ListFilesBatch lists several directories on the SMB share in a single session
+func ListFilesBatch(remotePaths []string, cfg *config.SMBConfig) ([]BatchListResult, error) {
+	return ListFilesBatchWithContext(context.Background(), remotePaths, cfg)
+}
+
+// ListFilesBatchWithContext lists several directories on the SMB share in a single smbclient
+// session with context
+// smbclient keeps executing commands after one fails, so a missing or unreadable directory is
+// reported in its own result while the remaining paths are still listed. An error is returned
+// only when the session itself fails before every path has been listed.
+func ListFilesBatchWithContext(
+	ctx context.Context,
+	remotePaths []string,
+	cfg *config.SMBConfig,
+) ([]BatchListResult, error) {
+	if len(remotePaths) == 0 {
+		return nil, nil
+	}
+
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "list_batch",
+		attribute.Int("smb.path_count", len(remotePaths)),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	// One ls per path, each followed by a delimiter that marks where its output ends
+	commands := make([]string, 0, 2*len(remotePaths))
+	for _, remotePath := range remotePaths {
+		normalizedPath := normalizePathSegment(buildFullPath(remotePath, cfg))
+		if normalizedPath == "" || normalizedPath == "." {
+			commands = append(commands, "ls", batchDelimiterCommand)
+		} else {
+			commands = append(commands, fmt.Sprintf(`ls "%s/*"`, normalizedPath), batchDelimiterCommand)
+		}
+	}
+
+	args, env, err := buildSmbClientArgs(cfg, strings.Join(commands, "; "))
+	if err != nil {
+		recordOperation(ctx, "list_batch", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return nil, err
+	}
+
+	// Execute with retry logic
+	output, err := executeWithRetry(ctx, "Batch list files", cfg.RetryFor(config.RetryList), func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+
+	// A failed session setup or tree connect means no path was listed at all
+	var results []BatchListResult
+	if err == nil || (!strings.Contains(output, "session setup failed") &&
+		!strings.Contains(output, "tree connect failed")) {
+		results = parseBatchLsOutput(output, remotePaths)
+	}
+
+	// Record metrics
+	if len(results) < len(remotePaths) {
+		if err == nil {
+			err = fmt.Errorf("smbclient returned %d of %d listings", len(results), len(remotePaths))
+		}
+		err = fmt.Errorf("failed to list files: %w", err)
+		recordOperation(ctx, "list_batch", startTime, cfg, err, output)
+		telemetry.EndSpanWithError(span, err)
+		return nil, err
+	}
+	recordOperation(ctx, "list_batch", startTime, cfg, nil, "")
+
+	for i := range results {
+		localizeModTimes(results[i].Files, cfg)
+	}
+
+	telemetry.EndSpanWithError(span, nil)
+	return results, nil
+}
+
+// parseBatchLsOutput splits the output of consecutive ls commands into one result per path
+// Each ls is followed by batchDelimiterCommand, so a file name that looks like an error or a
+// summary line cannot shift the results. A listing ends with its free-space summary line; a
+// failed ls prints an NT_STATUS line instead. Paths without a delimiter are not included.
+func parseBatchLsOutput(output string, remotePaths []string) []BatchListResult {
+	segments := splitBatchOutput(output)
+	results := make([]BatchListResult, 0, len(remotePaths))
+
+	for i, segment := range segments {
+		if i == len(remotePaths) {
+			break
+		}
+		remotePath := remotePaths[i]
+
+		if listing, ok := trimListingSummary(segment); ok {
+			results = append(results, BatchListResult{Path: remotePath, Files: parseLsOutput(listing)})
+			continue
+		}
+		results = append(results, BatchListResult{
+			Path: remotePath,
+			Err:  batchListError(lastNTStatusLine(segment), remotePath),
+		})
+	}
+
+	return results
+}
+
+// trimListingSummary returns the output of an ls without its closing free-space summary line
+// ok is false when the output does not end with a summary, i.e. the listing failed.
+func trimListingSummary(output string) (listing string, ok bool) {
+	lines := strings.Split(strings.TrimRight(output, " \t\r\n"), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if match := diskSpacePattern.FindString(last); match == "" || match != last {
+		return "", false
+	}
+	return strings.Join(lines[:len(lines)-1], "\n"), true
+}
+
+// batchListError maps an smbclient NT_STATUS line to the same errors ListFiles returns
+func batchListError(line string, remotePath string) error {
+	switch {
+	case strings.Contains(line, "NT_STATUS_OBJECT_NAME_NOT_FOUND"),
+		strings.Contains(line, "NT_STATUS_OBJECT_PATH_NOT_FOUND"),
+		strings.Contains(line, "NT_STATUS_NO_SUCH_FILE"),
+		strings.Contains(line, "NT_STATUS_NOT_A_DIRECTORY"):
+		return fmt.Errorf("path %w: %s", ErrNotFound, remotePath)
+	case strings.Contains(line, "NT_STATUS_ACCESS_DENIED"):
+		return fmt.Errorf("%w to path: %s", ErrAccessDenied, remotePath)
+	default:
+		return fmt.Errorf("failed to list files: %s", strings.TrimSpace(line))
+	}
+}
+
+// lsLineRegex parses a line of smbclient ls output:
+// "  filename                        A     1024  Mon Jan  1 12:34:56 2024"
+// Captures: (1) filename, (2) attribute flags, (3) size, (4) timestamp. The attribute token may
+// combine several flags, e.g. DRHS, or be empty for an entry with no attributes set; the timestamp
+// may be missing. Matching the timestamp's shape keeps names like "Budget DR 2024 final.xlsx" whole.
+var lsLineRegex = regexp.MustCompile(
+	`^\s+(.+?)\s+([A-Za-z]*)\s+(\d+)(?:\s+((?:\S+\s+)?\S+\s+\d{1,2}\s+\d{1,2}:\d{2}:\d{2}\s+\d{4}))?\s*$`)
+
+// lsLineLooseRegex parses ls lines whose timestamp is in an unexpected format
+var lsLineLooseRegex = regexp.MustCompile(`^\s+(.+?)\s+([A-Za-z]*)\s+(\d+)\s+(\S.*?)\s*$`)
+
+// matchLsLine returns the lsLineRegex captures for a line of ls output, or nil if it is not an entry
+func matchLsLine(line string) []string {
+	if matches := lsLineRegex.FindStringSubmatch(line); matches != nil {
+		return matches
+	}
+	return lsLineLooseRegex.FindStringSubmatch(line)
+}
+
+// setAttributeFlags sets the flags of an ls attribute token on file
+// The token is a set of DOS attribute letters in any order: D directory, R read-only, H hidden,
+// S system; others, such as A archive or N normal, are ignored.
+func setAttributeFlags(file *FileInfo, attributes string) {
+	for _, flag := range strings.ToUpper(attributes) {
+		switch flag {
+		case 'D':
+			file.IsDir = true
+		case 'R':
+			file.ReadOnly = true
+		case 'H':
+			file.Hidden = true
+		case 'S':
+			file.System = true
+		}
+	}
+}
+
 // parseLsOutput parses the output from smbclient ls command
 func parseLsOutput(output string) []FileInfo {
 	lines := strings.Split(output, "\n")
 	files := make([]FileInfo, 0, len(lines))
 
-	// Regex to parse smbclient ls output format:
-	// "  filename                        A     1024  Mon Jan  1 12:34:56 2024"
-	// Captures: (1) filename, (2) attributes, (3) size, (4) timestamp
-	lineRegex := regexp.MustCompile(`^\s+(.+?)\s+([A-Za-z]+)\s+(\d+)\s+(.*)$`)
-
 	for _, line := range lines {
 		// Check for empty lines (after trimming)
 		if strings.TrimSpace(line) == "" {
@@ -164,7 +558,7 @@ func parseLsOutput(output string) []FileInfo {
 		}
 
 		// Use regex to parse the line (don't trim before regex - it needs the leading whitespace)
-		matches := lineRegex.FindStringSubmatch(line)
+		matches := matchLsLine(line)
 		if len(matches) != 5 {
 			// Line doesn't match expected format, skip it
 			continue
@@ -182,15 +576,21 @@ func parseLsOutput(output string) []FileInfo {
 
 		file := FileInfo{
 			Name:      name,
-			IsDir:     strings.Contains(attributes, "D"),
 			Timestamp: timestamp,
 		}
+		setAttributeFlags(&file, attributes)
 
 		// Parse size
 		if size, err := strconv.ParseInt(sizeStr, 10, 64); err == nil {
 			file.Size = size
 		}
 
+		// Parse modification time
+		if modTime, err := parseSmbTimestamp(timestamp); err == nil {
+			file.ModTime = &modTime
+			file.ModifiedUnix = modTime.Unix()
+		}
+
 		files = append(files, file)
 	}
 
@@ -230,37 +630,123 @@ func UploadFileWithContext(
 
 	// If overwrite is false, we need to check if file exists first
 	// Skip the check if fullPath is empty (uploading to root with original filename)
+	var uploadErr error
 	if !overwrite && fullPath != "" {
-		// Try to stat the file - if it exists, smbclient will show it
-		checkCmd := fmt.Sprintf("ls \"%s\"", fullPath)
-		args, env, err := buildSmbClientArgs(cfg, checkCmd)
-		if err != nil {
-			return err
-		}
+		uploadErr = checkUploadTarget(ctx, fullPath, remotePath, cfg)
+	}
 
-		// Execute with retry logic
-		output, err := executeWithRetry("Check file existence", cfg, func() (string, error) {
-			return executeSmbClient(args, env, cfg)
-		})
+	// Reject a large file the share has no room for rather than failing part way through the transfer
+	if uploadErr == nil {
+		uploadErr = checkFreeSpace(ctx, localPath, fullPath, remotePath, cfg)
+	}
+
+	// Upload the file
+	if uploadErr == nil {
+		uploadErr = uploadFileViaSmbClient(ctx, localPath, fullPath, cfg, overwrite)
+	}
+
+	// Record metrics
+	recordOperation(ctx, "upload", startTime, cfg, uploadErr, "")
+	if uploadErr == nil {
+		recordLocalFileSize(ctx, "upload", localPath)
+	}
+	telemetry.EndSpanWithError(span, uploadErr)
+
+	return uploadErr
+}
+
+// UploadStream uploads the content of a reader to the SMB share without staging it in a local file
+func UploadStream(content io.Reader, remotePath string, cfg *config.SMBConfig, overwrite bool) error {
+	return UploadStreamWithContext(context.Background(), content, remotePath, cfg, overwrite)
+}
+
+// UploadStreamWithContext uploads the content of a reader to the SMB share with context
+// The content is piped to smbclient's stdin as it is read, so it is never held in memory or on disk.
+func UploadStreamWithContext(
+	ctx context.Context,
+	content io.Reader,
+	remotePath string,
+	cfg *config.SMBConfig,
+	overwrite bool,
+) error {
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "upload_stream",
+		attribute.String("smb.path", remotePath),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+		attribute.Bool("smb.overwrite", overwrite),
+	)
+	defer span.End()
+
+	// Build full path including base path
+	fullPath := normalizePathSegment(buildFullPath(remotePath, cfg))
+	if fullPath == "" || fullPath == "." {
+		err := fmt.Errorf("%w: cannot upload to the root directory", ErrInvalidPath)
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
 
-		// If the file is found in the output, it exists
-		// Note: We ignore the error here as the command may fail if file doesn't exist
-		if err == nil && (strings.Contains(output, fullPath) || strings.Contains(output, "blocks of size")) {
-			return fmt.Errorf("remote file already exists: %s", remotePath)
+	// If overwrite is false, check the file does not exist before consuming the stream
+	if !overwrite {
+		if err := checkUploadTarget(ctx, fullPath, remotePath, cfg); err != nil {
+			telemetry.EndSpanWithError(span, err)
+			return err
 		}
 	}
 
-	// Upload the file
-	uploadErr := uploadFileViaSmbClient(localPath, fullPath, cfg)
+	counted := &countingReader{r: content}
+	uploadErr := uploadStreamViaSmbClient(ctx, counted, fullPath, cfg, overwrite)
 
 	// Record metrics
-	duration := float64(time.Since(startTime).Milliseconds())
-	telemetry.RecordSMBOperation(ctx, "upload", duration, uploadErr)
+	recordOperation(ctx, "upload_stream", startTime, cfg, uploadErr, "")
+	if uploadErr == nil {
+		recordSMBFileSize(ctx, "upload_stream", counted.n)
+	}
 	telemetry.EndSpanWithError(span, uploadErr)
 
 	return uploadErr
 }
 
+// checkUploadTarget returns an error if fullPath already exists on the share
+// remotePath is the request path reported in the error.
+func checkUploadTarget(ctx context.Context, fullPath string, remotePath string, cfg *config.SMBConfig) error {
+	// Try to stat the file - if it exists, smbclient will show it
+	checkCmd := fmt.Sprintf("ls \"%s\"", fullPath)
+	args, env, err := buildSmbClientArgs(cfg, checkCmd)
+	if err != nil {
+		return err
+	}
+
+	// Execute with retry logic
+	retry := cfg.RetryFor(config.RetryUpload)
+	output, err := executeWithRetry(ctx, "Check file existence", retry, func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+
+	// Note: We ignore the error here as the command may fail if file doesn't exist
+	if err != nil {
+		return nil
+	}
+	return uploadTargetError(output, fullPath, remotePath)
+}
+
+// uploadTargetError returns the conflict an upload target's successful ls output shows, if any
+func uploadTargetError(output string, fullPath string, remotePath string) error {
+	// An existing directory is reported as such rather than as a file conflict
+	if lsOutputHasDirectory(output, fullPath) {
+		return fmt.Errorf("remote path %w: %s", ErrIsDirectory, remotePath)
+	}
+
+	// If the file is found in the output, it exists
+	if strings.Contains(output, fullPath) || strings.Contains(output, "blocks of size") {
+		return fmt.Errorf("remote file %w: %s", ErrFileExists, remotePath)
+	}
+
+	return nil
+}
+
 // DeleteFile deletes a file from the SMB share using smbclient
 func DeleteFile(remotePath string, cfg *config.SMBConfig) error {
 	return DeleteFileWithContext(context.Background(), remotePath, cfg)
@@ -285,7 +771,10 @@ func DeleteFileWithContext(ctx context.Context, remotePath string, cfg *config.S
 	fullPath = normalizePathSegment(fullPath)
 
 	if fullPath == "" || fullPath == "." {
-		return fmt.Errorf("invalid remote path: cannot delete root directory")
+		err := fmt.Errorf("%w: cannot delete root directory", ErrInvalidPath)
+		recordOperation(ctx, "delete", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return err
 	}
 
 	// Build the del command
@@ -293,41 +782,703 @@ func DeleteFileWithContext(ctx context.Context, remotePath string, cfg *config.S
 
 	args, env, err := buildSmbClientArgs(cfg, cmd)
 	if err != nil {
+		recordOperation(ctx, "delete", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
 		return err
 	}
 
 	// Execute with retry logic
-	output, err := executeWithRetry("Delete file", cfg, func() (string, error) {
-		return executeSmbClient(args, env, cfg)
+	output, err := executeWithRetry(ctx, "Delete file", cfg.RetryFor(config.RetryDefault), func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
 	})
 
-	// Record metrics
-	duration := float64(time.Since(startTime).Milliseconds())
-	telemetry.RecordSMBOperation(ctx, "delete", duration, err)
-
 	if err != nil {
 		// Parse error messages
-		if strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
-			strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND") {
-			err = fmt.Errorf("file not found: %s", remotePath)
-			telemetry.EndSpanWithError(span, err)
-			return err
-		}
-		if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") {
-			err = fmt.Errorf("access denied: cannot delete %s", remotePath)
-			telemetry.EndSpanWithError(span, err)
-			return err
-		}
-		if strings.Contains(output, "NT_STATUS_FILE_IS_A_DIRECTORY") {
-			err = fmt.Errorf("cannot delete directory: %s (use rmdir for directories)", remotePath)
-			telemetry.EndSpanWithError(span, err)
-			return err
+		switch {
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
+			strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"):
+			err = fmt.Errorf("file %w: %s", ErrNotFound, remotePath)
+		case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+			err = fmt.Errorf("%w: cannot delete %s", ErrAccessDenied, remotePath)
+		case strings.Contains(output, "NT_STATUS_FILE_IS_A_DIRECTORY"):
+			err = classify(ErrIsDirectory, "cannot delete directory: %s (use rmdir for directories)", remotePath)
+		default:
+			err = fmt.Errorf("failed to delete file: %w", err)
 		}
-		err = fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	// Record metrics
+	recordOperation(ctx, "delete", startTime, cfg, err, output)
+	telemetry.EndSpanWithError(span, err)
+	return err
+}
+
+// MoveFile moves or renames a file within the SMB share using smbclient's rename
+func MoveFile(srcPath, dstPath string, cfg *config.SMBConfig) error {
+	return MoveFileWithContext(context.Background(), srcPath, dstPath, cfg)
+}
+
+// MoveFileWithContext moves or renames a file within the SMB share with context
+// The destination's parent directory is created first unless SMB_AUTO_MKDIR is disabled.
+func MoveFileWithContext(ctx context.Context, srcPath, dstPath string, cfg *config.SMBConfig) error {
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "move",
+		attribute.String("smb.path", srcPath),
+		attribute.String("smb.destination", dstPath),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	// Build and normalize both full paths including base path
+	fullSrc := normalizePathSegment(buildFullPath(srcPath, cfg))
+	fullDst := normalizePathSegment(buildFullPath(dstPath, cfg))
+
+	if fullSrc == "" || fullSrc == "." || fullDst == "" || fullDst == "." {
+		err := fmt.Errorf("%w: cannot move to or from the root directory", ErrInvalidPath)
 		telemetry.EndSpanWithError(span, err)
 		return err
 	}
 
+	// Check the source first: smbclient reports a missing source directory and a missing
+	// destination directory alike, and nothing should be created for a move that cannot happen
+	if _, err := StatFileWithContext(ctx, srcPath, cfg); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			err = fmt.Errorf("file %w: %s", ErrNotFound, srcPath)
+		}
+		recordOperation(ctx, "move", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	if !cfg.DisableAutoMkdir {
+		if err := ensureParentDirectory(ctx, fullDst, cfg); err != nil {
+			telemetry.EndSpanWithError(span, err)
+			return err
+		}
+	}
+
+	// Build the rename command
+	cmd := fmt.Sprintf(`rename "%s" "%s"`, fullSrc, fullDst)
+
+	args, env, err := buildSmbClientArgs(cfg, cmd)
+	if err != nil {
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// Execute with retry logic
+	output, err := executeWithRetry(ctx, "Move file", cfg.RetryFor(config.RetryDefault), func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+
+	if err != nil {
+		// Parse error messages
+		switch {
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_COLLISION"):
+			err = fmt.Errorf("remote file %w: %s", ErrFileExists, dstPath)
+		case strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"):
+			// The source was found above, so the missing directory is the destination's
+			err = fmt.Errorf("destination %w: %s", ErrParentNotFound, filepath.Dir(dstPath))
+		case strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND"),
+			strings.Contains(output, "NT_STATUS_NO_SUCH_FILE"):
+			err = fmt.Errorf("file %w: %s", ErrNotFound, srcPath)
+		case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+			err = fmt.Errorf("%w: cannot move %s", ErrAccessDenied, srcPath)
+		default:
+			err = fmt.Errorf("failed to move file: %w", err)
+		}
+	}
+
+	// Record metrics
+	recordOperation(ctx, "move", startTime, cfg, err, output)
+	telemetry.EndSpanWithError(span, err)
+
+	return err
+}
+
+// CreateDirectory creates a directory on the SMB share, including any missing parents
+// It succeeds if the directory already exists.
+func CreateDirectory(remotePath string, cfg *config.SMBConfig) error {
+	return CreateDirectoryWithContext(context.Background(), remotePath, cfg)
+}
+
+// CreateDirectoryWithContext creates a directory and its missing parents on the SMB share with context
+// smbclient's mkdir is not recursive, so every segment is created in turn within one session and
+// a final cd confirms the full path is a directory.
+func CreateDirectoryWithContext(ctx context.Context, remotePath string, cfg *config.SMBConfig) error {
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "mkdir",
+		attribute.String("smb.path", remotePath),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	// Build full path including base path
+	fullPath := normalizePathSegment(buildFullPath(remotePath, cfg))
+	if fullPath == "" || fullPath == "." {
+		err := fmt.Errorf("%w: cannot create the root directory", ErrInvalidPath)
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// mkdir each prefix of the path, then cd into the result; smbclient carries on after the
+	// expected failures for segments that already exist
+	segments := strings.Split(fullPath, "/")
+	commands := make([]string, 0, 2*len(segments)+2)
+	for i := range segments {
+		commands = append(commands, fmt.Sprintf(`mkdir "%s"`, strings.Join(segments[:i+1], "/")), batchDelimiterCommand)
+	}
+	commands = append(commands, fmt.Sprintf(`cd "%s"`, fullPath), batchDelimiterCommand)
+
+	args, env, err := buildSmbClientArgs(cfg, strings.Join(commands, "; "))
+	if err != nil {
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// Execute with retry logic
+	output, err := executeWithRetry(ctx, "Create directory", cfg.RetryFor(config.RetryDefault), func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+
+	// The final cd decides success: a mkdir may be refused for a directory that already exists
+	results := splitBatchOutput(output)
+	switch {
+	case len(results) < len(segments)+1:
+		if err == nil {
+			err = fmt.Errorf("smbclient returned incomplete output")
+		}
+		err = fmt.Errorf("failed to create directory: %w", err)
+	case !strings.Contains(results[len(segments)], "NT_STATUS_"):
+		err = nil
+	case strings.Contains(failedMkdirStep(results[:len(segments)+1]), "NT_STATUS_ACCESS_DENIED"):
+		err = fmt.Errorf("%w: cannot create %s", ErrAccessDenied, remotePath)
+	default:
+		err = fmt.Errorf("remote path exists and %w: %s", ErrNotDirectory, remotePath)
+	}
+
+	// Record metrics
+	recordOperation(ctx, "mkdir", startTime, cfg, err, output)
+	telemetry.EndSpanWithError(span, err)
+
+	return err
+}
+
+// failedMkdirStep returns the output of the step that broke a CreateDirectory session
+// That is the first mkdir that failed other than because the segment already exists, or the
+// closing cd when every segment was created or already there.
+func failedMkdirStep(results []string) string {
+	for _, result := range results[:len(results)-1] {
+		if strings.Contains(result, "NT_STATUS_") && !strings.Contains(result, "NT_STATUS_OBJECT_NAME_COLLISION") {
+			return result
+		}
+	}
+	return results[len(results)-1]
+}
+
+// SetReadOnly sets the DOS read-only attribute on a remote file using smbclient's setmode
+func SetReadOnly(remotePath string, cfg *config.SMBConfig) error {
+	return SetReadOnlyWithContext(context.Background(), remotePath, cfg)
+}
+
+// SetReadOnlyWithContext sets the DOS read-only attribute on a remote file with context
+// Not every SMB server honors DOS attributes, so callers should treat failures as best-effort
+func SetReadOnlyWithContext(ctx context.Context, remotePath string, cfg *config.SMBConfig) error {
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "setmode",
+		attribute.String("smb.path", remotePath),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	// Build full path including base path
+	fullPath := normalizePathSegment(buildFullPath(remotePath, cfg))
+
+	if fullPath == "" || fullPath == "." {
+		err := fmt.Errorf("%w: cannot set attributes on root directory", ErrInvalidPath)
+		recordOperation(ctx, "setmode", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// Build the setmode command
+	cmd := fmt.Sprintf(`setmode "%s" +r`, fullPath)
+
+	args, env, err := buildSmbClientArgs(cfg, cmd)
+	if err != nil {
+		recordOperation(ctx, "setmode", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// Execute with retry logic
+	retry := cfg.RetryFor(config.RetryDefault)
+	output, err := executeWithRetry(ctx, "Set read-only attribute", retry, func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+
+	// Record metrics
+	recordOperation(ctx, "setmode", startTime, cfg, err, output)
+
+	if err != nil {
+		// Parse error messages
+		if strings.Contains(output, "NT_STATUS_NOT_SUPPORTED") ||
+			strings.Contains(output, "NT_STATUS_INVALID_INFO_CLASS") ||
+			strings.Contains(output, "NT_STATUS_INVALID_DEVICE_REQUEST") {
+			err = fmt.Errorf("setting the read-only attribute is not supported by the server")
+			telemetry.EndSpanWithError(span, err)
+			return err
+		}
+		if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") {
+			err = fmt.Errorf("%w: cannot set attributes on %s", ErrAccessDenied, remotePath)
+			telemetry.EndSpanWithError(span, err)
+			return err
+		}
+		err = fmt.Errorf("failed to set read-only attribute: %w", err)
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	telemetry.EndSpanWithError(span, nil)
+	return nil
+}
+
+// utimesLayout is the time format accepted by smbclient's utimes command, read in the relay's local zone
+const utimesLayout = "2006:01:02-15:04:05"
+
+// SetModifiedTime sets the last write time of a remote file using smbclient's utimes
+func SetModifiedTime(remotePath string, modified time.Time, cfg *config.SMBConfig) error {
+	return SetModifiedTimeWithContext(context.Background(), remotePath, modified, cfg)
+}
+
+// SetModifiedTimeWithContext sets the last write time of a remote file with context
+// The creation, access and change times are left as they are. Not every SMB server or smbclient
+// version supports this, so callers should treat failures as best-effort.
+func SetModifiedTimeWithContext(
+	ctx context.Context,
+	remotePath string,
+	modified time.Time,
+	cfg *config.SMBConfig,
+) error {
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "utimes",
+		attribute.String("smb.path", remotePath),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	// Build full path including base path
+	fullPath := normalizePathSegment(buildFullPath(remotePath, cfg))
+
+	if fullPath == "" || fullPath == "." {
+		err := fmt.Errorf("%w: cannot set times on root directory", ErrInvalidPath)
+		recordOperation(ctx, "utimes", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// Build the utimes command: create, access, write and change times, -1 for no change
+	cmd := fmt.Sprintf(`utimes "%s" -1 -1 "%s" -1`, fullPath, modified.In(time.Local).Format(utimesLayout))
+
+	args, env, err := buildSmbClientArgs(cfg, cmd)
+	if err != nil {
+		recordOperation(ctx, "utimes", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// Execute with retry logic
+	retry := cfg.RetryFor(config.RetryDefault)
+	output, err := executeWithRetry(ctx, "Set modification time", retry, func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+
+	// Record metrics
+	recordOperation(ctx, "utimes", startTime, cfg, err, output)
+
+	if err != nil {
+		// Parse error messages
+		switch {
+		case strings.Contains(output, "NT_STATUS_NOT_SUPPORTED") ||
+			strings.Contains(output, "NT_STATUS_INVALID_INFO_CLASS") ||
+			strings.Contains(output, "NT_STATUS_INVALID_DEVICE_REQUEST") ||
+			strings.Contains(output, "command not found"):
+			err = fmt.Errorf("setting the modification time is not supported by the server")
+		case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+			err = fmt.Errorf("%w: cannot set times on %s", ErrAccessDenied, remotePath)
+		default:
+			err = fmt.Errorf("failed to set modification time: %w", err)
+		}
+	}
+
+	telemetry.EndSpanWithError(span, err)
+	return err
+}
+
+// smbTimestampLayout is the modification time format used in smbclient ls output
+// Day numbers may be space-padded or not; runs of spaces are collapsed before parsing.
+const smbTimestampLayout = "Mon Jan 2 15:04:05 2006"
+
+// smbTimestampNoWeekdayLayout parses timestamps whose weekday is missing or not in English
+const smbTimestampNoWeekdayLayout = "Jan 2 15:04:05 2006"
+
+// parseSmbTimestamp parses a modification time from smbclient ls output
+// smbclient prints times in the local time zone of the machine running it
+func parseSmbTimestamp(timestamp string) (time.Time, error) {
+	fields := strings.Fields(timestamp)
+	parsed, err := time.ParseInLocation(smbTimestampLayout, strings.Join(fields, " "), time.Local)
+	if err == nil {
+		return parsed, nil
+	}
+
+	// Fall back to ignoring a weekday that is missing or that time.Parse does not know
+	if len(fields) == 5 {
+		fields = fields[1:]
+	}
+	if t, fallbackErr := time.ParseInLocation(smbTimestampNoWeekdayLayout, strings.Join(fields, " "),
+		time.Local); fallbackErr == nil {
+		return t, nil
+	}
+	return parsed, err
+}
+
+// FindStaleFiles recursively lists files under remotePath last modified before cutoff
+// Returned names are relative to remotePath. Nothing is deleted; entries whose
+// timestamp cannot be parsed are skipped.
+func FindStaleFiles(remotePath string, cutoff time.Time, cfg *config.SMBConfig) ([]FileInfo, error) {
+	return FindStaleFilesWithContext(context.Background(), remotePath, cutoff, cfg)
+}
+
+// FindStaleFilesWithContext recursively lists files older than cutoff with context
+// Like a recursive listing, subdirectories deeper than cfg.MaxListDepth levels are not entered.
+func FindStaleFilesWithContext(
+	ctx context.Context, remotePath string, cutoff time.Time, cfg *config.SMBConfig,
+) ([]FileInfo, error) {
+	// Start telemetry span covering the whole walk
+	ctx, span := telemetry.StartSMBSpan(ctx, "find_stale",
+		attribute.String("smb.path", remotePath),
+		attribute.Int("smb.max_depth", cfg.MaxListDepth),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	type pendingDir struct {
+		path  string
+		depth int
+	}
+
+	stale := []FileInfo{}
+	// SMB paths are case-insensitive; never list the same directory twice
+	visited := make(map[string]bool)
+	pending := []pendingDir{{path: "", depth: 0}}
+
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		key := strings.ToLower(dir.path)
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+
+		entries, err := ListFilesWithContext(ctx, joinSmbPaths(remotePath, dir.path), cfg)
+		if err != nil {
+			telemetry.EndSpanWithError(span, err)
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			relative := joinSmbPaths(dir.path, entry.Name)
+			if entry.IsDir {
+				if dir.depth < cfg.MaxListDepth {
+					pending = append(pending, pendingDir{path: relative, depth: dir.depth + 1})
+				}
+				continue
+			}
+
+			if entry.ModTime == nil || !entry.ModTime.Before(cutoff) {
+				continue
+			}
+
+			entry.Name = relative
+			stale = append(stale, entry)
+		}
+	}
+
+	telemetry.AddSpanAttributes(span, attribute.Int("smb.file_count", len(stale)))
+	telemetry.EndSpanWithError(span, nil)
+
+	return stale, nil
+}
+
+// PartialListError reports the subdirectories a recursive listing skipped because access was denied
+// The listing returned alongside it is complete apart from those subdirectories.
+type PartialListError struct {
+	Skipped []string
+}
+
+// Error implements the error interface
+func (e *PartialListError) Error() string {
+	return fmt.Sprintf("access denied to %d subdirectories, their contents are not listed: %s",
+		len(e.Skipped), strings.Join(e.Skipped, ", "))
+}
+
+// ListFilesRecursive lists files and folders below the given path on the SMB share
+func ListFilesRecursive(remotePath string, cfg *config.SMBConfig, maxDepth int) ([]FileInfo, error) {
+	return ListFilesRecursiveWithContext(context.Background(), remotePath, cfg, maxDepth)
+}
+
+// ListFilesRecursiveWithContext lists files and folders below the given path depth-first with context
+// Each entry's name is its path relative to remotePath. Subdirectories deeper than maxDepth levels are
+// not entered. A subdirectory that cannot be read because access is denied is skipped and reported
+// through a *PartialListError returned with the rest of the listing; any other failure aborts the walk.
+func ListFilesRecursiveWithContext(
+	ctx context.Context, remotePath string, cfg *config.SMBConfig, maxDepth int,
+) ([]FileInfo, error) {
+	// Start telemetry span covering the whole walk
+	ctx, span := telemetry.StartSMBSpan(ctx, "list_recursive",
+		attribute.String("smb.path", remotePath),
+		attribute.Int("smb.max_depth", maxDepth),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	type pendingDir struct {
+		path  string
+		depth int
+	}
+
+	files := []FileInfo{}
+	var skipped []string
+	// SMB paths are case-insensitive; never list the same directory twice
+	visited := make(map[string]bool)
+	stack := []pendingDir{{path: "", depth: 0}}
+
+	for len(stack) > 0 {
+		dir := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		key := strings.ToLower(dir.path)
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+
+		entries, err := ListFilesWithContext(ctx, joinSmbPaths(remotePath, dir.path), cfg)
+		if err != nil {
+			if dir.path != "" && errors.Is(err, ErrAccessDenied) {
+				skipped = append(skipped, dir.path)
+				continue
+			}
+			telemetry.EndSpanWithError(span, err)
+			return nil, err
+		}
+
+		// Push subdirectories in reverse so they are walked in listing order
+		var subdirs []pendingDir
+		for _, entry := range entries {
+			relative := joinSmbPaths(dir.path, entry.Name)
+			entry.Name = relative
+			files = append(files, entry)
+			if entry.IsDir && dir.depth < maxDepth {
+				subdirs = append(subdirs, pendingDir{path: relative, depth: dir.depth + 1})
+			}
+		}
+		for i := len(subdirs) - 1; i >= 0; i-- {
+			stack = append(stack, subdirs[i])
+		}
+	}
+
+	telemetry.AddSpanAttributes(span, attribute.Int("smb.file_count", len(files)))
+	if len(skipped) > 0 {
+		telemetry.AddSpanAttributes(span, attribute.Int("smb.skipped_dirs", len(skipped)))
+		telemetry.EndSpanWithError(span, nil)
+		return files, &PartialListError{Skipped: skipped}
+	}
+
+	telemetry.EndSpanWithError(span, nil)
+	return files, nil
+}
+
+// DownloadFile downloads a remote file from the SMB share to a local path
+func DownloadFile(remotePath string, localPath string, cfg *config.SMBConfig) error {
+	return DownloadFileWithContext(context.Background(), remotePath, localPath, cfg)
+}
+
+// DownloadFileWithContext downloads a remote file from the SMB share to a local path with context
+func DownloadFileWithContext(ctx context.Context, remotePath string, localPath string, cfg *config.SMBConfig) error {
+	startTime := time.Now()
+
+	// Start telemetry span
+	ctx, span := telemetry.StartSMBSpan(ctx, "download",
+		attribute.String("smb.path", remotePath),
+		attribute.String("smb.server", cfg.ServerName),
+		attribute.String("smb.share", cfg.ShareName),
+	)
+	defer span.End()
+
+	// Build full path including base path
+	fullPath := normalizePathSegment(buildFullPath(remotePath, cfg))
+
+	if fullPath == "" || fullPath == "." {
+		err := fmt.Errorf("%w: cannot download root directory", ErrInvalidPath)
+		recordOperation(ctx, "download", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// Build command: lcd <localdir>; get <remotepath> <localfile>
+	cmd := fmt.Sprintf(`lcd "%s"; get "%s" "%s"`, filepath.Dir(localPath), fullPath, filepath.Base(localPath))
+
+	args, env, err := buildSmbClientArgs(cfg, cmd)
+	if err != nil {
+		recordOperation(ctx, "download", startTime, cfg, err, "")
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	// Execute with retry logic
+	output, err := executeWithRetry(ctx, "Download file", cfg.RetryFor(config.RetryDefault), func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+
+	// Record metrics
+	recordOperation(ctx, "download", startTime, cfg, err, output)
+
+	if err != nil {
+		// Parse error messages
+		if strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
+			strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND") {
+			err = fmt.Errorf("file %w: %s", ErrNotFound, remotePath)
+			telemetry.EndSpanWithError(span, err)
+			return err
+		}
+		if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") {
+			err = fmt.Errorf("%w: cannot read %s", ErrAccessDenied, remotePath)
+			telemetry.EndSpanWithError(span, err)
+			return err
+		}
+		if strings.Contains(output, "NT_STATUS_FILE_IS_A_DIRECTORY") {
+			err = classify(ErrIsDirectory, "cannot download directory: %s", remotePath)
+			telemetry.EndSpanWithError(span, err)
+			return err
+		}
+		err = fmt.Errorf("failed to download file: %w", err)
+		telemetry.EndSpanWithError(span, err)
+		return err
+	}
+
+	recordLocalFileSize(ctx, "download", localPath)
 	telemetry.EndSpanWithError(span, nil)
 	return nil
 }
+
+// StatFile returns the listing entry of a remote file or directory
+func StatFile(remotePath string, cfg *config.SMBConfig) (FileInfo, error) {
+	return StatFileWithContext(context.Background(), remotePath, cfg)
+}
+
+// StatFileWithContext returns the listing entry of a remote file or directory with context
+// smbclient has no stat command, so the parent directory is listed and searched for the entry;
+// names match case-insensitively, as on the share. The share root is always a directory.
+func StatFileWithContext(ctx context.Context, remotePath string, cfg *config.SMBConfig) (FileInfo, error) {
+	remotePath = normalizePathSegment(remotePath)
+	if remotePath == "" || remotePath == "." {
+		return FileInfo{IsDir: true}, nil
+	}
+
+	parent := path.Dir(remotePath)
+	if parent == "." {
+		parent = ""
+	}
+	entries, err := ListFilesWithContext(ctx, parent, cfg)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return FileInfo{}, fmt.Errorf("path %w: %s", ErrNotFound, remotePath)
+		}
+		return FileInfo{}, err
+	}
+
+	name := path.Base(remotePath)
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name, name) {
+			return entry, nil
+		}
+	}
+	return FileInfo{}, fmt.Errorf("path %w: %s", ErrNotFound, remotePath)
+}
+
+// checksumCompanionSuffix is the extension of companion files holding a file's SHA-256 digest
+const checksumCompanionSuffix = ".sha256"
+
+// sha256HexPattern matches a hex-encoded SHA-256 digest
+var sha256HexPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// AttachCompanionChecksums sets SHA256 on each file in a directory listing that has a
+// sibling "<name>.sha256" companion file. Only the companion files are downloaded, never
+// the files themselves. Files without a readable companion are left unchanged.
+func AttachCompanionChecksums(ctx context.Context, dirPath string, files []FileInfo, cfg *config.SMBConfig) {
+	names := make(map[string]bool, len(files))
+	for _, f := range files {
+		if !f.IsDir {
+			names[f.Name] = true
+		}
+	}
+
+	for i := range files {
+		if files[i].IsDir || !names[files[i].Name+checksumCompanionSuffix] {
+			continue
+		}
+
+		companion := joinSmbPaths(dirPath, files[i].Name+checksumCompanionSuffix)
+		checksum, err := readCompanionChecksum(ctx, companion, cfg)
+		if err != nil {
+			logger.WarnContext(ctx, "Skipping checksum for %s: %v", files[i].Name, err)
+			continue
+		}
+		files[i].SHA256 = checksum
+	}
+}
+
+// readCompanionChecksum downloads a .sha256 companion file and returns the digest it holds
+// Both a bare digest and the sha256sum "<digest>  <filename>" format are accepted
+func readCompanionChecksum(ctx context.Context, remotePath string, cfg *config.SMBConfig) (string, error) {
+	tmpFile, err := os.CreateTemp("", "smb-checksum-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpPath)
+
+	if err := DownloadFileWithContext(ctx, remotePath, tmpPath, cfg); err != nil {
+		return "", err
+	}
+
+	content, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read checksum file: %w", err)
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 || !sha256HexPattern.MatchString(fields[0]) {
+		return "", fmt.Errorf("invalid checksum file: %s", remotePath)
+	}
+
+	return strings.ToLower(fields[0]), nil
+}
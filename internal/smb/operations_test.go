@@ -1,11 +1,17 @@
 package smb
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/bancey/document-smbrelay-service/internal/config"
 )
@@ -272,6 +278,47 @@ func TestUploadFile_OverwriteFalse(t *testing.T) {
 	}
 }
 
+func TestUploadFile_OverwriteFalseDirectory(t *testing.T) {
+	// Save original executor and restore after test
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	// Use mock where the existence check finds a directory
+	smbClientExec = &MockSmbClientExecutor{
+		ExecuteFunc: func(_ []string) (string, error) {
+			return "  archive                             D        0  Mon Jan  1 00:00:00 2024\n\n" +
+				"\t\t65535 blocks of size 1024. 32768 blocks available\n", nil
+		},
+	}
+
+	// Create a temporary test file
+	tmpDir := os.TempDir()
+	tmpFile := filepath.Join(tmpDir, "test-upload.txt")
+	err := os.WriteFile(tmpFile, []byte("test content"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		Port:         445,
+		AuthProtocol: "ntlm",
+	}
+
+	err = UploadFile(tmpFile, "test/archive", cfg, false)
+	if err == nil {
+		t.Fatal("Expected error when remote path is a directory")
+	}
+	if !contains(err.Error(), "is a directory") {
+		t.Errorf("Expected 'is a directory' error, got: %v", err)
+	}
+}
+
 func TestUploadFile_LargeFile(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping large file test in short mode")
@@ -316,6 +363,119 @@ func TestUploadFile_LargeFile(t *testing.T) {
 	}
 }
 
+// patternReader produces an endless byte pattern without holding it in memory
+type patternReader struct{}
+
+func (patternReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(i % 251)
+	}
+	return len(p), nil
+}
+
+func TestUploadStream_LargeContent(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	// Any staged copy of the content would land here
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+
+	const size = 5 * 1024 * 1024
+	var received int64
+	mock := SetupSuccessfulMock()
+	mock.ExecuteWithStdinFunc = func(args []string, stdin io.Reader) (string, error) {
+		if cmd := args[len(args)-1]; cmd != `put - "uploads/inbox/big.bin"` {
+			t.Errorf("Unexpected streaming command: %s", cmd)
+		}
+		n, err := io.Copy(io.Discard, stdin)
+		received = n
+		return "putting file - as \\uploads\\inbox\\big.bin", err
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		BasePath:   "uploads",
+	}
+
+	content := io.LimitReader(patternReader{}, size)
+	if err := UploadStream(content, "inbox/big.bin", cfg, false); err != nil {
+		t.Fatalf("Expected streamed upload to succeed, got: %v", err)
+	}
+	if received != size {
+		t.Errorf("Expected %d bytes on stdin, got %d", size, received)
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected nothing written to the temp dir, found %d entries", len(entries))
+	}
+}
+
+func TestUploadStream_Errors(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+	}
+
+	tests := []struct {
+		name    string
+		output  string
+		path    string
+		wantErr string
+	}{
+		{
+			name:    "collision is not retried",
+			output:  "NT_STATUS_OBJECT_NAME_COLLISION opening remote file \\report.pdf",
+			path:    "report.pdf",
+			wantErr: "remote file already exists: report.pdf",
+		},
+		{
+			name:    "disk full",
+			output:  "NT_STATUS_DISK_FULL writing remote file \\report.pdf",
+			path:    "report.pdf",
+			wantErr: "insufficient storage: share is full, cannot write report.pdf",
+		},
+		{
+			name:    "root",
+			path:    "/",
+			wantErr: "invalid remote path: cannot upload to the root directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := SetupSuccessfulMock()
+			streamed := 0
+			mock.ExecuteWithStdinFunc = func(_ []string, _ io.Reader) (string, error) {
+				streamed++
+				return tt.output, fmt.Errorf("smbclient command failed: exit status 1")
+			}
+			smbClientExec = mock
+
+			err := UploadStream(strings.NewReader("content"), tt.path, cfg, true)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q, got: %v", tt.wantErr, err)
+			}
+			if streamed > 1 {
+				t.Errorf("Expected at most one streamed put, got %d", streamed)
+			}
+		})
+	}
+}
+
 func TestUploadFile_SpecialCharactersInPath(t *testing.T) {
 	// Save original executor and restore after test
 	origExec := smbClientExec
@@ -1021,6 +1181,62 @@ func TestParseLsOutput(t *testing.T) {
 	}
 }
 
+func TestParseLsOutput_AttributeFlags(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		line     string
+		name     string
+		size     int64
+		isDir    bool
+		readOnly bool
+		hidden   bool
+		system   bool
+	}{
+		{"  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024", "report.pdf", 1024,
+			false, false, false, false},
+		{"  archive                             D        0  Mon Jan  1 10:00:00 2024", "archive", 0,
+			true, false, false, false},
+		{"  System Volume Information        DHS        0  Tue Mar  5 08:15:42 2024", "System Volume Information", 0,
+			true, false, true, true},
+		{"  $RECYCLE.BIN                     DRHS        0  Wed Feb 14 09:00:00 2024", "$RECYCLE.BIN", 0,
+			true, true, true, true},
+		{"  Templates                          DR        0  Thu Apr 11 16:20:00 2024", "Templates", 0,
+			true, true, false, false},
+		{"  desktop.ini                       AHS      282  Fri Jan  5 11:11:11 2024", "desktop.ini", 282,
+			false, false, true, true},
+		{"  thumbs.db                          AH    18432  Sat Jun  1 07:45:00 2024", "thumbs.db", 18432,
+			false, false, true, false},
+		{"  policy.docx                        AR    20480  Sun Jul  7 13:30:00 2024", "policy.docx", 20480,
+			false, true, false, false},
+		{"  plain.txt                           N       12  Mon Aug  5 10:00:00 2024", "plain.txt", 12,
+			false, false, false, false},
+		{"  no flags.txt                                64  Mon Aug  5 10:00:00 2024", "no flags.txt", 64,
+			false, false, false, false},
+		{"  Budget DR 2024.xlsx                 A     4096  Mon Aug  5 10:00:00 2024", "Budget DR 2024.xlsx", 4096,
+			false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := parseLsOutput(tt.line)
+			if len(files) != 1 {
+				t.Fatalf("Expected 1 entry, got %d", len(files))
+			}
+			f := files[0]
+			if f.Name != tt.name || f.Size != tt.size {
+				t.Errorf("Expected %q of %d bytes, got %q of %d bytes", tt.name, tt.size, f.Name, f.Size)
+			}
+			if f.IsDir != tt.isDir || f.ReadOnly != tt.readOnly || f.Hidden != tt.hidden || f.System != tt.system {
+				t.Errorf("Expected dir=%v read_only=%v hidden=%v system=%v, got dir=%v read_only=%v hidden=%v system=%v",
+					tt.isDir, tt.readOnly, tt.hidden, tt.system, f.IsDir, f.ReadOnly, f.Hidden, f.System)
+			}
+			if f.ModTime == nil {
+				t.Error("Expected the timestamp to be parsed")
+			}
+		})
+	}
+}
+
 func TestListFiles_NormalizePath(t *testing.T) {
 	// Save original executor and restore after test
 	origExec := smbClientExec
@@ -1499,6 +1715,11 @@ func TestJoinSmbPaths(t *testing.T) {
 		{"Relative is dot", "apps/myapp", ".", "apps/myapp"},
 		{"Both are dots", ".", ".", "."},
 		{"Complex path", "/apps//myapp\\", "\\inbox//file.txt", "apps/myapp/inbox/file.txt"},
+		{"Base empty, relative with duplicate slashes", "", "//a//b", "a/b"},
+		{"Base empty, relative with trailing slashes", "", "a/b//", "a/b"},
+		{"Slash then backslash", "", "a/\\b", "a/b"},
+		{"Base only slashes", "//", "file.txt", "file.txt"},
+		{"Relative only slashes", "apps/myapp", "///", "apps/myapp"},
 	}
 
 	for _, tc := range testCases {
@@ -1525,6 +1746,14 @@ func TestBuildFullPath(t *testing.T) {
 		{"Base with trailing slash", "apps/myapp/", "file.txt", "apps/myapp/file.txt"},
 		{"Relative with leading slash", "apps/myapp", "/file.txt", "apps/myapp/file.txt"},
 		{"Backslash normalization", "apps\\myapp", "inbox\\file.txt", "apps/myapp/inbox/file.txt"},
+		{"No base, duplicate slashes", "", "//a//b", "a/b"},
+		{"No base, trailing slash", "", "inbox/", "inbox"},
+		{"Duplicate slashes in both", "//apps//myapp//", "//inbox//file.txt//", "apps/myapp/inbox/file.txt"},
+		{"Backslash and slash mix", "apps\\/myapp", "inbox/\\file.txt", "apps/myapp/inbox/file.txt"},
+		{"Root is dot", "", ".", "."},
+		{"Root with dot base", ".", ".", "."},
+		{"Root is empty", "", "", ""},
+		{"Base only", "apps/myapp/", "/", "apps/myapp"},
 	}
 
 	for _, tc := range testCases {
@@ -1639,3 +1868,1386 @@ func TestDeleteFile_WithBasePath(t *testing.T) {
 		t.Errorf("Expected successful deletion, got error: %v", err)
 	}
 }
+
+// ============================================================================
+// Move File Tests
+// ============================================================================
+
+func TestMoveFile_CrossDirectory(t *testing.T) {
+	// Save original executor and restore after test
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name         string
+		src          string
+		dst          string
+		disableMkdir bool
+		wantCommands []string
+	}{
+		{
+			name: "creates missing destination directory",
+			src:  "inbox/report.pdf",
+			dst:  "/archive/2024/report.pdf",
+			wantCommands: []string{
+				`cd "apps/myapp/inbox"; ls`,
+				`mkdir "apps/myapp/archive/2024"`,
+				`rename "apps/myapp/inbox/report.pdf" "apps/myapp/archive/2024/report.pdf"`,
+			},
+		},
+		{
+			name: "normalizes backslashes",
+			src:  `inbox\report.pdf`,
+			dst:  `archive\report.pdf`,
+			wantCommands: []string{
+				`cd "apps/myapp/inbox"; ls`,
+				`mkdir "apps/myapp/archive"`,
+				`rename "apps/myapp/inbox/report.pdf" "apps/myapp/archive/report.pdf"`,
+			},
+		},
+		{
+			name:         "no mkdir when auto mkdir is disabled",
+			src:          "inbox/report.pdf",
+			dst:          "archive/report.pdf",
+			disableMkdir: true,
+			wantCommands: []string{
+				`cd "apps/myapp/inbox"; ls`,
+				`rename "apps/myapp/inbox/report.pdf" "apps/myapp/archive/report.pdf"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var commands []string
+			smbClientExec = &MockSmbClientExecutor{
+				ExecuteFunc: func(args []string) (string, error) {
+					commands = append(commands, args[len(args)-1])
+					if strings.HasSuffix(args[len(args)-1], "ls") {
+						return lsLine("report.pdf", "A", 1024, time.Now()), nil
+					}
+					return "", nil
+				},
+			}
+
+			cfg := &config.SMBConfig{
+				ServerName:       "testserver",
+				ServerIP:         "127.0.0.1",
+				ShareName:        "data",
+				BasePath:         "apps/myapp",
+				Username:         "testuser",
+				Password:         "testpass",
+				Port:             445,
+				AuthProtocol:     "ntlm",
+				DisableAutoMkdir: tt.disableMkdir,
+			}
+
+			if err := MoveFile(tt.src, tt.dst, cfg); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if strings.Join(commands, "\n") != strings.Join(tt.wantCommands, "\n") {
+				t.Errorf("Expected commands %v, got %v", tt.wantCommands, commands)
+			}
+		})
+	}
+}
+
+func TestMoveFile_Errors(t *testing.T) {
+	// Save original executor and restore after test
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	tests := []struct {
+		name         string
+		src          string
+		dst          string
+		output       string
+		wantErr      string
+		disableMkdir bool
+	}{
+		{
+			name:    "destination exists",
+			src:     "a.txt",
+			dst:     "b.txt",
+			output:  "NT_STATUS_OBJECT_NAME_COLLISION renaming files \\a.txt -> \\b.txt",
+			wantErr: "remote file already exists: b.txt",
+		},
+		{
+			name:    "source missing",
+			src:     "missing.txt",
+			dst:     "b.txt",
+			output:  testStatusObjectNameNotFound + " renaming files \\missing.txt -> \\b.txt",
+			wantErr: "file not found: missing.txt",
+		},
+		{
+			name:         "destination directory missing",
+			src:          "a.txt",
+			dst:          "archive/b.txt",
+			output:       "NT_STATUS_OBJECT_PATH_NOT_FOUND renaming files \\a.txt -> \\archive\\b.txt",
+			wantErr:      "destination parent directory does not exist: archive",
+			disableMkdir: true,
+		},
+		{
+			name:    "source directory missing",
+			src:     "gone/a.txt",
+			dst:     "archive/b.txt",
+			wantErr: "file not found: gone/a.txt",
+		},
+		{
+			name:    "access denied",
+			src:     "a.txt",
+			dst:     "b.txt",
+			output:  testStatusAccessDenied,
+			wantErr: "access denied: cannot move a.txt",
+		},
+		{
+			name:    "root source",
+			src:     "/",
+			dst:     "b.txt",
+			wantErr: "invalid remote path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mkdirs int
+			smbClientExec = &MockSmbClientExecutor{
+				ExecuteFunc: func(args []string) (string, error) {
+					cmd := args[len(args)-1]
+					switch {
+					case strings.HasPrefix(cmd, "rename"):
+						return tt.output, fmt.Errorf("smbclient command failed")
+					case strings.HasPrefix(cmd, `cd "gone"`):
+						return testStatusObjectNameNotFound, fmt.Errorf("smbclient command failed")
+					case strings.HasSuffix(cmd, "ls"):
+						return lsLine("a.txt", "A", 10, time.Now()), nil
+					case strings.HasPrefix(cmd, "mkdir"):
+						mkdirs++
+					}
+					return "", nil
+				},
+			}
+
+			cfg := &config.SMBConfig{
+				ServerName:       "testserver",
+				ServerIP:         "127.0.0.1",
+				ShareName:        "data",
+				Username:         "testuser",
+				Password:         "testpass",
+				Port:             445,
+				AuthProtocol:     "ntlm",
+				DisableAutoMkdir: tt.disableMkdir,
+			}
+
+			err := MoveFile(tt.src, tt.dst, cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+			if strings.HasPrefix(tt.wantErr, "file not found") && mkdirs != 0 {
+				t.Errorf("Expected no directories to be created for a missing source, got %d mkdirs", mkdirs)
+			}
+		})
+	}
+}
+
+// ============================================================================
+// Create Directory Tests
+// ============================================================================
+
+// newDirectoryTreeMock simulates mkdir, cd and pwd against a share holding the given directories
+// and files; mkdir under a path in denied fails with access denied
+func newDirectoryTreeMock(dirs, files []string, denied string) *MockSmbClientExecutor {
+	existing := make(map[string]string)
+	for _, d := range dirs {
+		existing[d] = "dir"
+	}
+	for _, f := range files {
+		existing[f] = "file"
+	}
+
+	return &MockSmbClientExecutor{
+		ExecuteFunc: func(args []string) (string, error) {
+			var output strings.Builder
+			var err error
+			for _, command := range strings.Split(args[len(args)-1], "; ") {
+				if command == "pwd" {
+					output.WriteString("Current directory is \\\\testserver\\data\\\n")
+					continue
+				}
+				name := strings.Trim(command[strings.Index(command, " ")+1:], `"`)
+				parent := path.Dir(name)
+				status := ""
+				switch {
+				case strings.HasPrefix(command, "mkdir") && denied != "" && strings.HasPrefix(name, denied):
+					status = testStatusAccessDenied
+				case strings.HasPrefix(command, "mkdir") && existing[name] != "":
+					status = "NT_STATUS_OBJECT_NAME_COLLISION"
+				case strings.HasPrefix(command, "mkdir") && parent != "." && existing[parent] != "dir":
+					status = "NT_STATUS_OBJECT_PATH_NOT_FOUND"
+				case strings.HasPrefix(command, "mkdir"):
+					existing[name] = "dir"
+				case strings.HasPrefix(command, "cd") && existing[name] != "dir":
+					status = "NT_STATUS_NOT_A_DIRECTORY"
+				}
+				err = nil
+				if status != "" {
+					output.WriteString(status + " " + command + "\n")
+					err = fmt.Errorf("smbclient command failed: exit status 1")
+				}
+			}
+			return output.String(), err
+		},
+	}
+}
+
+func TestCreateDirectory(t *testing.T) {
+	// Save original executor and restore after test
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name    string
+		path    string
+		dirs    []string
+		files   []string
+		denied  string
+		wantErr string
+	}{
+		{name: "nested path where only the first segment exists", path: "a/b/c/d", dirs: []string{"a"}},
+		{name: "nothing exists", path: "/x/y/", dirs: nil},
+		{name: "already exists", path: "a/b", dirs: []string{"a", "a/b"}},
+		{name: "file in the way", path: "a/b/c", dirs: []string{"a"}, files: []string{"a/b"}, wantErr: "not a directory"},
+		{name: "access denied", path: "a/locked/c", dirs: []string{"a"}, denied: "a/locked", wantErr: "access denied"},
+		{
+			name:   "existing path without create rights",
+			path:   "a/locked",
+			dirs:   []string{"a", "a/locked"},
+			denied: "a",
+		},
+		{
+			name:    "access denied after a file in the way",
+			path:    "a/b/c/d",
+			dirs:    []string{"a"},
+			files:   []string{"a/b"},
+			denied:  "a/b/c/d",
+			wantErr: "not a directory",
+		},
+		{name: "root", path: "/", wantErr: "invalid remote path"},
+		{name: "empty", path: "", wantErr: "invalid remote path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smbClientExec = newDirectoryTreeMock(tt.dirs, tt.files, tt.denied)
+
+			cfg := &config.SMBConfig{
+				ServerName:   "testserver",
+				ServerIP:     "127.0.0.1",
+				ShareName:    "data",
+				Username:     "testuser",
+				Password:     "testpass",
+				Port:         445,
+				AuthProtocol: "ntlm",
+			}
+
+			err := CreateDirectory(tt.path, cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateDirectory_CreatesEachSegment(t *testing.T) {
+	// Save original executor and restore after test
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	mockExec := newDirectoryTreeMock([]string{"apps"}, nil, "")
+	smbClientExec = mockExec
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "data",
+		BasePath:     "apps",
+		Username:     "testuser",
+		Password:     "testpass",
+		Port:         445,
+		AuthProtocol: "ntlm",
+	}
+
+	if err := CreateDirectory("reports/2024", cfg); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := `mkdir "apps"; pwd; mkdir "apps/reports"; pwd; mkdir "apps/reports/2024"; pwd; cd "apps/reports/2024"; pwd`
+	if got := mockExec.LastArgs[len(mockExec.LastArgs)-1]; got != expected {
+		t.Errorf("Expected command %q, got %q", expected, got)
+	}
+	if mockExec.CallCount != 1 {
+		t.Errorf("Expected a single smbclient session, got %d", mockExec.CallCount)
+	}
+}
+
+// ============================================================================
+// Set Read-Only Tests
+// ============================================================================
+
+func TestSetReadOnly_IssuesSetmode(t *testing.T) {
+	// Save original executor and restore after test
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	mockExec := &MockSmbClientExecutor{
+		ExecuteFunc: func(_ []string) (string, error) {
+			return "", nil
+		},
+	}
+	smbClientExec = mockExec
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "data",
+		BasePath:     "apps/myapp",
+		Username:     "testuser",
+		Password:     "testpass",
+		Port:         445,
+		AuthProtocol: "ntlm",
+	}
+
+	err := SetReadOnly("inbox/file.txt", cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expectedCmd := `setmode "apps/myapp/inbox/file.txt" +r`
+	if mockExec.LastArgs[len(mockExec.LastArgs)-1] != expectedCmd {
+		t.Errorf("Expected command %q, got args: %v", expectedCmd, mockExec.LastArgs)
+	}
+}
+
+func TestSetModifiedTime_IssuesUtimes(t *testing.T) {
+	// Save original executor and restore after test
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	tests := []struct {
+		name        string
+		output      string
+		fail        bool
+		expectedErr string
+	}{
+		{name: "success"},
+		{name: "not supported", output: "NT_STATUS_NOT_SUPPORTED", fail: true, expectedErr: "not supported by the server"},
+		{name: "old smbclient", output: "utimes: command not found", fail: true, expectedErr: "not supported by the server"},
+		{name: "access denied", output: testStatusAccessDenied, fail: true, expectedErr: "access denied"},
+	}
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "data",
+		BasePath:     "apps/myapp",
+		Username:     "testuser",
+		Password:     "testpass",
+		Port:         445,
+		AuthProtocol: "ntlm",
+	}
+	modified := time.Date(2023, time.November, 5, 14, 30, 15, 0, time.Local)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExec := &MockSmbClientExecutor{
+				ExecuteFunc: func(_ []string) (string, error) {
+					if tt.fail {
+						return tt.output, fmt.Errorf("smbclient command failed")
+					}
+					return "", nil
+				},
+			}
+			smbClientExec = mockExec
+
+			err := SetModifiedTime("inbox/file.txt", modified, cfg)
+
+			expectedCmd := `utimes "apps/myapp/inbox/file.txt" -1 -1 "2023:11:05-14:30:15" -1`
+			if mockExec.LastArgs[len(mockExec.LastArgs)-1] != expectedCmd {
+				t.Errorf("Expected command %q, got args: %v", expectedCmd, mockExec.LastArgs)
+			}
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSetReadOnly_Errors(t *testing.T) {
+	// Save original executor and restore after test
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	tests := []struct {
+		name        string
+		output      string
+		expectedErr string
+	}{
+		{
+			name:        "not supported",
+			output:      "NT_STATUS_NOT_SUPPORTED",
+			expectedErr: "not supported by the server",
+		},
+		{
+			name:        "access denied",
+			output:      testStatusAccessDenied,
+			expectedErr: "access denied",
+		},
+		{
+			name:        "generic failure",
+			output:      "something went wrong",
+			expectedErr: "failed to set read-only attribute",
+		},
+	}
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		Port:         445,
+		AuthProtocol: "ntlm",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			smbClientExec = &MockSmbClientExecutor{
+				ExecuteFunc: func(_ []string) (string, error) {
+					return tt.output, fmt.Errorf("smbclient command failed")
+				},
+			}
+
+			err := SetReadOnly("file.txt", cfg)
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSetReadOnly_RootPath(t *testing.T) {
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		AuthProtocol: "ntlm",
+	}
+
+	err := SetReadOnly("/", cfg)
+	if err == nil || !strings.Contains(err.Error(), "invalid remote path") {
+		t.Errorf("Expected invalid remote path error, got: %v", err)
+	}
+}
+
+// lsLine formats a single smbclient ls output line for the given entry
+func lsLine(name, attrs string, size int64, modified time.Time) string {
+	return fmt.Sprintf("  %-35s %s %8d  %s\n", name, attrs, size, modified.Format(smbTimestampLayout))
+}
+
+func TestFindStaleFiles(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	now := time.Now().Truncate(time.Second)
+	old := now.Add(-45 * 24 * time.Hour)
+	recent := now.Add(-2 * time.Hour)
+
+	listings := map[string]string{
+		`cd "archive"; ls`: lsLine(".", "D", 0, old) +
+			lsLine("..", "D", 0, old) +
+			lsLine("invoice.pdf", "A", 1024, old) +
+			lsLine("nested", "D", 0, old),
+		`cd "archive/nested"; ls`: lsLine("ancient.txt", "A", 10, old) +
+			lsLine("fresh.txt", "A", 20, recent),
+		`cd "archive/current"; ls`: lsLine("today.txt", "A", 30, recent),
+	}
+	listings[`cd "archive"; ls`] += lsLine("current", "D", 0, recent) +
+		lsLine("notes.txt", "A", 512, recent)
+
+	smbClientExec = &MockSmbClientExecutor{
+		ExecuteFunc: func(args []string) (string, error) {
+			cmd := args[len(args)-1]
+			if output, ok := listings[cmd]; ok {
+				return output, nil
+			}
+			return testStatusObjectNameNotFound, fmt.Errorf("smbclient command failed: exit status 1")
+		},
+	}
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		MaxListDepth: 10,
+	}
+
+	files, err := FindStaleFiles("archive", now.Add(-30*24*time.Hour), cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	expected := []string{"invoice.pdf", "nested/ancient.txt"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected stale files %v, got %v", expected, names)
+	}
+}
+
+func TestFindStaleFiles_DepthLimit(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	old := time.Now().Add(-45 * 24 * time.Hour)
+	listings := map[string]string{
+		// The same directory listed twice under different case is walked once
+		`cd "archive"; ls`: lsLine("nested", "D", 0, old) + lsLine("NESTED", "D", 0, old),
+		`cd "archive/nested"; ls`: lsLine("ancient.txt", "A", 10, old) +
+			lsLine("deeper", "D", 0, old),
+	}
+
+	mockExec := &MockSmbClientExecutor{
+		ExecuteFunc: func(args []string) (string, error) {
+			if output, ok := listings[args[len(args)-1]]; ok {
+				return output, nil
+			}
+			return testStatusObjectNameNotFound, fmt.Errorf("smbclient command failed: exit status 1")
+		},
+	}
+	smbClientExec = mockExec
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		MaxListDepth: 1,
+	}
+
+	files, err := FindStaleFiles("archive", time.Now(), cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "nested/ancient.txt" {
+		t.Errorf("Expected only nested/ancient.txt, got: %+v", files)
+	}
+	if mockExec.CallCount != 2 {
+		t.Errorf("Expected 2 listings, got %d", mockExec.CallCount)
+	}
+}
+
+func TestFindStaleFiles_PathNotFound(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	smbClientExec = &MockSmbClientExecutor{
+		ExecuteFunc: func(_ []string) (string, error) {
+			return testStatusObjectNameNotFound, fmt.Errorf("smbclient command failed: exit status 1")
+		},
+	}
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+	}
+
+	_, err := FindStaleFiles("missing", time.Now(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+}
+
+// newListingTreeMock serves cd/ls listings from listings, keyed by directory, and fails directories
+// in denied with access denied
+func newListingTreeMock(listings map[string]string, denied map[string]bool) *MockSmbClientExecutor {
+	return &MockSmbClientExecutor{
+		ExecuteFunc: func(args []string) (string, error) {
+			cmd := args[len(args)-1]
+			dir := ""
+			if strings.HasPrefix(cmd, `cd "`) {
+				dir = strings.Split(cmd, `"`)[1]
+			}
+			if denied[dir] {
+				return testStatusAccessDenied, fmt.Errorf("smbclient command failed: exit status 1")
+			}
+			if output, ok := listings[dir]; ok {
+				return output, nil
+			}
+			return testStatusObjectNameNotFound, fmt.Errorf("smbclient command failed: exit status 1")
+		},
+	}
+}
+
+func TestListFilesRecursive(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	now := time.Now().Truncate(time.Second)
+	smbClientExec = newListingTreeMock(map[string]string{
+		"docs": lsLine(".", "D", 0, now) +
+			lsLine("..", "D", 0, now) +
+			lsLine("a", "D", 0, now) +
+			lsLine("readme.txt", "A", 10, now) +
+			lsLine("b", "D", 0, now),
+		"docs/a":             lsLine("one.txt", "A", 1, now) + lsLine("deep", "D", 0, now),
+		"docs/a/deep":        lsLine("two.txt", "A", 2, now) + lsLine("deeper", "D", 0, now),
+		"docs/a/deep/deeper": lsLine("three.txt", "A", 3, now),
+		"docs/b":             lsLine("four.txt", "A", 4, now),
+	}, nil)
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+	}
+
+	tests := []struct {
+		name     string
+		expected []string
+		maxDepth int
+	}{
+		{
+			name:     "unbounded walk is depth-first",
+			maxDepth: 10,
+			expected: []string{"a", "readme.txt", "b", "a/one.txt", "a/deep", "a/deep/two.txt",
+				"a/deep/deeper", "a/deep/deeper/three.txt", "b/four.txt"},
+		},
+		{
+			name:     "depth cap stops descending",
+			maxDepth: 1,
+			expected: []string{"a", "readme.txt", "b", "a/one.txt", "a/deep", "b/four.txt"},
+		},
+		{
+			name:     "zero depth lists only the top level",
+			maxDepth: 0,
+			expected: []string{"a", "readme.txt", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ListFilesRecursive("docs", cfg, tt.maxDepth)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			names := make([]string, 0, len(files))
+			for _, f := range files {
+				names = append(names, f.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestListFilesRecursive_AccessDeniedSubdirectory(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	now := time.Now().Truncate(time.Second)
+	smbClientExec = newListingTreeMock(map[string]string{
+		"docs":        lsLine("private", "D", 0, now) + lsLine("public", "D", 0, now),
+		"docs/public": lsLine("notes.txt", "A", 10, now),
+	}, map[string]bool{"docs/private": true})
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+	}
+
+	files, err := ListFilesRecursive("docs", cfg, 10)
+
+	var partial *PartialListError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Expected a PartialListError, got: %v", err)
+	}
+	if len(partial.Skipped) != 1 || partial.Skipped[0] != "private" {
+		t.Errorf("Expected private to be skipped, got %v", partial.Skipped)
+	}
+	if len(files) != 3 || files[2].Name != "public/notes.txt" {
+		t.Errorf("Expected the rest of the tree to be listed, got %+v", files)
+	}
+
+	// Access denied on the requested path itself is not a partial result
+	smbClientExec = newListingTreeMock(nil, map[string]bool{"docs": true})
+	if _, err := ListFilesRecursive("docs", cfg, 10); err == nil || errors.As(err, &partial) {
+		t.Errorf("Expected access denied error for the root, got: %v", err)
+	}
+}
+
+func TestParseSmbTimestamp(t *testing.T) {
+	ts, err := parseSmbTimestamp("Mon Jan  1 12:34:56 2024")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if ts.Year() != 2024 || ts.Month() != time.January || ts.Day() != 1 || ts.Hour() != 12 {
+		t.Errorf("Unexpected parsed time: %v", ts)
+	}
+
+	if _, err := parseSmbTimestamp("not a timestamp"); err == nil {
+		t.Error("Expected error for invalid timestamp")
+	}
+}
+
+func TestParseLsOutput_Timestamps(t *testing.T) {
+	origLocal := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = origLocal }()
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		desc      string
+		line      string
+		name      string
+		timestamp string
+		unix      int64
+	}{
+		{"space padded day", "  report.pdf                          A     1024  Mon Jan  1 12:34:56 2024",
+			"report.pdf", "Mon Jan  1 12:34:56 2024", 1704112496},
+		{"unpadded day", "  report.pdf                          A     1024  Mon Jan 1 12:34:56 2024",
+			"report.pdf", "Mon Jan 1 12:34:56 2024", 1704112496},
+		{"two digit day", "  budget.xlsx                         A     2048  Sat Dec 31 23:59:59 2022",
+			"budget.xlsx", "Sat Dec 31 23:59:59 2022", 1672531199},
+		{"single digit time fields", "  scan.tif                           AR      512  Sat Nov  9 07:05:03 2024",
+			"scan.tif", "Sat Nov  9 07:05:03 2024", 1731135903},
+		{"localized weekday", "  notes.txt                           A       10  Di Mär  5 08:15:42 2024",
+			"notes.txt", "Di Mär  5 08:15:42 2024", 0},
+		{"english month with localized weekday", "  notes.txt                           A       10  Di Mar  5 08:15:42 2024",
+			"notes.txt", "Di Mar  5 08:15:42 2024", 1709626542},
+		{"missing weekday", "  notes.txt                           A       10  Mar  5 08:15:42 2024",
+			"notes.txt", "Mar  5 08:15:42 2024", 1709626542},
+		{"number in name", "  Budget DR 2024 final.xlsx           A     4096  Tue Mar  5 08:15:42 2024",
+			"Budget DR 2024 final.xlsx", "Tue Mar  5 08:15:42 2024", 1709626542},
+		{"missing date", "  orphan.bin                          A       64",
+			"orphan.bin", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			files := parseLsOutput(tt.line)
+			if len(files) != 1 {
+				t.Fatalf("Expected 1 entry, got %d", len(files))
+			}
+			f := files[0]
+			if f.Name != tt.name || f.Timestamp != tt.timestamp {
+				t.Errorf("Expected %q with timestamp %q, got %q with %q", tt.name, tt.timestamp, f.Name, f.Timestamp)
+			}
+			if f.ModifiedUnix != tt.unix {
+				t.Errorf("Expected modified_unix %d, got %d", tt.unix, f.ModifiedUnix)
+			}
+			if (f.ModTime != nil) != (tt.unix != 0) {
+				t.Errorf("Expected modified set=%v, got %v", tt.unix != 0, f.ModTime)
+			}
+		})
+	}
+}
+
+// getCommandPattern extracts the local directory, remote path and local file from a get command
+var getCommandPattern = regexp.MustCompile(`^lcd "(.*)"; get "(.*)" "(.*)"$`)
+
+// newDownloadMock returns a mock that serves get commands from the given remote contents
+// and ls commands with the given listing
+func newDownloadMock(listing string, remote map[string]string) *MockSmbClientExecutor {
+	return &MockSmbClientExecutor{
+		ExecuteFunc: func(args []string) (string, error) {
+			cmd := args[len(args)-1]
+			if matches := getCommandPattern.FindStringSubmatch(cmd); matches != nil {
+				content, ok := remote[matches[2]]
+				if !ok {
+					return testStatusObjectNameNotFound, fmt.Errorf("smbclient command failed: exit status 1")
+				}
+				if err := os.WriteFile(filepath.Join(matches[1], matches[3]), []byte(content), 0600); err != nil {
+					return "", err
+				}
+				return "getting file " + matches[2], nil
+			}
+			return listing, nil
+		},
+	}
+}
+
+func TestDownloadFile_Success(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	smbClientExec = newDownloadMock("", map[string]string{"apps/inbox/report.txt": "hello"})
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		BasePath:   "apps",
+	}
+
+	localPath := filepath.Join(t.TempDir(), "report.txt")
+	if err := DownloadFile("inbox/report.txt", localPath, cfg); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	content, err := os.ReadFile(localPath)
+	if err != nil || string(content) != "hello" {
+		t.Errorf("Expected downloaded content 'hello', got %q (err: %v)", string(content), err)
+	}
+}
+
+func TestDownloadFile_NotFound(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	smbClientExec = newDownloadMock("", map[string]string{})
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+	}
+
+	err := DownloadFile("missing.txt", filepath.Join(t.TempDir(), "missing.txt"), cfg)
+	if err == nil || !strings.Contains(err.Error(), "file not found") {
+		t.Errorf("Expected file not found error, got: %v", err)
+	}
+}
+
+func TestStatFile(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		switch args[len(args)-1] {
+		case `cd "apps/inbox"; ls`:
+			return "  .                                   D        0  Mon Jan  1 10:00:00 2024\n" +
+				"  Report.TXT                          A      512  Tue Jan  2 12:30:00 2024\n" +
+				"  old                                 D        0  Wed Jan  3 08:00:00 2024\n", nil
+		default:
+			return "NT_STATUS_OBJECT_NAME_NOT_FOUND listing \\missing\\*", fmt.Errorf("exit status 1")
+		}
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		BasePath:   "apps",
+	}
+
+	file, err := StatFile("inbox/report.txt", cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if file.Name != "Report.TXT" || file.Size != 512 || file.IsDir || file.ModTime == nil {
+		t.Errorf("Expected the case-insensitively matched file entry, got %+v", file)
+	}
+
+	if file, err := StatFile("inbox/old", cfg); err != nil || !file.IsDir {
+		t.Errorf("Expected a directory entry, got %+v (err: %v)", file, err)
+	}
+	if file, err := StatFile("", cfg); err != nil || !file.IsDir {
+		t.Errorf("Expected the root to be a directory, got %+v (err: %v)", file, err)
+	}
+	if _, err := StatFile("inbox/missing.txt", cfg); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a not found error for a missing file, got: %v", err)
+	}
+	if _, err := StatFile("missing/report.txt", cfg); !errors.Is(err, ErrNotFound) ||
+		!strings.Contains(err.Error(), "missing/report.txt") {
+		t.Errorf("Expected a not found error naming the path for a missing parent, got: %v", err)
+	}
+}
+
+func TestAttachCompanionChecksums(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	digest := strings.Repeat("ab", 32)
+	smbClientExec = newDownloadMock("", map[string]string{
+		"docs/report.pdf.sha256": strings.ToUpper(digest) + "  report.pdf\n",
+		"docs/broken.txt.sha256": "not a checksum",
+	})
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+	}
+
+	files := []FileInfo{
+		{Name: "report.pdf", Size: 1024},
+		{Name: "report.pdf.sha256", Size: 78},
+		{Name: "notes.txt", Size: 10},
+		{Name: "broken.txt", Size: 5},
+		{Name: "broken.txt.sha256", Size: 14},
+		{Name: "sub", IsDir: true},
+	}
+
+	AttachCompanionChecksums(context.Background(), "docs", files, cfg)
+
+	if files[0].SHA256 != digest {
+		t.Errorf("Expected report.pdf checksum %s, got %q", digest, files[0].SHA256)
+	}
+	for _, f := range files[1:] {
+		if f.SHA256 != "" {
+			t.Errorf("Expected no checksum for %s, got %q", f.Name, f.SHA256)
+		}
+	}
+}
+
+func TestValidateRemotePath(t *testing.T) {
+	testCases := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"Parent of root", "../../etc", true},
+		{"Escapes after descending", "foo/../../bar", true},
+		{"Trailing parent", "foo/..", true},
+		{"Backslash separators", "..\\windows\\system32", true},
+		{"Encoded dots", "%2e%2e/etc", true},
+		{"Encoded dots and slash", "foo/%2e%2e%2f%2e%2e%2fbar", true},
+		{"Mixed encoding", ".%2E/secret", true},
+		{"Trailing space", "foo/.. /bar", true},
+		{"Dots within a name", "reports/file.multiple.dots.txt", false},
+		{"Leading dots in a name", "..hidden/..config", false},
+		{"Three dots", "archive/.../file.txt", false},
+		{"Current directory", "./reports/./q1.pdf", false},
+		{"Literal percent", "100%/report.pdf", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.SMBConfig{BasePath: "apps/myapp"}
+
+			_, err := PrepareRequestPath(tc.path, cfg)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("PrepareRequestPath(%q): wantErr=%v, got %v", tc.path, tc.wantErr, err)
+			}
+			if err != nil && err.Error() != "invalid remote path: traversal not allowed" {
+				t.Errorf("Expected traversal error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidatePathDepth(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		maxDepth int
+		wantErr  bool
+	}{
+		{"Root", "", 3, false},
+		{"Below limit", "a/b", 3, false},
+		{"At limit", "a/b/c", 3, false},
+		{"Beyond limit", "a/b/c/d", 3, true},
+		{"Duplicate and trailing slashes are not segments", "//a//b//c//", 3, false},
+		{"Backslashes count as separators", "a\\b\\c\\d", 3, true},
+		{"Dot segments are not counted", "./a/./b/c", 3, false},
+		{"Zero disables the check", strings.Repeat("level/", 100) + "file.txt", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The base path is not part of the request path and is never counted
+			cfg := &config.SMBConfig{BasePath: "apps/myapp/deep/base", MaxPathDepth: tc.maxDepth}
+
+			err := ValidatePathDepth(tc.path, cfg)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidatePathDepth(%q, %d): wantErr=%v, got %v", tc.path, tc.maxDepth, tc.wantErr, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "path too deep") {
+				t.Errorf("Expected path too deep error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidatePathNameLength(t *testing.T) {
+	testCases := []struct {
+		name      string
+		path      string
+		maxLength int
+		wantErr   bool
+	}{
+		{"Root", "", 255, false},
+		{"At limit", "docs/" + strings.Repeat("a", 255), 255, false},
+		{"Over limit", "docs/" + strings.Repeat("a", 256), 255, true},
+		{"Directory segment over limit", strings.Repeat("d", 256) + "/file.txt", 255, true},
+		{"Multibyte characters counted as characters", strings.Repeat("é", 255), 255, false},
+		{"Astral characters count twice", strings.Repeat("😀", 128), 255, true},
+		{"Zero disables the check", strings.Repeat("a", 1000), 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The base path is configured by the operator and is never checked
+			cfg := &config.SMBConfig{BasePath: strings.Repeat("b", 300), MaxNameLength: tc.maxLength}
+
+			err := ValidatePathNameLength(tc.path, cfg)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidatePathNameLength(%.20q, %d): wantErr=%v, got %v", tc.path, tc.maxLength, tc.wantErr, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "path segment too long") {
+				t.Errorf("Expected path segment too long error, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidatePath_NamesOffendingSegment(t *testing.T) {
+	long := strings.Repeat("x", 11)
+	cfg := &config.SMBConfig{MaxPathDepth: 5, MaxNameLength: 10}
+
+	err := ValidatePath("inbox/"+long+"/file.txt", cfg)
+	if err == nil || !strings.Contains(err.Error(), `"`+long+`"`) {
+		t.Errorf("Expected error naming segment %q, got: %v", long, err)
+	}
+	if err := ValidatePath("a/b/c/d/e/f", cfg); err == nil || !strings.Contains(err.Error(), "path too deep") {
+		t.Errorf("Expected path too deep error, got: %v", err)
+	}
+}
+
+func TestPrepareRequestPath_DriveLetters(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		policy   string
+		expected string
+		wantErr  bool
+	}{
+		{"Plain path unchanged under reject", "folder/file.txt", config.DriveLetterPolicyReject, "folder/file.txt", false},
+		{"Plain path unchanged under strip", "folder/file.txt", config.DriveLetterPolicyStrip, "folder/file.txt", false},
+		{"Backslash path rejected", `C:\folder\file.txt`, config.DriveLetterPolicyReject, "", true},
+		{"Lowercase drive rejected", "d:/folder/file.txt", config.DriveLetterPolicyReject, "", true},
+		{"Backslash path stripped", `C:\folder\file.txt`, config.DriveLetterPolicyStrip, "folder/file.txt", false},
+		{"Forward slash path stripped", "d:/folder/file.txt", config.DriveLetterPolicyStrip, "folder/file.txt", false},
+		{"Drive-relative path stripped", "E:file.txt", config.DriveLetterPolicyStrip, "file.txt", false},
+		{"Bare drive maps to root", `C:\`, config.DriveLetterPolicyStrip, "", false},
+		{"Colon later in path is not a drive", "folder/C:file.txt", config.DriveLetterPolicyReject, "folder/C:file.txt", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.SMBConfig{DriveLetterPolicy: tc.policy}
+
+			got, err := PrepareRequestPath(tc.path, cfg)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "drive letter paths are not supported") {
+					t.Errorf("Expected drive letter error, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("PrepareRequestPath(%q) = %q, want %q", tc.path, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestPrepareUploadPath_FilenamePolicy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		policy   string
+		expected string
+		wantErr  bool
+	}{
+		{"Colon passed without a policy", "inbox/report: v2.pdf", "", "inbox/report: v2.pdf", false},
+		{"Colon replaced", "inbox/report: v2.pdf", config.FilenamePolicyReplace, "inbox/report_ v2.pdf", false},
+		{"Colon rejected", "inbox/report: v2.pdf", config.FilenamePolicyReject, "", true},
+		{"Trailing dots trimmed", "inbox/report...", config.FilenamePolicyReplace, "inbox/report", false},
+		{"Trailing dots rejected", "inbox/report...", config.FilenamePolicyReject, "", true},
+		{"Trailing dot in a directory trimmed", "inbox./report.pdf", config.FilenamePolicyReplace, "inbox/report.pdf", false},
+		{"Trailing space rejected", "inbox/report.pdf ", config.FilenamePolicyReject, "", true},
+		{"Reserved characters replaced", `a*b?"c<d>e|f.txt`, config.FilenamePolicyReplace, "a_b__c_d_e_f.txt", false},
+		{"Control character rejected", "inbox/rep\x01ort.pdf", config.FilenamePolicyReject, "", true},
+		{"Name of only dots and spaces rejected", "inbox/. ./report.pdf", config.FilenamePolicyReplace, "", true},
+		{"Valid name kept", "inbox/report.v2.pdf", config.FilenamePolicyReject, "inbox/report.v2.pdf", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.SMBConfig{FilenamePolicy: tc.policy}
+
+			got, err := PrepareUploadPath(tc.path, cfg)
+			if tc.wantErr {
+				if !errors.Is(err, ErrInvalidPath) {
+					t.Errorf("Expected an invalid path error, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("PrepareUploadPath(%q) = %q, want %q", tc.path, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestPrepareRequestPath_AllowedPrefixes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		basePath string
+		prefixes []string
+		allowed  bool
+	}{
+		{"Unrestricted by default", "anything/report.pdf", "", nil, true},
+		{"Path below a prefix", "teams/a/report.pdf", "", []string{"teams/a", "teams/b"}, true},
+		{"Prefix itself", "teams/b", "", []string{"teams/a", "teams/b"}, true},
+		{"Sibling directory", "teams/c/report.pdf", "", []string{"teams/a", "teams/b"}, false},
+		{"Sibling sharing the name's start", "teams/ab/report.pdf", "", []string{"teams/a"}, false},
+		{"Parent of a prefix", "teams", "", []string{"teams/a"}, false},
+		{"Share root", "", "", []string{"teams/a"}, false},
+		{"Case-insensitive like the share", "Teams/A/report.pdf", "", []string{"teams/a"}, true},
+		{"Prefix with slashes and backslashes", "teams/a/report.pdf", "", []string{`\teams\a\`}, true},
+		{"Prefix includes the base path", "a/report.pdf", "teams", []string{"teams/a"}, true},
+		{"Base path applied before matching", "a/report.pdf", "other", []string{"teams/a"}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.SMBConfig{BasePath: tc.basePath, AllowedPrefixes: tc.prefixes}
+
+			_, err := PrepareRequestPath(tc.path, cfg)
+			if tc.allowed && err != nil {
+				t.Errorf("Expected %q to be allowed, got: %v", tc.path, err)
+			}
+			if !tc.allowed && !errors.Is(err, ErrPathNotAllowed) {
+				t.Errorf("Expected %q to be outside the allowed prefixes, got: %v", tc.path, err)
+			}
+		})
+	}
+}
+
+func TestPrepareRequestPath_StrippedPathIsValidated(t *testing.T) {
+	cfg := &config.SMBConfig{DriveLetterPolicy: config.DriveLetterPolicyStrip, MaxPathDepth: 2}
+
+	if _, err := PrepareRequestPath(`C:\a\b\c.txt`, cfg); err == nil || !strings.Contains(err.Error(), "path too deep") {
+		t.Errorf("Expected path too deep error, got: %v", err)
+	}
+}
+
+func TestListFiles_TimestampTimezone(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	smbClientExec = NewMockExecutorWithOutput(
+		"  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n")
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+
+	// smbclient timestamps are parsed in the local zone of the relay
+	parsed := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.Local)
+
+	testCases := []struct {
+		location *time.Location
+		name     string
+		expected string
+	}{
+		{name: "Unset leaves timestamps as parsed", location: nil, expected: parsed.Format(time.RFC3339)},
+		{name: "UTC", location: time.UTC, expected: parsed.UTC().Format(time.RFC3339)},
+		{name: "Asia/Tokyo", location: tokyo, expected: parsed.In(tokyo).Format(time.RFC3339)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.SMBConfig{
+				ServerName:        "testserver",
+				ShareName:         "testshare",
+				Username:          "testuser",
+				Password:          "testpass",
+				TimestampLocation: tc.location,
+			}
+
+			files, err := ListFiles("", cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(files) != 1 || files[0].ModTime == nil {
+				t.Fatalf("Expected one file with a modification time, got: %+v", files)
+			}
+
+			got := files[0].ModTime.Format(time.RFC3339)
+			if got != tc.expected {
+				t.Errorf("Expected modified time %s, got %s", tc.expected, got)
+			}
+			if !files[0].ModTime.Equal(parsed) {
+				t.Errorf("Conversion must not change the instant: %v vs %v", files[0].ModTime, parsed)
+			}
+		})
+	}
+}
+
+// batchLsOutput simulates smbclient running a sequence of ls commands in one session
+// listings maps an ls command to its entries and failures maps it to an NT_STATUS code.
+// smbclient keeps going after a failed command and exits with the status of the last one.
+func batchLsOutput(cmd string, listings, failures map[string]string) (string, error) {
+	var out strings.Builder
+	var err error
+	for _, c := range strings.Split(cmd, "; ") {
+		if c == batchDelimiterCommand {
+			out.WriteString("Current directory is \\\\testserver\\testshare\\\n")
+			continue
+		}
+		if status, ok := failures[c]; ok {
+			out.WriteString(status + " listing " + strings.Trim(strings.TrimPrefix(c, "ls "), `"`) + "\n")
+			err = fmt.Errorf("smbclient command failed: exit status 1")
+			continue
+		}
+		out.WriteString("  .                                   D        0  Mon Jan  1 12:00:00 2024\n")
+		out.WriteString(listings[c])
+		out.WriteString("\n\t\t1234 blocks of size 4096. 567 blocks available\n")
+		err = nil
+	}
+	return out.String(), err
+}
+
+func TestListFilesBatch_MixedResults(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	listings := map[string]string{
+		`ls "base/inbox/*"`: "  a.pdf                               A     1024  Mon Jan  1 12:00:00 2024\n",
+		// An entry that looks like an error must not end the listing early
+		`ls "base/archive/*"`: "  NT_STATUS_ACCESS_DENIED.txt         A       10  Mon Jan  1 12:00:00 2024\n",
+	}
+	failures := map[string]string{
+		`ls "base/missing/*"`: "NT_STATUS_OBJECT_NAME_NOT_FOUND",
+		`ls "base/private/*"`: "NT_STATUS_ACCESS_DENIED",
+	}
+
+	mockExec := &MockSmbClientExecutor{
+		ExecuteFunc: func(args []string) (string, error) {
+			return batchLsOutput(args[len(args)-1], listings, failures)
+		},
+	}
+	smbClientExec = mockExec
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ServerIP:   "192.168.1.100",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		BasePath:   "base",
+		Port:       445,
+	}
+
+	paths := []string{"inbox", "missing", "archive", "private"}
+	results, err := ListFilesBatch(paths, cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if mockExec.CallCount != 1 {
+		t.Errorf("Expected a single smbclient session, got %d calls", mockExec.CallCount)
+	}
+	if len(results) != len(paths) {
+		t.Fatalf("Expected %d results, got %d", len(paths), len(results))
+	}
+
+	if results[0].Path != "inbox" || results[0].Err != nil || len(results[0].Files) != 1 ||
+		results[0].Files[0].Name != "a.pdf" {
+		t.Errorf("Unexpected inbox result: %+v", results[0])
+	}
+	if results[1].Err == nil || results[1].Err.Error() != "path not found: missing" {
+		t.Errorf("Expected path not found for missing, got: %v", results[1].Err)
+	}
+	if results[2].Err != nil || len(results[2].Files) != 1 || results[2].Files[0].Name != "NT_STATUS_ACCESS_DENIED.txt" {
+		t.Errorf("Expected the archive entry, got: %+v", results[2])
+	}
+	if results[3].Err == nil || results[3].Err.Error() != "access denied to path: private" {
+		t.Errorf("Expected access denied for private, got: %v", results[3].Err)
+	}
+}
+
+func TestListFilesBatch_SessionFailure(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	smbClientExec = &MockSmbClientExecutor{
+		ExecuteFunc: func(_ []string) (string, error) {
+			return "session setup failed: NT_STATUS_LOGON_FAILURE", fmt.Errorf("smbclient command failed: exit status 1")
+		},
+	}
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ServerIP:   "192.168.1.100",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		Port:       445,
+	}
+
+	results, err := ListFilesBatch([]string{"inbox"}, cfg)
+	if err == nil {
+		t.Fatalf("Expected session failure error, got results: %+v", results)
+	}
+	if !strings.Contains(err.Error(), "failed to list files") {
+		t.Errorf("Expected 'failed to list files' error, got: %v", err)
+	}
+}
+
+func TestParseBatchLsOutput_Truncated(t *testing.T) {
+	output := "  a.txt                               A       10  Mon Jan  1 12:00:00 2024\n" +
+		"\t\t1234 blocks of size 4096. 567 blocks available\n" +
+		"Current directory is \\\\testserver\\testshare\\\n" +
+		"  b.txt                               A       10  Mon Jan  1 12:00:00 2024\n" +
+		"\t\t1234 blocks of size 4096. 567 blocks available\n"
+
+	results := parseBatchLsOutput(output, []string{"one", "two"})
+	if len(results) != 1 || results[0].Path != "one" || len(results[0].Files) != 1 {
+		t.Errorf("Expected only the terminated listing to be returned, got: %+v", results)
+	}
+}
@@ -1,8 +1,10 @@
 package smb
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"math"
+	"math/rand"
 	"strings"
 	"time"
 
@@ -12,13 +14,38 @@ import (
 
 // isRetryableError determines if an error is transient and should be retried
 func isRetryableError(err error, output string) bool {
-	if err == nil {
+	if err == nil || errors.Is(err, ErrSecurityRefused) {
 		return false
 	}
 
 	errorStr := strings.ToLower(err.Error())
 	outputStr := strings.ToLower(output)
 
+	// Non-retryable errors (authentication, permission, file-not-found, etc.) are checked first, so a
+	// definitive answer fails fast even when the output also matches a transient pattern, e.g. a
+	// missing directory named "timeout-reports"
+	nonRetryablePatterns := []string{
+		"nt_status_logon_failure",
+		"nt_status_access_denied",
+		"nt_status_bad_network_name",
+		"nt_status_object_name_not_found",
+		"nt_status_object_path_not_found",
+		"nt_status_object_name_collision",
+		"nt_status_file_is_a_directory",
+		"nt_status_disk_full",
+		"authentication failed",
+		"invalid credentials",
+		"access denied",
+		"not found",
+		"invalid parameter",
+	}
+
+	for _, pattern := range nonRetryablePatterns {
+		if strings.Contains(errorStr, pattern) || strings.Contains(outputStr, pattern) {
+			return false
+		}
+	}
+
 	// Network-related errors that are typically transient
 	retryablePatterns := []string{
 		"connection refused",
@@ -34,8 +61,12 @@ func isRetryableError(err error, output string) bool {
 		"nt_status_network_unreachable",
 		"nt_status_host_unreachable",
 		"nt_status_connection_reset",
+		"nt_status_connection_disconnected",
+		"nt_status_network_session_expired",
 		"nt_status_pipe_broken",
 		"nt_status_pipe_disconnected",
+		"nt_status_too_many_sessions",
+		"nt_status_sharing_violation",
 		"temporary failure",
 	}
 
@@ -45,55 +76,71 @@ func isRetryableError(err error, output string) bool {
 		}
 	}
 
-	// Non-retryable errors (authentication, permission, file-not-found, etc.)
-	nonRetryablePatterns := []string{
-		"nt_status_logon_failure",
-		"nt_status_access_denied",
-		"nt_status_bad_network_name",
-		"nt_status_object_name_not_found",
-		"nt_status_object_path_not_found",
-		"nt_status_object_name_collision",
-		"nt_status_file_is_a_directory",
-		"authentication failed",
-		"invalid credentials",
-		"access denied",
-		"not found",
-		"invalid parameter",
-	}
-
-	for _, pattern := range nonRetryablePatterns {
-		if strings.Contains(errorStr, pattern) || strings.Contains(outputStr, pattern) {
-			return false
-		}
-	}
-
 	// Default: don't retry unknown errors
 	return false
 }
 
-// calculateBackoff calculates the delay for the next retry using exponential backoff
-func calculateBackoff(attempt int, cfg *config.SMBConfig) time.Duration {
-	// Calculate exponential backoff: initialDelay * (backoff ^ attempt)
-	delay := cfg.InitialRetryDelay * math.Pow(cfg.RetryBackoff, float64(attempt))
+// retryJitter returns a random int64 in [0, n), replaceable in tests with a seeded rand.Source
+var retryJitter = rand.Int63n
+
+// backoffCap returns the exponential backoff ceiling for a retry: initialDelay * (backoff ^ attempt),
+// capped at MaxRetryDelay
+func backoffCap(attempt int, policy config.RetryPolicy) time.Duration {
+	delay := policy.InitialDelay * math.Pow(policy.Backoff, float64(attempt))
 
 	// Cap at maximum delay
-	if delay > cfg.MaxRetryDelay {
-		delay = cfg.MaxRetryDelay
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
 	}
 
 	return time.Duration(delay * float64(time.Second))
 }
 
+// calculateBackoff calculates the delay for the next retry using exponential backoff with full jitter
+// The delay is random between zero and the backoff ceiling, so clients that failed together do not
+// retry against a recovering server in lockstep.
+func calculateBackoff(attempt int, policy config.RetryPolicy) time.Duration {
+	return jitter(backoffCap(attempt, policy))
+}
+
+// sharingViolationMaxDelay caps the retry delay after a sharing violation, which clears as soon as
+// the other client closes the file rather than when a server recovers
+const sharingViolationMaxDelay = 500 * time.Millisecond
+
+// isSharingViolation reports whether a command failed because another client has the file open
+func isSharingViolation(err error, output string) bool {
+	const status = "nt_status_sharing_violation"
+	return strings.Contains(strings.ToLower(err.Error()), status) || strings.Contains(strings.ToLower(output), status)
+}
+
+// retryDelay returns the delay before retrying a failed command, shorter for sharing violations
+func retryDelay(attempt int, policy config.RetryPolicy, err error, output string) time.Duration {
+	if isSharingViolation(err, output) {
+		return jitter(min(backoffCap(attempt, policy), sharingViolationMaxDelay))
+	}
+	return calculateBackoff(attempt, policy)
+}
+
+// jitter returns a random duration between zero and limit
+func jitter(limit time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	return time.Duration(retryJitter(int64(limit) + 1))
+}
+
 // executeWithRetry executes a function with retry logic for transient errors
+// policy is the retry settings for the kind of operation, from config.SMBConfig.RetryFor.
 func executeWithRetry(
+	ctx context.Context,
 	operation string,
-	cfg *config.SMBConfig,
+	policy config.RetryPolicy,
 	fn func() (string, error),
 ) (string, error) {
 	var lastOutput string
 	var lastErr error
 
-	maxAttempts := cfg.MaxRetries + 1 // +1 for initial attempt
+	maxAttempts := policy.MaxRetries + 1 // +1 for initial attempt
 
 	for attempt := 0; attempt < maxAttempts; attempt++ {
 		// Execute the operation
@@ -102,7 +149,7 @@ func executeWithRetry(
 		// If successful, return immediately
 		if err == nil {
 			if attempt > 0 {
-				logger.Info(fmt.Sprintf("%s succeeded after %d retries", operation, attempt))
+				logger.InfoContext(ctx, "%s succeeded after %d retries", operation, attempt)
 			}
 			return output, nil
 		}
@@ -115,7 +162,7 @@ func executeWithRetry(
 		if attempt == maxAttempts-1 {
 			// No more retries available
 			if attempt > 0 {
-				logger.Error(fmt.Sprintf("%s failed after %d retries: %v", operation, attempt, err))
+				logger.ErrorContext(ctx, "%s failed after %d retries: %v", operation, attempt, err)
 			}
 			break
 		}
@@ -124,17 +171,17 @@ func executeWithRetry(
 		if !isRetryableError(err, output) {
 			// Non-retryable error, fail immediately
 			if attempt > 0 {
-				logger.Info(fmt.Sprintf("%s failed with non-retryable error after %d attempts: %v", operation, attempt+1, err))
+				logger.InfoContext(ctx, "%s failed with non-retryable error after %d attempts: %v", operation, attempt+1, err)
 			}
 			break
 		}
 
 		// Calculate backoff delay
-		delay := calculateBackoff(attempt, cfg)
+		delay := retryDelay(attempt, policy, err, output)
 
 		// Log retry attempt
-		logger.Info(fmt.Sprintf("%s failed (attempt %d/%d), retrying in %v: %v",
-			operation, attempt+1, maxAttempts, delay, err))
+		logger.InfoContext(ctx, "%s failed (attempt %d/%d), retrying in %v: %v",
+			operation, attempt+1, maxAttempts, delay, err)
 
 		// Wait before retrying
 		time.Sleep(delay)
@@ -1,9 +1,13 @@
 package smb
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -133,11 +137,70 @@ func TestIsRetryableError(t *testing.T) {
 	}
 }
 
-func TestCalculateBackoff(t *testing.T) {
-	cfg := &config.SMBConfig{
-		InitialRetryDelay: 1.0,
-		MaxRetryDelay:     30.0,
-		RetryBackoff:      2.0,
+func TestIsRetryableError_NTStatus(t *testing.T) {
+	tests := []struct {
+		status   string
+		expected bool
+	}{
+		{"NT_STATUS_NETWORK_SESSION_EXPIRED", true},
+		{"NT_STATUS_CONNECTION_DISCONNECTED", true},
+		{"NT_STATUS_CONNECTION_RESET", true},
+		{"NT_STATUS_PIPE_BROKEN", true},
+		{"NT_STATUS_TOO_MANY_SESSIONS", true},
+		{"NT_STATUS_SHARING_VIOLATION", true},
+		{"NT_STATUS_LOGON_FAILURE", false},
+		{"NT_STATUS_OBJECT_NAME_NOT_FOUND", false},
+		{"NT_STATUS_OBJECT_PATH_NOT_FOUND", false},
+		{"NT_STATUS_BAD_NETWORK_NAME", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status+" in error", func(t *testing.T) {
+			err := fmt.Errorf("smbclient command failed: %s", tt.status)
+			if result := isRetryableError(err, ""); result != tt.expected {
+				t.Errorf("isRetryableError() = %v, want %v", result, tt.expected)
+			}
+		})
+		t.Run(tt.status+" in output", func(t *testing.T) {
+			output := "putting file report.pdf as \\inbox\\report.pdf " + tt.status
+			if result := isRetryableError(errors.New("exit status 1"), output); result != tt.expected {
+				t.Errorf("isRetryableError() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetryDelay_SharingViolation(t *testing.T) {
+	origJitter := retryJitter
+	defer func() { retryJitter = origJitter }()
+	retryJitter = func(n int64) int64 { return n - 1 }
+
+	policy := config.RetryPolicy{
+		InitialDelay: 1.0,
+		MaxDelay:     30.0,
+		Backoff:      2.0,
+	}
+	err := errors.New("exit status 1")
+
+	output := "NT_STATUS_SHARING_VIOLATION opening remote file"
+	if delay := retryDelay(2, policy, err, output); delay != sharingViolationMaxDelay {
+		t.Errorf("Expected sharing violations to wait at most %v, got %v", sharingViolationMaxDelay, delay)
+	}
+	if delay := retryDelay(2, policy, err, "NT_STATUS_IO_TIMEOUT"); delay != 4*time.Second {
+		t.Errorf("Expected other errors to use the full backoff of 4s, got %v", delay)
+	}
+
+	policy.InitialDelay = 0.1
+	if delay := retryDelay(0, policy, err, "NT_STATUS_SHARING_VIOLATION"); delay != 100*time.Millisecond {
+		t.Errorf("Expected a backoff shorter than the sharing violation cap to be kept, got %v", delay)
+	}
+}
+
+func TestBackoffCap(t *testing.T) {
+	policy := config.RetryPolicy{
+		InitialDelay: 1.0,
+		MaxDelay:     30.0,
+		Backoff:      2.0,
 	}
 
 	tests := []struct {
@@ -168,20 +231,52 @@ func TestCalculateBackoff(t *testing.T) {
 		{
 			name:     "Fifth retry (capped)",
 			attempt:  5,
-			expected: 30 * time.Second, // Should be capped at MaxRetryDelay
+			expected: 30 * time.Second, // Should be capped at MaxDelay
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := calculateBackoff(tt.attempt, cfg)
+			result := backoffCap(tt.attempt, policy)
 			if result != tt.expected {
-				t.Errorf("calculateBackoff(%d) = %v, want %v", tt.attempt, result, tt.expected)
+				t.Errorf("backoffCap(%d) = %v, want %v", tt.attempt, result, tt.expected)
 			}
 		})
 	}
 }
 
+func TestCalculateBackoff_Jitter(t *testing.T) {
+	origJitter := retryJitter
+	defer func() { retryJitter = origJitter }()
+	retryJitter = rand.New(rand.NewSource(1)).Int63n
+
+	policy := config.RetryPolicy{
+		InitialDelay: 1.0,
+		MaxDelay:     30.0,
+		Backoff:      2.0,
+	}
+
+	for _, attempt := range []int{0, 2, 5} {
+		limit := backoffCap(attempt, policy)
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 50; i++ {
+			delay := calculateBackoff(attempt, policy)
+			if delay < 0 || delay > limit {
+				t.Fatalf("calculateBackoff(%d) = %v, want within [0, %v]", attempt, delay, limit)
+			}
+			seen[delay] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("Expected varied delays for attempt %d, got %v", attempt, seen)
+		}
+	}
+
+	policy.InitialDelay = 0
+	if delay := calculateBackoff(3, policy); delay != 0 {
+		t.Errorf("Expected no delay with a zero initial delay, got %v", delay)
+	}
+}
+
 func TestExecuteWithRetry_Success(t *testing.T) {
 	cfg := createTestRetryConfig(3)
 
@@ -191,7 +286,7 @@ func TestExecuteWithRetry_Success(t *testing.T) {
 		return testOutputSuccess, nil
 	}
 
-	output, err := executeWithRetry("test operation", cfg, fn)
+	output, err := executeWithRetry(context.Background(), "test operation", cfg.RetryFor(config.RetryDefault), fn)
 
 	assertError(t, err, false, "Success case")
 	assertOutput(t, output, testOutputSuccess, "Success case")
@@ -201,6 +296,11 @@ func TestExecuteWithRetry_Success(t *testing.T) {
 func TestExecuteWithRetry_TransientErrorThenSuccess(t *testing.T) {
 	cfg := createTestRetryConfig(3)
 
+	// Always wait the full backoff so the elapsed time is predictable
+	origJitter := retryJitter
+	defer func() { retryJitter = origJitter }()
+	retryJitter = func(n int64) int64 { return n - 1 }
+
 	callCount := 0
 	fn := func() (string, error) {
 		callCount++
@@ -211,7 +311,7 @@ func TestExecuteWithRetry_TransientErrorThenSuccess(t *testing.T) {
 	}
 
 	start := time.Now()
-	output, err := executeWithRetry("test operation", cfg, fn)
+	output, err := executeWithRetry(context.Background(), "test operation", cfg.RetryFor(config.RetryDefault), fn)
 	elapsed := time.Since(start)
 
 	assertError(t, err, false, "Transient error then success")
@@ -232,7 +332,7 @@ func TestExecuteWithRetry_NonRetryableError(t *testing.T) {
 		return testStatusAccessDenied, errors.New("access denied")
 	}
 
-	output, err := executeWithRetry("test operation", cfg, fn)
+	output, err := executeWithRetry(context.Background(), "test operation", cfg.RetryFor(config.RetryDefault), fn)
 
 	assertError(t, err, true, "Non-retryable error")
 	assertOutput(t, output, testStatusAccessDenied, "Non-retryable error")
@@ -248,7 +348,7 @@ func TestExecuteWithRetry_MaxRetriesExceeded(t *testing.T) {
 		return testOutputConnectionRefused, errors.New("connection refused")
 	}
 
-	output, err := executeWithRetry("test operation", cfg, fn)
+	output, err := executeWithRetry(context.Background(), "test operation", cfg.RetryFor(config.RetryDefault), fn)
 
 	assertError(t, err, true, "Max retries exceeded")
 	assertOutput(t, output, testOutputConnectionRefused, "Max retries exceeded")
@@ -264,7 +364,7 @@ func TestExecuteWithRetry_ZeroRetries(t *testing.T) {
 		return testOutputConnectionRefused, errors.New("connection refused")
 	}
 
-	output, err := executeWithRetry("test operation", cfg, fn)
+	output, err := executeWithRetry(context.Background(), "test operation", cfg.RetryFor(config.RetryDefault), fn)
 
 	assertError(t, err, true, "Zero retries")
 	assertOutput(t, output, testOutputConnectionRefused, "Zero retries")
@@ -336,3 +436,118 @@ func TestUploadFile_WithTransientError(t *testing.T) {
 		t.Errorf("Expected at least 3 calls (for retries), got %d", callCount)
 	}
 }
+
+// newRetryReadConfig returns a complete SMB configuration with short retry delays
+func newRetryReadConfig() *config.SMBConfig {
+	cfg := createTestRetryConfig(3)
+	cfg.ServerName = "testserver"
+	cfg.ServerIP = "127.0.0.1"
+	cfg.ShareName = "testshare"
+	cfg.Username = "testuser"
+	cfg.Password = "testpass"
+	cfg.Port = 445
+	cfg.AuthProtocol = "ntlm"
+	return cfg
+}
+
+func TestReadOperations_RetryTransientConnectionRefused(t *testing.T) {
+	listing := "  .                                   D        0  Mon Jan  1 10:00:00 2024\n" +
+		"  report.pdf                          A     1024  Tue Jan  2 12:30:00 2024\n"
+
+	tests := []struct {
+		name string
+		run  func(cfg *config.SMBConfig) error
+	}{
+		{"list", func(cfg *config.SMBConfig) error {
+			_, err := ListFiles("reports", cfg)
+			return err
+		}},
+		{"stat", func(cfg *config.SMBConfig) error {
+			_, err := StatFile("reports/report.pdf", cfg)
+			return err
+		}},
+		{"delete", func(cfg *config.SMBConfig) error {
+			return DeleteFile("reports/report.pdf", cfg)
+		}},
+		{"connection test", testConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(_ []string) (string, error) {
+				if mock.CallCount == 1 {
+					return "Connection to 127.0.0.1 failed (Error NT_STATUS_CONNECTION_REFUSED)",
+						fmt.Errorf("smbclient command failed: exit status 1")
+				}
+				return listing, nil
+			}
+			origExec := SetExecutor(mock)
+			defer SetExecutor(origExec)
+
+			if err := tt.run(newRetryReadConfig()); err != nil {
+				t.Fatalf("Expected success after one retry, got %v", err)
+			}
+			assertCallCount(t, mock.CallCount, 2, tt.name)
+		})
+	}
+}
+
+func TestReadOperations_NotFoundFailsFast(t *testing.T) {
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return `cd \timeout-reports\: NT_STATUS_OBJECT_PATH_NOT_FOUND`, fmt.Errorf("smbclient command failed: exit status 1")
+	}
+	origExec := SetExecutor(mock)
+	defer SetExecutor(origExec)
+
+	_, err := ListFiles("timeout-reports", newRetryReadConfig())
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	assertCallCount(t, mock.CallCount, 1, "not found (no retries)")
+}
+
+func TestExecuteWithRetry_PerOperationPolicies(t *testing.T) {
+	cfg := newRetryReadConfig()
+	cfg.MaxRetries = 0
+	cfg.DisableAutoMkdir = true
+	cfg.ListRetry = &config.RetryPolicy{MaxRetries: 1, InitialDelay: 0.001, MaxDelay: 0.01, Backoff: 2.0}
+	cfg.UploadRetry = &config.RetryPolicy{MaxRetries: 3, InitialDelay: 0.001, MaxDelay: 0.01, Backoff: 2.0}
+
+	commands := make(map[string]int)
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		switch {
+		case strings.Contains(cmd, "put "):
+			commands["put"]++
+		case strings.HasPrefix(cmd, "cd "):
+			commands["list"]++
+		default:
+			return "NT_STATUS_NO_SUCH_FILE listing \\report.pdf", fmt.Errorf("smbclient command failed: exit status 1")
+		}
+		return "Connection to 127.0.0.1 failed (Error NT_STATUS_CONNECTION_REFUSED)",
+			fmt.Errorf("smbclient command failed: exit status 1")
+	}
+	origExec := SetExecutor(mock)
+	defer SetExecutor(origExec)
+
+	if _, err := ListFiles("reports", cfg); err == nil {
+		t.Fatal("Expected the listing to fail")
+	}
+	if commands["list"] != 2 {
+		t.Errorf("Expected the list policy's 1 retry (2 attempts), got %d attempts", commands["list"])
+	}
+
+	localFile := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(localFile, []byte("content"), 0600); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := UploadFile(localFile, "report.pdf", cfg, false); err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	if commands["put"] != 4 {
+		t.Errorf("Expected the upload policy's 3 retries (4 attempts), got %d attempts", commands["put"])
+	}
+}
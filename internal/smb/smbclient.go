@@ -1,13 +1,22 @@
 package smb
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bancey/document-smbrelay-service/internal/config"
 	"github.com/bancey/document-smbrelay-service/internal/logger"
@@ -17,6 +26,8 @@ import (
 // This allows for easy mocking in tests
 type ClientExecutor interface {
 	Execute(args []string) (string, error)
+	// ExecuteWithStdin runs smbclient with stdin connected to the given reader, e.g. for "put -"
+	ExecuteWithStdin(args []string, env map[string]string, stdin io.Reader) (string, error)
 }
 
 // DefaultSmbClientExecutor uses the real smbclient binary
@@ -57,6 +68,21 @@ func getSmbClientPath() string {
 	return "/usr/bin/smbclient"
 }
 
+// resolvedSmbClientPath caches the binary found by getSmbClientPath for the life of the process
+var resolvedSmbClientPath struct {
+	path string
+	once sync.Once
+}
+
+// cachedSmbClientPath returns the smbclient binary path, looking it up only on the first call
+// so that executing a command does not stat the filesystem and search PATH every time
+func cachedSmbClientPath() string {
+	resolvedSmbClientPath.once.Do(func() {
+		resolvedSmbClientPath.path = getSmbClientPath()
+	})
+	return resolvedSmbClientPath.path
+}
+
 // validateBinaryPath checks if a path exists and is executable
 func validateBinaryPath(path string) bool {
 	// Check if file exists
@@ -78,12 +104,35 @@ func validateBinaryPath(path string) bool {
 	return true
 }
 
+// CheckSmbClientBinary reports the smbclient binary SMB operations would run, or why there is none
+// An SMBCLIENT_PATH that is not an executable file is an error even though operations would fall
+// back to searching PATH, since the setting is evidently wrong.
+func CheckSmbClientBinary() (string, error) {
+	if path := os.Getenv("SMBCLIENT_PATH"); path != "" && !validateBinaryPath(path) {
+		return "", fmt.Errorf("SMBCLIENT_PATH %s is not an executable file", path)
+	}
+	path := getSmbClientPath()
+	if !validateBinaryPath(path) {
+		return "", fmt.Errorf("%w in PATH or common locations", ErrNoSmbClient)
+	}
+	return path, nil
+}
+
 // isIPAddress checks if a string is a valid IP address (IPv4 or IPv6)
 func isIPAddress(host string) bool {
 	// net.ParseIP returns nil if the string is not a valid IP address
 	return net.ParseIP(host) != nil
 }
 
+// isNTHash reports whether s looks like an NT hash (32 hexadecimal characters)
+func isNTHash(s string) bool {
+	if len(s) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
 // sanitizeArgsForLogging replaces sensitive data in args for safe logging
 func sanitizeArgsForLogging(args []string, env map[string]string) ([]string, map[string]string) {
 	sanitized := make([]string, len(args))
@@ -120,34 +169,140 @@ func (e *DefaultSmbClientExecutor) Execute(args []string) (string, error) {
 
 // ExecuteWithEnv runs smbclient with the given arguments and environment variables
 func (e *DefaultSmbClientExecutor) ExecuteWithEnv(args []string, env map[string]string) (string, error) {
-	return e.ExecuteWithEnvAndLogging(args, env, false)
+	return e.ExecuteWithEnvAndLogging(context.Background(), args, env, false)
+}
+
+// ExecuteWithStdin runs smbclient with the given arguments and environment variables, reading stdin
+// from the given reader
+func (e *DefaultSmbClientExecutor) ExecuteWithStdin(
+	args []string, env map[string]string, stdin io.Reader,
+) (string, error) {
+	return e.execute(context.Background(), args, env, stdin, false)
 }
 
 // ExecuteWithEnvAndLogging runs smbclient with the given arguments,
 // environment variables, and optional logging
+// The process is killed if ctx is done before it exits.
 func (e *DefaultSmbClientExecutor) ExecuteWithEnvAndLogging(
-	args []string, env map[string]string, enableLogging bool,
+	ctx context.Context, args []string, env map[string]string, enableLogging bool,
 ) (string, error) {
+	return e.execute(ctx, args, env, nil, enableLogging)
+}
+
+// execute runs smbclient, optionally feeding it stdin and logging the command and its output
+func (e *DefaultSmbClientExecutor) execute(
+	ctx context.Context, args []string, env map[string]string, stdin io.Reader, enableLogging bool,
+) (string, error) {
+	cmd := e.command(ctx, args, env, enableLogging)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if binErr := binaryError(cmd, err); binErr != nil {
+		return "", binErr
+	}
+
+	// Combine stdout and stderr for complete output
+	return commandResult(ctx, stdout.String()+stderr.String(), err, enableLogging)
+}
+
+// ExecuteSession runs smbclient interactively: input is written to its stdin, then reply is called
+// with every line it prints and whatever reply returns is written next
+// stdin is closed once reply reports it has nothing more to send, which ends the session.
+func (e *DefaultSmbClientExecutor) ExecuteSession(
+	args []string, env map[string]string, input string, reply func(line string) (string, bool),
+) (string, error) {
+	return e.session(context.Background(), args, env, input, reply, false)
+}
+
+// session runs an interactive smbclient session, optionally logging the command and its output
+func (e *DefaultSmbClientExecutor) session(
+	ctx context.Context,
+	args []string,
+	env map[string]string,
+	input string,
+	reply func(line string) (string, bool),
+	enableLogging bool,
+) (string, error) {
+	cmd := e.command(ctx, args, env, enableLogging)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", fmt.Errorf("smbclient command failed: %w", err)
+	}
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("smbclient command failed: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		if binErr := binaryError(cmd, err); binErr != nil {
+			return "", binErr
+		}
+		return "", fmt.Errorf("smbclient command failed: %w", err)
+	}
+
+	// Input that cannot be written means smbclient has exited; Wait reports why
+	closed := false
+	send := func(text string, last bool) {
+		if closed {
+			return
+		}
+		if _, err := io.WriteString(stdin, text); err != nil || last {
+			closed = true
+			stdin.Close()
+		}
+	}
+	send(input, false)
+
+	// The prompt smbclient prints before reading a command has no line break, so a line is only
+	// complete once the previous command has finished
+	reader := bufio.NewReader(stdoutPipe)
+	for {
+		line, readErr := reader.ReadString('\n')
+		stdout.WriteString(line)
+		if readErr != nil {
+			break
+		}
+		send(reply(strings.TrimSuffix(line, "\n")))
+	}
+	send("", true)
+
+	err = cmd.Wait()
+	return commandResult(ctx, stdout.String()+stderr.String(), err, enableLogging)
+}
+
+// command builds the smbclient command for args, logging it if enabled
+// The process is killed if ctx is done before it exits.
+func (e *DefaultSmbClientExecutor) command(
+	ctx context.Context, args []string, env map[string]string, enableLogging bool,
+) *exec.Cmd {
 	binaryPath := e.BinaryPath
 	if binaryPath == "" {
-		binaryPath = getSmbClientPath()
+		binaryPath = cachedSmbClientPath()
 	}
 
 	// Log command if enabled
 	if enableLogging {
 		sanitizedArgs, sanitizedEnv := sanitizeArgsForLogging(args, env)
-		logger.Info(fmt.Sprintf("Executing smbclient: %s %s", binaryPath, strings.Join(sanitizedArgs, " ")))
+		logger.InfoContext(ctx, "Executing smbclient: %s %s", binaryPath, strings.Join(sanitizedArgs, " "))
 		if len(sanitizedEnv) > 0 {
-			logger.Debug(fmt.Sprintf("Environment variables: %v", sanitizedEnv))
+			logger.DebugContext(ctx, "Environment variables: %v", sanitizedEnv)
 		}
 	}
 
-	// #nosec G204 - binaryPath is validated and comes from trusted sources:
+	// #nosec G204 - binaryPath comes from trusted sources, validated when it is first resolved:
 	// 1. Environment variable (SMBCLIENT_PATH) - user is responsible for ensuring input is properly
 	//    sanitised and do not contain unsafe user-controlled data.
 	// 2. System PATH via exec.LookPath()
 	// 3. Hardcoded known paths checked with validateBinaryPath()
-	cmd := exec.Command(binaryPath, args...)
+	cmd := exec.CommandContext(ctx, binaryPath, args...)
+	// Don't wait indefinitely on a stdin reader that blocks after the process is killed
+	cmd.WaitDelay = commandWaitDelay
 
 	// Set environment variables if provided
 	if len(env) > 0 {
@@ -159,25 +314,30 @@ func (e *DefaultSmbClientExecutor) ExecuteWithEnvAndLogging(
 		}
 	}
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
+	return cmd
+}
 
-	// Combine stdout and stderr for complete output
-	output := stdout.String() + stderr.String()
+// binaryError reports a command that could not be started because the smbclient binary is missing
+// The binary is only checked once a start has failed, so running a command never stats it.
+func binaryError(cmd *exec.Cmd, err error) error {
+	if err == nil || cmd.Process != nil || validateBinaryPath(cmd.Path) {
+		return nil
+	}
+	return fmt.Errorf("%w at %s: install smbclient or set SMBCLIENT_PATH", ErrNoSmbClient, cmd.Path)
+}
 
+// commandResult logs a finished command's output if enabled and wraps its error
+func commandResult(ctx context.Context, output string, err error, enableLogging bool) (string, error) {
 	// Log output if enabled
 	if enableLogging {
 		if err != nil {
-			logger.Error(fmt.Sprintf("smbclient failed with error: %v", err))
+			logger.ErrorContext(ctx, "smbclient failed with error: %v", err)
 			if output != "" {
 				// Log output at ERROR level so it's always visible when debugging
-				logger.Error(fmt.Sprintf("smbclient output: %s", output))
+				logger.ErrorContext(ctx, "smbclient output: %s", output)
 			}
 		} else {
-			logger.Debug(fmt.Sprintf("smbclient succeeded. Output: %s", output))
+			logger.DebugContext(ctx, "smbclient succeeded. Output: %s", output)
 		}
 	}
 
@@ -188,17 +348,368 @@ func (e *DefaultSmbClientExecutor) ExecuteWithEnvAndLogging(
 	return output, nil
 }
 
+// sessionExecutor is implemented by executors that can run an interactive smbclient session
+type sessionExecutor interface {
+	ExecuteSession(
+		args []string, env map[string]string, input string, reply func(line string) (string, bool),
+	) (string, error)
+}
+
+// emulateSession runs a session as one "-c" command per round of input, for executors that can
+// only run complete commands
+func emulateSession(
+	execute func(args []string) (string, error), args []string, input string, reply func(line string) (string, bool),
+) (string, error) {
+	var output strings.Builder
+	var err error
+	for input != "" {
+		commands := strings.Split(strings.TrimSuffix(input, "\n"), "\n")
+		var roundOutput string
+		roundOutput, err = execute(append(slices.Clone(args), "-c", strings.Join(commands, "; ")))
+		output.WriteString(roundOutput)
+
+		input = ""
+		for _, line := range strings.Split(strings.TrimSuffix(roundOutput, "\n"), "\n") {
+			more, done := reply(line)
+			if done {
+				return output.String(), err
+			}
+			input += more
+		}
+	}
+	return output.String(), err
+}
+
 // Global executor that can be replaced in tests
 var smbClientExec ClientExecutor = &DefaultSmbClientExecutor{}
 
+// SetExecutor replaces the global smbclient executor and returns the previous one
+// This allows packages outside smb (e.g. handlers) to substitute a mock in tests
+func SetExecutor(executor ClientExecutor) ClientExecutor {
+	previous := smbClientExec
+	smbClientExec = executor
+	clientVersion.reset()
+	return previous
+}
+
+// versionCache holds the smbclient version once it has been detected
+type versionCache struct {
+	version string
+	mu      sync.Mutex
+}
+
+var clientVersion = &versionCache{}
+
+// reset forgets the cached version, e.g. when the executor changes
+func (v *versionCache) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.version = ""
+}
+
+// ClientVersion returns the smbclient version reported by "smbclient --version"
+// The version is cached after the first successful detection; failures are retried on the next call.
+func ClientVersion() (string, error) {
+	clientVersion.mu.Lock()
+	defer clientVersion.mu.Unlock()
+
+	if clientVersion.version != "" {
+		return clientVersion.version, nil
+	}
+
+	output, err := smbClientExec.Execute([]string{"--version"})
+	if err != nil {
+		return "", fmt.Errorf("failed to run smbclient --version: %w", err)
+	}
+
+	version, err := parseClientVersion(output)
+	if err != nil {
+		return "", err
+	}
+	clientVersion.version = version
+	return version, nil
+}
+
+// parseClientVersion extracts the version from smbclient --version output, e.g. "Version 4.19.5-Ubuntu"
+func parseClientVersion(output string) (string, error) {
+	for _, line := range strings.Split(output, "\n") {
+		if version, ok := strings.CutPrefix(strings.TrimSpace(line), "Version "); ok && version != "" {
+			return strings.TrimSpace(version), nil
+		}
+	}
+	return "", fmt.Errorf("unexpected smbclient --version output: %q", strings.TrimSpace(output))
+}
+
+// BinaryPath returns the smbclient binary the default executor runs
+func BinaryPath() string {
+	if e, ok := smbClientExec.(*DefaultSmbClientExecutor); ok && e.BinaryPath != "" {
+		return e.BinaryPath
+	}
+	return cachedSmbClientPath()
+}
+
 // executeSmbClient is a helper function that executes smbclient with proper logging support
 // This reduces code duplication across all executeWithRetry calls
-func executeSmbClient(args []string, env map[string]string, cfg *config.SMBConfig) (string, error) {
+// The command waits for one of cfg.MaxConcurrent slots, then is stopped when ctx is done or after
+// cfg.CommandTimeout, whichever comes first.
+func executeSmbClient(
+	ctx context.Context, args []string, env map[string]string, cfg *config.SMBConfig,
+) (string, error) {
+	release, err := smbClientSlots.acquire(ctx, cfg.MaxConcurrent)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := commandContext(ctx, cfg)
+	defer cancel()
+
+	endOperation := BeginOperation()
+	defer endOperation()
+
+	finish := startSMBClientProcess()
+	var output string
 	if executor, ok := smbClientExec.(*DefaultSmbClientExecutor); ok {
-		return executor.ExecuteWithEnvAndLogging(args, env, cfg.LogSmbCommands)
+		output, err = executor.ExecuteWithEnvAndLogging(ctx, args, env, cfg.LogSmbCommands)
+	} else {
+		// For mock executors in tests
+		executor := smbClientExec
+		output, err = waitForCommand(ctx, func() (string, error) {
+			return executor.Execute(args)
+		})
+	}
+	err = commandError(ctx, err, cfg)
+	output, err = securityError(output, err, cfg)
+	finish(operationOutcome(err))
+	return output, err
+}
+
+// executeSmbClientWithStdin executes smbclient with stdin read from the given reader
+// Unlike executeSmbClient it is never retried by callers, as the reader cannot be replayed.
+func executeSmbClientWithStdin(
+	ctx context.Context, args []string, env map[string]string, stdin io.Reader, cfg *config.SMBConfig,
+) (string, error) {
+	release, err := smbClientSlots.acquire(ctx, cfg.MaxConcurrent)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := commandContext(ctx, cfg)
+	defer cancel()
+
+	endOperation := BeginOperation()
+	defer endOperation()
+
+	finish := startSMBClientProcess()
+	var output string
+	if executor, ok := smbClientExec.(*DefaultSmbClientExecutor); ok {
+		output, err = executor.execute(ctx, args, env, stdin, cfg.LogSmbCommands)
+	} else {
+		// For mock executors in tests
+		executor := smbClientExec
+		output, err = waitForCommand(ctx, func() (string, error) {
+			return executor.ExecuteWithStdin(args, env, stdin)
+		})
+	}
+	err = commandError(ctx, err, cfg)
+	output, err = securityError(output, err, cfg)
+	finish(operationOutcome(err))
+	return output, err
+}
+
+// executeSmbClientSession runs an interactive smbclient session driven by reply (see ExecuteSession)
+// Like executeSmbClientWithStdin it is never retried, as the session may already have changed the share.
+func executeSmbClientSession(
+	ctx context.Context,
+	args []string,
+	env map[string]string,
+	input string,
+	reply func(line string) (string, bool),
+	cfg *config.SMBConfig,
+) (string, error) {
+	release, err := smbClientSlots.acquire(ctx, cfg.MaxConcurrent)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ctx, cancel := commandContext(ctx, cfg)
+	defer cancel()
+
+	endOperation := BeginOperation()
+	defer endOperation()
+
+	finish := startSMBClientProcess()
+	var output string
+	switch executor := smbClientExec.(type) {
+	case *DefaultSmbClientExecutor:
+		output, err = executor.session(ctx, args, env, input, reply, cfg.LogSmbCommands)
+	case sessionExecutor:
+		// For mock executors in tests
+		output, err = waitForCommand(ctx, func() (string, error) {
+			return executor.ExecuteSession(args, env, input, reply)
+		})
+	default:
+		output, err = waitForCommand(ctx, func() (string, error) {
+			return emulateSession(executor.Execute, args, input, reply)
+		})
+	}
+	err = commandError(ctx, err, cfg)
+	output, err = securityError(output, err, cfg)
+	finish(operationOutcome(err))
+	return output, err
+}
+
+// commandSlots bounds how many smbclient processes run at once across the whole service
+type commandSlots struct {
+	slots chan struct{}
+	mu    sync.Mutex
+}
+
+var smbClientSlots = &commandSlots{}
+
+// acquire waits for a free slot, or until ctx is done, and returns a function that releases it
+// A limit of 0 or less means unlimited. When the limit changes, commands already running keep
+// their slots in the previous pool and are not counted against the new one.
+func (s *commandSlots) acquire(ctx context.Context, limit int) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	if cap(s.slots) != limit {
+		s.slots = make(chan struct{}, limit)
+	}
+	slots := s.slots
+	s.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("smbclient command canceled while waiting for a free slot: %w", ctx.Err())
+	}
+}
+
+// commandWaitDelay bounds how long a killed smbclient process may hold on to its output and stdin
+const commandWaitDelay = 5 * time.Second
+
+// commandContext derives the context one smbclient command runs under, applying cfg.CommandTimeout
+func commandContext(ctx context.Context, cfg *config.SMBConfig) (context.Context, context.CancelFunc) {
+	if cfg.CommandTimeout > 0 {
+		return context.WithTimeout(ctx, cfg.CommandTimeout)
 	}
-	// For mock executors in tests
-	return smbClientExec.Execute(args)
+	return context.WithCancel(ctx)
+}
+
+// waitForCommand runs a command that does not take a context, returning early when ctx is done
+// The command itself keeps running in the background until it returns.
+func waitForCommand(ctx context.Context, run func() (string, error)) (string, error) {
+	type result struct {
+		err    error
+		output string
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := run()
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// commandError replaces the error of a command stopped by its context with one that says why
+func commandError(ctx context.Context, err error, cfg *config.SMBConfig) error {
+	if err == nil {
+		return nil
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		if cfg.CommandTimeout > 0 {
+			return fmt.Errorf("smbclient command %w after %s", ErrTimeout, cfg.CommandTimeout)
+		}
+		return fmt.Errorf("smbclient command %w: %w", ErrTimeout, ctx.Err())
+	case context.Canceled:
+		return fmt.Errorf("smbclient command canceled: %w", ctx.Err())
+	}
+	return err
+}
+
+// connectionRefusal matches a line smbclient prints when negotiation or session setup fails
+// Both fields are lowercase; the line must start with stage and contain status.
+type connectionRefusal struct {
+	stage  string
+	status string
+}
+
+// signingRefusals and encryptionRefusals are the connection failures showing the server would not
+// sign or encrypt the connection. Only lines from before any command ran are matched, so a failing
+// command on a file named e.g. encrypted-backup.zip is not mistaken for a refusal.
+var (
+	signingRefusals = []connectionRefusal{
+		{stage: "protocol negotiation failed:", status: "nt_status_access_denied"},
+		{stage: "protocol negotiation failed:", status: "nt_status_invalid_signature"},
+		{stage: "session setup failed:", status: "nt_status_invalid_signature"},
+	}
+	encryptionRefusals = []connectionRefusal{
+		{stage: "encryption required and server doesn't support smb3 encryption"},
+		{stage: "encryption required and setup failed with error", status: "nt_status_"},
+		{stage: "protocol negotiation failed:", status: "nt_status_not_supported"},
+	}
+)
+
+// securityError replaces the error of a command the server refused to sign or encrypt
+// The refusal line is removed from the output so callers do not misread it, e.g. as access denied;
+// the rest of the output, and the original error, are kept.
+func securityError(output string, err error, cfg *config.SMBConfig) (string, error) {
+	if err == nil {
+		return output, nil
+	}
+	if cfg.RequireEncryption {
+		if line := refusalLine(output, encryptionRefusals); line != "" {
+			return withoutLine(output, line), classify(ErrSecurityRefused,
+				"SMB server refused the required encryption (SMB_REQUIRE_ENCRYPTION is enabled): %s: %v", line, err)
+		}
+	}
+	if cfg.RequireSigning {
+		if line := refusalLine(output, signingRefusals); line != "" {
+			return withoutLine(output, line), classify(ErrSecurityRefused,
+				"SMB server refused the required signing (SMB_REQUIRE_SIGNING is enabled): %s: %v", line, err)
+		}
+	}
+	return output, err
+}
+
+// refusalLine returns the first line of output matching one of the refusals, trimmed
+func refusalLine(output string, refusals []connectionRefusal) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		for _, refusal := range refusals {
+			if strings.HasPrefix(lower, refusal.stage) && strings.Contains(lower, refusal.status) {
+				return line
+			}
+		}
+	}
+	return ""
+}
+
+// withoutLine returns output without the lines that are line once trimmed
+func withoutLine(output, line string) string {
+	lines := strings.Split(output, "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if strings.TrimSpace(l) != line {
+			kept = append(kept, l)
+		}
+	}
+	return strings.Join(kept, "\n")
 }
 
 // buildSmbClientArgs constructs the arguments for smbclient command
@@ -231,6 +742,16 @@ func buildSmbClientArgs(cfg *config.SMBConfig, command string) ([]string, map[st
 		args = append(args, "-p", fmt.Sprintf("%d", cfg.Port))
 	}
 
+	args = append(args, protocolArgs(cfg)...)
+
+	// Require signing and encryption rather than letting smbclient negotiate them away
+	if cfg.RequireSigning {
+		args = append(args, "--option=client signing=required")
+	}
+	if cfg.RequireEncryption {
+		args = append(args, "--option=client smb encrypt=required")
+	}
+
 	// Add domain/workgroup if specified
 	if cfg.Domain != "" {
 		args = append(args, "-W", cfg.Domain)
@@ -239,13 +760,11 @@ func buildSmbClientArgs(cfg *config.SMBConfig, command string) ([]string, map[st
 	// Handle authentication based on protocol
 	switch strings.ToLower(cfg.AuthProtocol) {
 	case "kerberos":
-		args = append(args, "--use-kerberos=required")
-		// For Kerberos, username/password are optional (uses system ticket cache)
-		if cfg.Username != "" {
-			args = append(args, "-U", cfg.Username)
+		kerberosArgs, err := buildKerberosArgs(cfg)
+		if err != nil {
+			return nil, nil, err
 		}
-		// Kerberos uses -N flag to avoid password prompt
-		args = append(args, "-N")
+		args = append(args, kerberosArgs...)
 	case "ntlm", "negotiate", "":
 		// For NTLM and Negotiate, we need username and password
 		if cfg.Username == "" || cfg.Password == "" {
@@ -253,15 +772,41 @@ func buildSmbClientArgs(cfg *config.SMBConfig, command string) ([]string, map[st
 		}
 		// Pass username via -U flag (without password for security)
 		args = append(args, "-U", cfg.Username)
+		// The password may be an NT hash instead of plaintext
+		if cfg.PasswordIsNTHash {
+			if !isNTHash(cfg.Password) {
+				return nil, nil, fmt.Errorf("invalid NT hash: SMB_PASSWORD must be 32 hexadecimal characters")
+			}
+			args = append(args, "--pw-nt-hash")
+		}
 		// Pass password via PASSWD environment variable to handle special characters
 		// This is more secure and avoids issues with special characters like %, $, etc.
 		env["PASSWD"] = cfg.Password
 		// Do NOT use -N flag here - smbclient will read password from PASSWD env var
 		// The -N flag means "no password" which would prevent reading from PASSWD
+	case "guest":
+		// Guest access to public shares: -N skips the password, so none is sent or prompted for
+		username := cfg.Username
+		if username == "" {
+			username = "guest"
+		}
+		args = append(args, "-U", username, "-N")
 	default:
 		return nil, nil, fmt.Errorf("unsupported authentication protocol: %s", cfg.AuthProtocol)
 	}
 
+	// Options the service does not model go just before the command, which they must never replace;
+	// LoadFromEnv refuses such arguments, and a config built elsewhere is checked here
+	for _, arg := range cfg.ExtraArgs {
+		if config.IsCommandArg(arg) {
+			return nil, nil, fmt.Errorf("%w: %s", config.ErrCommandArg, arg)
+		}
+	}
+	if len(cfg.ExtraArgs) > 0 {
+		warnExtraArgs(cfg.ExtraArgs)
+		args = append(args, cfg.ExtraArgs...)
+	}
+
 	// Add the command to execute
 	if command != "" {
 		args = append(args, "-c", command)
@@ -270,6 +815,50 @@ func buildSmbClientArgs(cfg *config.SMBConfig, command string) ([]string, map[st
 	return args, env, nil
 }
 
+// protocolArgs returns the options pinning the protocol dialects smbclient may negotiate
+// SMB_ALLOW_SMB1 lowers the floor to NT1 unless SMB_MIN_PROTOCOL is set, and a maximum of NT1
+// also lowers the floor, since smbclient's default floor is newer than SMB1.
+func protocolArgs(cfg *config.SMBConfig) []string {
+	minProtocol := cfg.MinProtocol
+	if minProtocol == "" && (cfg.AllowSMB1 || cfg.MaxProtocol == config.ProtocolNT1) {
+		minProtocol = config.ProtocolNT1
+	}
+
+	var args []string
+	if minProtocol != "" {
+		if minProtocol == config.ProtocolNT1 {
+			warnSMB1Enabled()
+		}
+		args = append(args, "--option=client min protocol="+minProtocol)
+	}
+	if cfg.MaxProtocol != "" {
+		args = append(args, "--option=client max protocol="+cfg.MaxProtocol)
+	}
+	return args
+}
+
+// smb1Warning ensures the SMB1 deprecation warning is logged once per process
+var smb1Warning sync.Once
+
+// warnSMB1Enabled logs a deprecation warning the first time SMB1 is allowed
+func warnSMB1Enabled() {
+	smb1Warning.Do(func() {
+		logger.Warn("SMB1 (NT1) is enabled: SMB1 is deprecated and insecure; " +
+			"connections may fall back to it. Upgrade or replace legacy SMB servers as soon as possible")
+	})
+}
+
+// extraArgsWarning ensures the SMB_EXTRA_ARGS warning is logged once per process
+var extraArgsWarning sync.Once
+
+// warnExtraArgs logs a warning the first time raw smbclient arguments are used
+func warnExtraArgs(extraArgs []string) {
+	extraArgsWarning.Do(func() {
+		logger.Warn("SMB_EXTRA_ARGS passes %q to smbclient without validation; "+
+			"check them whenever smbclient is upgraded", extraArgs)
+	})
+}
+
 // testConnection tests the connection to the SMB share
 func testConnection(cfg *config.SMBConfig) error {
 	args, env, err := buildSmbClientArgs(cfg, "ls")
@@ -278,26 +867,27 @@ func testConnection(cfg *config.SMBConfig) error {
 	}
 
 	// Execute with retry logic
-	output, err := executeWithRetry("SMB connection test", cfg, func() (string, error) {
-		return executeSmbClient(args, env, cfg)
+	retry := cfg.RetryFor(config.RetryDefault)
+	output, err := executeWithRetry(context.Background(), "SMB connection test", retry, func() (string, error) {
+		return executeSmbClient(context.Background(), args, env, cfg)
 	})
 
 	if err != nil {
 		// Parse error message to provide more context
 		if strings.Contains(output, "NT_STATUS_BAD_NETWORK_NAME") {
-			return fmt.Errorf("share not found: %s", cfg.ShareName)
+			return fmt.Errorf("share %w: %s", ErrNotFound, cfg.ShareName)
 		}
 		if strings.Contains(output, "NT_STATUS_LOGON_FAILURE") {
 			return fmt.Errorf("authentication failed: invalid credentials")
 		}
 		if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") {
-			return fmt.Errorf("access denied to share: %s", cfg.ShareName)
+			return fmt.Errorf("%w to share: %s", ErrAccessDenied, cfg.ShareName)
 		}
 		if strings.Contains(output, "NT_STATUS_INVALID_PARAMETER") {
 			return fmt.Errorf("invalid authentication parameters (check username/password format and special characters)")
 		}
 		if strings.Contains(output, "Connection refused") || strings.Contains(output, "failed to connect") {
-			return fmt.Errorf("failed to connect to SMB server: connection refused")
+			return fmt.Errorf("failed to connect to SMB server: %w", ErrConnectionRefused)
 		}
 		return err
 	}
@@ -324,18 +914,22 @@ func testBasePath(cfg *config.SMBConfig) error {
 	}
 
 	// Execute with retry logic
-	output, err := executeWithRetry("Base path validation", cfg, func() (string, error) {
-		return executeSmbClient(args, env, cfg)
+	retry := cfg.RetryFor(config.RetryDefault)
+	output, err := executeWithRetry(context.Background(), "Base path validation", retry, func() (string, error) {
+		return executeSmbClient(context.Background(), args, env, cfg)
 	})
 
 	if err != nil {
 		// Parse error messages
 		if strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
 			strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND") {
+			if cfg.CreateBasePath {
+				return ensureBasePath(context.Background(), cfg)
+			}
 			return fmt.Errorf("base path does not exist: %s", cfg.BasePath)
 		}
 		if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") {
-			return fmt.Errorf("access denied to base path: %s", cfg.BasePath)
+			return fmt.Errorf("%w to base path: %s", ErrAccessDenied, cfg.BasePath)
 		}
 		return fmt.Errorf("failed to access base path: %w", err)
 	}
@@ -343,8 +937,142 @@ func testBasePath(cfg *config.SMBConfig) error {
 	return nil
 }
 
+// createdBasePaths records the base paths ensureBasePath has created or found, by share
+var createdBasePaths sync.Map
+
+// ensureBasePath creates the configured base path and its missing parents, once per process
+// It serves SMB_CREATE_BASE_PATH, so a fresh share works without creating the base path by hand.
+func ensureBasePath(ctx context.Context, cfg *config.SMBConfig) error {
+	basePath := normalizePathSegment(cfg.BasePath)
+	if basePath == "" || basePath == "." {
+		return nil
+	}
+	key := fmt.Sprintf("//%s/%s/%s", cfg.GetServer(), cfg.ShareName, basePath)
+	if _, ok := createdBasePaths.Load(key); ok {
+		return nil
+	}
+
+	if err := CreateDirectoryWithContext(ctx, "", cfg); err != nil {
+		return fmt.Errorf("base path does not exist and could not be created: %w", err)
+	}
+	if _, loaded := createdBasePaths.LoadOrStore(key, true); !loaded {
+		logger.InfoContext(ctx, "Ensured base path %s exists on %s", cfg.BasePath, cfg.ShareName)
+	}
+	return nil
+}
+
+// healthProbePrefix prefixes the names of probe files written by the health check
+const healthProbePrefix = "probe-"
+
+// testWrite verifies the share is writable by uploading and deleting a tiny probe file
+// in the configured health check directory; a probe left by a failed upload is deleted too
+func testWrite(cfg *config.SMBConfig) error {
+	probe, err := os.CreateTemp("", "smb-health-*")
+	if err != nil {
+		return fmt.Errorf("failed to create probe file: %w", err)
+	}
+	probePath := probe.Name()
+	_, writeErr := probe.WriteString("smbrelay health check\n")
+	probe.Close()
+	defer os.Remove(probePath)
+	if writeErr != nil {
+		return fmt.Errorf("failed to write probe file: %w", writeErr)
+	}
+
+	probeDir := buildFullPath(cfg.HealthWriteDir, cfg)
+	remotePath := joinSmbPaths(probeDir, healthProbePrefix+filepath.Base(probePath))
+
+	retry := cfg.RetryFor(config.RetryDefault)
+
+	// Create the probe directory, ignoring errors as it usually already exists
+	if probeDir != "" && probeDir != "." {
+		args, env, err := buildSmbClientArgs(cfg, fmt.Sprintf(`mkdir "%s"`, probeDir))
+		if err != nil {
+			return err
+		}
+		_, _ = executeWithRetry(context.Background(), "Create health probe directory", retry, func() (string, error) {
+			return executeSmbClient(context.Background(), args, env, cfg)
+		})
+	}
+
+	command := fmt.Sprintf(`lcd "%s"; put "%s" "%s"`, filepath.Dir(probePath), filepath.Base(probePath), remotePath)
+	args, env, err := buildSmbClientArgs(cfg, command)
+	if err != nil {
+		return err
+	}
+	output, err := executeWithRetry(context.Background(), "Health write test", retry, func() (string, error) {
+		return executeSmbClient(context.Background(), args, env, cfg)
+	})
+	if err != nil {
+		if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") || strings.Contains(output, "NT_STATUS_MEDIA_WRITE_PROTECTED") {
+			return fmt.Errorf("share is not writable: %w to %s", ErrAccessDenied, probeDir)
+		}
+		// A put that failed part way through may have left the probe behind
+		if delErr := deleteHealthProbe(remotePath, cfg, retry); delErr != nil {
+			logger.Warn("Failed to clean up health probe after a failed write test: %v", delErr)
+		}
+		return fmt.Errorf("failed to write probe file: %w", err)
+	}
+
+	return deleteHealthProbe(remotePath, cfg, retry)
+}
+
+// deleteHealthProbe deletes the probe file written by a health write test
+func deleteHealthProbe(remotePath string, cfg *config.SMBConfig, retry config.RetryPolicy) error {
+	args, env, err := buildSmbClientArgs(cfg, fmt.Sprintf(`del "%s"`, remotePath))
+	if err != nil {
+		return err
+	}
+	if _, err := executeWithRetry(context.Background(), "Delete health probe", retry, func() (string, error) {
+		return executeSmbClient(context.Background(), args, env, cfg)
+	}); err != nil {
+		return fmt.Errorf("failed to delete probe file %s: %w", remotePath, err)
+	}
+	return nil
+}
+
+// ensureParentDirectory creates the parent directory of remotePath on the share
+// Only errors building the command or creating the base path are returned; a failed mkdir is
+// ignored because the directory usually already exists, and the following command reports any
+// real problem.
+func ensureParentDirectory(ctx context.Context, remotePath string, cfg *config.SMBConfig) error {
+	// The base path is created first on a fresh share, as mkdir of the parent cannot create it
+	if cfg.CreateBasePath {
+		if err := ensureBasePath(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	remoteDir := filepath.Dir(remotePath)
+	if remoteDir == "." || remoteDir == "" {
+		return nil
+	}
+
+	// Create directory command
+	mkdirCmd := fmt.Sprintf("mkdir \"%s\"", remoteDir)
+	args, env, err := buildSmbClientArgs(cfg, mkdirCmd)
+	if err != nil {
+		return err
+	}
+	// Try to create the parent directory with retry, ignoring errors as it might already exist
+	// We intentionally ignore the error here since the directory might already exist
+	// nolint:errcheck
+	_ = func() error {
+		retry := cfg.RetryFor(config.RetryUpload)
+		_, err := executeWithRetry(ctx, "Create parent directory", retry, func() (string, error) {
+			return executeSmbClient(ctx, args, env, cfg)
+		})
+		return err
+	}()
+
+	return nil
+}
+
 // uploadFileViaSmbClient uploads a file using smbclient
-func uploadFileViaSmbClient(localPath string, remotePath string, cfg *config.SMBConfig) error {
+// With overwrite set, a put refused because the file exists deletes the existing file and retries once.
+func uploadFileViaSmbClient(
+	ctx context.Context, localPath string, remotePath string, cfg *config.SMBConfig, overwrite bool,
+) error {
 	// Normalize remote path - remove leading slash
 	remotePath = strings.TrimPrefix(remotePath, "/")
 	remotePath = strings.TrimPrefix(remotePath, "\\")
@@ -357,24 +1085,20 @@ func uploadFileViaSmbClient(localPath string, remotePath string, cfg *config.SMB
 		return fmt.Errorf("local file not found: %s", localPath)
 	}
 
-	// Ensure parent directories exist by creating them first
-	remoteDir := filepath.Dir(remotePath)
-	if remoteDir != "." && remoteDir != "" {
-		// Create directory command
-		mkdirCmd := fmt.Sprintf("mkdir \"%s\"", remoteDir)
-		args, env, err := buildSmbClientArgs(cfg, mkdirCmd)
-		if err != nil {
+	// Refuse to put a file over an existing directory - smbclient fails with an obscure error otherwise.
+	// Without overwrite the caller's checkUploadTarget has already refused one.
+	// The same listing tells whether the target already existed, so a failed put never deletes a
+	// file it was overwriting.
+	cleanupOnFailure, err := probeUploadTarget(ctx, remotePath, cfg, overwrite)
+	if err != nil {
+		return err
+	}
+
+	// Ensure parent directories exist by creating them first, unless auto-creation is disabled
+	if !cfg.DisableAutoMkdir {
+		if err := ensureParentDirectory(ctx, remotePath, cfg); err != nil {
 			return err
 		}
-		// Try to create the parent directory with retry, ignoring errors as it might already exist
-		// We intentionally ignore the error here since the directory might already exist
-		// nolint:errcheck
-		_ = func() error {
-			_, err := executeWithRetry("Create parent directory", cfg, func() (string, error) {
-				return executeSmbClient(args, env, cfg)
-			})
-			return err
-		}()
 	}
 
 	// Build the put command
@@ -391,22 +1115,30 @@ func uploadFileViaSmbClient(localPath string, remotePath string, cfg *config.SMB
 	}
 
 	// Execute with retry logic
-	output, err := executeWithRetry("Upload file", cfg, func() (string, error) {
-		return executeSmbClient(args, env, cfg)
-	})
+	put := func() (string, error) {
+		return executeWithRetry(ctx, "Upload file", cfg.RetryFor(config.RetryUpload), func() (string, error) {
+			return executeSmbClient(ctx, args, env, cfg)
+		})
+	}
+	output, err := put()
+
+	// Some servers refuse to put over an existing file; replace it when the caller asked to overwrite
+	if err != nil && overwrite && strings.Contains(output, "NT_STATUS_OBJECT_NAME_COLLISION") {
+		if delErr := deleteForOverwrite(ctx, remotePath, cfg); delErr != nil {
+			return delErr
+		}
+		output, err = put()
+	}
 
 	if err != nil {
 		// Parse error messages
 		if strings.Contains(output, "NT_STATUS_OBJECT_NAME_COLLISION") {
-			return fmt.Errorf("remote file already exists: %s", remotePath)
+			return fmt.Errorf("remote file %w: %s", ErrFileExists, remotePath)
 		}
-		if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") {
-			return fmt.Errorf("access denied: cannot write to %s", remotePath)
+		if cleanupOnFailure {
+			removePartialUpload(ctx, remotePath, cfg)
 		}
-		if strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND") {
-			return fmt.Errorf("remote path not found: %s", filepath.Dir(remotePath))
-		}
-		return fmt.Errorf("failed to upload file: %w", err)
+		return putError(output, remotePath, cfg, err)
 	}
 
 	// Check if the output indicates success
@@ -414,5 +1146,230 @@ func uploadFileViaSmbClient(localPath string, remotePath string, cfg *config.SMB
 		return fmt.Errorf("upload may have failed: unexpected output")
 	}
 
+	if cfg.VerifyUpload {
+		localSum, err := FileSHA256(localPath)
+		if err != nil {
+			return fmt.Errorf("upload verification failed: cannot hash local file: %w", err)
+		}
+		return verifyRemoteChecksum(ctx, remotePath, localSum, cfg)
+	}
+
 	return nil
 }
+
+// putError maps the output of a failed put to an upload error
+func putError(output string, remotePath string, cfg *config.SMBConfig, err error) error {
+	remoteDir := filepath.Dir(remotePath)
+	switch {
+	case strings.Contains(output, "NT_STATUS_OBJECT_NAME_COLLISION"):
+		return fmt.Errorf("remote file %w: %s", ErrFileExists, remotePath)
+	case strings.Contains(output, "NT_STATUS_ACCESS_DENIED"):
+		return fmt.Errorf("%w: cannot write to %s", ErrAccessDenied, remotePath)
+	case strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND") && cfg.DisableAutoMkdir:
+		return fmt.Errorf("%w: %s", ErrParentNotFound, remoteDir)
+	case strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND"):
+		return fmt.Errorf("remote path %w: %s", ErrNotFound, remoteDir)
+	case strings.Contains(output, "NT_STATUS_DISK_FULL"):
+		return fmt.Errorf("%w: share is full, cannot write %s", ErrInsufficientStorage, remotePath)
+	default:
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+}
+
+// uploadStreamViaSmbClient uploads the content of a reader using "put -", without a local file
+// The reader can only be consumed once, so the put is not retried and an overwrite that hits a
+// name collision is reported as a conflict rather than deleted and retried.
+func uploadStreamViaSmbClient(
+	ctx context.Context, content io.Reader, remotePath string, cfg *config.SMBConfig, overwrite bool,
+) error {
+	// Refuse to put a file over an existing directory - smbclient fails with an obscure error otherwise.
+	// Without overwrite the caller's checkUploadTarget has already refused one.
+	// The same listing tells whether the target already existed, so a failed put never deletes a
+	// file it was overwriting.
+	cleanupOnFailure, err := probeUploadTarget(ctx, remotePath, cfg, overwrite)
+	if err != nil {
+		return err
+	}
+
+	// Ensure parent directories exist by creating them first, unless auto-creation is disabled
+	if !cfg.DisableAutoMkdir {
+		if err := ensureParentDirectory(ctx, remotePath, cfg); err != nil {
+			return err
+		}
+	}
+
+	args, env, err := buildSmbClientArgs(cfg, fmt.Sprintf(`put - "%s"`, remotePath))
+	if err != nil {
+		return err
+	}
+
+	// Hash the content on its way through when the upload is to be verified
+	hash := sha256.New()
+	if cfg.VerifyUpload {
+		content = io.TeeReader(content, hash)
+	}
+
+	output, err := executeSmbClientWithStdin(ctx, args, env, content, cfg)
+	if err != nil {
+		if cleanupOnFailure && !strings.Contains(output, "NT_STATUS_OBJECT_NAME_COLLISION") {
+			removePartialUpload(ctx, remotePath, cfg)
+		}
+		return putError(output, remotePath, cfg, err)
+	}
+
+	if cfg.VerifyUpload {
+		return verifyRemoteChecksum(ctx, remotePath, hex.EncodeToString(hash.Sum(nil)), cfg)
+	}
+
+	return nil
+}
+
+// deleteForOverwrite removes the existing file at remotePath so an overwriting put can be retried
+// A file that has disappeared in the meantime is not an error.
+func deleteForOverwrite(ctx context.Context, remotePath string, cfg *config.SMBConfig) error {
+	logger.InfoContext(ctx, "Deleting existing %s before overwriting it", remotePath)
+
+	args, env, err := buildSmbClientArgs(cfg, fmt.Sprintf(`del "%s"`, remotePath))
+	if err != nil {
+		return err
+	}
+
+	retry := cfg.RetryFor(config.RetryUpload)
+	output, err := executeWithRetry(ctx, "Delete file before overwrite", retry, func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+	if err == nil ||
+		strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
+		strings.Contains(output, "NT_STATUS_NO_SUCH_FILE") {
+		return nil
+	}
+	if strings.Contains(output, "NT_STATUS_ACCESS_DENIED") {
+		return fmt.Errorf("%w: cannot replace %s", ErrAccessDenied, remotePath)
+	}
+	return fmt.Errorf("failed to delete existing file before overwrite: %w", err)
+}
+
+// FileSHA256 returns the hex-encoded SHA-256 digest of a local file
+func FileSHA256(localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyRemoteChecksum downloads the uploaded file back and compares its SHA-256 with localSum
+// remotePath is the full path on the share, including any base path
+func verifyRemoteChecksum(ctx context.Context, remotePath string, localSum string, cfg *config.SMBConfig) error {
+	tmpFile, err := os.CreateTemp("", "smb-verify-*")
+	if err != nil {
+		return fmt.Errorf("upload verification failed: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	tmpFile.Close()
+	defer os.Remove(tmpPath)
+
+	command := fmt.Sprintf(`lcd "%s"; get "%s" "%s"`, filepath.Dir(tmpPath), remotePath, filepath.Base(tmpPath))
+	args, env, err := buildSmbClientArgs(cfg, command)
+	if err != nil {
+		return err
+	}
+
+	if _, err := executeWithRetry(ctx, "Verify upload", cfg.RetryFor(config.RetryUpload), func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	}); err != nil {
+		return fmt.Errorf("upload verification failed: cannot read back %s: %w", remotePath, err)
+	}
+
+	remoteSum, err := FileSHA256(tmpPath)
+	if err != nil {
+		return fmt.Errorf("upload verification failed: cannot hash downloaded file: %w", err)
+	}
+
+	if remoteSum != localSum {
+		logger.ErrorContext(ctx, "Checksum mismatch after uploading %s: local %s, remote %s", remotePath, localSum, remoteSum)
+		return fmt.Errorf("upload verification failed: checksum mismatch for %s", remotePath)
+	}
+
+	logger.DebugContext(ctx, "Verified upload of %s (sha256 %s)", remotePath, localSum)
+	return nil
+}
+
+// probeUploadTarget lists an upload target once, when the put needs to know about it
+// With checkDirectory set, an existing directory is an ErrIsDirectory error. cleanupOnFailure
+// reports whether cfg.CleanupOnFailedUpload may delete the target after a failed put: only a
+// definite "not found" answer allows it, so an upload errs on the side of keeping a file.
+func probeUploadTarget(
+	ctx context.Context, remotePath string, cfg *config.SMBConfig, checkDirectory bool,
+) (cleanupOnFailure bool, err error) {
+	if !checkDirectory && !cfg.CleanupOnFailedUpload {
+		return false, nil
+	}
+
+	args, env, err := buildSmbClientArgs(cfg, fmt.Sprintf(`ls "%s"`, remotePath))
+	if err != nil {
+		return false, nil
+	}
+
+	retry := cfg.RetryFor(config.RetryUpload)
+	output, lsErr := executeWithRetry(ctx, "Check upload target", retry, func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+	if lsErr != nil {
+		return cfg.CleanupOnFailedUpload && isNotFoundOutput(output), nil
+	}
+	if checkDirectory && lsOutputHasDirectory(output, remotePath) {
+		return false, fmt.Errorf("remote path %w: %s", ErrIsDirectory, remotePath)
+	}
+	return false, nil
+}
+
+// isNotFoundOutput reports whether failed ls output says the path does not exist
+func isNotFoundOutput(output string) bool {
+	return strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") ||
+		strings.Contains(output, "NT_STATUS_OBJECT_PATH_NOT_FOUND") ||
+		strings.Contains(output, "NT_STATUS_NO_SUCH_FILE")
+}
+
+// removePartialUpload deletes a file left behind by a failed put
+// Failures are logged rather than returned so the original upload error is reported
+func removePartialUpload(ctx context.Context, remotePath string, cfg *config.SMBConfig) {
+	args, env, err := buildSmbClientArgs(cfg, fmt.Sprintf(`del "%s"`, remotePath))
+	if err != nil {
+		return
+	}
+
+	retry := cfg.RetryFor(config.RetryUpload)
+	output, err := executeWithRetry(ctx, "Remove partial upload", retry, func() (string, error) {
+		return executeSmbClient(ctx, args, env, cfg)
+	})
+	if err != nil {
+		// Nothing to clean up if the put failed before creating the file
+		if !strings.Contains(output, "NT_STATUS_OBJECT_NAME_NOT_FOUND") &&
+			!strings.Contains(output, "NT_STATUS_NO_SUCH_FILE") {
+			logger.WarnContext(ctx, "Failed to remove partial upload %s: %v", remotePath, err)
+		}
+		return
+	}
+	logger.InfoContext(ctx, "Removed partial upload %s after failed put", remotePath)
+}
+
+// lsOutputHasDirectory reports whether ls output contains a directory entry for remotePath
+// smbclient treats the ls argument as a mask, so listing an existing directory
+// returns a single entry for the directory itself with the D attribute set
+func lsOutputHasDirectory(output string, remotePath string) bool {
+	name := path.Base(remotePath)
+	for _, entry := range parseLsOutput(output) {
+		// SMB names are case-insensitive: "Reports" collides with an existing "reports"
+		if entry.IsDir && strings.EqualFold(entry.Name, name) {
+			return true
+		}
+	}
+	return false
+}
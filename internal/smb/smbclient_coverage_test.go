@@ -1,6 +1,8 @@
 package smb
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -45,13 +47,13 @@ func TestExecuteWithEnvAndLogging_WithLogging(t *testing.T) {
 	}
 
 	// Test with invalid command and logging enabled
-	_, err := executor.ExecuteWithEnvAndLogging([]string{"--invalid-flag-test"}, env, true)
+	_, err := executor.ExecuteWithEnvAndLogging(context.Background(), []string{"--invalid-flag-test"}, env, true)
 	if err == nil {
 		t.Error("Expected error for invalid command")
 	}
 
 	// Test with logging disabled
-	_, err = executor.ExecuteWithEnvAndLogging([]string{"--invalid-flag-test"}, env, false)
+	_, err = executor.ExecuteWithEnvAndLogging(context.Background(), []string{"--invalid-flag-test"}, env, false)
 	if err == nil {
 		t.Error("Expected error for invalid command")
 	}
@@ -62,7 +64,7 @@ func TestExecuteWithEnvAndLogging_NilEnv(t *testing.T) {
 	executor := &DefaultSmbClientExecutor{}
 
 	// Test with nil env and logging enabled
-	_, err := executor.ExecuteWithEnvAndLogging([]string{"--invalid-flag-test"}, nil, true)
+	_, err := executor.ExecuteWithEnvAndLogging(context.Background(), []string{"--invalid-flag-test"}, nil, true)
 	if err == nil {
 		t.Error("Expected error for invalid command")
 	}
@@ -222,6 +224,95 @@ func TestTestBasePath_AccessDenied(t *testing.T) {
 	}
 }
 
+// newMissingBasePathMock returns a share without the base path, where a plain cd of a missing
+// directory fails with NT_STATUS_OBJECT_PATH_NOT_FOUND as smbclient reports it
+func newMissingBasePathMock(denied string) *MockSmbClientExecutor {
+	tree := newDirectoryTreeMock(nil, nil, denied)
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		output, err := tree.ExecuteFunc(args)
+		if cmd := args[len(args)-1]; err != nil && strings.HasPrefix(cmd, "cd ") && !strings.Contains(cmd, ";") {
+			return "NT_STATUS_OBJECT_PATH_NOT_FOUND " + cmd, err
+		}
+		return output, err
+	}
+	return mock
+}
+
+// TestTestBasePath_CreateBasePath tests that SMB_CREATE_BASE_PATH creates a missing base path once
+func TestTestBasePath_CreateBasePath(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+	createdBasePaths.Clear()
+	defer createdBasePaths.Clear()
+
+	mock := newMissingBasePathMock("")
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{
+		ServerName:     "testserver",
+		ServerIP:       "127.0.0.1",
+		ShareName:      "data",
+		Username:       "testuser",
+		Password:       "testpass",
+		BasePath:       "apps/myapp",
+		AuthProtocol:   "ntlm",
+		CreateBasePath: true,
+	}
+
+	if err := testBasePath(cfg); err != nil {
+		t.Fatalf("Expected the missing base path to be created, got: %v", err)
+	}
+	expected := `mkdir "apps"; pwd; mkdir "apps/myapp"; pwd; cd "apps/myapp"; pwd`
+	if got := mock.LastArgs[len(mock.LastArgs)-1]; got != expected {
+		t.Errorf("Expected command %q, got %q", expected, got)
+	}
+
+	// Later checks find the base path without creating it again
+	if err := testBasePath(cfg); err != nil {
+		t.Fatalf("Expected the created base path to be found, got: %v", err)
+	}
+	if got := mock.LastArgs[len(mock.LastArgs)-1]; got != `cd "apps/myapp"` {
+		t.Errorf("Expected only a cd once the base path exists, got %q", got)
+	}
+
+	// Without the option a missing base path is still an error
+	smbClientExec = newMissingBasePathMock("")
+	cfg.CreateBasePath = false
+	if err := testBasePath(cfg); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected 'does not exist' error without SMB_CREATE_BASE_PATH, got: %v", err)
+	}
+}
+
+// TestTestBasePath_CreateBasePathAccessDenied tests that a base path that cannot be created is an error
+func TestTestBasePath_CreateBasePathAccessDenied(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+	createdBasePaths.Clear()
+	defer createdBasePaths.Clear()
+
+	smbClientExec = newMissingBasePathMock("apps")
+
+	cfg := &config.SMBConfig{
+		ServerName:     "testserver",
+		ServerIP:       "127.0.0.1",
+		ShareName:      "data",
+		Username:       "testuser",
+		Password:       "testpass",
+		BasePath:       "apps/myapp",
+		AuthProtocol:   "ntlm",
+		CreateBasePath: true,
+	}
+
+	err := testBasePath(cfg)
+	if !errors.Is(err, ErrAccessDenied) || !strings.Contains(err.Error(), "could not be created") {
+		t.Errorf("Expected an access denied error creating the base path, got: %v", err)
+	}
+	if _, ok := createdBasePaths.Load("//127.0.0.1:0/data/apps/myapp"); ok {
+		t.Error("Expected a base path that could not be created not to be remembered")
+	}
+}
+
 // TestTestBasePath_GenericError tests testBasePath with generic error
 func TestTestBasePath_GenericError(t *testing.T) {
 	// Save and restore executor
@@ -409,7 +500,7 @@ func TestUploadFileViaSmbClient_ObjectNameCollision(t *testing.T) {
 		AuthProtocol: "ntlm",
 	}
 
-	err = uploadFileViaSmbClient(tmpFile, "existing/file.txt", cfg)
+	err = uploadFileViaSmbClient(context.Background(), tmpFile, "existing/file.txt", cfg, false)
 	if err == nil {
 		t.Error("Expected error for file already exists")
 	}
@@ -418,6 +509,101 @@ func TestUploadFileViaSmbClient_ObjectNameCollision(t *testing.T) {
 	}
 }
 
+// TestUploadFileViaSmbClient_OverwriteCollision tests that an overwrite deletes the existing file and retries the put
+func TestUploadFileViaSmbClient_OverwriteCollision(t *testing.T) {
+	// Save and restore executor
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	tmpFile := filepath.Join(t.TempDir(), "test-overwrite.txt")
+	if err := os.WriteFile(tmpFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name          string
+		overwrite     bool
+		alwaysCollide bool
+		wantCommands  []string
+		wantErr       string
+	}{
+		{
+			name:         "overwrite deletes then retries",
+			overwrite:    true,
+			wantCommands: []string{"put", "del", "put"},
+		},
+		{
+			name:          "retry is attempted once",
+			overwrite:     true,
+			alwaysCollide: true,
+			wantCommands:  []string{"put", "del", "put"},
+			wantErr:       "remote file already exists: data/file.txt",
+		},
+		{
+			name:         "no overwrite reports the conflict",
+			overwrite:    false,
+			wantCommands: []string{"put"},
+			wantErr:      "remote file already exists: data/file.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var commands []string
+			deleted := false
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				cmd := args[len(args)-1]
+				switch {
+				case strings.HasPrefix(cmd, "del "):
+					commands = append(commands, "del")
+					if cmd != `del "data/file.txt"` {
+						t.Errorf("Unexpected delete command: %s", cmd)
+					}
+					deleted = true
+					return "", nil
+				case strings.Contains(cmd, "put "):
+					commands = append(commands, "put")
+					if !deleted || tt.alwaysCollide {
+						return "NT_STATUS_OBJECT_NAME_COLLISION opening remote file \\data\\file.txt",
+							fmt.Errorf("exit status 1")
+					}
+					return "putting file", nil
+				case strings.HasPrefix(cmd, "ls"):
+					return "  file.txt                            A       12  Mon Jan  1 12:00:00 2024\n", nil
+				}
+				return "", nil
+			}
+			smbClientExec = mock
+
+			cfg := &config.SMBConfig{
+				ServerName:       "testserver",
+				ServerIP:         "127.0.0.1",
+				ShareName:        "testshare",
+				Username:         "testuser",
+				Password:         "testpass",
+				AuthProtocol:     "ntlm",
+				DisableAutoMkdir: true,
+			}
+
+			err := uploadFileViaSmbClient(context.Background(), tmpFile, "data/file.txt", cfg, tt.overwrite)
+			if strings.Join(commands, ",") != strings.Join(tt.wantCommands, ",") {
+				t.Errorf("Expected commands %v, got %v", tt.wantCommands, commands)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected upload to succeed, got: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 // TestUploadFileViaSmbClient_AccessDenied tests upload with access denied error
 func TestUploadFileViaSmbClient_AccessDenied(t *testing.T) {
 	// Save and restore executor
@@ -449,7 +635,7 @@ func TestUploadFileViaSmbClient_AccessDenied(t *testing.T) {
 		AuthProtocol: "ntlm",
 	}
 
-	err = uploadFileViaSmbClient(tmpFile, "restricted/file.txt", cfg)
+	err = uploadFileViaSmbClient(context.Background(), tmpFile, "restricted/file.txt", cfg, false)
 	if err == nil {
 		t.Error("Expected error for access denied")
 	}
@@ -458,6 +644,44 @@ func TestUploadFileViaSmbClient_AccessDenied(t *testing.T) {
 	}
 }
 
+// TestUploadFileViaSmbClient_DiskFull tests upload to a full share
+func TestUploadFileViaSmbClient_DiskFull(t *testing.T) {
+	// Save and restore executor
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	// Create a temp file for upload
+	tmpFile := filepath.Join(t.TempDir(), "test-diskfull.txt")
+	if err := os.WriteFile(tmpFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Setup mock that fails the put with disk full
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if strings.Contains(args[len(args)-1], "put") {
+			return "NT_STATUS_DISK_FULL closing remote file \\data\\file.txt", fmt.Errorf("exit status 1")
+		}
+		return "", nil
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		AuthProtocol: "ntlm",
+		MaxRetries:   3,
+	}
+
+	err := uploadFileViaSmbClient(context.Background(), tmpFile, "data/file.txt", cfg, false)
+	if err == nil || !strings.Contains(err.Error(), "insufficient storage") {
+		t.Errorf("Expected 'insufficient storage' error, got: %v", err)
+	}
+}
+
 // TestUploadFileViaSmbClient_ObjectPathNotFound tests upload with path not found error
 func TestUploadFileViaSmbClient_ObjectPathNotFound(t *testing.T) {
 	// Save and restore executor
@@ -489,7 +713,7 @@ func TestUploadFileViaSmbClient_ObjectPathNotFound(t *testing.T) {
 		AuthProtocol: "ntlm",
 	}
 
-	err = uploadFileViaSmbClient(tmpFile, "nonexistent/dir/file.txt", cfg)
+	err = uploadFileViaSmbClient(context.Background(), tmpFile, "nonexistent/dir/file.txt", cfg, false)
 	if err == nil {
 		t.Error("Expected error for path not found")
 	}
@@ -498,6 +722,151 @@ func TestUploadFileViaSmbClient_ObjectPathNotFound(t *testing.T) {
 	}
 }
 
+// TestUploadFileViaSmbClient_AutoMkdir tests that parent directories are only created when enabled
+func TestUploadFileViaSmbClient_AutoMkdir(t *testing.T) {
+	// Save and restore executor
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	tmpFile := filepath.Join(t.TempDir(), "test-automkdir.txt")
+	if err := os.WriteFile(tmpFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		wantErr   string
+		disable   bool
+		dirExists bool
+		wantMkdir bool
+	}{
+		{name: "enabled creates missing directory", disable: false, wantMkdir: true},
+		{name: "disabled with existing directory", disable: true, dirExists: true},
+		{name: "disabled with missing directory", disable: true, wantErr: "parent directory does not exist: reports/2024"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mkdirCalled := false
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				cmd := args[len(args)-1]
+				switch {
+				case strings.HasPrefix(cmd, "mkdir"):
+					mkdirCalled = true
+					return "", nil
+				case strings.Contains(cmd, "put ") && !tt.dirExists && !mkdirCalled:
+					return "NT_STATUS_OBJECT_PATH_NOT_FOUND opening remote file \\reports\\2024\\file.txt",
+						fmt.Errorf("exit status 1")
+				case strings.HasPrefix(cmd, "ls"):
+					return "NT_STATUS_NO_SUCH_FILE listing \\reports\\2024\\file.txt", fmt.Errorf("exit status 1")
+				}
+				return "putting file", nil
+			}
+			smbClientExec = mock
+
+			cfg := &config.SMBConfig{
+				ServerName:       "testserver",
+				ServerIP:         "127.0.0.1",
+				ShareName:        "testshare",
+				Username:         "testuser",
+				Password:         "testpass",
+				AuthProtocol:     "ntlm",
+				DisableAutoMkdir: tt.disable,
+			}
+
+			err := uploadFileViaSmbClient(context.Background(), tmpFile, "reports/2024/file.txt", cfg, false)
+			if mkdirCalled != tt.wantMkdir {
+				t.Errorf("mkdir called = %v, want %v", mkdirCalled, tt.wantMkdir)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected upload to succeed, got: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestUploadFileViaSmbClient_Verify tests that uploads are read back and compared when verification is enabled
+func TestUploadFileViaSmbClient_Verify(t *testing.T) {
+	// Save and restore executor
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	tmpFile := filepath.Join(t.TempDir(), "test-verify.txt")
+	if err := os.WriteFile(tmpFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		stored   string
+		wantErr  string
+		verify   bool
+		wantRead bool
+	}{
+		{name: "disabled does not read back", stored: "corrupted", verify: false},
+		{name: "matching content", stored: "test content", verify: true, wantRead: true},
+		{
+			name:     "mismatched content",
+			stored:   "test c0ntent",
+			verify:   true,
+			wantRead: true,
+			wantErr:  "upload verification failed: checksum mismatch for inbox/file.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readBack := false
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(args []string) (string, error) {
+				cmd := args[len(args)-1]
+				switch {
+				case strings.Contains(cmd, "get "):
+					// Write what the share "stored" to the local file named by the get command
+					readBack = true
+					parts := strings.Split(cmd, `"`)
+					return "getting file", os.WriteFile(filepath.Join(parts[1], parts[5]), []byte(tt.stored), 0644)
+				case strings.HasPrefix(cmd, "ls"):
+					return "NT_STATUS_NO_SUCH_FILE listing \\inbox\\file.txt", fmt.Errorf("exit status 1")
+				}
+				return "putting file", nil
+			}
+			smbClientExec = mock
+
+			cfg := &config.SMBConfig{
+				ServerName:   "testserver",
+				ServerIP:     "127.0.0.1",
+				ShareName:    "testshare",
+				Username:     "testuser",
+				Password:     "testpass",
+				AuthProtocol: "ntlm",
+				VerifyUpload: tt.verify,
+			}
+
+			err := uploadFileViaSmbClient(context.Background(), tmpFile, "inbox/file.txt", cfg, false)
+			if readBack != tt.wantRead {
+				t.Errorf("read back = %v, want %v", readBack, tt.wantRead)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected upload to succeed, got: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Expected error %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 // TestUploadFileViaSmbClient_UnexpectedOutput tests upload with unexpected output
 func TestUploadFileViaSmbClient_UnexpectedOutput(t *testing.T) {
 	// Save and restore executor
@@ -550,7 +919,7 @@ func TestUploadFileViaSmbClient_UnexpectedOutput(t *testing.T) {
 	}
 
 	// Use simple filename (no path) to avoid mkdir call
-	err = uploadFileViaSmbClient(tmpFile, "file.txt", cfg)
+	err = uploadFileViaSmbClient(context.Background(), tmpFile, "file.txt", cfg, false)
 
 	if err == nil {
 		t.Fatal("Expected error for unexpected message, got nil")
@@ -560,6 +929,133 @@ func TestUploadFileViaSmbClient_UnexpectedOutput(t *testing.T) {
 	}
 }
 
+// TestUploadFileViaSmbClient_RemotePathIsDirectory tests upload onto an existing directory
+func TestUploadFileViaSmbClient_RemotePathIsDirectory(t *testing.T) {
+	// Save and restore executor
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	// Create a temp file for upload
+	tmpDir := os.TempDir()
+	tmpFile := filepath.Join(tmpDir, "test-isdir.txt")
+	err := os.WriteFile(tmpFile, []byte("test content"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	// Setup mock where ls reports the target as a directory
+	putIssued := false
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if strings.HasPrefix(cmd, "ls ") {
+			return "  reports                             D        0  Mon Jan  1 00:00:00 2024\n\n" +
+				"\t\t65535 blocks of size 1024. 32768 blocks available\n", nil
+		}
+		if strings.Contains(cmd, "put") {
+			putIssued = true
+		}
+		return "", nil
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		AuthProtocol: "ntlm",
+	}
+
+	err = uploadFileViaSmbClient(context.Background(), tmpFile, "inbox/reports", cfg, true)
+	if err == nil {
+		t.Fatal("Expected error when remote path is a directory")
+	}
+	if err.Error() != "remote path is a directory: inbox/reports" {
+		t.Errorf("Expected 'remote path is a directory' error, got: %v", err)
+	}
+	if putIssued {
+		t.Error("Expected put not to be issued when remote path is a directory")
+	}
+
+	// Without overwrite the caller's checkUploadTarget has already listed the target
+	var commands []string
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		commands = append(commands, args[len(args)-1])
+		return "putting file test-isdir.txt as inbox/report.pdf\n", nil
+	}
+	if err := uploadFileViaSmbClient(context.Background(), tmpFile, "inbox/report.pdf", cfg, false); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd, "ls ") {
+			t.Errorf("Expected no extra listing of the target, got commands: %v", commands)
+		}
+	}
+}
+
+func TestLsOutputHasDirectory(t *testing.T) {
+	output := "  reports                             D        0  Mon Jan  1 00:00:00 2024\n" +
+		"  notes.txt                           A       10  Mon Jan  1 00:00:00 2024\n"
+
+	for remotePath, want := range map[string]bool{
+		"inbox/reports":   true,
+		"inbox/Reports":   true,
+		"inbox/notes.txt": false,
+		"inbox/missing":   false,
+	} {
+		if got := lsOutputHasDirectory(output, remotePath); got != want {
+			t.Errorf("%s: expected %v, got %v", remotePath, want, got)
+		}
+	}
+}
+
+// TestUploadFileViaSmbClient_RemoteFileNotDirectory tests that an existing file is not treated as a directory
+func TestUploadFileViaSmbClient_RemoteFileNotDirectory(t *testing.T) {
+	// Save and restore executor
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	// Create a temp file for upload
+	tmpDir := os.TempDir()
+	tmpFile := filepath.Join(tmpDir, "test-notdir.txt")
+	err := os.WriteFile(tmpFile, []byte("test content"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	// Setup mock where ls reports the target as a regular file
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		if strings.HasPrefix(cmd, "ls ") {
+			return "  report.pdf                          A     1024  Mon Jan  1 00:00:00 2024\n", nil
+		}
+		if strings.Contains(cmd, "put") {
+			return "putting file test-notdir.txt as inbox/report.pdf\n", nil
+		}
+		return "", nil
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ServerIP:     "127.0.0.1",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		AuthProtocol: "ntlm",
+	}
+
+	err = uploadFileViaSmbClient(context.Background(), tmpFile, "inbox/report.pdf", cfg, false)
+	if err != nil {
+		t.Errorf("Expected upload over existing file to proceed, got: %v", err)
+	}
+}
+
 // TestUploadFileViaSmbClient_FileNotFound tests upload with local file not found
 func TestUploadFileViaSmbClient_FileNotFound(t *testing.T) {
 	// Save and restore executor
@@ -575,7 +1071,7 @@ func TestUploadFileViaSmbClient_FileNotFound(t *testing.T) {
 		AuthProtocol: "ntlm",
 	}
 
-	err := uploadFileViaSmbClient("/nonexistent/file.txt", "test/file.txt", cfg)
+	err := uploadFileViaSmbClient(context.Background(), "/nonexistent/file.txt", "test/file.txt", cfg, false)
 	if err == nil {
 		t.Error("Expected error for non-existent local file")
 	}
@@ -624,3 +1120,121 @@ func TestValidateBinaryPath_NonRegularFile(t *testing.T) {
 		t.Error("Expected validation to fail for empty path")
 	}
 }
+
+// newPartialUploadMock simulates a put that fails mid-transfer and records every command
+// The target exists before the upload only when existing is true
+func newPartialUploadMock(commands *[]string, existing bool) *MockSmbClientExecutor {
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		*commands = append(*commands, cmd)
+		switch {
+		case strings.HasPrefix(cmd, "ls "):
+			if existing {
+				return "  file.txt                            A     2048  Mon Jan  1 12:00:00 2024\n", nil
+			}
+			return "NT_STATUS_NO_SUCH_FILE listing \\data\\file.txt", fmt.Errorf("exit status 1")
+		case strings.Contains(cmd, "put "):
+			return "putting file test.txt as \\data\\file.txt\nNT_STATUS_CONNECTION_DISCONNECTED writing remote file",
+				fmt.Errorf("exit status 1")
+		}
+		return "", nil
+	}
+	return mock
+}
+
+// TestUploadFileViaSmbClient_CleanupOnFailure tests that only newly created partial files are removed
+func TestUploadFileViaSmbClient_CleanupOnFailure(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(tmpFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		enabled       bool
+		existing      bool
+		expectCleanup bool
+	}{
+		{name: "new file is removed", enabled: true, existing: false, expectCleanup: true},
+		{name: "overwritten file is kept", enabled: true, existing: true, expectCleanup: false},
+		{name: "disabled by default", enabled: false, existing: false, expectCleanup: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origExec := smbClientExec
+			defer func() { smbClientExec = origExec }()
+
+			var commands []string
+			smbClientExec = newPartialUploadMock(&commands, tt.existing)
+
+			cfg := &config.SMBConfig{
+				ServerName:            "testserver",
+				ServerIP:              "127.0.0.1",
+				ShareName:             "testshare",
+				Username:              "testuser",
+				Password:              "testpass",
+				AuthProtocol:          "ntlm",
+				CleanupOnFailedUpload: tt.enabled,
+			}
+
+			if err := uploadFileViaSmbClient(context.Background(), tmpFile, "data/file.txt", cfg, false); err == nil {
+				t.Fatal("Expected upload error")
+			}
+
+			cleanedUp := false
+			for _, cmd := range commands {
+				if cmd == `del "data/file.txt"` {
+					cleanedUp = true
+				}
+			}
+			if cleanedUp != tt.expectCleanup {
+				t.Errorf("Expected cleanup=%v, got commands: %v", tt.expectCleanup, commands)
+			}
+		})
+	}
+}
+
+// TestUploadFileViaSmbClient_NoCleanupOnCollision tests that a name collision never deletes the existing file
+func TestUploadFileViaSmbClient_NoCleanupOnCollision(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	tmpFile := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(tmpFile, []byte("test content"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	var commands []string
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		cmd := args[len(args)-1]
+		commands = append(commands, cmd)
+		if strings.Contains(cmd, "put ") {
+			return "NT_STATUS_OBJECT_NAME_COLLISION", fmt.Errorf("exit status 1")
+		}
+		return "NT_STATUS_NO_SUCH_FILE", fmt.Errorf("exit status 1")
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{
+		ServerName:            "testserver",
+		ServerIP:              "127.0.0.1",
+		ShareName:             "testshare",
+		Username:              "testuser",
+		Password:              "testpass",
+		AuthProtocol:          "ntlm",
+		CleanupOnFailedUpload: true,
+	}
+
+	err := uploadFileViaSmbClient(context.Background(), tmpFile, "data/file.txt", cfg, false)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected 'already exists' error, got: %v", err)
+	}
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd, "del ") {
+			t.Errorf("Expected no delete after a name collision, got commands: %v", commands)
+		}
+	}
+}
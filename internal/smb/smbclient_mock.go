@@ -2,7 +2,9 @@ package smb
 
 import (
 	"fmt"
+	"io"
 	"strings"
+	"sync"
 )
 
 const mockStatusAccessDenied = "NT_STATUS_ACCESS_DENIED"
@@ -11,16 +13,27 @@ const mockStatusAccessDenied = "NT_STATUS_ACCESS_DENIED"
 type MockSmbClientExecutor struct {
 	// ExecuteFunc allows tests to define custom behavior
 	ExecuteFunc func(args []string) (string, error)
+	// ExecuteWithStdinFunc allows tests to define custom behavior for commands that read stdin
+	ExecuteWithStdinFunc func(args []string, stdin io.Reader) (string, error)
 	// LastArgs stores the last arguments passed to Execute
 	LastArgs []string
 	// CallCount tracks how many times Execute was called
 	CallCount int
+	// mu guards LastArgs and CallCount against commands run concurrently
+	mu sync.Mutex
 }
 
-// Execute runs the mock function
-func (m *MockSmbClientExecutor) Execute(args []string) (string, error) {
+// record notes a call with the given arguments
+func (m *MockSmbClientExecutor) record(args []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.LastArgs = args
 	m.CallCount++
+}
+
+// Execute runs the mock function
+func (m *MockSmbClientExecutor) Execute(args []string) (string, error) {
+	m.record(args)
 
 	if m.ExecuteFunc != nil {
 		return m.ExecuteFunc(args)
@@ -30,6 +43,36 @@ func (m *MockSmbClientExecutor) Execute(args []string) (string, error) {
 	return "", fmt.Errorf("smbclient command failed: exit status 1 (output: Connection to 127.0.0.1 failed)")
 }
 
+// ExecuteWithStdin runs the mock stdin function
+// Without one, stdin is drained and the command is handled like any other by Execute.
+func (m *MockSmbClientExecutor) ExecuteWithStdin(args []string, _ map[string]string, stdin io.Reader) (string, error) {
+	if m.ExecuteWithStdinFunc == nil {
+		if _, err := io.Copy(io.Discard, stdin); err != nil {
+			return "", err
+		}
+		return m.Execute(args)
+	}
+
+	m.record(args)
+	return m.ExecuteWithStdinFunc(args, stdin)
+}
+
+// ExecuteSession runs an interactive session as one "-c" command per round of input through
+// ExecuteFunc, recording a single call for the whole session
+func (m *MockSmbClientExecutor) ExecuteSession(
+	args []string, _ map[string]string, input string, reply func(line string) (string, bool),
+) (string, error) {
+	m.record(args)
+
+	execute := m.ExecuteFunc
+	if execute == nil {
+		execute = func(_ []string) (string, error) {
+			return "", fmt.Errorf("smbclient command failed: exit status 1 (output: Connection to 127.0.0.1 failed)")
+		}
+	}
+	return emulateSession(execute, args, input, reply)
+}
+
 // NewMockExecutor creates a new mock executor with default behavior
 func NewMockExecutor() *MockSmbClientExecutor {
 	return &MockSmbClientExecutor{}
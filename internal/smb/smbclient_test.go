@@ -1,8 +1,18 @@
 package smb
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bancey/document-smbrelay-service/internal/config"
 )
@@ -65,6 +75,96 @@ func TestGetSmbClientPath_EnvironmentVariable(t *testing.T) {
 	}
 }
 
+func TestCheckSmbClientBinary(t *testing.T) {
+	testPath, err := os.Executable()
+	if err != nil {
+		t.Skipf("Cannot locate the test binary: %v", err)
+	}
+
+	t.Setenv("SMBCLIENT_PATH", testPath)
+	if path, err := CheckSmbClientBinary(); err != nil || path != testPath {
+		t.Errorf("Expected %s to be accepted, got %q, %v", testPath, path, err)
+	}
+
+	t.Setenv("SMBCLIENT_PATH", t.TempDir())
+	if _, err := CheckSmbClientBinary(); err == nil || !strings.Contains(err.Error(), "SMBCLIENT_PATH") {
+		t.Errorf("Expected an SMBCLIENT_PATH error for a directory, got %v", err)
+	}
+
+	t.Setenv("SMBCLIENT_PATH", "")
+	t.Setenv("PATH", t.TempDir())
+	path, err := CheckSmbClientBinary()
+	if validateBinaryPath(path) == (err != nil) {
+		t.Errorf("Expected an error exactly when no binary is found, got %q, %v", path, err)
+	}
+}
+
+func TestSmbClientNotInstalled(t *testing.T) {
+	for _, path := range []string{"/usr/bin/smbclient", "/bin/smbclient", "/usr/local/bin/smbclient"} {
+		if validateBinaryPath(path) {
+			t.Skipf("smbclient is installed at %s", path)
+		}
+	}
+
+	resolvedSmbClientPath.once = sync.Once{}
+	defer func() { resolvedSmbClientPath.once = sync.Once{} }()
+	t.Setenv("SMBCLIENT_PATH", "/invalid/nonexistent/smbclient")
+	t.Setenv("PATH", t.TempDir())
+
+	origExec := SetExecutor(&DefaultSmbClientExecutor{})
+	defer SetExecutor(origExec)
+
+	cfg := &config.SMBConfig{
+		ServerName: "testserver",
+		ServerIP:   "127.0.0.1",
+		ShareName:  "testshare",
+		Username:   "testuser",
+		Password:   "testpass",
+		MaxRetries: 2,
+	}
+
+	if _, err := CheckSmbClientBinary(); err == nil {
+		t.Error("Expected CheckSmbClientBinary to fail for a nonexistent SMBCLIENT_PATH")
+	}
+
+	_, err := ListFiles("", cfg)
+	if !errors.Is(err, ErrNoSmbClient) {
+		t.Fatalf("Expected ErrNoSmbClient from a listing, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "smbclient binary not found") {
+		t.Errorf("Expected a clear error message, got %q", err.Error())
+	}
+
+	result := CheckHealth(cfg)
+	if result.Status != statusUnhealthy || result.SMBClientAvailable {
+		t.Errorf("Expected an unhealthy result with smbclient_available false, got %+v", result)
+	}
+	if !strings.Contains(result.Error, "smbclient binary not found") {
+		t.Errorf("Expected the health error to name the missing binary, got %q", result.Error)
+	}
+}
+
+func TestDefaultExecutor_MissingBinary(t *testing.T) {
+	executor := &DefaultSmbClientExecutor{BinaryPath: "/invalid/nonexistent/smbclient"}
+
+	if _, err := executor.ExecuteWithStdin([]string{"-c", "ls"}, nil, nil); !errors.Is(err, ErrNoSmbClient) {
+		t.Errorf("Expected ErrNoSmbClient from a command, got %v", err)
+	}
+	reply := func(_ string) (string, bool) { return "", true }
+	if _, err := executor.ExecuteSession(nil, nil, "ls\n", reply); !errors.Is(err, ErrNoSmbClient) {
+		t.Errorf("Expected ErrNoSmbClient from a session, got %v", err)
+	}
+
+	// A binary that starts but fails is reported as a failed command
+	executor.BinaryPath = "/bin/false"
+	if !validateBinaryPath(executor.BinaryPath) {
+		t.Skip("/bin/false is not available")
+	}
+	if _, err := executor.ExecuteWithStdin(nil, nil, nil); err == nil || errors.Is(err, ErrNoSmbClient) {
+		t.Errorf("Expected a failed command, got %v", err)
+	}
+}
+
 func TestGetSmbClientPath_Fallback(t *testing.T) {
 	// Save original env and restore after test
 	origPath := os.Getenv("SMBCLIENT_PATH")
@@ -92,6 +192,27 @@ func TestGetSmbClientPath_Fallback(t *testing.T) {
 	}
 }
 
+func TestCachedSmbClientPath_LooksUpOnce(t *testing.T) {
+	testPath, err := os.Executable()
+	if err != nil {
+		t.Skipf("Cannot determine test executable: %v", err)
+	}
+
+	resolvedSmbClientPath.once = sync.Once{}
+	defer func() { resolvedSmbClientPath.once = sync.Once{} }()
+
+	t.Setenv("SMBCLIENT_PATH", testPath)
+	if got := cachedSmbClientPath(); got != testPath {
+		t.Fatalf("Expected path '%s', got '%s'", testPath, got)
+	}
+
+	// Later changes are not picked up until the process restarts
+	t.Setenv("SMBCLIENT_PATH", "/invalid/nonexistent/path")
+	if got := cachedSmbClientPath(); got != testPath {
+		t.Errorf("Expected cached path '%s', got '%s'", testPath, got)
+	}
+}
+
 func TestDefaultSmbClientExecutor_CustomBinaryPath(t *testing.T) {
 	executor := &DefaultSmbClientExecutor{
 		BinaryPath: "/custom/test/path",
@@ -139,6 +260,167 @@ func TestExecuteWithEnv_EnvironmentVariables(t *testing.T) {
 	}
 }
 
+func TestExecuteWithEnvAndLogging_ContextDeadline(t *testing.T) {
+	sleepPath, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep binary not available")
+	}
+	executor := &DefaultSmbClientExecutor{BinaryPath: sleepPath}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = executor.ExecuteWithEnvAndLogging(ctx, []string{"10"}, nil, false)
+	if err == nil {
+		t.Fatal("Expected an error when the context deadline passes")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the process to be killed at the deadline, took %v", elapsed)
+	}
+}
+
+func TestExecuteSmbClient_Timeout(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	release := make(chan struct{})
+	defer close(release)
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		// Simulate a server that never answers within the deadline
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		return "", nil
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{
+		ServerName:     "testserver",
+		ShareName:      "testshare",
+		Username:       "user",
+		Password:       "pass",
+		Port:           445,
+		CommandTimeout: 50 * time.Millisecond,
+	}
+
+	t.Run("deadline", func(t *testing.T) {
+		start := time.Now()
+		_, err := ListFiles("docs", cfg)
+		if err == nil {
+			t.Fatal("Expected a timeout error")
+		}
+		if !strings.Contains(err.Error(), "smbclient command timed out after 50ms") {
+			t.Errorf("Expected timeout error, got: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected the call to return at the deadline, took %v", elapsed)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := ListFilesWithContext(ctx, "docs", cfg)
+		if err == nil || !strings.Contains(err.Error(), "smbclient command canceled") {
+			t.Errorf("Expected cancellation error, got: %v", err)
+		}
+	})
+}
+
+// concurrencyExecutor records the peak number of smbclient invocations running at once
+type concurrencyExecutor struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func (e *concurrencyExecutor) Execute(_ []string) (string, error) {
+	e.mu.Lock()
+	e.running++
+	e.peak = max(e.peak, e.running)
+	e.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	e.mu.Lock()
+	e.running--
+	e.mu.Unlock()
+	return "", nil
+}
+
+func (e *concurrencyExecutor) ExecuteWithStdin(args []string, _ map[string]string, _ io.Reader) (string, error) {
+	return e.Execute(args)
+}
+
+func TestExecuteSmbClient_ConcurrencyLimit(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	const limit = 3
+	executor := &concurrencyExecutor{}
+	smbClientExec = executor
+
+	cfg := &config.SMBConfig{MaxConcurrent: limit}
+
+	var wg sync.WaitGroup
+	for i := 0; i < limit*4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := executeSmbClient(context.Background(), []string{"-c", "ls"}, nil, cfg); err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if executor.peak > limit {
+		t.Errorf("Expected at most %d concurrent smbclient processes, peak was %d", limit, executor.peak)
+	}
+	if executor.peak < 2 {
+		t.Errorf("Expected commands to run concurrently up to the limit, peak was %d", executor.peak)
+	}
+}
+
+func TestExecuteSmbClient_ConcurrencyLimitCanceled(t *testing.T) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{MaxConcurrent: 1}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = executeSmbClient(context.Background(), []string{"-c", "ls"}, nil, cfg)
+	}()
+	<-started
+
+	// The only slot is taken, so the second command waits until its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := executeSmbClient(ctx, []string{"-c", "ls"}, nil, cfg)
+	if err == nil || !strings.Contains(err.Error(), "waiting for a free slot") {
+		t.Errorf("Expected to give up waiting for a slot, got: %v", err)
+	}
+
+	close(release)
+	<-done
+}
+
 func TestSanitizeArgsForLogging(t *testing.T) {
 	tests := []struct {
 		env        map[string]string
@@ -422,3 +704,489 @@ func TestBuildSmbClientArgs_DFSHostname(t *testing.T) {
 		t.Logf("Args: %v", args)
 	}
 }
+
+func TestBuildSmbClientArgs_PasswordIsNTHash(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability is more important than memory optimization
+	tests := []struct {
+		name        string
+		password    string
+		isNTHash    bool
+		expectFlag  bool
+		expectError bool
+	}{
+		{name: "plaintext password", password: "testpass", isNTHash: false},
+		{name: "valid hash", password: "8846F7EAEE8FB117AD06BDD830B7586C", isNTHash: true, expectFlag: true},
+		{name: "lowercase hash", password: "8846f7eaee8fb117ad06bdd830b7586c", isNTHash: true, expectFlag: true},
+		{name: "too short", password: "8846f7eaee8fb117", isNTHash: true, expectError: true},
+		{name: "not hex", password: "zz46f7eaee8fb117ad06bdd830b7586c", isNTHash: true, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.SMBConfig{
+				ServerName:       "testserver",
+				ServerIP:         "192.168.1.100",
+				ShareName:        "testshare",
+				Username:         "testuser",
+				Password:         tt.password,
+				PasswordIsNTHash: tt.isNTHash,
+				Port:             445,
+				AuthProtocol:     "ntlm",
+			}
+
+			args, env, err := buildSmbClientArgs(cfg, "ls")
+			if tt.expectError {
+				if err == nil || !strings.Contains(err.Error(), "invalid NT hash") {
+					t.Errorf("Expected invalid NT hash error, got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildSmbClientArgs failed: %v", err)
+			}
+
+			hasFlag := false
+			for _, arg := range args {
+				if arg == "--pw-nt-hash" {
+					hasFlag = true
+				}
+			}
+			if hasFlag != tt.expectFlag {
+				t.Errorf("Expected --pw-nt-hash present=%v, got args: %v", tt.expectFlag, args)
+			}
+			if env["PASSWD"] != tt.password {
+				t.Errorf("Expected PASSWD to carry the configured password")
+			}
+		})
+	}
+}
+
+func TestListFiles_GuestAccess(t *testing.T) {
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return "  readme.txt                          A     1024  Mon Jan  1 10:00:00 2024\n", nil
+	}
+	origExec := SetExecutor(mock)
+	defer SetExecutor(origExec)
+
+	cfg := &config.SMBConfig{
+		ServerName:   "publicnas",
+		ShareName:    "public",
+		Port:         445,
+		AuthProtocol: "guest",
+	}
+
+	files, err := ListFiles("", cfg)
+	if err != nil {
+		t.Fatalf("Expected a guest listing to succeed, got: %v", err)
+	}
+	if len(files) != 1 || files[0].Name != "readme.txt" {
+		t.Errorf("Expected readme.txt to be listed, got %+v", files)
+	}
+
+	args := strings.Join(mock.LastArgs, " ")
+	if !strings.Contains(args, "-U guest -N") {
+		t.Errorf("Expected guest user and -N in args, got: %v", mock.LastArgs)
+	}
+
+	_, env, err := buildSmbClientArgs(cfg, "ls")
+	if err != nil {
+		t.Fatalf("buildSmbClientArgs failed: %v", err)
+	}
+	if _, ok := env["PASSWD"]; ok {
+		t.Error("Expected no PASSWD for guest access")
+	}
+
+	cfg.Username = "visitor"
+	args2, _, err := buildSmbClientArgs(cfg, "ls")
+	if err != nil || !strings.Contains(strings.Join(args2, " "), "-U visitor -N") {
+		t.Errorf("Expected a configured guest user to be used, got %v, %v", args2, err)
+	}
+}
+
+func TestBuildSmbClientArgs_AllowSMB1(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	for _, allow := range []bool{false, true} {
+		smb1Warning = sync.Once{}
+		buf.Reset()
+
+		cfg := &config.SMBConfig{
+			ServerName:   "legacynas",
+			ShareName:    "testshare",
+			Username:     "testuser",
+			Password:     "testpass",
+			Port:         445,
+			AuthProtocol: "ntlm",
+			AllowSMB1:    allow,
+		}
+
+		args, _, err := buildSmbClientArgs(cfg, "ls")
+		if err != nil {
+			t.Fatalf("buildSmbClientArgs failed: %v", err)
+		}
+
+		hasOption := false
+		for _, arg := range args {
+			if arg == "--option=client min protocol=NT1" {
+				hasOption = true
+			}
+		}
+		if hasOption != allow {
+			t.Errorf("AllowSMB1=%v: expected NT1 option present=%v, got args: %v", allow, allow, args)
+		}
+
+		warned := strings.Contains(buf.String(), "SMB1 is deprecated")
+		if warned != allow {
+			t.Errorf("AllowSMB1=%v: expected deprecation warning present=%v, got log: %q", allow, allow, buf.String())
+		}
+	}
+}
+
+func TestBuildSmbClientArgs_ExtraArgs(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	extraArgsWarning = sync.Once{}
+
+	cfg := &config.SMBConfig{
+		ServerName:   "testserver",
+		ShareName:    "testshare",
+		Username:     "testuser",
+		Password:     "testpass",
+		Port:         445,
+		AuthProtocol: "ntlm",
+		ExtraArgs:    []string{"--socket-options=TCP_NODELAY IPTOS_LOWDELAY", "-e", "-t", "60"},
+	}
+
+	args, _, err := buildSmbClientArgs(cfg, "ls")
+	if err != nil {
+		t.Fatalf("buildSmbClientArgs failed: %v", err)
+	}
+	tail := args[len(args)-6:]
+	expected := []string{"--socket-options=TCP_NODELAY IPTOS_LOWDELAY", "-e", "-t", "60", "-c", "ls"}
+	if strings.Join(tail, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected the extra args in order before the command, got args: %v", args)
+	}
+	if !strings.Contains(buf.String(), "SMB_EXTRA_ARGS passes") {
+		t.Errorf("Expected a warning about unvalidated arguments, got log: %q", buf.String())
+	}
+
+	for _, injected := range []string{"-c", "-Nc", "--command=rm *"} {
+		cfg.ExtraArgs = []string{"-e", injected}
+		if _, _, err := buildSmbClientArgs(cfg, "ls"); !errors.Is(err, config.ErrCommandArg) {
+			t.Errorf("Expected %q to be rejected, got: %v", injected, err)
+		}
+	}
+}
+
+func TestBuildSmbClientArgs_ProtocolPinning(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name        string
+		minProtocol string
+		maxProtocol string
+		allowSMB1   bool
+		expected    []string
+	}{
+		{"unpinned", "", "", false, nil},
+		{"minimum only", "SMB3", "", false, []string{"--option=client min protocol=SMB3"}},
+		{"maximum only", "", "SMB2", false, []string{"--option=client max protocol=SMB2"}},
+		{"range", "SMB2", "SMB3", false,
+			[]string{"--option=client min protocol=SMB2", "--option=client max protocol=SMB3"}},
+		{"exact dialect", "SMB3", "SMB3", false,
+			[]string{"--option=client min protocol=SMB3", "--option=client max protocol=SMB3"}},
+		{"SMB1 only", "", "NT1", false,
+			[]string{"--option=client min protocol=NT1", "--option=client max protocol=NT1"}},
+		{"allow SMB1 with maximum", "", "SMB2", true,
+			[]string{"--option=client min protocol=NT1", "--option=client max protocol=SMB2"}},
+		{"minimum overrides allow SMB1", "SMB2", "", true, []string{"--option=client min protocol=SMB2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.SMBConfig{
+				ServerName:   "fileserver",
+				ShareName:    "testshare",
+				Username:     "testuser",
+				Password:     "testpass",
+				Port:         445,
+				AuthProtocol: "ntlm",
+				AllowSMB1:    tt.allowSMB1,
+				MinProtocol:  tt.minProtocol,
+				MaxProtocol:  tt.maxProtocol,
+			}
+
+			args, _, err := buildSmbClientArgs(cfg, "ls")
+			if err != nil {
+				t.Fatalf("buildSmbClientArgs failed: %v", err)
+			}
+
+			var options []string
+			for _, arg := range args {
+				if strings.Contains(arg, " protocol=") {
+					options = append(options, arg)
+				}
+			}
+			if strings.Join(options, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Expected protocol options %v, got %v", tt.expected, options)
+			}
+		})
+	}
+}
+
+func TestBuildSmbClientArgs_SigningAndEncryption(t *testing.T) {
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name              string
+		requireSigning    bool
+		requireEncryption bool
+		expected          []string
+	}{
+		{"default", false, false, nil},
+		{"signing", true, false, []string{"--option=client signing=required"}},
+		{"encryption", false, true, []string{"--option=client smb encrypt=required"}},
+		{"both", true, true, []string{"--option=client signing=required", "--option=client smb encrypt=required"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.SMBConfig{
+				ServerName:        "fileserver",
+				ShareName:         "testshare",
+				Username:          "testuser",
+				Password:          "testpass",
+				Port:              445,
+				AuthProtocol:      "ntlm",
+				RequireSigning:    tt.requireSigning,
+				RequireEncryption: tt.requireEncryption,
+			}
+
+			args, _, err := buildSmbClientArgs(cfg, "ls")
+			if err != nil {
+				t.Fatalf("buildSmbClientArgs failed: %v", err)
+			}
+
+			var options []string
+			for _, arg := range args {
+				if strings.Contains(arg, "signing=") || strings.Contains(arg, "encrypt=") {
+					options = append(options, arg)
+				}
+			}
+			if strings.Join(options, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Expected security options %v, got %v", tt.expected, options)
+			}
+		})
+	}
+}
+
+func TestExecuteSmbClient_SecurityRefused(t *testing.T) {
+	origExec := SetExecutor(NewMockExecutor())
+	defer SetExecutor(origExec)
+
+	//nolint:govet // fieldalignment: test struct readability over memory optimization
+	tests := []struct {
+		name              string
+		output            string
+		requireSigning    bool
+		requireEncryption bool
+		expected          string
+		refused           bool
+	}{
+		{
+			name:              "encryption",
+			output:            "Encryption required and server doesn't support SMB3 encryption - failing connect\n",
+			requireEncryption: true,
+			expected: "SMB server refused the required encryption (SMB_REQUIRE_ENCRYPTION is enabled): " +
+				"Encryption required and server doesn't support SMB3 encryption - failing connect",
+			refused: true,
+		},
+		{
+			name:           "signing",
+			output:         "protocol negotiation failed: NT_STATUS_ACCESS_DENIED\n",
+			requireSigning: true,
+			expected: "SMB server refused the required signing (SMB_REQUIRE_SIGNING is enabled): " +
+				"protocol negotiation failed: NT_STATUS_ACCESS_DENIED",
+			refused: true,
+		},
+		{
+			name:     "not required",
+			output:   "protocol negotiation failed: NT_STATUS_ACCESS_DENIED\n",
+			expected: "access denied to path: docs",
+		},
+		{
+			// A command failing on a file whose name mentions encryption or signing is no refusal
+			name:              "file named like a refusal",
+			output:            "NT_STATUS_OBJECT_NAME_NOT_FOUND listing \\docs\\encrypted-signing-keys\\*\n",
+			requireSigning:    true,
+			requireEncryption: true,
+			expected:          "path not found: docs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockExecutor()
+			mock.ExecuteFunc = func(_ []string) (string, error) {
+				return tt.output, fmt.Errorf("smbclient command failed: exit status 1")
+			}
+			SetExecutor(mock)
+
+			cfg := &config.SMBConfig{
+				ServerName:        "fileserver",
+				ShareName:         "testshare",
+				Username:          "testuser",
+				Password:          "testpass",
+				Port:              445,
+				RequireSigning:    tt.requireSigning,
+				RequireEncryption: tt.requireEncryption,
+			}
+
+			_, err := ListFiles("docs", cfg)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+			if errors.Is(err, ErrSecurityRefused) != tt.refused {
+				t.Errorf("Expected errors.Is(err, ErrSecurityRefused) = %v, got: %v", tt.refused, err)
+			}
+			if mock.CallCount != 1 {
+				t.Errorf("Expected the refusal not to be retried, got %d calls", mock.CallCount)
+			}
+		})
+	}
+}
+
+func TestParseClientVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+		wantErr  bool
+	}{
+		{name: "distribution build", output: "Version 4.19.5-Ubuntu\n", expected: "4.19.5-Ubuntu"},
+		{name: "plain version", output: "Version 4.20.1", expected: "4.20.1"},
+		{name: "leading noise", output: "lp_load_ex: refreshing parameters\nVersion 4.18.0\n", expected: "4.18.0"},
+		{name: "unexpected output", output: "smbclient: command not found", wantErr: true},
+		{name: "empty output", output: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := parseClientVersion(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wantErr=%v, got %v", tt.wantErr, err)
+			}
+			if version != tt.expected {
+				t.Errorf("Expected version %q, got %q", tt.expected, version)
+			}
+		})
+	}
+}
+
+func TestClientVersion_CachedAfterDetection(t *testing.T) {
+	origExec := smbClientExec
+	defer func() {
+		smbClientExec = origExec
+		clientVersion.reset()
+	}()
+
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		if len(args) != 1 || args[0] != "--version" {
+			t.Errorf("Expected only --version, got %v", args)
+		}
+		return "Version 4.19.5-Ubuntu\n", nil
+	}
+	smbClientExec = mock
+	clientVersion.reset()
+
+	for i := 0; i < 3; i++ {
+		version, err := ClientVersion()
+		if err != nil || version != "4.19.5-Ubuntu" {
+			t.Fatalf("Expected version 4.19.5-Ubuntu, got %q, %v", version, err)
+		}
+	}
+	if mock.CallCount != 1 {
+		t.Errorf("Expected smbclient --version to run once, got %d", mock.CallCount)
+	}
+}
+
+func TestClientVersion_FailureNotCached(t *testing.T) {
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return "", os.ErrNotExist
+	}
+	origExec := SetExecutor(mock)
+	defer SetExecutor(origExec)
+
+	if _, err := ClientVersion(); err == nil {
+		t.Fatal("Expected an error when smbclient cannot run")
+	}
+	if _, err := ClientVersion(); err == nil {
+		t.Fatal("Expected an error when smbclient cannot run")
+	}
+	if mock.CallCount != 2 {
+		t.Errorf("Expected failed detection to be retried, got %d calls", mock.CallCount)
+	}
+}
+
+// benchmarkListOutput is the ls output of a directory holding one file
+const benchmarkListOutput = "  report.pdf                          A     1024  Mon Jan  1 12:00:00 2024\n" +
+	"\n\t\t65535 blocks of size 1024. 1000 blocks available\n"
+
+// BenchmarkListFiles_Sequential lists 100 directories one call at a time, spawning one smbclient per directory
+func BenchmarkListFiles_Sequential(b *testing.B) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(_ []string) (string, error) {
+		return benchmarkListOutput, nil
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{ServerName: "testserver", ShareName: "testshare", Username: "u", Password: "p"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for dir := 0; dir < 100; dir++ {
+			if _, err := ListFiles(fmt.Sprintf("dir%d", dir), cfg); err != nil {
+				b.Fatalf("ListFiles failed: %v", err)
+			}
+		}
+	}
+	b.ReportMetric(float64(mock.CallCount)/float64(b.N), "spawns/op")
+}
+
+// BenchmarkListFilesBatch lists the same 100 directories in a single smbclient session
+func BenchmarkListFilesBatch(b *testing.B) {
+	origExec := smbClientExec
+	defer func() { smbClientExec = origExec }()
+
+	mock := NewMockExecutor()
+	mock.ExecuteFunc = func(args []string) (string, error) {
+		count := strings.Count(args[len(args)-1], "ls ")
+		return strings.Repeat(benchmarkListOutput+"Current directory is \\\\testserver\\testshare\\\n", count), nil
+	}
+	smbClientExec = mock
+
+	cfg := &config.SMBConfig{ServerName: "testserver", ShareName: "testshare", Username: "u", Password: "p"}
+	paths := make([]string, 100)
+	for dir := range paths {
+		paths[dir] = fmt.Sprintf("dir%d", dir)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results, err := ListFilesBatch(paths, cfg)
+		if err != nil || len(results) != len(paths) {
+			b.Fatalf("ListFilesBatch failed: %v", err)
+		}
+	}
+	b.ReportMetric(float64(mock.CallCount)/float64(b.N), "spawns/op")
+}
@@ -2,14 +2,42 @@
 package telemetry
 
 import (
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// Supported values for OTEL_TRACES_SAMPLER
+const (
+	SamplerAlwaysOn                = "always_on"
+	SamplerAlwaysOff               = "always_off"
+	SamplerTraceIDRatio            = "traceidratio"
+	SamplerParentBasedAlwaysOn     = "parentbased_always_on"
+	SamplerParentBasedAlwaysOff    = "parentbased_always_off"
+	SamplerParentBasedTraceIDRatio = "parentbased_traceidratio"
+)
+
+// defaultSamplerRatio is used when OTEL_TRACES_SAMPLER_ARG is unset or invalid
+const defaultSamplerRatio = 1.0
+
+// Batch span processor defaults
+const (
+	defaultBSPScheduleDelay      = 5 * time.Second
+	defaultBSPMaxQueueSize       = 2048
+	defaultBSPMaxExportBatchSize = 512
+)
+
+// defaultMetricExportInterval is how often metrics are pushed to the exporter
+const defaultMetricExportInterval = 60 * time.Second
+
 // Config holds the telemetry configuration
 type Config struct {
 	// OTLPHeaders are additional headers to send with OTLP requests (e.g., for authentication)
 	OTLPHeaders map[string]string
+	// ResourceAttributes are extra attributes attached to all traces and metrics (e.g., environment, region)
+	ResourceAttributes map[string]string
 	// ServiceName is the name of the service (defaults to "document-smbrelay-service")
 	ServiceName string
 	// ServiceVersion is the version of the service
@@ -18,6 +46,18 @@ type Config struct {
 	OTLPEndpoint string
 	// AzureAppInsightsConnectionString is the Application Insights connection string
 	AzureAppInsightsConnectionString string
+	// TracesSampler selects the trace sampler (defaults to "always_on")
+	TracesSampler string
+	// TracesSamplerArg is the sampling ratio used by the ratio-based samplers (0.0 to 1.0)
+	TracesSamplerArg float64
+	// BSPScheduleDelay is the maximum delay between batch span exports
+	BSPScheduleDelay time.Duration
+	// BSPMaxQueueSize is the maximum number of spans buffered before new spans are dropped
+	BSPMaxQueueSize int
+	// BSPMaxExportBatchSize is the maximum number of spans sent in a single export
+	BSPMaxExportBatchSize int
+	// MetricExportInterval is the interval between periodic metric exports
+	MetricExportInterval time.Duration
 	// Enabled determines if telemetry is enabled
 	Enabled bool
 	// TracingEnabled determines if tracing is enabled
@@ -46,18 +86,38 @@ func LoadConfig() *Config {
 
 	// Parse OTLP headers from environment variable
 	// Format: key1=value1,key2=value2
-	headersStr := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
-	headers := make(map[string]string)
-	if headersStr != "" {
-		pairs := strings.Split(headersStr, ",")
-		for _, pair := range pairs {
-			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
-			if len(kv) == 2 {
-				headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
-			}
+	headers := parseKeyValuePairs(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+
+	// Parse resource attributes using the same key=value format, e.g. deployment.environment=prod,region=uksouth
+	// Values may be percent-encoded as per the OTel spec
+	resourceAttributes := parseKeyValuePairs(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	for k, v := range resourceAttributes {
+		if decoded, err := url.PathUnescape(v); err == nil {
+			resourceAttributes[k] = decoded
 		}
 	}
 
+	// Trace sampler selection, following the standard OTEL_TRACES_SAMPLER values
+	tracesSampler := strings.ToLower(strings.TrimSpace(os.Getenv("OTEL_TRACES_SAMPLER")))
+	if tracesSampler == "" {
+		tracesSampler = SamplerAlwaysOn
+	}
+	tracesSamplerArg := parseSamplerArg(os.Getenv("OTEL_TRACES_SAMPLER_ARG"))
+
+	// Batch span processor tuning; the schedule delay is in milliseconds as per the OTel spec
+	bspScheduleDelay := time.Duration(getPositiveIntEnv("OTEL_BSP_SCHEDULE_DELAY",
+		int(defaultBSPScheduleDelay/time.Millisecond))) * time.Millisecond
+	bspMaxQueueSize := getPositiveIntEnv("OTEL_BSP_MAX_QUEUE_SIZE", defaultBSPMaxQueueSize)
+	bspMaxExportBatchSize := getPositiveIntEnv("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", defaultBSPMaxExportBatchSize)
+	if bspMaxExportBatchSize > bspMaxQueueSize {
+		// A batch can never be larger than the queue feeding it
+		bspMaxExportBatchSize = bspMaxQueueSize
+	}
+
+	// Metric export interval, in milliseconds as per the OTel spec
+	metricExportInterval := time.Duration(getPositiveIntEnv("OTEL_METRIC_EXPORT_INTERVAL",
+		int(defaultMetricExportInterval/time.Millisecond))) * time.Millisecond
+
 	// Azure Application Insights connection string
 	appInsightsConnStr := os.Getenv("APPLICATIONINSIGHTS_CONNECTION_STRING")
 
@@ -84,6 +144,56 @@ func LoadConfig() *Config {
 		MetricsEnabled:                   metricsEnabled && enabled,
 		OTLPEndpoint:                     otlpEndpoint,
 		OTLPHeaders:                      headers,
+		ResourceAttributes:               resourceAttributes,
 		AzureAppInsightsConnectionString: appInsightsConnStr,
+		TracesSampler:                    tracesSampler,
+		TracesSamplerArg:                 tracesSamplerArg,
+		BSPScheduleDelay:                 bspScheduleDelay,
+		BSPMaxQueueSize:                  bspMaxQueueSize,
+		BSPMaxExportBatchSize:            bspMaxExportBatchSize,
+		MetricExportInterval:             metricExportInterval,
+	}
+}
+
+// parseKeyValuePairs parses a comma-separated list of key=value pairs
+// Entries without an "=" or with an empty key are ignored
+func parseKeyValuePairs(value string) map[string]string {
+	pairs := make(map[string]string)
+	if value == "" {
+		return pairs
+	}
+	for _, pair := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) != "" {
+			pairs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return pairs
+}
+
+// getPositiveIntEnv gets a positive integer from an environment variable with a default value
+// Missing, malformed, zero, or negative values use the default
+func getPositiveIntEnv(key string, defaultValue int) int {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	val, err := strconv.Atoi(value)
+	if err != nil || val <= 0 {
+		return defaultValue
+	}
+	return val
+}
+
+// parseSamplerArg parses a sampling ratio, falling back to the default when it is
+// missing, malformed, or outside the range 0.0 to 1.0
+func parseSamplerArg(value string) float64 {
+	if value == "" {
+		return defaultSamplerRatio
+	}
+	ratio, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return defaultSamplerRatio
 	}
+	return ratio
 }
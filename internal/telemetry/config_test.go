@@ -3,6 +3,7 @@ package telemetry
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -296,3 +297,192 @@ func TestLoadConfig_TracingMetricsToggle(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadConfig_TracesSampler(t *testing.T) {
+	tests := []struct {
+		envVars         map[string]string
+		name            string
+		expectedSampler string
+		expectedArg     float64
+	}{
+		{
+			name:            "defaults to always on",
+			envVars:         map[string]string{},
+			expectedSampler: SamplerAlwaysOn,
+			expectedArg:     1.0,
+		},
+		{
+			name: "ratio sampler with argument",
+			envVars: map[string]string{
+				"OTEL_TRACES_SAMPLER":     "traceidratio",
+				"OTEL_TRACES_SAMPLER_ARG": "0.25",
+			},
+			expectedSampler: SamplerTraceIDRatio,
+			expectedArg:     0.25,
+		},
+		{
+			name: "sampler name is case insensitive",
+			envVars: map[string]string{
+				"OTEL_TRACES_SAMPLER": "Always_Off",
+			},
+			expectedSampler: SamplerAlwaysOff,
+			expectedArg:     1.0,
+		},
+		{
+			name: "invalid argument falls back to default",
+			envVars: map[string]string{
+				"OTEL_TRACES_SAMPLER":     "traceidratio",
+				"OTEL_TRACES_SAMPLER_ARG": "half",
+			},
+			expectedSampler: SamplerTraceIDRatio,
+			expectedArg:     1.0,
+		},
+		{
+			name: "out of range argument falls back to default",
+			envVars: map[string]string{
+				"OTEL_TRACES_SAMPLER":     "traceidratio",
+				"OTEL_TRACES_SAMPLER_ARG": "1.5",
+			},
+			expectedSampler: SamplerTraceIDRatio,
+			expectedArg:     1.0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			for k, v := range tt.envVars {
+				os.Setenv(k, v)
+			}
+
+			cfg := LoadConfig()
+
+			if cfg.TracesSampler != tt.expectedSampler {
+				t.Errorf("Expected TracesSampler=%q, got %q", tt.expectedSampler, cfg.TracesSampler)
+			}
+			if cfg.TracesSamplerArg != tt.expectedArg {
+				t.Errorf("Expected TracesSamplerArg=%v, got %v", tt.expectedArg, cfg.TracesSamplerArg)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_BatchSpanProcessor(t *testing.T) {
+	tests := []struct {
+		envVars       map[string]string
+		name          string
+		expectedDelay time.Duration
+		expectedQueue int
+		expectedBatch int
+	}{
+		{
+			name:          "defaults",
+			envVars:       map[string]string{},
+			expectedDelay: 5 * time.Second,
+			expectedQueue: 2048,
+			expectedBatch: 512,
+		},
+		{
+			name: "custom values",
+			envVars: map[string]string{
+				"OTEL_BSP_SCHEDULE_DELAY":        "1000",
+				"OTEL_BSP_MAX_QUEUE_SIZE":        "8192",
+				"OTEL_BSP_MAX_EXPORT_BATCH_SIZE": "1024",
+			},
+			expectedDelay: time.Second,
+			expectedQueue: 8192,
+			expectedBatch: 1024,
+		},
+		{
+			name: "invalid values use defaults",
+			envVars: map[string]string{
+				"OTEL_BSP_SCHEDULE_DELAY":        "soon",
+				"OTEL_BSP_MAX_QUEUE_SIZE":        "-1",
+				"OTEL_BSP_MAX_EXPORT_BATCH_SIZE": "0",
+			},
+			expectedDelay: 5 * time.Second,
+			expectedQueue: 2048,
+			expectedBatch: 512,
+		},
+		{
+			name: "batch size capped at queue size",
+			envVars: map[string]string{
+				"OTEL_BSP_MAX_QUEUE_SIZE":        "100",
+				"OTEL_BSP_MAX_EXPORT_BATCH_SIZE": "500",
+			},
+			expectedDelay: 5 * time.Second,
+			expectedQueue: 100,
+			expectedBatch: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			for k, v := range tt.envVars {
+				os.Setenv(k, v)
+			}
+
+			cfg := LoadConfig()
+
+			if cfg.BSPScheduleDelay != tt.expectedDelay {
+				t.Errorf("Expected BSPScheduleDelay=%v, got %v", tt.expectedDelay, cfg.BSPScheduleDelay)
+			}
+			if cfg.BSPMaxQueueSize != tt.expectedQueue {
+				t.Errorf("Expected BSPMaxQueueSize=%d, got %d", tt.expectedQueue, cfg.BSPMaxQueueSize)
+			}
+			if cfg.BSPMaxExportBatchSize != tt.expectedBatch {
+				t.Errorf("Expected BSPMaxExportBatchSize=%d, got %d", tt.expectedBatch, cfg.BSPMaxExportBatchSize)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_MetricExportInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "default", value: "", expected: 60 * time.Second},
+		{name: "custom", value: "15000", expected: 15 * time.Second},
+		{name: "zero uses default", value: "0", expected: 60 * time.Second},
+		{name: "negative uses default", value: "-100", expected: 60 * time.Second},
+		{name: "invalid uses default", value: "1m", expected: 60 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			if tt.value != "" {
+				os.Setenv("OTEL_METRIC_EXPORT_INTERVAL", tt.value)
+			}
+
+			cfg := LoadConfig()
+			if cfg.MetricExportInterval != tt.expected {
+				t.Errorf("Expected MetricExportInterval=%v, got %v", tt.expected, cfg.MetricExportInterval)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ResourceAttributes(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=prod, region=uk%20south,invalid,=novalue")
+
+	cfg := LoadConfig()
+
+	expected := map[string]string{
+		"deployment.environment": "prod",
+		"region":                 "uk south",
+	}
+	if len(cfg.ResourceAttributes) != len(expected) {
+		t.Errorf("Expected %d resource attributes, got %d: %v",
+			len(expected), len(cfg.ResourceAttributes), cfg.ResourceAttributes)
+	}
+	for k, v := range expected {
+		if cfg.ResourceAttributes[k] != v {
+			t.Errorf("ResourceAttributes[%q] = %q, want %q", k, cfg.ResourceAttributes[k], v)
+		}
+	}
+}
@@ -63,9 +63,9 @@ func Middleware(serviceName string) fiber.Handler {
 		// 	return c.Next()
 		// }
 
-		// Extract trace context from headers
+		// Extract trace context from headers, keeping values such as the request ID already set
 		ctx := otel.GetTextMapPropagator().Extract(
-			c.Context(),
+			c.UserContext(),
 			propagation.HeaderCarrier(c.GetReqHeaders()),
 		)
 
@@ -0,0 +1,108 @@
+package telemetry
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute is the route label for requests that matched no route, keeping label cardinality bounded
+const unmatchedRoute = "unmatched"
+
+// Prometheus collectors, registered on a private registry served by PrometheusHandler
+// They are updated whether or not /metrics is exposed, alongside the OpenTelemetry instruments.
+var (
+	promRegistry = prometheus.NewRegistry()
+
+	promHTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "smbrelay_http_requests_total",
+		Help: "Total number of HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	promSMBClientDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "smbrelay_smbclient_duration_seconds",
+		Help:    "Duration of smbclient processes in seconds by outcome.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"outcome"})
+
+	promSMBInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "smbrelay_smb_operations_in_flight",
+		Help: "Number of smbclient processes currently running.",
+	})
+)
+
+func init() {
+	promRegistry.MustRegister(
+		promHTTPRequests,
+		promSMBClientDuration,
+		promSMBInFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// PrometheusMiddleware returns a Fiber middleware that counts requests by method, route and status
+// The route is the registered pattern (e.g. /jobs/:id) rather than the raw path.
+func PrometheusMiddleware() fiber.Handler {
+	var (
+		once   sync.Once
+		routes map[string]bool
+	)
+
+	return func(c *fiber.Ctx) error {
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		// Fiber reuses the request buffers, so label values kept by the registry must be copies
+		method := utils.CopyString(c.Method())
+		once.Do(func() { routes = handlerRoutes(c.App()) })
+		label := unmatchedRoute
+		switch {
+		case routes[method+" "+c.Route().Path]:
+			label = c.Route().Path
+		case routes[method+" "+c.Path()]:
+			// Answered by middleware (e.g. 401 or 429) before reaching the handler
+			label = utils.CopyString(c.Path())
+		}
+		promHTTPRequests.WithLabelValues(method, label, strconv.Itoa(status)).Inc()
+		return err
+	}
+}
+
+// handlerRoutes returns the method and path of each route with a handler, leaving out middleware
+func handlerRoutes(app *fiber.App) map[string]bool {
+	routes := make(map[string]bool)
+	for _, r := range app.GetRoutes(true) {
+		routes[r.Method+" "+r.Path] = true
+	}
+	return routes
+}
+
+// PrometheusHandler serves the Prometheus text exposition of the service metrics
+func PrometheusHandler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(promRegistry, promhttp.HandlerOpts{}))
+}
+
+// StartSMBClientProcess marks an smbclient process as running and returns a function to call
+// with its outcome (e.g. success, timeout, error) once it exits, recording the process duration
+func StartSMBClientProcess() func(outcome string) {
+	start := time.Now()
+	promSMBInFlight.Inc()
+	return func(outcome string) {
+		promSMBInFlight.Dec()
+		promSMBClientDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,75 @@
+package telemetry
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	app := fiber.New()
+	app.Use(PrometheusMiddleware())
+	app.Use(func(c *fiber.Ctx) error {
+		if c.Get("X-API-Key") == "reject" {
+			return c.Status(fiber.StatusUnauthorized).SendString("no")
+		}
+		return c.Next()
+	})
+	app.Get("/metrics", PrometheusHandler())
+	app.Get("/list", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/jobs/:id", func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusNotFound).SendString("no such job")
+	})
+
+	request := func(path string, headers map[string]string) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		return string(body)
+	}
+
+	request("/list", nil)
+	request("/jobs/abc123", nil)
+	request("/list", map[string]string{"X-API-Key": "reject"})
+	request("/nowhere/abc123", nil)
+
+	finish := StartSMBClientProcess()
+	StartSMBClientProcess()("success")
+	finish("timeout")
+
+	body := request("/metrics", nil)
+	expected := []string{
+		`smbrelay_http_requests_total{method="GET",route="/list",status="200"}`,
+		`smbrelay_http_requests_total{method="GET",route="/jobs/:id",status="404"}`,
+		`smbrelay_http_requests_total{method="GET",route="/list",status="401"}`,
+		`smbrelay_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`smbrelay_smbclient_duration_seconds_bucket{outcome="success",le=`,
+		`smbrelay_smbclient_duration_seconds_count{outcome="timeout"}`,
+		"smbrelay_smb_operations_in_flight 0",
+		"go_goroutines",
+	}
+	for _, want := range expected {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected /metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "abc123") {
+		t.Error("Expected raw request paths not to be used as route labels")
+	}
+}
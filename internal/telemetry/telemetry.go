@@ -5,9 +5,9 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
@@ -49,15 +49,8 @@ func Initialize(ctx context.Context, cfg *Config) (*Provider, error) {
 	logger.Info("Initializing OpenTelemetry instrumentation")
 	logger.Info("Service: %s, Version: %s", cfg.ServiceName, cfg.ServiceVersion)
 
-	// Create resource with service information
-	res, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(cfg.ServiceName),
-			semconv.ServiceVersionKey.String(cfg.ServiceVersion),
-		),
-	)
+	// Create resource with service information and any custom attributes
+	res, err := buildResource(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
@@ -96,6 +89,29 @@ func Initialize(ctx context.Context, cfg *Config) (*Provider, error) {
 	return provider, nil
 }
 
+// buildResource creates the telemetry resource from the defaults, the configured resource
+// attributes, and the service name/version, which always take precedence
+func buildResource(cfg *Config) (*resource.Resource, error) {
+	custom := make([]attribute.KeyValue, 0, len(cfg.ResourceAttributes))
+	for k, v := range cfg.ResourceAttributes {
+		custom = append(custom, attribute.String(k, v))
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(custom...))
+	if err != nil {
+		return nil, err
+	}
+
+	return resource.Merge(
+		res,
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(cfg.ServiceName),
+			semconv.ServiceVersionKey.String(cfg.ServiceVersion),
+		),
+	)
+}
+
 // initTracing initializes the tracing provider
 func initTracing(ctx context.Context, cfg *Config, res *resource.Resource) (*sdktrace.TracerProvider, error) {
 	var exporter sdktrace.SpanExporter
@@ -117,18 +133,59 @@ func initTracing(ctx context.Context, cfg *Config, res *resource.Resource) (*sdk
 		}
 	}
 
+	sampler := newSampler(cfg)
+	logger.Info("Configuring trace sampler: %s", sampler.Description())
+
 	// Create tracer provider with batch span processor
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter,
-			sdktrace.WithBatchTimeout(5*time.Second),
-		),
+		sdktrace.WithBatcher(exporter, batchSpanProcessorOptions(cfg)...),
 		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSampler(sampler),
 	)
 
 	return tp, nil
 }
 
+// batchSpanProcessorOptions builds the batch span processor tuning options from the configuration
+// Zero values leave the SDK defaults in place.
+func batchSpanProcessorOptions(cfg *Config) []sdktrace.BatchSpanProcessorOption {
+	opts := []sdktrace.BatchSpanProcessorOption{}
+
+	if cfg.BSPScheduleDelay > 0 {
+		opts = append(opts, sdktrace.WithBatchTimeout(cfg.BSPScheduleDelay))
+	}
+	if cfg.BSPMaxQueueSize > 0 {
+		opts = append(opts, sdktrace.WithMaxQueueSize(cfg.BSPMaxQueueSize))
+	}
+	if cfg.BSPMaxExportBatchSize > 0 {
+		opts = append(opts, sdktrace.WithMaxExportBatchSize(cfg.BSPMaxExportBatchSize))
+	}
+
+	return opts
+}
+
+// newSampler builds the trace sampler selected by the configuration
+// Unknown sampler names fall back to always-on so tracing keeps working.
+func newSampler(cfg *Config) sdktrace.Sampler {
+	switch cfg.TracesSampler {
+	case SamplerAlwaysOn, "":
+		return sdktrace.AlwaysSample()
+	case SamplerAlwaysOff:
+		return sdktrace.NeverSample()
+	case SamplerTraceIDRatio:
+		return sdktrace.TraceIDRatioBased(cfg.TracesSamplerArg)
+	case SamplerParentBasedAlwaysOn:
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	case SamplerParentBasedAlwaysOff:
+		return sdktrace.ParentBased(sdktrace.NeverSample())
+	case SamplerParentBasedTraceIDRatio:
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.TracesSamplerArg))
+	default:
+		logger.Warn("Unknown trace sampler %q, falling back to %s", cfg.TracesSampler, SamplerAlwaysOn)
+		return sdktrace.AlwaysSample()
+	}
+}
+
 // initMetrics initializes the metrics provider
 func initMetrics(ctx context.Context, cfg *Config, res *resource.Resource) (*sdkmetric.MeterProvider, error) {
 	var exporter sdkmetric.Exporter
@@ -152,15 +209,25 @@ func initMetrics(ctx context.Context, cfg *Config, res *resource.Resource) (*sdk
 
 	// Create meter provider with periodic reader
 	mp := sdkmetric.NewMeterProvider(
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter,
-			sdkmetric.WithInterval(60*time.Second),
-		)),
+		sdkmetric.WithReader(newPeriodicReader(exporter, cfg)),
 		sdkmetric.WithResource(res),
 	)
 
 	return mp, nil
 }
 
+// newPeriodicReader creates the periodic metric reader using the configured export interval
+// A zero interval falls back to the 60 second default.
+func newPeriodicReader(exporter sdkmetric.Exporter, cfg *Config) *sdkmetric.PeriodicReader {
+	interval := cfg.MetricExportInterval
+	if interval <= 0 {
+		interval = defaultMetricExportInterval
+	}
+	logger.Info("Configuring metric export interval: %v", interval)
+
+	return sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))
+}
+
 // Shutdown gracefully shuts down the telemetry providers
 func (p *Provider) Shutdown(ctx context.Context) error {
 	if !p.config.Enabled {
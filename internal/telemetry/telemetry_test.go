@@ -1,10 +1,18 @@
 package telemetry
 
 import (
+	"bytes"
 	"context"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func TestInitialize_Disabled(t *testing.T) {
@@ -434,3 +442,147 @@ func TestStripScheme(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		sampler  string
+		expected string
+		arg      float64
+	}{
+		{name: "empty defaults to always on", sampler: "", expected: "AlwaysOnSampler"},
+		{name: "always on", sampler: SamplerAlwaysOn, expected: "AlwaysOnSampler"},
+		{name: "always off", sampler: SamplerAlwaysOff, expected: "AlwaysOffSampler"},
+		{name: "trace ID ratio", sampler: SamplerTraceIDRatio, arg: 0.25, expected: "TraceIDRatioBased{0.25}"},
+		{
+			name:     "parent based always on",
+			sampler:  SamplerParentBasedAlwaysOn,
+			expected: "ParentBased{root:AlwaysOnSampler",
+		},
+		{
+			name:     "parent based ratio",
+			sampler:  SamplerParentBasedTraceIDRatio,
+			arg:      0.1,
+			expected: "ParentBased{root:TraceIDRatioBased{0.1}",
+		},
+		{name: "unknown falls back to always on", sampler: "sometimes", expected: "AlwaysOnSampler"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{TracesSampler: tt.sampler, TracesSamplerArg: tt.arg}
+
+			description := newSampler(cfg).Description()
+			if !strings.HasPrefix(description, tt.expected) {
+				t.Errorf("newSampler() = %s, want prefix %s", description, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBatchSpanProcessorOptions(t *testing.T) {
+	cfg := &Config{
+		BSPScheduleDelay:      250 * time.Millisecond,
+		BSPMaxQueueSize:       4096,
+		BSPMaxExportBatchSize: 256,
+	}
+
+	var applied sdktrace.BatchSpanProcessorOptions
+	for _, opt := range batchSpanProcessorOptions(cfg) {
+		opt(&applied)
+	}
+
+	if applied.BatchTimeout != cfg.BSPScheduleDelay {
+		t.Errorf("BatchTimeout = %v, want %v", applied.BatchTimeout, cfg.BSPScheduleDelay)
+	}
+	if applied.MaxQueueSize != cfg.BSPMaxQueueSize {
+		t.Errorf("MaxQueueSize = %d, want %d", applied.MaxQueueSize, cfg.BSPMaxQueueSize)
+	}
+	if applied.MaxExportBatchSize != cfg.BSPMaxExportBatchSize {
+		t.Errorf("MaxExportBatchSize = %d, want %d", applied.MaxExportBatchSize, cfg.BSPMaxExportBatchSize)
+	}
+
+	if opts := batchSpanProcessorOptions(&Config{}); len(opts) != 0 {
+		t.Errorf("Expected no options for zero config, got %d", len(opts))
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe for use by the exporter goroutine and the test
+type lockedBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Len()
+}
+
+func TestNewPeriodicReader_UsesConfiguredInterval(t *testing.T) {
+	buf := &lockedBuffer{}
+	exporter, err := stdoutmetric.New(stdoutmetric.WithWriter(buf))
+	if err != nil {
+		t.Fatalf("Failed to create exporter: %v", err)
+	}
+
+	reader := newPeriodicReader(exporter, &Config{MetricExportInterval: 20 * time.Millisecond})
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	// With the 60s default nothing would be exported within the test window
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Check before shutdown, which always flushes a final export
+	exported := buf.Len() > 0
+	if err := mp.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() failed: %v", err)
+	}
+	if !exported {
+		t.Error("Expected a periodic export within the configured interval")
+	}
+}
+
+func TestBuildResource_IncludesCustomAttributes(t *testing.T) {
+	cfg := &Config{
+		ServiceName:    "test-service",
+		ServiceVersion: "2.0.0",
+		ResourceAttributes: map[string]string{
+			"deployment.environment": "staging",
+			"region":                 "uksouth",
+			// Service identity from the dedicated settings wins over resource attributes
+			"service.name": "overridden",
+		},
+	}
+
+	res, err := buildResource(cfg)
+	if err != nil {
+		t.Fatalf("buildResource() failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"deployment.environment": "staging",
+		"region":                 "uksouth",
+		"service.name":           "test-service",
+		"service.version":        "2.0.0",
+	}
+	set := res.Set()
+	for k, want := range expected {
+		got, ok := set.Value(attribute.Key(k))
+		if !ok {
+			t.Errorf("Expected resource attribute %q to be present", k)
+			continue
+		}
+		if got.AsString() != want {
+			t.Errorf("Resource attribute %q = %q, want %q", k, got.AsString(), want)
+		}
+	}
+}